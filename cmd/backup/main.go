@@ -32,6 +32,8 @@ var (
 
 const helpMessage = `
 dvid-backup does a cold backup of a local leveldb storage engine.
+For an online backup of a running server, use "dvid backup <target directory>"
+or POST /api/server/backup.
 
 Usage: dvid-backup [options] <database directory> <backup directory>
 
@@ -1,5 +1,5 @@
 // Command-line interface to a remote DVID server.
-// Provides essential commands on top of core http server: init, serve, repair.
+// Provides essential commands on top of core http server: init, serve, repair, restore.
 
 package main
 
@@ -19,6 +19,7 @@ import (
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/rpc"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
 	"github.com/janelia-flyem/go/profiler"
@@ -55,6 +56,11 @@ var (
 
 	rpcAddress = flag.String("rpc", server.DefaultRPCAddress, "")
 
+	// TLS certificates for RPC communication with a server using TLS.
+	rpcCert = flag.String("rpccert", "", "")
+	rpcKey  = flag.String("rpckey", "", "")
+	rpcCA   = flag.String("rpcca", "", "")
+
 	// msgAddress = flag.String("message", message.DefaultAddress, "")
 
 	// Profile CPU usage using standard gotest system.
@@ -78,6 +84,9 @@ Usage: dvid [options] <command>
 
       -readonly   (flag)    HTTP API ignores anything but GET and HEAD requests.
       -rpc        =string   Address for RPC communication.
+      -rpcca      =string   PEM file of CA certificates to verify a TLS RPC server.
+      -rpccert    =string   PEM client certificate for a TLS RPC server requiring client certs.
+      -rpckey     =string   PEM key for the client certificate.
       -cpuprofile =string   Write CPU profile to this file.
       -memprofile =string   Write memory profile to this file on ctrl-C.
       -numcpu     =number   Number of logical CPUs to use for DVID.
@@ -99,6 +108,13 @@ For storage engines that have repair ability (e.g., basholeveldb):
         The <engine name> refers to the name of the engine: "basholeveldb", "kvautobus", etc.
         The <database path> is the file path to the directory.
 
+To restore an online backup (see "help server" for the backup command):
+
+    restore <backup directory> <configuration path>
+
+        Writes the backed up stores into the stores of the same names in the configuration,
+        which must be empty.  Run before "serve" with that configuration.
+
 To get help for a remote DVID server:
 
     help server
@@ -212,11 +228,13 @@ func DoCommand(cmd dvid.Command) error {
 		return DoServe(cmd)
 	case "repair":
 		return DoRepair(cmd)
+	case "restore":
+		return DoRestore(cmd)
 	case "about":
 		fmt.Println(server.About())
 	// Send everything else to server via DVID terminal
 	default:
-		request := datastore.Request{Command: cmd}
+		request := datastore.Request{Command: cmd, User: os.Getenv("USER")}
 		if *useStdin {
 			var err error
 			request.Input, err = ioutil.ReadAll(os.Stdin)
@@ -224,6 +242,13 @@ func DoCommand(cmd dvid.Command) error {
 				return fmt.Errorf("Error in reading from standard input: %v", err)
 			}
 		}
+		if *rpcCert != "" || *rpcKey != "" || *rpcCA != "" {
+			cfg, err := rpc.ClientTLSConfig(*rpcCert, *rpcKey, *rpcCA)
+			if err != nil {
+				return err
+			}
+			rpc.SetClientTLS(cfg)
+		}
 		return server.SendRPC(*rpcAddress, request)
 	}
 	return nil
@@ -243,6 +268,39 @@ func DoRepair(cmd dvid.Command) error {
 	return nil
 }
 
+// DoRestore performs the "restore" command, writing an online backup into the empty
+// stores of a configuration.
+func DoRestore(cmd dvid.Command) error {
+	backupDir := cmd.Argument(1)
+	configPath := cmd.Argument(2)
+	if configPath == "" {
+		return fmt.Errorf("restore command must be followed by the backup directory and the path to the TOML configuration file")
+	}
+	if err := server.LoadConfig(configPath); err != nil {
+		return fmt.Errorf("error loading configuration file %q: %v", configPath, err)
+	}
+	backend, err := server.GetBackend()
+	if err != nil {
+		return err
+	}
+	if _, err := storage.Initialize(cmd.Settings(), backend); err != nil {
+		return fmt.Errorf("unable to initialize storage: %v", err)
+	}
+	defer storage.Shutdown()
+
+	manifest, err := storage.RestoreBackup(backupDir)
+	if err != nil {
+		return err
+	}
+	for _, storeStatus := range manifest.Stores {
+		if storeStatus.File != "" {
+			fmt.Printf("Restored %d key-value pairs into store %q.\n", storeStatus.KeyValues, storeStatus.Alias)
+		}
+	}
+	fmt.Printf("Restored backup in %s.\n", backupDir)
+	return nil
+}
+
 // DoServe opens a datastore then creates both web and rpc servers for the datastore
 func DoServe(cmd dvid.Command) error {
 	// Capture ctrl+c and other interrupts.  Then handle graceful shutdown.
@@ -94,8 +94,10 @@ func (t *txStats) printStats() {
 
 // MigrateInstance migrates a data instance locally from an old storage
 // engine to the current configured storage.  After completion of the copy,
-// the data instance in the old storage is deleted.
-func MigrateInstance(uuid dvid.UUID, source dvid.InstanceName, srcStore, dstStore dvid.Store, c dvid.Config) error {
+// the data instance in the old storage is deleted if the "delete" setting is "true".
+// The migration is done before returning, so callers should run it in a goroutine.
+// The optional job is updated with the migration progress and stops it if canceled.
+func MigrateInstance(uuid dvid.UUID, source dvid.InstanceName, srcStore, dstStore dvid.Store, c dvid.Config, job *dvid.Job) error {
 	if manager == nil {
 		return ErrManagerNotInitialized
 	}
@@ -140,23 +142,17 @@ func MigrateInstance(uuid dvid.UUID, source dvid.InstanceName, srcStore, dstStor
 		return fmt.Errorf("old store for data %q seems same as current store", source)
 	}
 
-	// Migrate data asynchronously.
-	go func() {
-		if err := copyData(srcKV, dstKV, d, nil, uuid, nil, flatten); err != nil {
-			dvid.Errorf("error in migration of data %q: %v\n", source, err)
-			return
-		}
-		if deleteSrc {
-			dvid.Infof("Starting delete of instance %q from store %q\n", d.DataName(), srcKV)
-			ctx := storage.NewDataContext(d, 0)
-			if err := srcKV.DeleteAll(ctx, true); err != nil {
-				dvid.Errorf("deleting instance %q from %q after copy to %q: %v\n", d.DataName(), srcKV, dstKV, err)
-				return
-			}
-		}
-	}()
-
 	dvid.Infof("Migrating data %q from store %q to store %q ...\n", d.DataName(), srcKV, dstKV)
+	if err := copyData(srcKV, dstKV, d, nil, uuid, nil, flatten, job); err != nil {
+		return fmt.Errorf("error in migration of data %q: %v", source, err)
+	}
+	if deleteSrc {
+		dvid.Infof("Starting delete of instance %q from store %q\n", d.DataName(), srcKV)
+		ctx := storage.NewDataContext(d, 0)
+		if err := srcKV.DeleteAll(ctx, true); err != nil {
+			return fmt.Errorf("deleting instance %q from %q after copy to %q: %v", d.DataName(), srcKV, dstKV, err)
+		}
+	}
 	return nil
 }
 
@@ -286,8 +282,9 @@ func LimitVersions(uui dvid.UUID, configFName string) error {
 //
 // If Metadata property is true, then if metadata exists in the old store,
 // it is transferred to the new store with only the versions specified
-// appearing in the DAG.
-func TransferData(uuid dvid.UUID, srcStore, dstStore dvid.Store, configFName string) error {
+// appearing in the DAG.  If the optional job is canceled, the transfer stops and
+// dvid.ErrJobCanceled is returned.
+func TransferData(uuid dvid.UUID, srcStore, dstStore dvid.Store, configFName string, job *dvid.Job) error {
 	tc, okVersions, err := getTransferConfig(configFName)
 	if err != nil {
 		return err
@@ -351,17 +348,24 @@ func TransferData(uuid dvid.UUID, srcStore, dstStore dvid.Store, configFName str
 	} else {
 		begKey = storage.MinDataKey()
 	}
-	if err = srcKVDB.RawRangeQuery(begKey, endKey, false, ch, nil); err != nil {
+	if err = srcKVDB.RawRangeQuery(begKey, endKey, false, ch, job.CancelChan()); err != nil {
 		return fmt.Errorf("transfer data range query: %v", err)
 	}
+	if job.Canceled() {
+		ch <- nil // a canceled range query doesn't terminate the channel.
+	}
 	wg.Wait()
+	if job.Canceled() {
+		return dvid.ErrJobCanceled
+	}
 	return nil
 }
 
 // CopyInstance copies a data instance locally, perhaps to a different storage
 // engine if the new instance uses a different backend per a data instance-specific configuration.
-// (See sample config.example.toml file in root dvid source directory.)
-func CopyInstance(uuid dvid.UUID, source, target dvid.InstanceName, c dvid.Config) error {
+// (See sample config.example.toml file in root dvid source directory.)  The optional job
+// stops the copy if canceled.
+func CopyInstance(uuid dvid.UUID, source, target dvid.InstanceName, c dvid.Config, job *dvid.Job) error {
 	if manager == nil {
 		return ErrManagerNotInitialized
 	}
@@ -441,14 +445,15 @@ func CopyInstance(uuid dvid.UUID, source, target dvid.InstanceName, c dvid.Confi
 	}
 
 	// copy data with optional datatype-specific filtering.
-	return copyData(oldKV, newKV, d1, d2, uuid, filter, flatten)
+	return copyData(oldKV, newKV, d1, d2, uuid, filter, flatten, job)
 }
 
 // copyData copies all key-value pairs pertinent to the given data instance d2.  If d2 is nil,
 // the destination data instance is d1, useful for migration of data to a new store.
 // Each datatype can implement filters that can restrict the transmitted key-value pairs
-// based on the given FilterSpec.
-func copyData(oldKV, newKV storage.OrderedKeyValueDB, d1, d2 dvid.Data, uuid dvid.UUID, f storage.Filter, flatten bool) error {
+// based on the given FilterSpec.  If the optional job is canceled, the copy stops and
+// dvid.ErrJobCanceled is returned.
+func copyData(oldKV, newKV storage.OrderedKeyValueDB, d1, d2 dvid.Data, uuid dvid.UUID, f storage.Filter, flatten bool, job *dvid.Job) error {
 	// Get data context for this UUID.
 	v, err := VersionFromUUID(uuid)
 	if err != nil {
@@ -513,10 +518,16 @@ func copyData(oldKV, newKV storage.OrderedKeyValueDB, d1, d2 dvid.Data, uuid dvi
 			if c == nil {
 				return fmt.Errorf("received nil chunk in flatten push for data %s", d1.DataName())
 			}
+			if job.Canceled() {
+				return dvid.ErrJobCanceled
+			}
 			ch <- c.TKeyValue
 			return nil
 		})
 		ch <- nil
+		if err == dvid.ErrJobCanceled {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("error in flatten push for data %q: %v", d1.DataName(), err)
 		}
@@ -568,10 +579,16 @@ func copyData(oldKV, newKV storage.OrderedKeyValueDB, d1, d2 dvid.Data, uuid dvi
 		}()
 
 		begKey, endKey := srcCtx.KeyRange()
-		if err = oldKV.RawRangeQuery(begKey, endKey, keysOnly, ch, nil); err != nil {
+		if err = oldKV.RawRangeQuery(begKey, endKey, keysOnly, ch, job.CancelChan()); err != nil {
 			return fmt.Errorf("push voxels %q range query: %v", d1.DataName(), err)
 		}
+		if job.Canceled() {
+			ch <- nil // a canceled range query doesn't terminate the channel.
+		}
 	}
 	wg.Wait()
+	if job.Canceled() {
+		return dvid.ErrJobCanceled
+	}
 	return nil
 }
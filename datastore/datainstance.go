@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,6 +32,10 @@ func init() {
 type Request struct {
 	dvid.Command
 	Input []byte
+
+	// User is the client-reported user sending the request and is the owner of any
+	// jobs started by it.
+	User string
 }
 
 // Response supports RPC responses from DVID.
@@ -60,6 +65,10 @@ func (r *Response) Write(w io.Writer) error {
 type VersionedCtx struct {
 	*storage.DataContext
 	User string
+
+	// AuthUser is the user verified by the server's authentication, if any, and is the
+	// owner of jobs started by the request.  Unlike User, it is never set by clients.
+	AuthUser string
 }
 
 // NewVersionedCtx creates a new versioned context that also has ability to
@@ -233,6 +242,13 @@ type InstanceMutator interface {
 	LoadMutable(root dvid.VersionID, storedVersion, expectedVersion uint64) (saveNeeded bool, err error)
 }
 
+// QueryMutationChecker is a data instance where whether a request on an endpoint is a
+// mutation depends on its query string, e.g., an optional target for storing results.
+// If implemented, it is used instead of IsMutationRequest.
+type QueryMutationChecker interface {
+	IsMutationQuery(action, endpoint string, query url.Values) bool
+}
+
 // MutationMutexer is an interface for mutexes on particular mutation IDs.
 type MutationMutexer interface {
 	MutAdd(mutID uint64) (newOp bool)
@@ -247,6 +263,12 @@ type VersionInitializer interface {
 	InitVersion(dvid.UUID, dvid.VersionID) error
 }
 
+// VersionRemover provides a hook for data instances to receive version deletion events
+// and remove any properties or cached data for the deleted version.
+type VersionRemover interface {
+	RemoveVersion(dvid.UUID, dvid.VersionID) error
+}
+
 // DataInitializer is a data instance that needs to be initialized, e.g., start
 // long-lived goroutines that handle data syncs, etc.  Initialization should only
 // constitute supporting data and goroutines and not change the data itself like
@@ -800,6 +822,8 @@ func (d *Data) ModifyConfig(config dvid.Config) error {
 			d.compression, _ = dvid.NewCompression(dvid.LZ4, dvid.DefaultCompression)
 		case "gzip":
 			d.compression, _ = dvid.NewCompression(dvid.Gzip, dvid.DefaultCompression)
+		case "zstd":
+			d.compression, _ = dvid.NewCompression(dvid.Zstd, dvid.DefaultCompression)
 		case "jpeg":
 			// Jpeg should only be used on datatypes with a BlockSize property
 			// and should only be used on uint8blk dim1 < 256 -- not enforced
@@ -825,7 +849,7 @@ func (d *Data) ModifyConfig(config dvid.Config) error {
 			// all data stored must be divisible by firstdim -- which will be the case for block datatypes
 			d.compression, _ = dvid.NewCompression(dvid.JPEG, dvid.CompressionLevel(firstdim))
 		default:
-			// Check for gzip + compression level
+			// Check for gzip or zstd + compression level
 			parts := strings.Split(format, ":")
 			if len(parts) == 2 && parts[0] == "gzip" {
 				level, err := strconv.Atoi(parts[1])
@@ -833,6 +857,14 @@ func (d *Data) ModifyConfig(config dvid.Config) error {
 					return fmt.Errorf("unable to parse gzip compression level (%q).  Should be 'gzip:<level>'", parts[1])
 				}
 				d.compression, _ = dvid.NewCompression(dvid.Gzip, dvid.CompressionLevel(level))
+			} else if len(parts) == 2 && parts[0] == "zstd" {
+				level, err := strconv.Atoi(parts[1])
+				if err != nil {
+					return fmt.Errorf("unable to parse zstd compression level (%q).  Should be 'zstd:<level>'", parts[1])
+				}
+				if d.compression, err = dvid.NewCompression(dvid.Zstd, dvid.CompressionLevel(level)); err != nil {
+					return err
+				}
 			} else {
 				return fmt.Errorf("Illegal compression specified: %s", s)
 			}
@@ -34,6 +34,16 @@ type TestData struct {
 	*Data
 }
 
+// GobDecode allocates the embedded Data so received repos can decode test instances.
+func (d *TestData) GobDecode(b []byte) error {
+	d.Data = new(Data)
+	return d.Data.GobDecode(b)
+}
+
+func (d *TestData) GobEncode() ([]byte, error) {
+	return d.Data.GobEncode()
+}
+
 func (d *TestData) DoRPC(request Request, reply *Response) error {
 	return nil
 }
@@ -83,7 +83,8 @@ func VersionFromUUID(uuid dvid.UUID) (dvid.VersionID, error) {
 	return manager.versionFromUUID(uuid)
 }
 
-// MatchingUUID returns version identifiers that uniquely matches a uuid string.
+// MatchingUUID returns version identifiers that uniquely matches a uuid string.  A
+// string of the form ":tagname" matches the node with that tag.
 func MatchingUUID(uuidStr string) (dvid.UUID, dvid.VersionID, error) {
 	if manager == nil {
 		return dvid.NilUUID, 0, ErrManagerNotInitialized
@@ -205,6 +206,15 @@ func SetNodeNote(uuid dvid.UUID, note string) error {
 	return manager.setNodeNote(uuid, note)
 }
 
+// TagNode adds an immutable tag to a committed node.  The tag must be unique across
+// all repos.
+func TagNode(uuid dvid.UUID, tag string) error {
+	if manager == nil {
+		return ErrManagerNotInitialized
+	}
+	return manager.tagNode(uuid, tag)
+}
+
 func GetNodeLog(uuid dvid.UUID) ([]string, error) {
 	if manager == nil {
 		return nil, ErrManagerNotInitialized
@@ -230,6 +240,15 @@ func NewVersion(parent dvid.UUID, note string, branchname string, assign *dvid.U
 	return manager.newVersion(parent, note, branchname, assign)
 }
 
+// DeleteVersion deletes an uncommitted leaf node and its key-values in all data instances.
+// The repo passcode must be given unless the request has admin rights.
+func DeleteVersion(uuid dvid.UUID, passcode string, admin bool) error {
+	if manager == nil {
+		return ErrManagerNotInitialized
+	}
+	return manager.deleteVersion(uuid, passcode, admin)
+}
+
 // GetParents returns the parent nodes of the given version id.
 func GetParentsByVersion(v dvid.VersionID) ([]dvid.VersionID, error) {
 	if manager == nil {
@@ -282,7 +301,38 @@ func Merge(parents []dvid.UUID, note string, mt MergeType) (dvid.UUID, error) {
 	if manager == nil {
 		return dvid.NilUUID, ErrManagerNotInitialized
 	}
-	return manager.merge(parents, note, mt)
+	return manager.merge(parents, note, mt, nil)
+}
+
+// MergeWithResolutions merges the parents into a new child using external data,
+// where the resolutions specify the parent or value that should be used for every
+// conflicting key.  Resolved key-values for each data instance are written to the
+// child in a single batch.
+func MergeWithResolutions(parents []dvid.UUID, note string, resolutions []MergeResolution) (dvid.UUID, error) {
+	if manager == nil {
+		return dvid.NilUUID, ErrManagerNotInitialized
+	}
+	return manager.merge(parents, note, MergeExternalData, resolutions)
+}
+
+// GetMergeConflicts returns the keys modified in more than one of the given parents
+// for each data instance, grouped by TKeyClass.  This is a dry run that can be used
+// to check for conflicts before doing a merge.
+func GetMergeConflicts(parents []dvid.UUID) (map[dvid.InstanceName][]MergeConflictClass, error) {
+	if manager == nil {
+		return nil, ErrManagerNotInitialized
+	}
+	return manager.mergeConflictReport(parents)
+}
+
+// GetVersionDiff returns, for each data instance or only the named instance if name
+// is not empty, a JSON-encodable summary of the keys added, modified, or deleted between
+// the two versions.  Data instances implementing Differ provide type-specific summaries.
+func GetVersionDiff(from, to dvid.UUID, name dvid.InstanceName) (map[dvid.InstanceName]interface{}, error) {
+	if manager == nil {
+		return nil, ErrManagerNotInitialized
+	}
+	return manager.getVersionDiff(from, to, name)
 }
 
 // ----- Data Instance functions -----------
@@ -607,7 +657,8 @@ func (stats StorageStats) String() string {
 }
 
 // GetStorageDetails scans all key-value stores and returns detailed stats per instances.
-func GetStorageDetails() (map[string]StorageStats, error) {
+// The optional job is updated as each store is scanned and stops the scan if canceled.
+func GetStorageDetails(job *dvid.Job) (map[string]StorageStats, error) {
 	timedLog := dvid.NewTimeLog()
 	stores, err := storage.AllStores()
 	if err != nil {
@@ -616,7 +667,10 @@ func GetStorageDetails() (map[string]StorageStats, error) {
 	isLeaf := make(map[dvid.VersionID]bool)
 
 	statsByStore := make(map[string]StorageStats, len(stores))
+	var storeNum uint64
 	for alias, store := range stores {
+		job.SetProgress(storeNum, uint64(len(stores)))
+		storeNum++
 		stats := make(StorageStats)
 		db, ok := store.(storage.OrderedKeyValueGetter)
 		if !ok {
@@ -713,9 +767,14 @@ func GetStorageDetails() (map[string]StorageStats, error) {
 
 		minKey, maxKey := storage.DataKeyRange()
 		keysOnly := false
-		if err = db.RawRangeQuery(minKey, maxKey, keysOnly, ch, nil); err != nil {
+		if err = db.RawRangeQuery(minKey, maxKey, keysOnly, ch, job.CancelChan()); err != nil {
 			return nil, err
 		}
+		if job.Canceled() {
+			ch <- nil // a canceled range query doesn't terminate the channel.
+			wg.Wait()
+			return nil, dvid.ErrJobCanceled
+		}
 		wg.Wait()
 
 		timedLog.Infof("Finished storage details for store %s: %d keys", store, numKeys)
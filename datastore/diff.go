@@ -0,0 +1,204 @@
+/*
+	This file supports reporting the key-value differences between two versions.
+*/
+
+package datastore
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// VersionDiff holds the type-specific keys of a data instance that differ between
+// two versions.  A key is modified if it is visible in both versions but was
+// written at different versions.
+type VersionDiff struct {
+	Added    []storage.TKey
+	Modified []storage.TKey
+	Deleted  []storage.TKey
+}
+
+// Differ is a data instance that can summarize the changed keys between two versions
+// in a type-specific way, e.g., changed labels or elements.  The returned summary
+// should be JSON-encodable.
+type Differ interface {
+	DiffSummary(from, to dvid.VersionID, diff *VersionDiff) (interface{}, error)
+}
+
+// defaultDiffSummary lists hex-encoded keys for data instances that aren't Differ.
+type defaultDiffSummary struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+}
+
+func hexKeys(tks []storage.TKey) []string {
+	strs := make([]string, len(tks))
+	for i, tk := range tks {
+		strs[i] = fmt.Sprintf("%x", []byte(tk))
+	}
+	return strs
+}
+
+// visibleVersion returns the version of the key-value visible from v or 0 if the key
+// is absent or deleted.
+func (m *repoManager) visibleVersion(kvv kvVersions, v dvid.VersionID) (dvid.VersionID, error) {
+	fresh := make(kvVersions, len(kvv))
+	for ver, n := range kvv {
+		fresh[ver] = kvvNode{kv: n.kv}
+	}
+	kv, matchV, err := m.findMatch(fresh, v)
+	if err != nil || kv == nil {
+		return 0, err
+	}
+	return matchV, nil
+}
+
+// versionDiff scans all key-values of a data instance and returns the keys that differ
+// between the two versions.
+func (m *repoManager) versionDiff(data DataService, from, to dvid.VersionID) (*VersionDiff, error) {
+	diff := new(VersionDiff)
+	if !data.Versioned() || from == to {
+		return diff, nil
+	}
+	store, err := GetOrderedKeyValueDB(data)
+	if err != nil {
+		return nil, err
+	}
+
+	baseCtx := NewVersionedCtx(data, 0)
+	ch := make(chan *storage.KeyValue, 1000)
+	var diffErr error
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var batchTK storage.TKey
+		kvv := kvVersions{}
+		processBatch := func() {
+			if len(kvv) == 0 || diffErr != nil {
+				return
+			}
+			fromV, err := m.visibleVersion(kvv, from)
+			if err != nil {
+				diffErr = fmt.Errorf("key %x of data %q: %v", []byte(batchTK), data.DataName(), err)
+				return
+			}
+			toV, err := m.visibleVersion(kvv, to)
+			if err != nil {
+				diffErr = fmt.Errorf("key %x of data %q: %v", []byte(batchTK), data.DataName(), err)
+				return
+			}
+			switch {
+			case fromV == toV:
+			case fromV == 0:
+				diff.Added = append(diff.Added, batchTK)
+			case toV == 0:
+				diff.Deleted = append(diff.Deleted, batchTK)
+			default:
+				diff.Modified = append(diff.Modified, batchTK)
+			}
+		}
+		for kv := range ch {
+			if kv == nil {
+				processBatch()
+				return
+			}
+			curV, err := baseCtx.VersionFromKey(kv.K)
+			if err != nil {
+				dvid.Errorf("Can't decode key when diffing versions for %s", data.DataName())
+				continue
+			}
+			curTK, err := storage.TKeyFromKey(kv.K)
+			if err != nil {
+				dvid.Errorf("Error in processing kv pairs when diffing versions: %v\n", err)
+				continue
+			}
+			if batchTK != nil && !bytes.Equal(curTK, batchTK) {
+				processBatch()
+				kvv = kvVersions{}
+			}
+			batchTK = curTK
+			kvv[curV] = kvvNode{kv: kv}
+		}
+	}()
+
+	minKey, maxKey := baseCtx.KeyRange()
+	keysOnly := true
+	if err := store.RawRangeQuery(minKey, maxKey, keysOnly, ch, nil); err != nil {
+		close(ch)
+		wg.Wait()
+		return nil, err
+	}
+	wg.Wait()
+	if diffErr != nil {
+		return nil, diffErr
+	}
+	return diff, nil
+}
+
+// getVersionDiff returns a summary of changes between two versions for each data
+// instance, or just the named instance if name is not empty.
+func (m *repoManager) getVersionDiff(fromUUID, toUUID dvid.UUID, name dvid.InstanceName) (map[dvid.InstanceName]interface{}, error) {
+	r, err := m.repoFromUUID(fromUUID)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := m.repoFromUUID(toUUID)
+	if err != nil {
+		return nil, err
+	}
+	if r != r2 {
+		return nil, fmt.Errorf("versions %s and %s are not in the same repo", fromUUID, toUUID)
+	}
+	from, err := m.versionFromUUID(fromUUID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := m.versionFromUUID(toUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var dataservices []DataService
+	r.RLock()
+	if name != "" {
+		dataservice, found := r.data[name]
+		if !found {
+			r.RUnlock()
+			return nil, ErrInvalidDataName
+		}
+		dataservices = append(dataservices, dataservice)
+	} else {
+		for _, dataservice := range r.data {
+			dataservices = append(dataservices, dataservice)
+		}
+	}
+	r.RUnlock()
+
+	summaries := make(map[dvid.InstanceName]interface{}, len(dataservices))
+	for _, dataservice := range dataservices {
+		diff, err := m.versionDiff(dataservice, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if differ, ok := dataservice.(Differ); ok {
+			summary, err := differ.DiffSummary(from, to, diff)
+			if err != nil {
+				return nil, fmt.Errorf("unable to summarize diff for data %q: %v", dataservice.DataName(), err)
+			}
+			summaries[dataservice.DataName()] = summary
+		} else {
+			summaries[dataservice.DataName()] = defaultDiffSummary{
+				Added:    hexKeys(diff.Added),
+				Modified: hexKeys(diff.Modified),
+				Deleted:  hexKeys(diff.Deleted),
+			}
+		}
+	}
+	return summaries, nil
+}
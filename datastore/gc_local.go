@@ -0,0 +1,455 @@
+// +build !clustered,!gcloud
+
+/*
+	This file contains garbage collection of key-value pairs that belong to data instances
+	or versions that are no longer referenced by any repo, e.g., after deleting a data
+	instance or limiting versions.
+*/
+
+package datastore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+const (
+	// DefaultGCBatchSize is the default number of keys deleted before pausing.
+	DefaultGCBatchSize = 1000
+
+	// DefaultGCPause is the default pause between batches of deletions.
+	DefaultGCPause = 100 * time.Millisecond
+
+	// number of keys scanned between checkpoints if no garbage is found.
+	gcCheckpointInterval = 100000
+)
+
+// GCConfig specifies how garbage collection is done.
+type GCConfig struct {
+	// DryRun only reports the garbage without deleting it.
+	DryRun bool
+
+	// BatchSize is the number of keys deleted before pausing.
+	BatchSize int
+
+	// Pause is the time between batches of deletions to throttle load on the stores.
+	Pause time.Duration
+}
+
+// GCStoreReport describes the garbage found in a store.
+type GCStoreReport struct {
+	Alias       storage.Alias
+	KeysScanned uint64
+
+	// InstanceKeys is the number of keys for deleted or unknown data instances.
+	InstanceKeys uint64
+
+	// VersionKeys is the number of keys for removed versions of existing data instances.
+	VersionKeys uint64
+
+	// Deleted is the number of garbage keys deleted, which is zero for dry runs.
+	Deleted uint64
+
+	// InstanceBytes is the approximate number of bytes for each unreferenced instance
+	// in a dry run, if the store supports size estimates.
+	InstanceBytes map[dvid.InstanceID]uint64 `json:",omitempty"`
+
+	Done bool
+}
+
+// GCReport describes the progress of garbage collection across all stores.
+type GCReport struct {
+	Config   GCConfig
+	Started  time.Time
+	Finished *time.Time `json:",omitempty"`
+	Running  bool
+	Resumed  bool   `json:",omitempty"`
+	Canceled bool   `json:",omitempty"`
+	Error    string `json:",omitempty"`
+	Stores   []*GCStoreReport
+
+	// Job is the id of the job running the garbage collection.
+	Job dvid.JobID
+}
+
+func (r *GCReport) copy() *GCReport {
+	c := *r
+	c.Stores = make([]*GCStoreReport, len(r.Stores))
+	for i, sr := range r.Stores {
+		srCopy := *sr
+		if sr.InstanceBytes != nil {
+			srCopy.InstanceBytes = make(map[dvid.InstanceID]uint64, len(sr.InstanceBytes))
+			for id, n := range sr.InstanceBytes {
+				srCopy.InstanceBytes[id] = n
+			}
+		}
+		c.Stores[i] = &srCopy
+	}
+	return &c
+}
+
+// gcCheckpoint is persisted in the metadata store so interrupted garbage collection
+// can resume after a restart.
+type gcCheckpoint struct {
+	Report GCReport
+
+	// StoreNum is the index of the store being scanned.
+	StoreNum int
+
+	// LastKey is the last key processed in the store being scanned.
+	LastKey storage.Key
+}
+
+// tracks the current or last garbage collection
+var gc struct {
+	sync.Mutex
+	report *GCReport
+}
+
+// GetGCReport returns the report of the current or last garbage collection, or nil if
+// none was run since the server started.
+func GetGCReport() *GCReport {
+	gc.Lock()
+	defer gc.Unlock()
+	if gc.report == nil {
+		return nil
+	}
+	return gc.report.copy()
+}
+
+// StartGC starts garbage collection as a job owned by the given user, scanning all stores
+// for keys whose data instance or version is no longer referenced and deleting them in
+// throttled batches.  If a prior garbage collection was interrupted or canceled, it is
+// resumed from its last checkpoint unless this is a dry run.
+func StartGC(config GCConfig, owner string) (*GCReport, error) {
+	if manager == nil {
+		return nil, ErrManagerNotInitialized
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultGCBatchSize
+	}
+	if config.Pause < 0 {
+		config.Pause = 0
+	}
+
+	gc.Lock()
+	defer gc.Unlock()
+	if gc.report != nil && gc.report.Running {
+		return nil, fmt.Errorf("garbage collection started %s is already running", gc.report.Started)
+	}
+	var cp *gcCheckpoint
+	var err error
+	if !config.DryRun {
+		if cp, err = manager.loadGCCheckpoint(); err != nil {
+			return nil, err
+		}
+	}
+	if cp != nil {
+		dvid.Infof("Resuming garbage collection started %s\n", cp.Report.Started)
+		cp.Report.Config = config
+		cp.Report.Resumed = true
+		cp.Report.Canceled = false
+	} else if cp, err = newGCCheckpoint(config); err != nil {
+		return nil, err
+	}
+	job := dvid.NewJob("gc", owner, "", "")
+	cp.Report.Job = job.ID()
+	gc.report = &cp.Report
+	go func() {
+		job.Finish(manager.runGC(cp, job))
+	}()
+	return cp.Report.copy(), nil
+}
+
+// resumeGC restarts any garbage collection that was interrupted by a server shutdown
+// unless a garbage collection was already started, which resumes from the checkpoint
+// itself if it isn't a dry run.  Canceled garbage collections are only resumed by
+// StartGC.
+func (m *repoManager) resumeGC() {
+	gc.Lock()
+	if gc.report != nil && gc.report.Running {
+		gc.Unlock()
+		return
+	}
+	cp, err := m.loadGCCheckpoint()
+	if err != nil {
+		gc.Unlock()
+		dvid.Errorf("unable to check for interrupted garbage collection: %v\n", err)
+		return
+	}
+	if cp == nil || cp.Report.Canceled {
+		gc.Unlock()
+		return
+	}
+	dvid.Infof("Resuming interrupted garbage collection started %s\n", cp.Report.Started)
+	cp.Report.Resumed = true
+	job := dvid.NewJob("gc", "", "", "")
+	cp.Report.Job = job.ID()
+	gc.report = &cp.Report
+	gc.Unlock()
+	job.Finish(m.runGC(cp, job))
+}
+
+func newGCCheckpoint(config GCConfig) (*gcCheckpoint, error) {
+	stores, err := storage.AllStores()
+	if err != nil {
+		return nil, err
+	}
+	var aliases []string
+	for alias, store := range stores {
+		if _, ok := store.(storage.OrderedKeyValueDB); ok {
+			aliases = append(aliases, string(alias))
+		}
+	}
+	sort.Strings(aliases)
+	cp := &gcCheckpoint{
+		Report: GCReport{
+			Config:  config,
+			Started: time.Now(),
+			Running: true,
+		},
+	}
+	for _, alias := range aliases {
+		cp.Report.Stores = append(cp.Report.Stores, &GCStoreReport{Alias: storage.Alias(alias)})
+	}
+	return cp, nil
+}
+
+func (m *repoManager) loadGCCheckpoint() (*gcCheckpoint, error) {
+	var cp gcCheckpoint
+	found, err := m.loadData(gcCheckpointKey, &cp)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	cp.Report.Running = true
+	return &cp, nil
+}
+
+// saveGCCheckpoint persists the checkpoint unless this is a dry run.
+func (m *repoManager) saveGCCheckpoint(cp *gcCheckpoint) error {
+	if cp.Report.Config.DryRun {
+		return nil
+	}
+	gc.Lock()
+	defer gc.Unlock()
+	return m.putData(gcCheckpointKey, cp)
+}
+
+// isGarbage returns whether a data key belongs to a deleted or unknown data instance, or
+// a removed version of a versioned data instance.  Keys of instances still being received
+// by a pull and keys with the instance or version ids of an open push are kept.
+func (m *repoManager) isGarbage(k storage.Key, pulling map[dvid.InstanceID]struct{}) (instanceGone, versionGone bool) {
+	if len(k) < 1+dvid.InstanceIDSize+dvid.VersionIDSize+dvid.ClientIDSize+1 {
+		return false, false
+	}
+	instanceID, versionID, _, err := storage.DataKeyToLocalIDs(k)
+	if err != nil {
+		return false, false
+	}
+	if _, found := pulling[instanceID]; found {
+		return false, false
+	}
+	if isPushing(instanceID, versionID) {
+		return false, false
+	}
+	m.idMutex.RLock()
+	defer m.idMutex.RUnlock()
+	d, found := m.iids[instanceID]
+	if !found || d.IsDeleted() {
+		return true, false
+	}
+	if !d.Versioned() {
+		return false, false
+	}
+	_, found = m.versionToUUID[versionID]
+	return false, !found
+}
+
+// runGC scans the stores starting from the checkpoint.  If the job is canceled, the
+// checkpoint is kept so a later StartGC can resume.
+func (m *repoManager) runGC(cp *gcCheckpoint, job *dvid.Job) error {
+	timedLog := dvid.NewTimeLog()
+	stores, err := storage.AllStores()
+	for err == nil && cp.StoreNum < len(cp.Report.Stores) {
+		job.SetProgress(uint64(cp.StoreNum), uint64(len(cp.Report.Stores)))
+		report := cp.Report.Stores[cp.StoreNum]
+		store, found := stores[report.Alias]
+		if !found {
+			err = fmt.Errorf("store %q is no longer available", report.Alias)
+			break
+		}
+		db, ok := store.(storage.OrderedKeyValueDB)
+		if !ok {
+			err = fmt.Errorf("store %q is not an ordered key-value store", report.Alias)
+			break
+		}
+		if err = m.gcStore(db, cp, report, job); err != nil {
+			if err != dvid.ErrJobCanceled {
+				err = fmt.Errorf("store %q: %v", report.Alias, err)
+			}
+			break
+		}
+		cp.StoreNum++
+		cp.LastKey = nil
+		err = m.saveGCCheckpoint(cp)
+	}
+
+	gc.Lock()
+	finished := time.Now()
+	cp.Report.Finished = &finished
+	cp.Report.Running = false
+	if err == dvid.ErrJobCanceled {
+		cp.Report.Canceled = true
+	} else if err != nil {
+		cp.Report.Error = err.Error()
+	}
+	gc.Unlock()
+	if err == dvid.ErrJobCanceled {
+		if err := m.saveGCCheckpoint(cp); err != nil {
+			dvid.Errorf("Unable to save checkpoint of canceled garbage collection: %v\n", err)
+		}
+		dvid.Infof("Canceled garbage collection after %d of %d stores\n", cp.StoreNum, len(cp.Report.Stores))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if !cp.Report.Config.DryRun {
+		var ctx storage.MetadataContext
+		if err := m.store.Delete(ctx, storage.NewTKey(gcCheckpointKey, nil)); err != nil {
+			dvid.Errorf("Unable to delete garbage collection checkpoint: %v\n", err)
+		}
+	}
+	timedLog.Infof("Completed garbage collection (dry run %t) of %d stores", cp.Report.Config.DryRun, len(cp.Report.Stores))
+	return nil
+}
+
+// gcStore scans the data keys of a store starting after any checkpointed key.  If the
+// job is canceled, the pending batch is deleted and the scan position checkpointed.
+func (m *repoManager) gcStore(db storage.OrderedKeyValueDB, cp *gcCheckpoint, report *GCStoreReport, job *dvid.Job) error {
+	config := cp.Report.Config
+	begKey, endKey := storage.DataKeyRange()
+	if cp.LastKey != nil {
+		begKey = cp.LastKey
+	}
+
+	pulling, err := m.pullingInstances()
+	if err != nil {
+		return err
+	}
+
+	ch := make(chan *storage.KeyValue, 1000)
+	cancel := make(chan struct{})
+	queryErr := make(chan error, 1)
+	go func() {
+		queryErr <- db.RawRangeQuery(begKey, endKey, true, ch, cancel)
+	}()
+
+	var batch []storage.Key
+	var lastKey storage.Key
+	instances := make(map[dvid.InstanceID]struct{})
+	deleteBatch := func() error {
+		var deleted uint64
+		for _, k := range batch {
+			// recheck in case of concurrent metadata changes.
+			if instanceGone, versionGone := m.isGarbage(k, pulling); instanceGone || versionGone {
+				if err := db.RawDelete(k); err != nil {
+					return err
+				}
+				deleted++
+			}
+		}
+		batch = batch[:0]
+		gc.Lock()
+		report.Deleted += deleted
+		gc.Unlock()
+		return nil
+	}
+	checkpoint := func() error {
+		cp.LastKey = lastKey
+		if err := m.saveGCCheckpoint(cp); err != nil {
+			return err
+		}
+		if config.Pause > 0 {
+			time.Sleep(config.Pause)
+		}
+		return nil
+	}
+	var sinceCheckpoint int
+	for {
+		kv := <-ch
+		if kv == nil {
+			break
+		}
+		lastKey = kv.K
+		sinceCheckpoint++
+		instanceGone, versionGone := m.isGarbage(kv.K, pulling)
+		gc.Lock()
+		report.KeysScanned++
+		if instanceGone {
+			report.InstanceKeys++
+		} else if versionGone {
+			report.VersionKeys++
+		}
+		gc.Unlock()
+		if instanceGone {
+			instanceID, _, _, _ := storage.DataKeyToLocalIDs(kv.K)
+			instances[instanceID] = struct{}{}
+		}
+		if (instanceGone || versionGone) && !config.DryRun {
+			batch = append(batch, kv.K)
+		}
+		if len(batch) >= config.BatchSize {
+			if err = deleteBatch(); err == nil {
+				err = checkpoint()
+			}
+			sinceCheckpoint = 0
+		} else if sinceCheckpoint >= gcCheckpointInterval && !config.DryRun {
+			err = checkpoint()
+			sinceCheckpoint = 0
+		}
+		if err == nil && job.Canceled() {
+			if err = deleteBatch(); err == nil {
+				cp.LastKey = lastKey
+				err = dvid.ErrJobCanceled
+			}
+		}
+		if err != nil {
+			close(cancel)
+			return err
+		}
+	}
+	if err := <-queryErr; err != nil {
+		return err
+	}
+	if err = deleteBatch(); err != nil {
+		return err
+	}
+
+	// In dry runs, estimate the size of unreferenced instances.
+	var sizes map[dvid.InstanceID]uint64
+	if config.DryRun && len(instances) != 0 {
+		ids := make([]dvid.InstanceID, 0, len(instances))
+		for id := range instances {
+			ids = append(ids, id)
+		}
+		if sizes, err = storage.GetDataSizes(db, ids); err != nil {
+			return err
+		}
+	}
+	gc.Lock()
+	report.InstanceBytes = sizes
+	report.Done = true
+	gc.Unlock()
+	dvid.Infof("Garbage collection of store %q: scanned %d keys, %d from removed instances, %d from removed versions, deleted %d\n",
+		report.Alias, report.KeysScanned, report.InstanceKeys, report.VersionKeys, report.Deleted)
+	return nil
+}
@@ -0,0 +1,151 @@
+// +build !clustered,!gcloud
+
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+func waitForGC(t *testing.T) *GCReport {
+	for i := 0; i < 500; i++ {
+		report := GetGCReport()
+		if report != nil && !report.Running {
+			return report
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for garbage collection\n")
+	return nil
+}
+
+// countDataKeys returns the number of data keys in the store.
+func countDataKeys(t *testing.T, db storage.OrderedKeyValueDB) int {
+	begKey, endKey := storage.DataKeyRange()
+	ch := make(chan *storage.KeyValue, 100)
+	go func() {
+		if err := db.RawRangeQuery(begKey, endKey, true, ch, nil); err != nil {
+			t.Errorf("bad range query: %v\n", err)
+		}
+	}()
+	var n int
+	for kv := range ch {
+		if kv == nil {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func TestGarbageCollection(t *testing.T) {
+	OpenTest()
+	defer CloseTest()
+
+	db, err := storage.DefaultOrderedKVDB()
+	if err != nil {
+		t.Fatalf("can't get default store: %v\n", err)
+	}
+
+	// write keys for an instance that doesn't exist.
+	minKey, _ := storage.DataKeyRange()
+	unknownID := dvid.InstanceID(9999)
+	for i := 0; i < 10; i++ {
+		k := append([]byte{minKey[0]}, unknownID.Bytes()...)
+		k = append(k, byte(i))
+		k = append(k, dvid.VersionID(1).Bytes()...)
+		k = append(k, dvid.ClientID(0).Bytes()...)
+		k = append(k, storage.MarkData)
+		if err := db.RawPut(k, []byte("garbage")); err != nil {
+			t.Fatalf("unable to put garbage key: %v\n", err)
+		}
+	}
+	if n := countDataKeys(t, db); n != 10 {
+		t.Fatalf("expected 10 data keys before GC, got %d\n", n)
+	}
+
+	if _, err := StartGC(GCConfig{DryRun: true}, ""); err != nil {
+		t.Fatalf("unable to start dry run GC: %v\n", err)
+	}
+	report := waitForGC(t)
+	if report.Error != "" {
+		t.Fatalf("dry run GC failed: %s\n", report.Error)
+	}
+	if len(report.Stores) != 1 || report.Stores[0].InstanceKeys != 10 || report.Stores[0].Deleted != 0 {
+		t.Fatalf("bad dry run report: %v\n", report.Stores[0])
+	}
+	if n := countDataKeys(t, db); n != 10 {
+		t.Fatalf("expected dry run to keep 10 data keys, got %d\n", n)
+	}
+
+	if _, err := StartGC(GCConfig{BatchSize: 3}, ""); err != nil {
+		t.Fatalf("unable to start GC: %v\n", err)
+	}
+	report = waitForGC(t)
+	if report.Error != "" {
+		t.Fatalf("GC failed: %s\n", report.Error)
+	}
+	if report.Stores[0].Deleted != 10 || !report.Stores[0].Done {
+		t.Errorf("expected 10 deleted keys, got report %v\n", report.Stores[0])
+	}
+	if n := countDataKeys(t, db); n != 0 {
+		t.Errorf("expected no data keys after GC, got %d\n", n)
+	}
+	if cp, err := manager.loadGCCheckpoint(); err != nil || cp != nil {
+		t.Errorf("expected GC checkpoint to be removed after completion: %v, %v\n", cp, err)
+	}
+}
+
+func TestPushSessionGC(t *testing.T) {
+	OpenTest()
+	defer CloseTest()
+
+	db, err := storage.DefaultOrderedKVDB()
+	if err != nil {
+		t.Fatalf("can't get default store: %v\n", err)
+	}
+
+	// write keys with ids of an open push that aren't yet registered.
+	minKey, _ := storage.DataKeyRange()
+	pushedID := dvid.InstanceID(9999)
+	for i := 0; i < 10; i++ {
+		k := append([]byte{minKey[0]}, pushedID.Bytes()...)
+		k = append(k, byte(i))
+		k = append(k, dvid.VersionID(1).Bytes()...)
+		k = append(k, dvid.ClientID(0).Bytes()...)
+		k = append(k, storage.MarkData)
+		if err := db.RawPut(k, []byte("pushed")); err != nil {
+			t.Fatalf("unable to put pushed key: %v\n", err)
+		}
+	}
+	p := &pusher{
+		sessionID:   12345,
+		instanceMap: dvid.InstanceMap{5: pushedID},
+		versionMap:  dvid.VersionMap{3: 999},
+	}
+	p.registerPush()
+
+	if _, err := StartGC(GCConfig{}, ""); err != nil {
+		t.Fatalf("unable to start GC: %v\n", err)
+	}
+	if report := waitForGC(t); report.Error != "" {
+		t.Fatalf("GC failed: %s\n", report.Error)
+	}
+	if n := countDataKeys(t, db); n != 10 {
+		t.Fatalf("expected GC to keep 10 keys of open push, got %d\n", n)
+	}
+
+	p.unregisterPush()
+	if _, err := StartGC(GCConfig{}, ""); err != nil {
+		t.Fatalf("unable to start GC: %v\n", err)
+	}
+	if report := waitForGC(t); report.Error != "" {
+		t.Fatalf("GC failed: %s\n", report.Error)
+	}
+	if n := countDataKeys(t, db); n != 0 {
+		t.Errorf("expected no data keys after GC of closed push, got %d\n", n)
+	}
+}
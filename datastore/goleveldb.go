@@ -0,0 +1,6 @@
+// +build goleveldb
+
+package datastore
+
+import _ "github.com/janelia-flyem/dvid/storage/goleveldb"
+import _ "github.com/janelia-flyem/dvid/storage/filelog"
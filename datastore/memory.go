@@ -0,0 +1,6 @@
+// +build memory
+
+package datastore
+
+import _ "github.com/janelia-flyem/dvid/storage/memory"
+import _ "github.com/janelia-flyem/dvid/storage/filelog"
@@ -0,0 +1,576 @@
+/*
+	This file supports type-specific merging of versions, where key-values modified
+	in more than one parent are handed to the data instance for resolution.
+*/
+
+package datastore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// MergeConflict describes a type-specific key that was modified in more than one
+// parent of a merge.
+type MergeConflict struct {
+	TKey storage.TKey
+
+	// Versions holds, for each parent in merge order, the version of the key-value
+	// visible from that parent or 0 if the key was never written along its ancestry.
+	Versions []dvid.VersionID
+
+	// Base is the value visible from the nearest common ancestor of the parents
+	// or nil if there was no value.
+	Base []byte
+
+	// Values holds the value visible from each parent in merge order, where nil
+	// means the key is absent or was deleted.
+	Values [][]byte
+}
+
+// Merger is a data instance that can resolve conflicting key-values during a
+// type-specific auto merge.  MergeConflicts should write resolved key-values
+// into the merge child given by the context.  Parents are in merge order, where
+// earlier parents should be given precedence when no better resolution exists.
+type Merger interface {
+	MergeConflicts(ctx *VersionedCtx, parents []dvid.VersionID, conflicts []MergeConflict) error
+}
+
+// maxConflictsShown is the maximum number of conflicting keys listed in errors.
+const maxConflictsShown = 10
+
+// describeConflicts returns a human-readable list of the conflicting keys.
+func describeConflicts(data DataService, conflicts []MergeConflict) string {
+	var descs []string
+	for i, conflict := range conflicts {
+		if i == maxConflictsShown {
+			descs = append(descs, fmt.Sprintf("... and %d more", len(conflicts)-maxConflictsShown))
+			break
+		}
+		desc := "unknown key"
+		if class, err := conflict.TKey.Class(); err == nil {
+			desc = data.DescribeTKeyClass(class)
+		}
+		descs = append(descs, fmt.Sprintf("%s %x", desc, []byte(conflict.TKey)))
+	}
+	return strings.Join(descs, ", ")
+}
+
+// ancestorCache memoizes the set of ancestors (including itself) of versions.
+type ancestorCache map[dvid.VersionID]map[dvid.VersionID]struct{}
+
+func (m *repoManager) ancestorSet(cache ancestorCache, v dvid.VersionID) (map[dvid.VersionID]struct{}, error) {
+	if set, found := cache[v]; found {
+		return set, nil
+	}
+	set := map[dvid.VersionID]struct{}{v: {}}
+	toVisit := []dvid.VersionID{v}
+	for len(toVisit) > 0 {
+		cur := toVisit[0]
+		toVisit = toVisit[1:]
+		parents, err := m.getParentsByVersion(cur)
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range parents {
+			if _, found := set[parent]; !found {
+				set[parent] = struct{}{}
+				toVisit = append(toVisit, parent)
+			}
+		}
+	}
+	cache[v] = set
+	return set, nil
+}
+
+// commonAncestor returns the nearest version that is an ancestor of all given
+// versions, or 0 if there is none.
+func (m *repoManager) commonAncestor(cache ancestorCache, versions []dvid.VersionID) (dvid.VersionID, error) {
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	sets := make([]map[dvid.VersionID]struct{}, len(versions))
+	for i, v := range versions {
+		set, err := m.ancestorSet(cache, v)
+		if err != nil {
+			return 0, err
+		}
+		sets[i] = set
+	}
+	visited := map[dvid.VersionID]struct{}{versions[0]: {}}
+	toVisit := []dvid.VersionID{versions[0]}
+	for len(toVisit) > 0 {
+		cur := toVisit[0]
+		toVisit = toVisit[1:]
+		inAll := true
+		for _, set := range sets[1:] {
+			if _, found := set[cur]; !found {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			return cur, nil
+		}
+		parents, err := m.getParentsByVersion(cur)
+		if err != nil {
+			return 0, err
+		}
+		for _, parent := range parents {
+			if _, found := visited[parent]; !found {
+				visited[parent] = struct{}{}
+				toVisit = append(toVisit, parent)
+			}
+		}
+	}
+	return 0, nil
+}
+
+// parentMatches returns the version of the key-value (including tombstones) visible
+// from each parent and whether the key was modified independently along more
+// than one parent's ancestry.
+func (m *repoManager) parentMatches(cache ancestorCache, kvv kvVersions, parents []dvid.VersionID) ([]dvid.VersionID, bool, error) {
+	matches := make([]dvid.VersionID, len(parents))
+	for i, parent := range parents {
+		// findMatch invalidates ancestors as it goes, so use a fresh map for each parent.
+		fresh := make(kvVersions, len(kvv))
+		for v, n := range kvv {
+			fresh[v] = kvvNode{kv: n.kv}
+		}
+		_, matchV, err := m.findMatch(fresh, parent)
+		if err != nil {
+			return nil, false, err
+		}
+		matches[i] = matchV
+	}
+	for i, vi := range matches {
+		if vi == 0 {
+			continue
+		}
+		iset, err := m.ancestorSet(cache, vi)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, vj := range matches[i+1:] {
+			if vj == 0 || vj == vi {
+				continue
+			}
+			if _, found := iset[vj]; found {
+				continue
+			}
+			jset, err := m.ancestorSet(cache, vj)
+			if err != nil {
+				return nil, false, err
+			}
+			if _, found := jset[vi]; !found {
+				return matches, true, nil
+			}
+		}
+	}
+	return matches, false, nil
+}
+
+// findMergeConflicts scans all key-values of a data instance and returns the keys that
+// were modified in more than one of the given parents.  If withValues is true, the
+// values visible from each parent and the nearest common ancestor are also retrieved.
+func (m *repoManager) findMergeConflicts(data DataService, parents []dvid.VersionID, withValues bool) ([]MergeConflict, error) {
+	if !data.Versioned() {
+		return nil, nil
+	}
+	store, err := GetOrderedKeyValueDB(data)
+	if err != nil {
+		return nil, err
+	}
+	cache := make(ancestorCache)
+
+	baseCtx := NewVersionedCtx(data, 0)
+	ch := make(chan *storage.KeyValue, 1000)
+	var conflicts []MergeConflict
+	var scanErr error
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var batchTK storage.TKey
+		kvv := kvVersions{}
+		processBatch := func() {
+			if len(kvv) < 2 || scanErr != nil {
+				return
+			}
+			matches, conflicted, err := m.parentMatches(cache, kvv, parents)
+			if err != nil {
+				scanErr = fmt.Errorf("key %x of data %q: %v", []byte(batchTK), data.DataName(), err)
+				return
+			}
+			if conflicted {
+				conflicts = append(conflicts, MergeConflict{TKey: batchTK, Versions: matches})
+			}
+		}
+		for kv := range ch {
+			if kv == nil {
+				processBatch()
+				return
+			}
+			curV, err := baseCtx.VersionFromKey(kv.K)
+			if err != nil {
+				dvid.Errorf("Can't decode key when finding merge conflicts for %s", data.DataName())
+				continue
+			}
+			curTK, err := storage.TKeyFromKey(kv.K)
+			if err != nil {
+				dvid.Errorf("Error in processing kv pairs when finding merge conflicts: %v\n", err)
+				continue
+			}
+			if batchTK != nil && !bytes.Equal(curTK, batchTK) {
+				processBatch()
+				kvv = kvVersions{}
+			}
+			batchTK = curTK
+			kvv[curV] = kvvNode{kv: kv}
+		}
+	}()
+
+	minKey, maxKey := baseCtx.KeyRange()
+	keysOnly := true
+	if err := store.RawRangeQuery(minKey, maxKey, keysOnly, ch, nil); err != nil {
+		close(ch)
+		wg.Wait()
+		return nil, err
+	}
+	wg.Wait()
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	if !withValues || len(conflicts) == 0 {
+		return conflicts, nil
+	}
+
+	baseV, err := m.commonAncestor(cache, parents)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conflicts {
+		conflict := &conflicts[i]
+		if baseV != 0 {
+			if conflict.Base, err = store.Get(NewVersionedCtx(data, baseV), conflict.TKey); err != nil {
+				return nil, err
+			}
+		}
+		conflict.Values = make([][]byte, len(parents))
+		for j, parentV := range parents {
+			if conflict.Values[j], err = store.Get(NewVersionedCtx(data, parentV), conflict.TKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// mergeConflictsByInstance returns the merge conflicts for each versioned data instance
+// in the repo holding the given parents.
+func (m *repoManager) mergeConflictsByInstance(r *repoT, parents []dvid.VersionID, withValues bool) (map[dvid.InstanceName][]MergeConflict, error) {
+	r.RLock()
+	names := make([]string, 0, len(r.data))
+	dataservices := make(map[string]DataService, len(r.data))
+	for name, dataservice := range r.data {
+		names = append(names, string(name))
+		dataservices[string(name)] = dataservice
+	}
+	r.RUnlock()
+	sort.Strings(names)
+
+	byInstance := make(map[dvid.InstanceName][]MergeConflict)
+	for _, name := range names {
+		dataservice := dataservices[name]
+		conflicts, err := m.findMergeConflicts(dataservice, parents, withValues)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			byInstance[dataservice.DataName()] = conflicts
+		}
+	}
+	return byInstance, nil
+}
+
+// MergeConflictClass lists the keys of one TKeyClass that were modified in more
+// than one parent of a prospective merge.
+type MergeConflictClass struct {
+	Class       storage.TKeyClass `json:"class"`
+	Description string            `json:"description"`
+	Keys        []string          `json:"keys"` // hex-encoded type-specific keys
+}
+
+// mergeConflictReport returns, for each data instance with conflicts, the conflicting
+// keys grouped by TKeyClass.  Nothing is modified.
+func (m *repoManager) mergeConflictReport(parents []dvid.UUID) (map[dvid.InstanceName][]MergeConflictClass, error) {
+	if len(parents) < 2 {
+		return nil, fmt.Errorf("must specify at least two parents to check for merge conflicts")
+	}
+	r, err := m.repoFromUUID(parents[0])
+	if err != nil {
+		return nil, err
+	}
+	parentsV := make([]dvid.VersionID, len(parents))
+	for i, parent := range parents {
+		pr, err := m.repoFromUUID(parent)
+		if err != nil {
+			return nil, err
+		}
+		if pr != r {
+			return nil, fmt.Errorf("parent %s is not in the same repo as parent %s", parent, parents[0])
+		}
+		if parentsV[i], err = m.versionFromUUID(parent); err != nil {
+			return nil, err
+		}
+	}
+
+	byInstance, err := m.mergeConflictsByInstance(r, parentsV, false)
+	if err != nil {
+		return nil, err
+	}
+	report := make(map[dvid.InstanceName][]MergeConflictClass, len(byInstance))
+	for name, conflicts := range byInstance {
+		r.RLock()
+		dataservice := r.data[name]
+		r.RUnlock()
+		var classes []MergeConflictClass
+		classIndex := make(map[storage.TKeyClass]int)
+		for _, conflict := range conflicts {
+			class, err := conflict.TKey.Class()
+			if err != nil {
+				return nil, err
+			}
+			i, found := classIndex[class]
+			if !found {
+				i = len(classes)
+				classIndex[class] = i
+				classes = append(classes, MergeConflictClass{
+					Class:       class,
+					Description: dataservice.DescribeTKeyClass(class),
+				})
+			}
+			classes[i].Keys = append(classes[i].Keys, fmt.Sprintf("%x", []byte(conflict.TKey)))
+		}
+		report[name] = classes
+	}
+	return report, nil
+}
+
+// MergeResolution specifies how keys of a data instance should be resolved in an
+// external-data merge.  Either a single Key or an inclusive range of keys from KeyBeg
+// to KeyEnd is given, where keys are hex-encoded type-specific keys as returned by
+// a merge conflict report and an empty KeyBeg or KeyEnd leaves the range unbounded.
+// Conflicting keys within the range take the value visible from the Parent UUID.
+// A single Key may instead be given an explicit Value, as a client would send it to the
+// datatype, or be deleted.
+type MergeResolution struct {
+	Data   dvid.InstanceName `json:"data"`
+	Key    string            `json:"key,omitempty"`
+	KeyBeg string            `json:"keyBeg,omitempty"`
+	KeyEnd string            `json:"keyEnd,omitempty"`
+	Parent string            `json:"parent,omitempty"`
+	Value  []byte            `json:"value,omitempty"`
+	Delete bool              `json:"delete,omitempty"`
+}
+
+// ValueSerializer is a data instance that converts a client-supplied value for a key
+// into the format it stores, e.g., by compressing it.  Explicit values in external
+// merge resolutions are passed through it, and values for instances that don't
+// implement it are stored as given.
+type ValueSerializer interface {
+	SerializeValue(tk storage.TKey, value []byte) ([]byte, error)
+}
+
+// resolvedKV is a key-value to be written into a merge child, where a nil value
+// denotes a deletion.
+type resolvedKV struct {
+	tk    storage.TKey
+	value []byte
+}
+
+// compiledResolution is a MergeResolution with decoded keys and parent index.
+type compiledResolution struct {
+	beg, end storage.TKey // nil if unbounded
+	parent   int          // index into parents or -1 if explicit value
+	single   bool
+	value    []byte
+	remove   bool
+}
+
+func (cr compiledResolution) contains(tk storage.TKey) bool {
+	if cr.beg != nil && bytes.Compare(tk, cr.beg) < 0 {
+		return false
+	}
+	if cr.end != nil && bytes.Compare(tk, cr.end) > 0 {
+		return false
+	}
+	return true
+}
+
+func decodeResolutionKey(s string) (storage.TKey, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad hex-encoded key %q: %v", s, err)
+	}
+	return storage.TKey(b), nil
+}
+
+// resolveExternal applies the given resolutions to the merge conflicts and returns the
+// key-values to be written into the merge child for each data instance.  An error
+// is returned if any conflict is not covered by a resolution.
+func (m *repoManager) resolveExternal(r *repoT, parents []dvid.UUID, conflicts map[dvid.InstanceName][]MergeConflict, resolutions []MergeResolution) (map[dvid.InstanceName][]resolvedKV, error) {
+	compiled := make(map[dvid.InstanceName][]compiledResolution)
+	for i, res := range resolutions {
+		r.RLock()
+		dataservice, found := r.data[res.Data]
+		r.RUnlock()
+		if !found {
+			return nil, fmt.Errorf("resolution %d: no data %q in repo", i, res.Data)
+		}
+		if !dataservice.Versioned() {
+			return nil, fmt.Errorf("resolution %d: data %q is unversioned and cannot be merged", i, res.Data)
+		}
+		var cr compiledResolution
+		var err error
+		cr.parent = -1
+		if res.Parent != "" {
+			parentUUID, _, err := m.matchingUUID(res.Parent)
+			if err != nil {
+				return nil, fmt.Errorf("resolution %d: %v", i, err)
+			}
+			for j, parent := range parents {
+				if parent == parentUUID {
+					cr.parent = j
+					break
+				}
+			}
+			if cr.parent < 0 {
+				return nil, fmt.Errorf("resolution %d: %s is not a parent of the merge", i, parentUUID)
+			}
+		}
+		if res.Key != "" {
+			if cr.beg, err = decodeResolutionKey(res.Key); err != nil {
+				return nil, fmt.Errorf("resolution %d: %v", i, err)
+			}
+			cr.end = cr.beg
+			cr.single = true
+			cr.value = res.Value
+			cr.remove = res.Delete
+			settings := 0
+			for _, set := range []bool{cr.parent >= 0, cr.value != nil, cr.remove} {
+				if set {
+					settings++
+				}
+			}
+			if settings != 1 {
+				return nil, fmt.Errorf("resolution %d: key %s must have exactly one of parent, value, or delete", i, res.Key)
+			}
+		} else {
+			if cr.parent < 0 || res.Value != nil || res.Delete {
+				return nil, fmt.Errorf("resolution %d: key ranges must be resolved by specifying a parent", i)
+			}
+			if cr.beg, err = decodeResolutionKey(res.KeyBeg); err != nil {
+				return nil, fmt.Errorf("resolution %d: %v", i, err)
+			}
+			if cr.end, err = decodeResolutionKey(res.KeyEnd); err != nil {
+				return nil, fmt.Errorf("resolution %d: %v", i, err)
+			}
+		}
+		compiled[res.Data] = append(compiled[res.Data], cr)
+	}
+
+	resolved := make(map[dvid.InstanceName][]resolvedKV)
+	var unresolved []string
+	for name, instanceConflicts := range conflicts {
+		var missing []MergeConflict
+		for _, conflict := range instanceConflicts {
+			found := false
+			for _, cr := range compiled[name] {
+				if !cr.contains(conflict.TKey) {
+					continue
+				}
+				if cr.parent >= 0 {
+					resolved[name] = append(resolved[name], resolvedKV{conflict.TKey, conflict.Values[cr.parent]})
+					found = true
+				}
+				break
+			}
+			if !found && !hasExplicitKey(compiled[name], conflict.TKey) {
+				missing = append(missing, conflict)
+			}
+		}
+		if len(missing) != 0 {
+			r.RLock()
+			dataservice := r.data[name]
+			r.RUnlock()
+			unresolved = append(unresolved, fmt.Sprintf("data %q has %d unresolved conflicting keys: %s",
+				name, len(missing), describeConflicts(dataservice, missing)))
+		}
+	}
+	if len(unresolved) != 0 {
+		sort.Strings(unresolved)
+		return nil, fmt.Errorf("merge resolutions do not cover all conflicts: %s", strings.Join(unresolved, "; "))
+	}
+
+	// Explicit values and deletions are applied whether or not the key is in conflict.
+	for name, crs := range compiled {
+		r.RLock()
+		dataservice := r.data[name]
+		r.RUnlock()
+		serializer, canSerialize := dataservice.(ValueSerializer)
+		for _, cr := range crs {
+			if cr.single && cr.parent < 0 {
+				var value []byte
+				if !cr.remove {
+					value = cr.value
+					if canSerialize {
+						var err error
+						if value, err = serializer.SerializeValue(cr.beg, cr.value); err != nil {
+							return nil, fmt.Errorf("unable to serialize value for data %q key %x: %v", name, []byte(cr.beg), err)
+						}
+					}
+				}
+				resolved[name] = append(resolved[name], resolvedKV{cr.beg, value})
+			}
+		}
+	}
+	return resolved, nil
+}
+
+func hasExplicitKey(crs []compiledResolution, tk storage.TKey) bool {
+	for _, cr := range crs {
+		if cr.single && cr.parent < 0 && bytes.Equal(cr.beg, tk) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResolved stores the resolved key-values for a data instance into the given
+// version using a single batch so the instance's resolutions are applied atomically.
+func writeResolved(data DataService, v dvid.VersionID, kvs []resolvedKV) error {
+	batcher, err := GetKeyValueBatcher(data)
+	if err != nil {
+		return err
+	}
+	batch := batcher.NewBatch(NewVersionedCtx(data, v))
+	for _, kv := range kvs {
+		if kv.value == nil {
+			batch.Delete(kv.tk)
+		} else {
+			batch.Put(kv.tk, kv.value)
+		}
+	}
+	return batch.Commit()
+}
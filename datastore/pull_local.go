@@ -0,0 +1,395 @@
+// +build !clustered,!gcloud
+
+/*
+	This file supports pulling a repo from a remote DVID server over HTTP.  The receiving
+	server initiates the transfer, so it works when only the receiver can reach the remote.
+	The remote streams the same messages used in a push session, one data instance per
+	request, and the receiver checkpoints each completed data instance so an interrupted
+	pull can be resumed.
+*/
+
+package datastore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// only one modification of pull checkpoints at a time.
+var pullMu sync.Mutex
+
+// names of pull checkpoints for pulls that are running.
+var activePulls = make(map[string]struct{})
+
+// pullMsg is streamed by the remote during the pull of a data instance.  Only one of
+// the fields is set in each message.
+type pullMsg struct {
+	Start *DataTxInit
+	KV    *KVMessage
+	Error string
+}
+
+// pullCheckpoint is persisted in the metadata store after the repo metadata and each data
+// instance is received so an interrupted pull can be resumed.
+type pullCheckpoint struct {
+	Remote   string
+	UUID     dvid.UUID
+	Data     string // the data, filter, and transmit settings of the pull
+	Filter   string
+	Transmit string
+
+	Repo        []byte // the received repo with local ids
+	InstanceMap dvid.InstanceMap
+	VersionMap  dvid.VersionMap
+	Versions    []dvid.VersionID // remote versions requested
+	Done        []dvid.InstanceName
+}
+
+func pullCheckpointName(remote string, uuid dvid.UUID) string {
+	return remote + " " + string(uuid)
+}
+
+// SendPullRepo writes the repo metadata requested by a pulling server, customized by the
+// same "data" and "transmit" settings as a push.
+func SendPullRepo(w io.Writer, uuid dvid.UUID, config dvid.Config) error {
+	if manager == nil {
+		return ErrManagerNotInitialized
+	}
+	txRepo, transmit, err := customizedRepo(uuid, config)
+	if err != nil {
+		return err
+	}
+	repoSerialization, err := txRepo.GobEncode()
+	if err != nil {
+		return err
+	}
+	repoMsg := repoTxMsg{
+		Transmit: transmit,
+		UUID:     uuid,
+		Repo:     repoSerialization,
+	}
+	return gob.NewEncoder(w).Encode(repoMsg)
+}
+
+// SendPullData writes the key-values of a data instance at the given versions for a
+// pulling server, applying any "filter" setting just like a push.  Errors after the
+// stream has started are logged and sent to the pulling server instead of returned.
+func SendPullData(w io.Writer, uuid dvid.UUID, name dvid.InstanceName, versions map[dvid.VersionID]struct{}, config dvid.Config) error {
+	if manager == nil {
+		return ErrManagerNotInitialized
+	}
+	filter, _, err := config.GetString("filter")
+	if err != nil {
+		return err
+	}
+	txRepo, transmit, err := customizedRepo(uuid, config)
+	if err != nil {
+		return err
+	}
+	d, found := txRepo.data[name]
+	if !found {
+		return ErrInvalidDataName
+	}
+	enc := gob.NewEncoder(w)
+	ps := &PushSession{Filter: storage.FilterSpec(filter), Versions: versions, t: transmit, enc: enc}
+	dvid.Infof("Sending instance %q data for pull of %s\n", name, uuid)
+	if err := d.PushData(ps); err != nil {
+		dvid.Errorf("Aborting send of instance %q data for pull: %v\n", name, err)
+		if err := enc.Encode(pullMsg{Error: err.Error()}); err != nil {
+			dvid.Errorf("Unable to send error to pulling server: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// PullRepo replicates a repo from a remote DVID server at the given HTTP address.  The
+// config can have the same "data", "filter", and "transmit" settings as a push, as well as
+// a "token" setting that is sent as a bearer token if the remote requires authentication.
+// If a prior pull of the UUID from the remote was interrupted, it is resumed with its
+// original settings, skipping data instances that were completely received.  Garbage
+// collection keeps the data received by incomplete pulls, including canceled pulls.  The
+// optional job is updated with the pull progress and stops the pull if canceled.
+func PullRepo(remote string, uuid dvid.UUID, config dvid.Config, job *dvid.Job) error {
+	if manager == nil {
+		return ErrManagerNotInitialized
+	}
+	return manager.pullRepo(remote, uuid, config, job)
+}
+
+func (m *repoManager) loadPullCheckpoints() (map[string]*pullCheckpoint, error) {
+	cps := make(map[string]*pullCheckpoint)
+	if _, err := m.loadData(pullCheckpointKey, &cps); err != nil {
+		return nil, err
+	}
+	return cps, nil
+}
+
+// savePullCheckpoint persists the checkpoint or removes it if it is nil.
+func (m *repoManager) savePullCheckpoint(name string, cp *pullCheckpoint) error {
+	pullMu.Lock()
+	defer pullMu.Unlock()
+	cps, err := m.loadPullCheckpoints()
+	if err != nil {
+		return err
+	}
+	if cp == nil {
+		delete(cps, name)
+	} else {
+		cps[name] = cp
+	}
+	if len(cps) == 0 {
+		var ctx storage.MetadataContext
+		return m.store.Delete(ctx, storage.NewTKey(pullCheckpointKey, nil))
+	}
+	return m.putData(pullCheckpointKey, cps)
+}
+
+// pullingInstances returns the local instance ids of data being received by incomplete
+// pulls, which aren't yet part of any repo.
+func (m *repoManager) pullingInstances() (map[dvid.InstanceID]struct{}, error) {
+	pullMu.Lock()
+	defer pullMu.Unlock()
+	cps, err := m.loadPullCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[dvid.InstanceID]struct{})
+	for _, cp := range cps {
+		for _, id := range cp.InstanceMap {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids, nil
+}
+
+// pullClient issues requests to the remote of a pull.
+type pullClient struct {
+	base  string
+	uuid  dvid.UUID
+	token string
+}
+
+// get returns the body of a successful GET on the remote's pull endpoint.
+func (c *pullClient) get(name dvid.InstanceName, query url.Values) (io.ReadCloser, error) {
+	path := fmt.Sprintf("%s/api/repo/%s/pull", c.base, c.uuid)
+	if name != "" {
+		path += "/" + url.PathEscape(string(name))
+	}
+	if len(query) != 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote returned status %d for %s: %s", resp.StatusCode, path, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+func (m *repoManager) pullRepo(remote string, uuid dvid.UUID, config dvid.Config, job *dvid.Job) error {
+	if remote == "" {
+		return fmt.Errorf("pull requires the address of the remote DVID server")
+	}
+	base := strings.TrimRight(remote, "/")
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	token, _, err := config.GetString("token")
+	if err != nil {
+		return err
+	}
+	cpName := pullCheckpointName(remote, uuid)
+	pullMu.Lock()
+	if _, running := activePulls[cpName]; running {
+		pullMu.Unlock()
+		return fmt.Errorf("pull of %s from %q is already running", uuid, remote)
+	}
+	cps, err := m.loadPullCheckpoints()
+	if err != nil {
+		pullMu.Unlock()
+		return err
+	}
+	activePulls[cpName] = struct{}{}
+	pullMu.Unlock()
+	defer func() {
+		pullMu.Lock()
+		delete(activePulls, cpName)
+		pullMu.Unlock()
+	}()
+
+	timedLog := dvid.NewTimeLog()
+	p := &pusher{uuid: uuid, startTime: time.Now()}
+	cp, found := cps[cpName]
+	if found {
+		dvid.Infof("Resuming pull of %s from %q with %d data instances already received\n", uuid, remote, len(cp.Done))
+		p.repo = new(repoT)
+		if err := p.repo.GobDecode(cp.Repo); err != nil {
+			return err
+		}
+		if err := p.repo.assignStores(); err != nil {
+			return err
+		}
+		p.instanceMap = cp.InstanceMap
+		p.versionMap = cp.VersionMap
+		p.negotiated = true
+	} else {
+		cp = &pullCheckpoint{Remote: remote, UUID: uuid}
+		if cp.Data, _, err = config.GetString("data"); err != nil {
+			return err
+		}
+		if cp.Filter, _, err = config.GetString("filter"); err != nil {
+			return err
+		}
+		if cp.Transmit, _, err = config.GetString("transmit"); err != nil {
+			return err
+		}
+	}
+	query := make(url.Values)
+	for key, value := range map[string]string{"data": cp.Data, "filter": cp.Filter, "transmit": cp.Transmit} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	client := &pullClient{base: base, uuid: uuid, token: token}
+
+	if !found {
+		dvid.Infof("Pulling repo %s metadata from %q\n", uuid, remote)
+		body, err := client.get("", query)
+		if err != nil {
+			return err
+		}
+		var repoMsg repoTxMsg
+		err = gob.NewDecoder(body).Decode(&repoMsg)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("unable to decode repo metadata from remote: %v", err)
+		}
+		versions, err := p.readRepo(&repoMsg)
+		if err != nil {
+			return err
+		}
+		for v := range versions {
+			cp.Versions = append(cp.Versions, v)
+		}
+		cp.InstanceMap = p.instanceMap
+		cp.VersionMap = p.versionMap
+		if cp.Repo, err = p.repo.GobEncode(); err != nil {
+			return err
+		}
+		if err := m.savePullCheckpoint(cpName, cp); err != nil {
+			return err
+		}
+	}
+
+	// Request each data instance not yet received.
+	done := make(map[dvid.InstanceName]struct{}, len(cp.Done))
+	for _, name := range cp.Done {
+		done[name] = struct{}{}
+	}
+	var names []string
+	for name := range p.repo.data {
+		if _, found := done[name]; !found {
+			names = append(names, string(name))
+		}
+	}
+	sort.Strings(names)
+	versionStrs := make([]string, len(cp.Versions))
+	for i, v := range cp.Versions {
+		versionStrs[i] = fmt.Sprintf("%d", v)
+	}
+	query.Set("versions", strings.Join(versionStrs, ","))
+	numInstances := uint64(len(p.repo.data))
+	job.SetProgress(uint64(len(cp.Done)), numInstances)
+	for _, name := range names {
+		if job.Canceled() {
+			return dvid.ErrJobCanceled
+		}
+		dvid.Infof("Pulling instance %q data of %s from %q\n", name, uuid, remote)
+		if err := p.pullData(client, dvid.InstanceName(name), query, job); err != nil {
+			if err == dvid.ErrJobCanceled {
+				return err
+			}
+			return fmt.Errorf("pull of data %q interrupted: %v", name, err)
+		}
+		cp.Done = append(cp.Done, dvid.InstanceName(name))
+		if err := m.savePullCheckpoint(cpName, cp); err != nil {
+			return err
+		}
+		job.SetProgress(uint64(len(cp.Done)), numInstances)
+	}
+
+	if err := p.complete(); err != nil {
+		return err
+	}
+	if err := p.Close(); err != nil {
+		return err
+	}
+	if err := m.savePullCheckpoint(cpName, nil); err != nil {
+		return err
+	}
+	timedLog.Infof("Completed pull of repo %s from %q", uuid, remote)
+	return nil
+}
+
+// pullData receives the stream of key-values for a data instance from the remote.
+func (p *pusher) pullData(client *pullClient, name dvid.InstanceName, query url.Values, job *dvid.Job) error {
+	body, err := client.get(name, query)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	dec := gob.NewDecoder(body)
+	for {
+		if job.Canceled() {
+			return dvid.ErrJobCanceled
+		}
+		var msg pullMsg
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("remote ended stream before all data was sent")
+			}
+			return err
+		}
+		switch {
+		case msg.Error != "":
+			return fmt.Errorf("remote error: %s", msg.Error)
+		case msg.Start != nil:
+			if err := p.startData(msg.Start); err != nil {
+				return err
+			}
+		case msg.KV != nil:
+			if p.store == nil {
+				return fmt.Errorf("remote sent key-value before start of data")
+			}
+			if err := p.putData(msg.KV); err != nil {
+				return err
+			}
+			if msg.KV.Terminate {
+				p.store = nil
+				return nil
+			}
+		}
+	}
+}
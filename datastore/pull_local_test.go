@@ -0,0 +1,110 @@
+// +build !clustered,!gcloud
+
+package datastore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+func TestSendPullRepo(t *testing.T) {
+	OpenTest()
+	defer CloseTest()
+
+	uuid, _ := NewTestRepo()
+	var buf bytes.Buffer
+	config := dvid.NewConfig()
+	config.Set("transmit", "flatten")
+	if err := SendPullRepo(&buf, uuid, config); err != nil {
+		t.Fatalf("unable to send repo for pull: %v\n", err)
+	}
+	var repoMsg repoTxMsg
+	if err := gob.NewDecoder(&buf).Decode(&repoMsg); err != nil {
+		t.Fatalf("unable to decode repo message: %v\n", err)
+	}
+	if repoMsg.UUID != uuid {
+		t.Errorf("expected UUID %s in pull repo message, got %s\n", uuid, repoMsg.UUID)
+	}
+	r := new(repoT)
+	if err := r.GobDecode(repoMsg.Repo); err != nil {
+		t.Fatalf("unable to decode pulled repo: %v\n", err)
+	}
+	if r.uuid != uuid {
+		t.Errorf("expected pulled repo with root %s, got %s\n", uuid, r.uuid)
+	}
+
+	if err := SendPullData(&buf, uuid, "nonexistent", nil, config); err != ErrInvalidDataName {
+		t.Errorf("expected invalid data name error on pull of unknown data, got %v\n", err)
+	}
+}
+
+func TestPullCheckpointGC(t *testing.T) {
+	OpenTest()
+	defer CloseTest()
+
+	db, err := storage.DefaultOrderedKVDB()
+	if err != nil {
+		t.Fatalf("can't get default store: %v\n", err)
+	}
+
+	// write keys for an instance that is still being pulled.
+	minKey, _ := storage.DataKeyRange()
+	pulledID := dvid.InstanceID(9999)
+	for i := 0; i < 10; i++ {
+		k := append([]byte{minKey[0]}, pulledID.Bytes()...)
+		k = append(k, byte(i))
+		k = append(k, dvid.VersionID(1).Bytes()...)
+		k = append(k, dvid.ClientID(0).Bytes()...)
+		k = append(k, storage.MarkData)
+		if err := db.RawPut(k, []byte("pulled")); err != nil {
+			t.Fatalf("unable to put pulled key: %v\n", err)
+		}
+	}
+	cp := &pullCheckpoint{
+		Remote:      "remote:8000",
+		UUID:        "abc123",
+		InstanceMap: dvid.InstanceMap{5: pulledID},
+		Done:        []dvid.InstanceName{"grayscale"},
+	}
+	name := pullCheckpointName(cp.Remote, cp.UUID)
+	if err := manager.savePullCheckpoint(name, cp); err != nil {
+		t.Fatalf("unable to save pull checkpoint: %v\n", err)
+	}
+
+	CloseReopenTest()
+
+	cps, err := manager.loadPullCheckpoints()
+	if err != nil {
+		t.Fatalf("unable to load pull checkpoints: %v\n", err)
+	}
+	if saved, found := cps[name]; !found || len(saved.Done) != 1 || saved.Done[0] != "grayscale" {
+		t.Fatalf("pull checkpoint not persisted correctly: %v\n", cps)
+	}
+
+	if _, err := StartGC(GCConfig{}, ""); err != nil {
+		t.Fatalf("unable to start GC: %v\n", err)
+	}
+	if report := waitForGC(t); report.Error != "" {
+		t.Fatalf("GC failed: %s\n", report.Error)
+	}
+	if n := countDataKeys(t, db); n != 10 {
+		t.Fatalf("expected GC to keep 10 keys of incomplete pull, got %d\n", n)
+	}
+
+	if err := manager.savePullCheckpoint(name, nil); err != nil {
+		t.Fatalf("unable to remove pull checkpoint: %v\n", err)
+	}
+	if _, err := StartGC(GCConfig{}, ""); err != nil {
+		t.Fatalf("unable to start GC: %v\n", err)
+	}
+	if report := waitForGC(t); report.Error != "" {
+		t.Fatalf("GC failed: %s\n", report.Error)
+	}
+	if n := countDataKeys(t, db); n != 0 {
+		t.Errorf("expected no data keys after GC without pull checkpoint, got %d\n", n)
+	}
+}
@@ -13,6 +13,7 @@
 package datastore
 
 import (
+	"encoding/gob"
 	"fmt"
 	"strings"
 	"sync"
@@ -26,8 +27,9 @@ import (
 	"github.com/valyala/gorpc"
 )
 
-// PushRepo pushes a Repo to a remote DVID server at the target address.
-func PushRepo(uuid dvid.UUID, target string, config dvid.Config) error {
+// PushRepo pushes a Repo to a remote DVID server at the target address.  The optional
+// job is updated with the push progress and stops the push if canceled.
+func PushRepo(uuid dvid.UUID, target string, config dvid.Config, job *dvid.Job) error {
 	if manager == nil {
 		return ErrManagerNotInitialized
 	}
@@ -37,25 +39,13 @@ func PushRepo(uuid dvid.UUID, target string, config dvid.Config) error {
 		dvid.Infof("No target specified for push, defaulting to %q\n", rpc.DefaultAddress)
 	}
 
-	// Get the full local repo
-	thisRepo, err := manager.repoFromUUID(uuid)
-	if err != nil {
-		return err
-	}
-
 	// Get any filter
-	filter, found, err := config.GetString("filter")
+	filter, _, err := config.GetString("filter")
 	if err != nil {
 		return err
 	}
 
-	// Create a repo that is tailored by the push configuration, e.g.,
-	// keeping just given data instances, etc.
-	v, found := manager.uuidToVersion[uuid]
-	if !found {
-		return ErrInvalidUUID
-	}
-	txRepo, transmit, err := thisRepo.customize(v, config)
+	txRepo, transmit, err := customizedRepo(uuid, config)
 	if err != nil {
 		return err
 	}
@@ -95,32 +85,61 @@ func PushRepo(uuid dvid.UUID, target string, config dvid.Config) error {
 	dvid.Debugf("Remote sent list of %d versions to send\n", len(versions))
 
 	// For each data instance, send the data with optional datatype-specific filtering.
-	ps := &PushSession{storage.FilterSpec(filter), versions, s, transmit}
+	ps := &PushSession{Filter: storage.FilterSpec(filter), Versions: versions, s: s, t: transmit, job: job}
+	var numSent uint64
 	for _, d := range txRepo.data {
+		if job.Canceled() {
+			return dvid.ErrJobCanceled
+		}
 		dvid.Infof("Sending instance %q data to %q\n", d.DataName(), target)
 		if err := d.PushData(ps); err != nil {
 			dvid.Errorf("Aborting send of instance %q data\n", d.DataName())
 			return err
 		}
+		numSent++
+		job.SetProgress(numSent, uint64(len(txRepo.data)))
 	}
 
+	// The remote only adds the received versions once all data has been sent.
+	if job.Canceled() {
+		return dvid.ErrJobCanceled
+	}
+	if _, err := s.Call()(pushCompleteMsg, s.ID()); err != nil {
+		return fmt.Errorf("remote %q couldn't add pushed repo %s: %v", target, uuid, err)
+	}
+	dvid.Infof("Push of repo %s to %q sent %d of %d versions, skipping %d kv pairs (%s) already at remote\n",
+		uuid, target, len(versions), len(txRepo.dag.nodes), ps.SkippedKV, humanize.Bytes(ps.SkippedBytes))
 	return nil
 }
 
-/*
-func Pull(repo Repo, target string, config dvid.Config) error {
-	// To Pull() we initiate a push from target.
-	return nil
+// customizedRepo returns a copy of the repo holding the given UUID that is tailored by the
+// push configuration, e.g., keeping just given data instances, etc.
+func customizedRepo(uuid dvid.UUID, config dvid.Config) (*repoT, rpc.Transmit, error) {
+	thisRepo, err := manager.repoFromUUID(uuid)
+	if err != nil {
+		return nil, rpc.TransmitUnknown, err
+	}
+	v, err := manager.versionFromUUID(uuid)
+	if err != nil {
+		return nil, rpc.TransmitUnknown, err
+	}
+	return thisRepo.customize(v, config)
 }
-*/
 
 // PushSession encapsulates parameters necessary for DVID-to-DVID push/pull processing.
 type PushSession struct {
 	Filter   storage.FilterSpec
 	Versions map[dvid.VersionID]struct{}
 
-	s rpc.Session
-	t rpc.Transmit
+	// SkippedKV and SkippedBytes total the key-value pairs not sent because they are
+	// stored at versions the remote already has.
+	SkippedKV    uint64
+	SkippedBytes uint64
+
+	s   rpc.Session
+	t   rpc.Transmit
+	enc *gob.Encoder // if non-nil, messages are streamed to a pulling server instead.
+	job *dvid.Job    // if non-nil, the transfer stops when the job is canceled.
 }
 
 // StartInstancePush initiates a data instance push.  After some number of Send
@@ -133,6 +152,9 @@ func (p *PushSession) StartInstancePush(d dvid.Data) error {
 		InstanceID: d.InstanceID(),
 		Tags:       d.Tags(),
 	}
+	if p.enc != nil {
+		return p.enc.Encode(pullMsg{Start: &dmsg})
+	}
 	if _, err := p.s.Call()(StartDataMsg, dmsg); err != nil {
 		return fmt.Errorf("couldn't send data instance %q start: %v\n", d.DataName(), err)
 	}
@@ -143,6 +165,9 @@ func (p *PushSession) StartInstancePush(d dvid.Data) error {
 // for efficiency of transmission.
 func (p *PushSession) SendKV(kv *storage.KeyValue) error {
 	kvmsg := KVMessage{Session: p.s.ID(), KV: *kv, Terminate: false}
+	if p.enc != nil {
+		return p.enc.Encode(pullMsg{KV: &kvmsg})
+	}
 	if _, err := p.s.Call()(PutKVMsg, kvmsg); err != nil {
 		return fmt.Errorf("error sending key-value to remote: %v", err)
 	}
@@ -152,6 +177,9 @@ func (p *PushSession) SendKV(kv *storage.KeyValue) error {
 // EndInstancePush terminates a data instance push.
 func (p *PushSession) EndInstancePush() error {
 	endmsg := KVMessage{Session: p.s.ID(), Terminate: true}
+	if p.enc != nil {
+		return p.enc.Encode(pullMsg{KV: &endmsg})
+	}
 	if _, err := p.s.Call()(PutKVMsg, endmsg); err != nil {
 		return fmt.Errorf("error sending terminate data to remote: %v", err)
 	}
@@ -206,6 +234,7 @@ func PushData(d dvid.Data, p *PushSession) error {
 
 	var kvTotal, kvSent int
 	var bytesTotal, bytesSent uint64
+	var kvSkipped, bytesSkipped uint64
 	keysOnly := false
 	if p.t == rpc.TransmitFlatten {
 		// Start goroutine to receive flattened key-value pairs and transmit to remote.
@@ -214,6 +243,10 @@ func PushData(d dvid.Data, p *PushSession) error {
 			for {
 				tkv := <-ch
 				if tkv == nil {
+					if p.job.Canceled() {
+						wg.Done()
+						return
+					}
 					if err := p.EndInstancePush(); err != nil {
 						dvid.Errorf("Bad data %q termination: %v\n", d.DataName(), err)
 					}
@@ -252,10 +285,16 @@ func PushData(d dvid.Data, p *PushSession) error {
 			if c == nil {
 				return fmt.Errorf("received nil chunk in flatten push for data %s", d.DataName())
 			}
+			if p.job.Canceled() {
+				return dvid.ErrJobCanceled
+			}
 			ch <- c.TKeyValue
 			return nil
 		})
 		ch <- nil
+		if err == dvid.ErrJobCanceled {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("error in flatten push for data %q: %v", d.DataName(), err)
 		}
@@ -266,15 +305,23 @@ func PushData(d dvid.Data, p *PushSession) error {
 			for {
 				kv := <-ch
 				if kv == nil {
+					if p.job.Canceled() {
+						wg.Done()
+						return
+					}
 					if err := p.EndInstancePush(); err != nil {
 						dvid.Errorf("Bad data %q termination: %v\n", d.DataName(), err)
 					}
+					p.SkippedKV += kvSkipped
+					p.SkippedBytes += bytesSkipped
 					wg.Done()
-					dvid.Infof("Sent %d %q key-value pairs (%s, out of %d kv pairs, %s)\n",
-						kvSent, d.DataName(), humanize.Bytes(bytesSent), kvTotal, humanize.Bytes(bytesTotal))
+					dvid.Infof("Sent %d %q key-value pairs (%s, out of %d kv pairs, %s), skipped %d kv pairs (%s) at versions already at remote\n",
+						kvSent, d.DataName(), humanize.Bytes(bytesSent), kvTotal, humanize.Bytes(bytesTotal), kvSkipped, humanize.Bytes(bytesSkipped))
 					return
 				}
 				if !ctx.ValidKV(kv, p.Versions) {
+					kvSkipped++
+					bytesSkipped += uint64(len(kv.V) + len(kv.K))
 					continue
 				}
 				kvTotal++
@@ -304,11 +351,17 @@ func PushData(d dvid.Data, p *PushSession) error {
 		}()
 
 		begKey, endKey := ctx.KeyRange()
-		if err = store.RawRangeQuery(begKey, endKey, keysOnly, ch, nil); err != nil {
+		if err = store.RawRangeQuery(begKey, endKey, keysOnly, ch, p.job.CancelChan()); err != nil {
 			return fmt.Errorf("push voxels %q range query: %v", d.DataName(), err)
 		}
+		if p.job.Canceled() {
+			ch <- nil // a canceled range query doesn't terminate the channel.
+		}
 	}
 	wg.Wait()
+	if p.job.Canceled() {
+		return dvid.ErrJobCanceled
+	}
 	return nil
 }
 
@@ -317,9 +370,10 @@ var (
 )
 
 const (
-	sendRepoMsg  = "datastore.sendRepo"
-	StartDataMsg = "datastore.startData"
-	PutKVMsg     = "datastore.putKV"
+	sendRepoMsg     = "datastore.sendRepo"
+	StartDataMsg    = "datastore.startData"
+	PutKVMsg        = "datastore.putKV"
+	pushCompleteMsg = "datastore.pushComplete"
 )
 
 func init() {
@@ -329,6 +383,7 @@ func init() {
 	d.AddFunc(sendRepoMsg, handleSendRepo)
 	d.AddFunc(StartDataMsg, handleStartData)
 	d.AddFunc(PutKVMsg, handlePutKV)
+	d.AddFunc(pushCompleteMsg, handlePushComplete)
 
 	gorpc.RegisterType(&repoTxMsg{})
 	gorpc.RegisterType(&DataTxInit{})
@@ -375,7 +430,12 @@ func handleSendRepo(m *repoTxMsg) (map[dvid.VersionID]struct{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	return p.readRepo(m)
+	versions, err := p.readRepo(m)
+	if err != nil {
+		return nil, err
+	}
+	p.registerPush()
+	return versions, nil
 }
 
 func handleStartData(m *DataTxInit) error {
@@ -394,6 +454,14 @@ func handlePutKV(m *KVMessage) error {
 	return p.putData(m)
 }
 
+func handlePushComplete(s rpc.SessionID) error {
+	p, err := getPusherSession(s)
+	if err != nil {
+		return err
+	}
+	return p.complete()
+}
+
 // --- The following is the server side of a push command ----
 
 // TODO -- If we are actively reading instead of passively taking messages, consider
@@ -408,6 +476,11 @@ type pusher struct {
 	instanceMap dvid.InstanceMap // map from pushed to local instance ids
 	versionMap  dvid.VersionMap  // map from pushed to local version ids
 
+	// pushed ids of data instances and versions in an older local copy of the repo, whose
+	// key-values aren't stored since the committed local versions already hold them.
+	oldData     map[dvid.InstanceID]struct{}
+	oldVersions map[dvid.VersionID]struct{}
+
 	// current stats for data instance transfer
 	dname dvid.InstanceName
 	stats *txStats
@@ -415,6 +488,51 @@ type pusher struct {
 
 	startTime time.Time
 	received  uint64 // bytes received over entire push
+
+	negotiated bool // true if the versions to send were successfully determined
+	completed  bool // true if the sender sent all data and the repo was added
+}
+
+// local instance and version ids of push sessions receiving data, which aren't part of any
+// repo until the push is completed.
+var activePushes struct {
+	sync.RWMutex
+	sessions map[rpc.SessionID]*pusher
+}
+
+func (p *pusher) registerPush() {
+	activePushes.Lock()
+	if activePushes.sessions == nil {
+		activePushes.sessions = make(map[rpc.SessionID]*pusher)
+	}
+	activePushes.sessions[p.sessionID] = p
+	activePushes.Unlock()
+}
+
+func (p *pusher) unregisterPush() {
+	activePushes.Lock()
+	delete(activePushes.sessions, p.sessionID)
+	activePushes.Unlock()
+}
+
+// isPushing returns true if an open push session may be receiving data with the given
+// local instance or version id.
+func isPushing(instanceID dvid.InstanceID, versionID dvid.VersionID) bool {
+	activePushes.RLock()
+	defer activePushes.RUnlock()
+	for _, p := range activePushes.sessions {
+		for _, id := range p.instanceMap {
+			if id == instanceID {
+				return true
+			}
+		}
+		for _, v := range p.versionMap {
+			if v == versionID {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (p *pusher) printStats() {
@@ -440,14 +558,43 @@ func (p *pusher) Open(sid rpc.SessionID) error {
 	return nil
 }
 
+// Close ends the push session.  If the sender never sent the final completion message,
+// e.g., because the push failed or was canceled, the received repo is discarded and any
+// received key-values, stored under ids unknown to this server, are left for garbage
+// collection.
 func (p *pusher) Close() error {
+	if !p.negotiated {
+		dvid.Debugf("Closing push session %d that never received data\n", p.sessionID)
+		return nil
+	}
+	defer p.unregisterPush()
 	gb := float64(p.received) / 1000000000
+	if !p.completed {
+		dvid.Errorf("Discarding incomplete push of uuid %s after receiving %.1f GBytes in %s.  Run garbage collection to remove received data.\n",
+			p.repo.uuid, gb, time.Since(p.startTime))
+		return nil
+	}
 	dvid.Debugf("Closing push of uuid %s: received %.1f GBytes in %s\n", p.repo.uuid, gb, time.Since(p.startTime))
+	return nil
+}
 
-	// Add this repo to current DVID server
-	if err := manager.addRepo(p.repo); err != nil {
+// complete merges the received repo into any older copy of the repo or adds it to
+// this server after the sender has sent all data.
+func (p *pusher) complete() error {
+	if !p.negotiated {
+		return fmt.Errorf("push session %d completed without negotiating versions", p.sessionID)
+	}
+	if p.completed {
+		return nil
+	}
+	if local, err := manager.repoFromUUID(p.repo.uuid); err == nil {
+		if err := manager.mergeRepo(local, p.repo); err != nil {
+			return err
+		}
+	} else if err := manager.addRepo(p.repo); err != nil {
 		return err
 	}
+	p.completed = true
 	return nil
 }
 
@@ -465,22 +612,51 @@ func (p *pusher) readRepo(m *repoTxMsg) (map[dvid.VersionID]struct{}, error) {
 		return nil, err
 	}
 
-	// TODO: Check to see if the transmitted repo is in current metadata.
-
 	p.uuid = m.UUID
 	remoteV, err := p.repo.versionFromUUID(m.UUID) // do this before we remap the repo's IDs
 	if err != nil {
 		return nil, err
 	}
-	repoID, err := manager.newRepoID()
-	if err != nil {
-		return nil, err
-	}
-	p.repo.id = repoID
 
-	p.instanceMap, p.versionMap, err = p.repo.remapLocalIDs()
-	if err != nil {
-		return nil, err
+	// If we have an older copy of the repo, only request the versions we don't have unless
+	// the push adds data instances, e.g., ones left out of an earlier push, which need all
+	// their versions.  Instances already in the local copy only store missing versions.
+	var versions map[dvid.VersionID]struct{}
+	local, err := manager.repoFromUUID(p.repo.uuid)
+	if err == nil {
+		if m.Transmit != rpc.TransmitAll {
+			return nil, fmt.Errorf("repo %s already exists locally so only transmit 'all' can be used", p.repo.uuid)
+		}
+		var missing map[dvid.VersionID]struct{}
+		p.instanceMap, p.versionMap, missing, p.oldData, err = p.repo.remapToRepo(local)
+		if err != nil {
+			return nil, err
+		}
+		p.oldVersions = make(map[dvid.VersionID]struct{}, len(p.versionMap))
+		for v := range p.versionMap {
+			if _, found := missing[v]; !found {
+				p.oldVersions[v] = struct{}{}
+			}
+		}
+		versions = missing
+		if len(p.oldData) < len(p.repo.data) {
+			versions = make(map[dvid.VersionID]struct{}, len(p.versionMap))
+			for v := range p.versionMap {
+				versions[v] = struct{}{}
+			}
+		}
+		dvid.Infof("Repo %s exists locally: requesting %d of %d versions\n", p.repo.uuid, len(versions), len(p.versionMap))
+	} else {
+		repoID, err := manager.newRepoID()
+		if err != nil {
+			return nil, err
+		}
+		p.repo.id = repoID
+
+		p.instanceMap, p.versionMap, err = p.repo.remapLocalIDs()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// After getting remote repo, adjust data instances for local settings.
@@ -492,19 +668,15 @@ func (p *pusher) readRepo(m *repoTxMsg) (map[dvid.VersionID]struct{}, error) {
 				return nil, err
 			}
 		}
-
-		// check if we have an assigned store for this data instance.
-		store, err := storage.GetAssignedStore(d.DataName(), d.RootUUID(), d.Tags(), d.TypeName())
-		if err != nil {
-			return nil, err
-		}
-		d.SetKVStore(store)
-		dvid.Debugf("Assigning as default store of data instance %q @ %s: %s\n", d.DataName(), d.RootUUID(), store)
+	}
+	if err := p.repo.assignStores(); err != nil {
+		return nil, err
 	}
 
-	var versions map[dvid.VersionID]struct{}
-	switch m.Transmit {
-	case rpc.TransmitFlatten:
+	switch {
+	case versions != nil:
+		// already determined from local copy of repo.
+	case m.Transmit == rpc.TransmitFlatten:
 		versions = map[dvid.VersionID]struct{}{
 			remoteV: struct{}{},
 		}
@@ -516,38 +688,61 @@ func (p *pusher) readRepo(m *repoTxMsg) (map[dvid.VersionID]struct{}, error) {
 				p.repo.data[name].SetRootUUID(m.UUID)
 			}
 		}
-	case rpc.TransmitAll:
-		versions, err = getDeltaAll(p.repo, m.UUID)
+	case m.Transmit == rpc.TransmitAll:
+		versions, err = getDeltaAll(p.repo, p.versionMap)
 		if err != nil {
 			return nil, err
 		}
-	case rpc.TransmitBranch:
+	case m.Transmit == rpc.TransmitBranch:
 		versions, err = getDeltaBranch(p.repo, m.UUID)
 		if err != nil {
 			return nil, err
 		}
 	}
-	if versions == nil {
+	if len(versions) == 0 {
 		return nil, fmt.Errorf("no push required -- remote has necessary versions")
 	}
+	p.negotiated = true
 	dvid.Debugf("Finished comparing repos -- requesting %d versions from source.\n", len(versions))
 	return versions, nil
 }
 
+// assignStores sets the store of each data instance in a received repo using any
+// store assignments in this server's configuration.
+func (r *repoT) assignStores() error {
+	for _, d := range r.data {
+		store, err := storage.GetAssignedStore(d.DataName(), d.RootUUID(), d.Tags(), d.TypeName())
+		if err != nil {
+			return err
+		}
+		d.SetKVStore(store)
+		dvid.Debugf("Assigning as default store of data instance %q @ %s: %s\n", d.DataName(), d.RootUUID(), store)
+	}
+	return nil
+}
+
 // compares remote Repo with local one, determining a list of versions that
-// need to be sent from remote to bring the local DVID up-to-date.
-func getDeltaAll(remote *repoT, uuid dvid.UUID) (map[dvid.VersionID]struct{}, error) {
+// need to be sent from remote to bring the local DVID up-to-date.  The returned
+// version ids are those of the remote server, found by reversing the version map
+// used to remap the received repo.
+func getDeltaAll(remote *repoT, versionMap dvid.VersionMap) (map[dvid.VersionID]struct{}, error) {
 	// Determine all version ids of remote DAG nodes that aren't in the local DAG.
 	// Since VersionID can differ among DVID servers, we need to compare using UUIDs
 	// then convert to VersionID.
 	delta := make(map[dvid.VersionID]struct{})
-	for _, rnode := range remote.dag.nodes {
-		lv, found := manager.uuidToVersion[rnode.uuid]
+	for remoteV, localV := range versionMap {
+		rnode, found := remote.dag.nodes[localV]
+		if !found {
+			continue
+		}
+		manager.idMutex.RLock()
+		_, found = manager.uuidToVersion[rnode.uuid]
+		manager.idMutex.RUnlock()
 		if found {
 			dvid.Debugf("Both remote and local have uuid %s... skipping\n", rnode.uuid)
 		} else {
-			dvid.Debugf("Found version %s in remote not in local: sending local version id %d\n", rnode.uuid, lv)
-			delta[lv] = struct{}{}
+			dvid.Debugf("Found version %s in remote not in local: sending remote version id %d\n", rnode.uuid, remoteV)
+			delta[remoteV] = struct{}{}
 		}
 	}
 	return delta, nil
@@ -595,6 +790,13 @@ func (p *pusher) putData(kvmsg *KVMessage) error {
 		return err
 	}
 
+	// Skip key-values already held by committed versions of the local copy.
+	if _, found := p.oldData[oldInstance]; found {
+		if _, found := p.oldVersions[oldVersion]; found {
+			return nil
+		}
+	}
+
 	// Modify the transmitted key-value to have local instance and version ids.
 	newInstanceID, found := p.instanceMap[oldInstance]
 	if !found {
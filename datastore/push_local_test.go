@@ -0,0 +1,237 @@
+// +build !clustered,!gcloud
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/rpc"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+func TestIncrementalPushVersions(t *testing.T) {
+	OpenTest()
+	defer CloseTest()
+
+	root, _ := NewTestRepo()
+	if err := Commit(root, "root", nil); err != nil {
+		t.Fatalf("unable to commit root: %v\n", err)
+	}
+	child1, err := NewVersion(root, "child1", "", nil)
+	if err != nil {
+		t.Fatalf("unable to create child1: %v\n", err)
+	}
+	if err := Commit(child1, "child1", nil); err != nil {
+		t.Fatalf("unable to commit child1: %v\n", err)
+	}
+	child2, err := NewVersion(child1, "child2", "", nil)
+	if err != nil {
+		t.Fatalf("unable to create child2: %v\n", err)
+	}
+	child2V, err := VersionFromUUID(child2)
+	if err != nil {
+		t.Fatalf("can't get version of child2: %v\n", err)
+	}
+
+	// Simulate a source that has a version the local repo lacks.
+	config := dvid.NewConfig()
+	txRepo, _, err := customizedRepo(root, config)
+	if err != nil {
+		t.Fatalf("unable to customize repo: %v\n", err)
+	}
+	repoSerialization, err := txRepo.GobEncode()
+	if err != nil {
+		t.Fatalf("unable to encode repo: %v\n", err)
+	}
+	if err := DeleteVersion(child2, "", true); err != nil {
+		t.Fatalf("unable to delete child2: %v\n", err)
+	}
+	if _, err := VersionFromUUID(child2); err == nil {
+		t.Fatalf("expected child2 to be deleted\n")
+	}
+
+	// A push that isn't completed by the sender is discarded.
+	p := new(pusher)
+	if _, err := p.readRepo(&repoTxMsg{Transmit: rpc.TransmitAll, UUID: root, Repo: repoSerialization}); err != nil {
+		t.Fatalf("unable to negotiate incremental push: %v\n", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("unable to close incomplete push: %v\n", err)
+	}
+	if _, err := VersionFromUUID(child2); err == nil {
+		t.Fatalf("expected incomplete push to be discarded\n")
+	}
+
+	p = new(pusher)
+	versions, err := p.readRepo(&repoTxMsg{Transmit: rpc.TransmitAll, UUID: root, Repo: repoSerialization})
+	if err != nil {
+		t.Fatalf("unable to negotiate incremental push: %v\n", err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("expected only 1 version requested, got %v\n", versions)
+	}
+	if _, found := versions[child2V]; !found {
+		t.Fatalf("expected child2 source version %d requested, got %v\n", child2V, versions)
+	}
+	if err := p.complete(); err != nil {
+		t.Fatalf("unable to merge pushed repo: %v\n", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("unable to close push: %v\n", err)
+	}
+
+	v, err := VersionFromUUID(child2)
+	if err != nil {
+		t.Fatalf("expected child2 after incremental push: %v\n", err)
+	}
+	child1V, err := VersionFromUUID(child1)
+	if err != nil {
+		t.Fatalf("can't get version of child1: %v\n", err)
+	}
+	children, err := GetChildrenByVersion(child1V)
+	if err != nil {
+		t.Fatalf("can't get children of child1: %v\n", err)
+	}
+	if len(children) != 1 || children[0] != v {
+		t.Errorf("expected child1 to have pushed child2 (%d), got %v\n", v, children)
+	}
+	pushedRoot, err := GetRepoRoot(child2)
+	if err != nil || pushedRoot != root {
+		t.Errorf("expected child2 in repo with root %s, got %s: %v\n", root, pushedRoot, err)
+	}
+
+	// Pushes are refused if they would overwrite a locally uncommitted node.
+	p = new(pusher)
+	if _, err = p.readRepo(&repoTxMsg{Transmit: rpc.TransmitAll, UUID: root, Repo: repoSerialization}); err == nil {
+		t.Errorf("expected push to be refused when child2 is uncommitted locally\n")
+	}
+}
+
+func TestPushAfterFilteredPush(t *testing.T) {
+	OpenTest()
+	defer CloseTest()
+
+	root, rootV := NewTestRepo()
+	tkey := storage.NewTKey(1, []byte("key"))
+	var pushedKV []storage.KeyValue
+	for _, name := range []dvid.InstanceName{"a", "b"} {
+		d, err := NewData(root, &TestType{}, name, dvid.NewConfig())
+		if err != nil {
+			t.Fatalf("unable to create data %q: %v\n", name, err)
+		}
+		db, err := GetKeyValueDB(d)
+		if err != nil {
+			t.Fatalf("can't get store for data %q: %v\n", name, err)
+		}
+		ctx := NewVersionedCtx(d, rootV)
+		if err := db.Put(ctx, tkey, []byte(name)); err != nil {
+			t.Fatalf("unable to put data %q: %v\n", name, err)
+		}
+		pushedKV = append(pushedKV, storage.KeyValue{K: ctx.ConstructKey(tkey), V: []byte("pushed " + name)})
+	}
+	if err := Commit(root, "root", nil); err != nil {
+		t.Fatalf("unable to commit root: %v\n", err)
+	}
+	child, err := NewVersion(root, "child", "", nil)
+	if err != nil {
+		t.Fatalf("unable to create child: %v\n", err)
+	}
+
+	// Simulate a source with a version and a data instance the local repo lacks.
+	config := dvid.NewConfig()
+	config.Set("data", "a")
+	txRepo, _, err := customizedRepo(root, config)
+	if err != nil {
+		t.Fatalf("unable to customize repo: %v\n", err)
+	}
+	filtered, err := txRepo.GobEncode()
+	if err != nil {
+		t.Fatalf("unable to encode repo: %v\n", err)
+	}
+	if txRepo, _, err = customizedRepo(root, dvid.NewConfig()); err != nil {
+		t.Fatalf("unable to customize repo: %v\n", err)
+	}
+	full, err := txRepo.GobEncode()
+	if err != nil {
+		t.Fatalf("unable to encode repo: %v\n", err)
+	}
+	if err := DeleteVersion(child, "", true); err != nil {
+		t.Fatalf("unable to delete child: %v\n", err)
+	}
+	local, err := manager.repoFromUUID(root)
+	if err != nil {
+		t.Fatalf("can't get local repo: %v\n", err)
+	}
+	local.Lock()
+	delete(local.data, "b")
+	local.Unlock()
+
+	// The push filtered to "a" only needs the new child version.
+	p := new(pusher)
+	versions, err := p.readRepo(&repoTxMsg{Transmit: rpc.TransmitAll, UUID: root, Repo: filtered})
+	if err != nil {
+		t.Fatalf("unable to negotiate filtered push: %v\n", err)
+	}
+	if _, found := versions[rootV]; found || len(versions) != 1 {
+		t.Fatalf("expected only child version requested, got %v\n", versions)
+	}
+	if err := p.complete(); err != nil {
+		t.Fatalf("unable to merge filtered push: %v\n", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("unable to close filtered push: %v\n", err)
+	}
+	if err := Commit(child, "child", nil); err != nil {
+		t.Fatalf("unable to commit child: %v\n", err)
+	}
+
+	// A later unfiltered push adds "b", which needs the versions the local repo already has.
+	p = new(pusher)
+	versions, err = p.readRepo(&repoTxMsg{Transmit: rpc.TransmitAll, UUID: root, Repo: full})
+	if err != nil {
+		t.Fatalf("unable to negotiate unfiltered push: %v\n", err)
+	}
+	if _, found := versions[rootV]; !found {
+		t.Fatalf("expected root version %d requested for new data, got %v\n", rootV, versions)
+	}
+	for i, name := range []dvid.InstanceName{"a", "b"} {
+		if err := p.startData(&DataTxInit{DataName: name}); err != nil {
+			t.Fatalf("unable to start push of data %q: %v\n", name, err)
+		}
+		if err := p.putData(&KVMessage{KV: pushedKV[i]}); err != nil {
+			t.Fatalf("unable to push data %q: %v\n", name, err)
+		}
+	}
+	if err := p.complete(); err != nil {
+		t.Fatalf("unable to merge unfiltered push: %v\n", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("unable to close unfiltered push: %v\n", err)
+	}
+
+	// The new instance gets its old data while existing data isn't overwritten.
+	for _, test := range []struct {
+		name  dvid.InstanceName
+		value string
+	}{
+		{"a", "a"},
+		{"b", "pushed b"},
+	} {
+		d, err := GetDataByUUIDName(root, test.name)
+		if err != nil {
+			t.Fatalf("can't get data %q after push: %v\n", test.name, err)
+		}
+		db, err := GetKeyValueDB(d)
+		if err != nil {
+			t.Fatalf("can't get store for data %q: %v\n", test.name, err)
+		}
+		value, err := db.Get(NewVersionedCtx(d, rootV), tkey)
+		if err != nil {
+			t.Fatalf("can't get data %q at root: %v\n", test.name, err)
+		}
+		if string(value) != test.value {
+			t.Errorf("expected data %q at root to be %q, got %q\n", test.name, test.value, value)
+		}
+	}
+}
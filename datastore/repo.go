@@ -44,5 +44,6 @@ var (
 
 	ErrModifyLockedNode   = errors.New("can't modify locked node")
 	ErrBranchUnlockedNode = errors.New("can't branch an unlocked node")
+	ErrBranchSquashNode   = errors.New("can't branch a node that is being squashed")
 	ErrBranchUnique       = errors.New("branch already exists with given name")
 )
@@ -21,6 +21,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -54,6 +56,8 @@ const (
 	formatKey
 	ServerLockKey // name of key for locking metadata globally
 	mutidKey
+	gcCheckpointKey
+	pullCheckpointKey
 )
 
 // Config specifies new instance and mutation ID generation
@@ -131,6 +135,11 @@ func Initialize(initMetadata bool, iconfig Config) error {
 	// Set the package variable.  We are good to go...
 	manager = m
 
+	// Resume any interrupted garbage collection.
+	if !initMetadata {
+		go m.resumeGC()
+	}
+
 	// Allow data instance to initialize if desired.
 	for _, data := range m.iids {
 		if data.IsDeleted() {
@@ -269,6 +278,9 @@ type repoManager struct {
 
 	// Verified metadata storage for ease of use.
 	store storage.OrderedKeyValueDB
+
+	// Serializes tagging so tags stay unique across all repos.
+	tagMutex sync.Mutex
 }
 
 func (m *repoManager) Shutdown() {
@@ -843,8 +855,12 @@ func (m *repoManager) versionFromUUID(uuid dvid.UUID) (dvid.VersionID, error) {
 // string. Partial matches are accepted as long as they are unique for a datastore.  So if
 // a datastore has nodes with UUID strings 3FA22..., 7CD11..., and 836EE...,
 // we can still find a match even if given the minimum 3 letters.  (We don't
-// allow UUID strings of less than 3 letters just to prevent mistakes.)
+// allow UUID strings of less than 3 letters just to prevent mistakes.)  A string of the
+// form ":tagname" matches the node with that tag.
 func (m *repoManager) matchingUUID(str string) (dvid.UUID, dvid.VersionID, error) {
+	if strings.HasPrefix(str, ":") {
+		return m.uuidFromTag(str[1:])
+	}
 	var bestVersion dvid.VersionID
 	var bestUUID dvid.UUID
 	numMatches := 0
@@ -1318,6 +1334,90 @@ func (m *repoManager) setNodeNote(uuid dvid.UUID, note string) error {
 	return r.save()
 }
 
+// validTag matches tag names, which are restricted so they can be used within URLs.
+var validTag = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// tagNode adds an immutable tag to a committed node.  Tags are unique across all repos.
+func (m *repoManager) tagNode(uuid dvid.UUID, tag string) error {
+	if !validTag.MatchString(tag) {
+		return fmt.Errorf("tag %q must start with a letter or digit and only have letters, digits, '.', '_', or '-'", tag)
+	}
+	r, err := m.repoFromUUID(uuid)
+	if err != nil {
+		return err
+	}
+	v, err := m.versionFromUUID(uuid)
+	if err != nil {
+		return err
+	}
+
+	m.tagMutex.Lock()
+	defer m.tagMutex.Unlock()
+	if tagged, _, err := m.uuidFromTag(tag); err == nil {
+		return fmt.Errorf("tag %q is already used for node %s", tag, tagged)
+	}
+
+	r.RLock()
+	node, found := r.dag.nodes[v]
+	r.RUnlock()
+	if !found {
+		return ErrInvalidVersion
+	}
+
+	node.Lock()
+	if !node.locked {
+		node.Unlock()
+		return fmt.Errorf("node %s must be committed before it can be tagged", uuid)
+	}
+	node.tags = append(node.tags, tag)
+	t := time.Now()
+	r.Lock()
+	r.updated, node.updated = t, t
+	r.Unlock()
+	node.Unlock()
+	return r.save()
+}
+
+// uuidFromTag returns the UUID and version ID of the node with the given tag.
+func (m *repoManager) uuidFromTag(tag string) (dvid.UUID, dvid.VersionID, error) {
+	m.repoMutex.RLock()
+	repos := make(map[*repoT]struct{})
+	for _, r := range m.repos {
+		repos[r] = struct{}{}
+	}
+	m.repoMutex.RUnlock()
+
+	var nodes []*nodeT
+	for r := range repos {
+		r.RLock()
+		for _, node := range r.dag.nodes {
+			nodes = append(nodes, node)
+		}
+		r.RUnlock()
+	}
+	var uuid dvid.UUID
+	var v dvid.VersionID
+	var numMatches int
+	for _, node := range nodes {
+		node.RLock()
+		for _, nodeTag := range node.tags {
+			if nodeTag == tag {
+				uuid, v = node.uuid, node.version
+				numMatches++
+			}
+		}
+		node.RUnlock()
+	}
+	switch numMatches {
+	case 0:
+		return dvid.NilUUID, 0, fmt.Errorf("could not find node with tag %q", tag)
+	case 1:
+		return uuid, v, nil
+	default:
+		return dvid.NilUUID, 0, fmt.Errorf("more than one node has tag %q", tag)
+	}
+}
+
 func (m *repoManager) getNodeLog(uuid dvid.UUID) ([]string, error) {
 	r, err := m.repoFromUUID(uuid)
 	if err != nil {
@@ -1503,6 +1603,9 @@ func (m *repoManager) newVersion(parent dvid.UUID, note string, branchname strin
 	if !node.locked {
 		return dvid.NilUUID, ErrBranchUnlockedNode
 	}
+	if node.squashing {
+		return dvid.NilUUID, ErrBranchSquashNode
+	}
 
 	// check to make sure there are not already
 	// children with the same branch
@@ -1572,7 +1675,135 @@ func (m *repoManager) newVersion(parent dvid.UUID, note string, branchname strin
 	return child.uuid, r.save()
 }
 
-func (m *repoManager) merge(parents []dvid.UUID, note string, mt MergeType) (dvid.UUID, error) {
+// deleteVersion removes an uncommitted leaf node from the DAG, notifies data instances of the
+// removal, and deletes the version's key-values.  Any key-values that can't be deleted are
+// left for garbage collection since the version no longer exists.
+func (m *repoManager) deleteVersion(uuid dvid.UUID, passcode string, admin bool) error {
+	r, err := m.repoFromUUID(uuid)
+	if err != nil {
+		return err
+	}
+	v, err := m.versionFromUUID(uuid)
+	if err != nil {
+		return err
+	}
+	r.RLock()
+	if !admin && r.passcode != "" && r.passcode != passcode {
+		r.RUnlock()
+		return fmt.Errorf("Passcode does not match repo %s passcode", r.uuid)
+	}
+	node, found := r.dag.nodes[v]
+	r.RUnlock()
+	if !found {
+		return ErrInvalidVersion
+	}
+
+	// Hold the node lock so it can't be committed or branched during removal.
+	node.Lock()
+	if node.locked {
+		node.Unlock()
+		return fmt.Errorf("can't delete committed node %s", uuid)
+	}
+	if len(node.children) != 0 {
+		node.Unlock()
+		return fmt.Errorf("can't delete node %s with %d children", uuid, len(node.children))
+	}
+	if len(node.parents) == 0 {
+		node.Unlock()
+		return fmt.Errorf("can't delete root node %s; delete the repo instead", uuid)
+	}
+	m.unlinkVersion(r, node)
+	node.Unlock()
+	return m.deleteVersionData(r, uuid, v)
+}
+
+// unlinkVersion removes a node from its parents' children and from the DAG.
+// The caller must hold the node's lock.
+func (m *repoManager) unlinkVersion(r *repoT, node *nodeT) {
+	r.RLock()
+	parents := make([]*nodeT, 0, len(node.parents))
+	for _, parentV := range node.parents {
+		if parent, found := r.dag.nodes[parentV]; found {
+			parents = append(parents, parent)
+		}
+	}
+	r.RUnlock()
+	t := time.Now()
+	for _, parent := range parents {
+		parent.Lock()
+		var children []dvid.VersionID
+		for _, childV := range parent.children {
+			if childV != node.version {
+				children = append(children, childV)
+			}
+		}
+		parent.children = children
+		parent.updated = t
+		parent.Unlock()
+	}
+	r.Lock()
+	delete(r.dag.nodes, node.version)
+	r.updated = t
+	r.Unlock()
+}
+
+// deleteVersionData removes the ids of an unlinked version, saves the repo, and
+// deletes all key-values written in that version.
+func (m *repoManager) deleteVersionData(r *repoT, uuid dvid.UUID, v dvid.VersionID) error {
+	m.repoMutex.Lock()
+	delete(m.repos, uuid)
+	m.repoMutex.Unlock()
+	m.idMutex.Lock()
+	delete(m.uuidToVersion, uuid)
+	delete(m.versionToUUID, v)
+	m.idMutex.Unlock()
+	if err := m.putCaches(); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+
+	// Notify data instances and delete key-values of the removed version.
+	var dataservices []DataService
+	r.RLock()
+	for _, dataservice := range r.data {
+		dataservices = append(dataservices, dataservice)
+	}
+	r.RUnlock()
+	for _, dataservice := range dataservices {
+		if remover, ok := dataservice.(VersionRemover); ok {
+			if err := remover.RemoveVersion(uuid, v); err != nil {
+				return err
+			}
+		}
+		if !dataservice.Versioned() {
+			continue
+		}
+		store, err := GetOrderedKeyValueDB(dataservice)
+		if err != nil {
+			return fmt.Errorf("unable to delete version %s of data %q: %v", uuid, dataservice.DataName(), err)
+		}
+		if err := store.DeleteAll(NewVersionedCtx(dataservice, v), false); err != nil {
+			return fmt.Errorf("unable to delete version %s of data %q: %v", uuid, dataservice.DataName(), err)
+		}
+	}
+	dvid.Infof("Deleted node %s (version %d) of repo %s\n", uuid, v, r.uuid)
+	return nil
+}
+
+// abortMerge removes a partially constructed merge child and any key-values already
+// written into it so a failed merge leaves no trace in the DAG.
+func (m *repoManager) abortMerge(r *repoT, child *nodeT) {
+	child.Lock()
+	m.unlinkVersion(r, child)
+	child.Unlock()
+	if err := m.deleteVersionData(r, child.uuid, child.version); err != nil {
+		dvid.Errorf("unable to remove node %s after failed merge: %v\n", child.uuid, err)
+	}
+}
+
+func (m *repoManager) merge(parents []dvid.UUID, note string, mt MergeType, resolutions []MergeResolution) (dvid.UUID, error) {
 	if len(parents) < 2 {
 		return dvid.NilUUID, ErrInvalidUUID
 	}
@@ -1585,6 +1816,59 @@ func (m *repoManager) merge(parents []dvid.UUID, note string, mt MergeType) (dvi
 	}
 	m.repoMutex.RUnlock()
 
+	// For type-specific and external data merges, find all conflicts before creating
+	// the child so we can fail cleanly if any conflict can't be resolved.
+	var parentsV []dvid.VersionID
+	var conflicts map[dvid.InstanceName][]MergeConflict
+	var resolved map[dvid.InstanceName][]resolvedKV
+	if mt == MergeTypeSpecificAuto || mt == MergeExternalData {
+		parentsV = make([]dvid.VersionID, len(parents))
+		for i, parent := range parents {
+			v, err := m.versionFromUUID(parent)
+			if err != nil {
+				return dvid.NilUUID, err
+			}
+			locked, err := m.lockedVersion(v)
+			if err != nil {
+				return dvid.NilUUID, err
+			}
+			if !locked {
+				return dvid.NilUUID, ErrBranchUnlockedNode
+			}
+			parentsV[i] = v
+		}
+		var err error
+		if conflicts, err = m.mergeConflictsByInstance(r, parentsV, true); err != nil {
+			return dvid.NilUUID, err
+		}
+	}
+	switch mt {
+	case MergeExternalData:
+		if len(resolutions) == 0 {
+			return dvid.NilUUID, fmt.Errorf("merging with external data requires resolutions")
+		}
+		var err error
+		if resolved, err = m.resolveExternal(r, parents, conflicts, resolutions); err != nil {
+			return dvid.NilUUID, err
+		}
+	case MergeTypeSpecificAuto:
+		var unresolved []string
+		r.RLock()
+		for name, instanceConflicts := range conflicts {
+			dataservice := r.data[name]
+			if _, ok := dataservice.(Merger); !ok {
+				unresolved = append(unresolved, fmt.Sprintf("data %q (type %s) has %d conflicting keys: %s",
+					name, dataservice.TypeName(), len(instanceConflicts), describeConflicts(dataservice, instanceConflicts)))
+			}
+		}
+		r.RUnlock()
+		if len(unresolved) != 0 {
+			sort.Strings(unresolved)
+			return dvid.NilUUID, fmt.Errorf("unable to auto merge since data instances do not support type-specific merging: %s",
+				strings.Join(unresolved, "; "))
+		}
+	}
+
 	// Add the child node.  Since it's new and unavailable, no need to lock it.
 	childUUID, childV, err := m.newUUID(nil)
 	if err != nil {
@@ -1601,23 +1885,43 @@ func (m *repoManager) merge(parents []dvid.UUID, note string, mt MergeType) (dvi
 	r.dag.nodes[childV] = child
 	r.Unlock()
 
+	// From here on, any failure removes the child and whatever was written into it
+	// so the merge is all-or-nothing.
+	if err := m.mergeIntoChild(r, child, parents, parentsV, mt, conflicts, resolved); err != nil {
+		m.abortMerge(r, child)
+		return dvid.NilUUID, err
+	}
+	return child.uuid, nil
+}
+
+// mergeIntoChild links a newly created merge child to its parents and writes any
+// resolved key-values into it.
+func (m *repoManager) mergeIntoChild(r *repoT, child *nodeT, parents []dvid.UUID, parentsV []dvid.VersionID,
+	mt MergeType, conflicts map[dvid.InstanceName][]MergeConflict, resolved map[dvid.InstanceName][]resolvedKV) error {
+
+	childUUID, childV := child.uuid, child.version
+
 	// Set up pointers with parents
 	for _, parent := range parents {
 		v, err := m.versionFromUUID(parent)
 		if err != nil {
-			return dvid.NilUUID, err
+			return err
 		}
 		r.RLock()
 		node, found := r.dag.nodes[v]
 		r.RUnlock()
 		if !found {
-			return dvid.NilUUID, ErrInvalidVersion
+			return ErrInvalidVersion
 		}
 
 		node.Lock()
 		if !node.locked {
 			node.Unlock()
-			return dvid.NilUUID, ErrBranchUnlockedNode
+			return ErrBranchUnlockedNode
+		}
+		if node.squashing {
+			node.Unlock()
+			return ErrBranchSquashNode
 		}
 
 		// Add this parent node
@@ -1634,7 +1938,7 @@ func (m *repoManager) merge(parents []dvid.UUID, note string, mt MergeType) (dvi
 		if ok {
 			if err := initializer.InitVersion(childUUID, childV); err != nil {
 				r.RUnlock()
-				return dvid.NilUUID, err
+				return err
 			}
 		}
 	}
@@ -1651,20 +1955,39 @@ func (m *repoManager) merge(parents []dvid.UUID, note string, mt MergeType) (dvi
 		// Any issues will be noted during key-value lookup while traversing the DAG.
 
 	case MergeTypeSpecificAuto:
-		return dvid.NilUUID, fmt.Errorf("the type-specific auto merge has not been implemented yet")
-		// go r.asyncMerge(parentNode1, parentNode2, child)
+		// Let each data instance resolve its conflicting key-values into the child.
+		for name, instanceConflicts := range conflicts {
+			r.RLock()
+			dataservice := r.data[name]
+			r.RUnlock()
+			merger := dataservice.(Merger)
+			ctx := NewVersionedCtx(dataservice, childV)
+			if err := merger.MergeConflicts(ctx, parentsV, instanceConflicts); err != nil {
+				return fmt.Errorf("error merging %d conflicting keys for data %q: %v", len(instanceConflicts), name, err)
+			}
+			dvid.Infof("Resolved %d conflicting keys for data %q in merge to %s\n", len(instanceConflicts), name, childUUID)
+		}
 
 	case MergeExternalData:
-		return dvid.NilUUID, fmt.Errorf("merging with external data has not been implemented yet")
+		// Write the resolved key-values for each data instance into the child.
+		for name, kvs := range resolved {
+			r.RLock()
+			dataservice := r.data[name]
+			r.RUnlock()
+			if err := writeResolved(dataservice, childV, kvs); err != nil {
+				return fmt.Errorf("error writing %d resolved keys for data %q: %v", len(kvs), name, err)
+			}
+			dvid.Infof("Wrote %d resolved keys for data %q in merge to %s\n", len(kvs), name, childUUID)
+		}
 
 	default:
-		return dvid.NilUUID, ErrBadMergeType
+		return ErrBadMergeType
 	}
 
 	r.Lock()
 	r.updated = time.Now()
 	r.Unlock()
-	return child.uuid, r.save()
+	return r.save()
 }
 
 func (m *repoManager) invalidateAncestors(kvv kvVersions, v dvid.VersionID) error {
@@ -2292,6 +2615,14 @@ func (r *repoT) GobEncode() ([]byte, error) {
 
 func (r *repoT) MarshalJSON() (b []byte, err error) {
 	r.RLock()
+	tags := make(map[string]dvid.UUID)
+	for _, node := range r.dag.nodes {
+		node.RLock()
+		for _, tag := range node.tags {
+			tags[tag] = node.uuid
+		}
+		node.RUnlock()
+	}
 	b, err = json.Marshal(struct {
 		Root            dvid.UUID
 		Alias           string
@@ -2299,6 +2630,7 @@ func (r *repoT) MarshalJSON() (b []byte, err error) {
 		Log             []string
 		Properties      map[string]interface{}
 		Data            map[dvid.InstanceName]DataService `json:"DataInstances"`
+		Tags            map[string]dvid.UUID
 		DAG             *dagT
 		MutationID      uint64
 		SavedMutationID uint64
@@ -2311,6 +2643,7 @@ func (r *repoT) MarshalJSON() (b []byte, err error) {
 		r.log,
 		r.properties,
 		r.data,
+		tags,
 		r.dag,
 		r.mutCurID,
 		r.mutSavedID,
@@ -2506,6 +2839,165 @@ func (r *repoT) remapLocalIDs() (dvid.InstanceMap, dvid.VersionMap, error) {
 	return instanceMap, versionMap, nil
 }
 
+// remapToRepo converts a transmitted repo's local ids to this DVID server's local ids,
+// reusing the ids of version nodes and data instances already in a local copy of the repo.
+// It also returns the transmitted versions missing from the local copy and the transmitted
+// ids of data instances already in the local copy.  Since received key-values would
+// overwrite local changes, an error is returned if any transmitted version is uncommitted
+// in the local copy.
+func (r *repoT) remapToRepo(local *repoT) (instanceMap dvid.InstanceMap, versionMap dvid.VersionMap,
+	missing map[dvid.VersionID]struct{}, existing map[dvid.InstanceID]struct{}, err error) {
+	if manager == nil {
+		return nil, nil, nil, nil, ErrManagerNotInitialized
+	}
+	local.RLock()
+	localData := make(map[dvid.InstanceName]DataService, len(local.data))
+	for name, dataservice := range local.data {
+		localData[name] = dataservice
+	}
+	localNodes := make(map[dvid.VersionID]*nodeT, len(local.dag.nodes))
+	for v, node := range local.dag.nodes {
+		localNodes[v] = node
+	}
+	local.RUnlock()
+
+	r.Lock()
+	defer r.Unlock()
+
+	instanceMap = make(dvid.InstanceMap, len(r.data))
+	existing = make(map[dvid.InstanceID]struct{}, len(r.data))
+	for dataname, dataservice := range r.data {
+		var instanceID dvid.InstanceID
+		if localD, found := localData[dataname]; found {
+			if localD.DataUUID() != dataservice.DataUUID() {
+				return nil, nil, nil, nil, fmt.Errorf("local data %q is not the same instance as the transmitted one", dataname)
+			}
+			instanceID = localD.InstanceID()
+			existing[dataservice.InstanceID()] = struct{}{}
+		} else {
+			if instanceID, err = manager.newInstanceID(); err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+		instanceMap[dataservice.InstanceID()] = instanceID
+		r.data[dataname].SetInstanceID(instanceID)
+	}
+
+	newNodes := make(map[dvid.VersionID]*nodeT, len(r.dag.nodes))
+	versionMap = make(dvid.VersionMap, len(r.dag.nodes))
+	missing = make(map[dvid.VersionID]struct{})
+	for oldVersionID, nodePtr := range r.dag.nodes {
+		manager.idMutex.RLock()
+		localV, found := manager.uuidToVersion[nodePtr.uuid]
+		manager.idMutex.RUnlock()
+		if found {
+			localNode, inRepo := localNodes[localV]
+			if !inRepo {
+				return nil, nil, nil, nil, fmt.Errorf("UUID %s is in a different local repo", nodePtr.uuid)
+			}
+			localNode.RLock()
+			locked := localNode.locked
+			localNode.RUnlock()
+			if !locked {
+				return nil, nil, nil, nil, fmt.Errorf("node %s is uncommitted locally so pushed data could overwrite it: commit or delete the local node first", nodePtr.uuid)
+			}
+		} else {
+			if localV, err = manager.newVersionID(nodePtr.uuid, false); err != nil {
+				return nil, nil, nil, nil, err
+			}
+			missing[oldVersionID] = struct{}{}
+		}
+		versionMap[oldVersionID] = localV
+		newNodes[localV] = nodePtr
+	}
+	for _, nodePtr := range r.dag.nodes {
+		nodePtr.version = versionMap[nodePtr.version]
+		for i, oldVersionID := range nodePtr.parents {
+			nodePtr.parents[i] = versionMap[oldVersionID]
+		}
+		for i, oldVersionID := range nodePtr.children {
+			nodePtr.children[i] = versionMap[oldVersionID]
+		}
+	}
+	r.dag.nodes = newNodes
+	r.id = local.id
+	return instanceMap, versionMap, missing, existing, nil
+}
+
+// mergeRepo adds the version nodes and data instances of a transmitted repo, already
+// remapped by remapToRepo, that are missing from the local copy of the repo.  Nodes in
+// the local copy get any new children and the committed state of the transmitted nodes.
+// Properties of data instances already in the local copy are kept.
+func (m *repoManager) mergeRepo(local, r *repoT) error {
+	var newNodes []*nodeT
+	updated := time.Now()
+	for v, node := range r.dag.nodes {
+		local.RLock()
+		localNode, found := local.dag.nodes[v]
+		local.RUnlock()
+		if !found {
+			newNodes = append(newNodes, node)
+			continue
+		}
+		localNode.Lock()
+		for _, child := range node.children {
+			var hasChild bool
+			for _, localChild := range localNode.children {
+				if localChild == child {
+					hasChild = true
+					break
+				}
+			}
+			if !hasChild {
+				localNode.children = append(localNode.children, child)
+			}
+		}
+		if node.locked && !localNode.locked {
+			localNode.locked = true
+			localNode.note = node.note
+			localNode.log = node.log
+		}
+		localNode.updated = updated
+		localNode.Unlock()
+	}
+
+	var newData []DataService
+	local.Lock()
+	for _, node := range newNodes {
+		local.dag.nodes[node.version] = node
+	}
+	for name, dataservice := range r.data {
+		if _, found := local.data[name]; !found {
+			local.data[name] = dataservice
+			newData = append(newData, dataservice)
+		}
+	}
+	local.updated = updated
+	local.Unlock()
+
+	m.repoMutex.Lock()
+	for _, node := range newNodes {
+		m.repos[node.uuid] = local
+	}
+	m.repoMutex.Unlock()
+
+	m.idMutex.Lock()
+	for _, dataservice := range newData {
+		m.iids[dataservice.InstanceID()] = dataservice
+		m.dataByUUID[dataservice.DataUUID()] = dataservice
+	}
+	for _, node := range newNodes {
+		m.versionToUUID[node.version] = node.uuid
+		m.uuidToVersion[node.uuid] = node.version
+	}
+	m.idMutex.Unlock()
+
+	if err := m.putCaches(); err != nil {
+		return err
+	}
+	return local.save()
+}
+
 // Adds subscriptions for data instance events. making sure that duplicates are avoided.
 func (r *repoT) addSyncGraph(subs SyncSubs) {
 	r.Lock()
@@ -2816,6 +3308,9 @@ type nodeT struct {
 	branch string
 	note   string
 	log    []string
+	tags   []string // immutable names for committed nodes
+
+	squashing bool // set while the node is being squashed so it can't get new children.
 
 	uuid    dvid.UUID
 	version dvid.VersionID
@@ -2843,6 +3338,10 @@ func (node *nodeT) duplicate(versions map[dvid.VersionID]struct{}) *nodeT {
 	dup.note = node.note
 	dup.log = make([]string, len(node.log))
 	copy(dup.log, node.log)
+	if len(node.tags) != 0 {
+		dup.tags = make([]string, len(node.tags))
+		copy(dup.tags, node.tags)
+	}
 
 	dup.uuid = node.uuid
 	dup.version = node.version
@@ -2924,8 +3423,10 @@ func (node *nodeT) GobDecode(b []byte) error {
 		return err
 	}
 
-	// support unspecified branches for legacy dvid instances
-	dec.Decode(&(node.branch))
+	// support unspecified branches and tags for legacy dvid instances
+	if err := dec.Decode(&(node.branch)); err == nil {
+		dec.Decode(&(node.tags))
+	}
 
 	return nil
 }
@@ -2973,6 +3474,9 @@ func (node *nodeT) GobEncode() ([]byte, error) {
 	if err := enc.Encode(node.branch); err != nil {
 		return nil, err
 	}
+	if err := enc.Encode(node.tags); err != nil {
+		return nil, err
+	}
 	return buf.Bytes(), nil
 }
 
@@ -2982,6 +3486,7 @@ func (node *nodeT) MarshalJSON() (b []byte, err error) {
 		Branch    string
 		Note      string
 		Log       []string
+		Tags      []string `json:",omitempty"`
 		UUID      dvid.UUID
 		VersionID dvid.VersionID
 		Locked    bool
@@ -2993,6 +3498,7 @@ func (node *nodeT) MarshalJSON() (b []byte, err error) {
 		node.branch,
 		node.note,
 		node.log,
+		node.tags,
 		node.uuid,
 		node.version,
 		node.locked,
@@ -238,3 +238,60 @@ func TestUUIDAssignment(t *testing.T) {
 		t.Errorf("Error getting back correct UUID %s from %s\n", myuuid, uuid)
 	}
 }
+
+func TestNodeTags(t *testing.T) {
+	OpenTest()
+
+	root, err := NewRepo("test repo", "test repo description", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := TagNode(root, "release-1"); err == nil {
+		t.Errorf("expected error tagging uncommitted node\n")
+	}
+	if err := Commit(root, "root node", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := TagNode(root, "bad/tag"); err == nil {
+		t.Errorf("expected error using invalid tag\n")
+	}
+	if err := TagNode(root, "release-1"); err != nil {
+		t.Fatalf("unable to tag committed node: %v\n", err)
+	}
+	child, err := NewVersion(root, "child", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Commit(child, "child node", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := TagNode(child, "release-1"); err == nil {
+		t.Errorf("expected error reusing tag on another node\n")
+	}
+	if err := TagNode(child, "release-2"); err != nil {
+		t.Fatalf("unable to tag child node: %v\n", err)
+	}
+
+	// Tags should persist across restarts.
+	CloseReopenTest()
+	defer CloseTest()
+
+	uuid, _, err := MatchingUUID(":release-1")
+	if err != nil || uuid != root {
+		t.Errorf("expected tag release-1 to match %s, got %s: %v\n", root, uuid, err)
+	}
+	uuid, _, err = MatchingUUID(":release-2")
+	if err != nil || uuid != child {
+		t.Errorf("expected tag release-2 to match %s, got %s: %v\n", child, uuid, err)
+	}
+	if _, _, err = MatchingUUID(":release-3"); err == nil {
+		t.Errorf("expected error matching unknown tag\n")
+	}
+	jsonStr, err := GetRepoJSON(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(jsonStr, fmt.Sprintf(`"release-2":"%s"`, child)) {
+		t.Errorf("expected tags in repo JSON: %s\n", jsonStr)
+	}
+}
@@ -0,0 +1,6 @@
+// +build s3
+
+package datastore
+
+import _ "github.com/janelia-flyem/dvid/storage/s3"
+import _ "github.com/janelia-flyem/dvid/storage/filelog"
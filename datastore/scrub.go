@@ -0,0 +1,266 @@
+/*
+	This file supports scrubbing data instances, i.e., reading all stored key-value pairs
+	and verifying their checksums and datatype-specific invariants.
+*/
+
+package datastore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// MaxScrubDamaged is the maximum number of damaged keys listed for each data instance.
+const MaxScrubDamaged = 10000
+
+// Scrubber is a data instance that can verify a stored value, e.g., by deserializing it,
+// which checks any stored checksum, and then checking datatype-specific invariants.
+// The returned position describes the key in a type-specific way, e.g., a block
+// coordinate, and is used when reporting damaged keys.
+type Scrubber interface {
+	ScrubValue(tk storage.TKey, value []byte) (position string, err error)
+}
+
+// DamagedKey describes a key-value pair that failed verification.
+type DamagedKey struct {
+	Key      string    // hex-encoded type-specific key
+	UUID     dvid.UUID // version where key-value was stored
+	Position string    `json:",omitempty"`
+	Error    string
+}
+
+// ScrubInstanceReport summarizes the verification of a data instance's key-value pairs.
+type ScrubInstanceReport struct {
+	Name        dvid.InstanceName
+	TypeName    dvid.TypeString
+	Supported   bool // false if datatype can't verify values
+	KeysScanned uint64
+	NumDamaged  uint64
+	Damaged     []DamagedKey `json:",omitempty"` // up to MaxScrubDamaged keys
+	Done        bool
+}
+
+// ScrubReport describes the progress of scrubbing data instances within a repo.
+type ScrubReport struct {
+	UUID     dvid.UUID
+	Started  time.Time
+	Finished *time.Time `json:",omitempty"`
+	Running  bool
+	Error    string `json:",omitempty"`
+
+	// Skipped lists the data instances whose datatypes can't verify values, which aren't read.
+	Skipped []dvid.InstanceName `json:",omitempty"`
+
+	Instances []*ScrubInstanceReport
+
+	// Job is the id of the job running the scrub.
+	Job dvid.JobID
+}
+
+func (r *ScrubReport) copy() *ScrubReport {
+	c := *r
+	c.Skipped = make([]dvid.InstanceName, len(r.Skipped))
+	copy(c.Skipped, r.Skipped)
+	c.Instances = make([]*ScrubInstanceReport, len(r.Instances))
+	for i, ir := range r.Instances {
+		irCopy := *ir
+		irCopy.Damaged = make([]DamagedKey, len(ir.Damaged))
+		copy(irCopy.Damaged, ir.Damaged)
+		c.Instances[i] = &irCopy
+	}
+	return &c
+}
+
+// tracks the current or last scrub for each repo root.
+var scrubs struct {
+	sync.Mutex
+	reports map[dvid.UUID]*ScrubReport
+}
+
+// GetScrubReport returns the report of the current or last scrub of the repo containing
+// the given UUID, or nil if none was run since the server started.
+func GetScrubReport(uuid dvid.UUID) (*ScrubReport, error) {
+	root, err := GetRepoRoot(uuid)
+	if err != nil {
+		return nil, err
+	}
+	scrubs.Lock()
+	defer scrubs.Unlock()
+	report, found := scrubs.reports[root]
+	if !found {
+		return nil, nil
+	}
+	return report.copy(), nil
+}
+
+// StartScrub starts a scrub of the named data instance, or all data instances of the repo
+// if the name is empty, as a job owned by the given user.  All versions of each key-value
+// pair are verified.  Data instances that don't implement Scrubber are listed in the
+// report as skipped.  Only one scrub can be run at a time for each repo.
+func StartScrub(uuid dvid.UUID, name dvid.InstanceName, owner string) (*ScrubReport, error) {
+	if manager == nil {
+		return nil, ErrManagerNotInitialized
+	}
+	r, err := manager.repoFromUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	var dataservices []DataService
+	r.RLock()
+	root := r.uuid
+	if name != "" {
+		dataservice, found := r.data[name]
+		if !found {
+			r.RUnlock()
+			return nil, ErrInvalidDataName
+		}
+		dataservices = append(dataservices, dataservice)
+	} else {
+		for _, dataservice := range r.data {
+			dataservices = append(dataservices, dataservice)
+		}
+	}
+	r.RUnlock()
+
+	scrubs.Lock()
+	defer scrubs.Unlock()
+	if scrubs.reports == nil {
+		scrubs.reports = make(map[dvid.UUID]*ScrubReport)
+	}
+	if prior, found := scrubs.reports[root]; found && prior.Running {
+		return nil, fmt.Errorf("scrub of repo %s started %s is already running", root, prior.Started)
+	}
+	report := &ScrubReport{UUID: uuid, Started: time.Now(), Running: true}
+	for _, dataservice := range dataservices {
+		_, supported := dataservice.(Scrubber)
+		report.Instances = append(report.Instances, &ScrubInstanceReport{
+			Name:      dataservice.DataName(),
+			TypeName:  dataservice.TypeName(),
+			Supported: supported,
+			Done:      !supported,
+		})
+		if !supported {
+			report.Skipped = append(report.Skipped, dataservice.DataName())
+		}
+	}
+	if len(report.Skipped) != 0 {
+		dvid.Infof("Scrub of repo %s skipping data instances whose datatypes can't verify values: %v\n", root, report.Skipped)
+	}
+	job := dvid.NewJob("scrub", owner, uuid, name)
+	report.Job = job.ID()
+	scrubs.reports[root] = report
+	go func() {
+		timedLog := dvid.NewTimeLog()
+		var err error
+		for i, dataservice := range dataservices {
+			if err = manager.scrubData(dataservice, report.Instances[i], job); err != nil {
+				if err != dvid.ErrJobCanceled {
+					err = fmt.Errorf("data %q: %v", dataservice.DataName(), err)
+				}
+				break
+			}
+			job.SetProgress(uint64(i+1), uint64(len(dataservices)))
+		}
+		scrubs.Lock()
+		finished := time.Now()
+		report.Finished = &finished
+		report.Running = false
+		if err != nil {
+			report.Error = err.Error()
+		}
+		scrubs.Unlock()
+		job.Finish(err)
+		if err != nil {
+			return
+		}
+		timedLog.Infof("Completed scrub of %d data instances in repo %s", len(dataservices), root)
+	}()
+	return report.copy(), nil
+}
+
+// scrubValue calls the datatype's verification, converting any panic from decoding
+// badly damaged values into an error.
+func scrubValue(scrubber Scrubber, tk storage.TKey, value []byte) (position string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while verifying value: %v", r)
+		}
+	}()
+	return scrubber.ScrubValue(tk, value)
+}
+
+// scrubData verifies all versions of all key-value pairs of a data instance, stopping
+// if the job is canceled.
+func (m *repoManager) scrubData(data DataService, report *ScrubInstanceReport, job *dvid.Job) error {
+	scrubber, ok := data.(Scrubber)
+	if !ok {
+		return nil
+	}
+	store, err := GetOrderedKeyValueDB(data)
+	if err != nil {
+		return err
+	}
+
+	baseCtx := NewVersionedCtx(data, 0)
+	ch := make(chan *storage.KeyValue, 1000)
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for kv := range ch {
+			if kv == nil {
+				return
+			}
+			if kv.K.IsTombstone() {
+				continue
+			}
+			damaged := DamagedKey{Key: fmt.Sprintf("%x", []byte(kv.K))}
+			var scrubErr error
+			tk, err := storage.TKeyFromKey(kv.K)
+			if err != nil {
+				scrubErr = err
+			} else {
+				damaged.Key = fmt.Sprintf("%x", []byte(tk))
+				damaged.Position, scrubErr = scrubValue(scrubber, tk, kv.V)
+			}
+			if scrubErr != nil {
+				if v, err := baseCtx.VersionFromKey(kv.K); err == nil {
+					damaged.UUID, _ = m.uuidFromVersion(v)
+				}
+				damaged.Error = scrubErr.Error()
+				dvid.Errorf("Scrub found damaged key %s in data %q (version %s): %v\n", damaged.Key, data.DataName(), damaged.UUID, scrubErr)
+			}
+			scrubs.Lock()
+			report.KeysScanned++
+			if scrubErr != nil {
+				report.NumDamaged++
+				if len(report.Damaged) < MaxScrubDamaged {
+					report.Damaged = append(report.Damaged, damaged)
+				}
+			}
+			scrubs.Unlock()
+		}
+	}()
+
+	minKey, maxKey := baseCtx.KeyRange()
+	keysOnly := false
+	if err := store.RawRangeQuery(minKey, maxKey, keysOnly, ch, job.CancelChan()); err != nil {
+		close(ch)
+		wg.Wait()
+		return err
+	}
+	if job.Canceled() {
+		close(ch) // a canceled range query doesn't terminate the channel.
+		wg.Wait()
+		return dvid.ErrJobCanceled
+	}
+	wg.Wait()
+	scrubs.Lock()
+	report.Done = true
+	scrubs.Unlock()
+	return nil
+}
@@ -0,0 +1,397 @@
+// +build !clustered,!gcloud
+
+/*
+	This file supports squashing a linear chain of committed versions into a single node,
+	which shortens the ancestry that must be searched for every versioned read.
+*/
+
+package datastore
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// only one squash can run at a time.
+var squashMu sync.Mutex
+
+// Squash rewrites the key-values of a linear chain of committed versions, from an ancestor
+// node through a descendant node, so the ancestor node holds the data visible at the
+// descendant.  Children of the descendant become children of the ancestor and all other
+// nodes in the chain are removed.  Each node in the chain besides the descendant must have
+// exactly one child and each node besides the ancestor must have exactly one parent.
+// Tags of the descendant are moved to the ancestor, while tagged nodes elsewhere in the
+// chain can't be squashed since their data would change.
+//
+// Mutation log entries of the removed nodes are appended to the ancestor's log so replaying
+// the ancestor's log, e.g., to rebuild a labelmap's supervoxel mapping, includes all
+// mutations visible at the descendant.
+//
+// Data instances may cache version-specific data in memory, so the server should be
+// restarted after a squash.  Squashing can be done on a copy of the stores made with
+// "transfer-data" so production servers stay online.  The optional job's progress is
+// updated as each data instance is squashed.  The job can be canceled, e.g., while it
+// waits for another squash, until rewriting of key-values starts since a partially
+// rewritten chain can't be restored.
+func Squash(uuid, from, to dvid.UUID, job *dvid.Job) error {
+	if manager == nil {
+		return ErrManagerNotInitialized
+	}
+	return manager.squash(uuid, from, to, job)
+}
+
+// keyAtVersion returns a copy of a data key with its version replaced.
+func keyAtVersion(k storage.Key, v dvid.VersionID) storage.Key {
+	newK := make(storage.Key, len(k))
+	copy(newK, k)
+	pos := len(k) - 1 - dvid.ClientIDSize - dvid.VersionIDSize
+	copy(newK[pos:], v.Bytes())
+	return newK
+}
+
+// squashChain returns the nodes from the ancestor through the descendant version if they
+// form a linear chain of committed nodes that can be squashed.
+func (r *repoT) squashChain(fromV, toV dvid.VersionID) ([]*nodeT, error) {
+	r.RLock()
+	defer r.RUnlock()
+	node, found := r.dag.nodes[toV]
+	if !found {
+		return nil, ErrInvalidVersion
+	}
+	chain := []*nodeT{node}
+	for {
+		node.RLock()
+		uuid := node.uuid
+		locked := node.locked
+		numChildren := len(node.children)
+		numTags := len(node.tags)
+		parents := node.parents
+		node.RUnlock()
+		if !locked {
+			return nil, fmt.Errorf("node %s must be committed before squashing", uuid)
+		}
+		if len(chain) > 1 {
+			if numChildren != 1 {
+				return nil, fmt.Errorf("node %s has %d children so its data can't be squashed", uuid, numChildren)
+			}
+			if numTags != 0 {
+				return nil, fmt.Errorf("node %s is tagged so its data can't be squashed", uuid)
+			}
+		}
+		if node.version == fromV {
+			break
+		}
+		if len(parents) == 0 {
+			return nil, fmt.Errorf("node to squash from is not an ancestor of node %s", chain[0].uuid)
+		}
+		if len(parents) != 1 {
+			return nil, fmt.Errorf("node %s has %d parents so chain to be squashed is not linear", uuid, len(parents))
+		}
+		if node, found = r.dag.nodes[parents[0]]; !found {
+			return nil, ErrInvalidVersion
+		}
+		chain = append(chain, node)
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// setSquashing marks or unmarks nodes so they can't get new children.
+func setSquashing(nodes []*nodeT, squashing bool) {
+	for _, node := range nodes {
+		node.Lock()
+		node.squashing = squashing
+		node.Unlock()
+	}
+}
+
+func (m *repoManager) squash(uuid, from, to dvid.UUID, job *dvid.Job) error {
+	r, err := m.repoFromUUID(uuid)
+	if err != nil {
+		return err
+	}
+	for _, u := range []dvid.UUID{from, to} {
+		nodeRepo, err := m.repoFromUUID(u)
+		if err != nil {
+			return err
+		}
+		if nodeRepo != r {
+			return fmt.Errorf("node %s is not in repo %s", u, uuid)
+		}
+	}
+	fromV, err := m.versionFromUUID(from)
+	if err != nil {
+		return err
+	}
+	toV, err := m.versionFromUUID(to)
+	if err != nil {
+		return err
+	}
+	if fromV == toV {
+		return fmt.Errorf("squash requires two different nodes")
+	}
+
+	squashMu.Lock()
+	defer squashMu.Unlock()
+
+	// Prevent new children of all but the last node, then make sure the chain is still valid.
+	chain, err := r.squashChain(fromV, toV)
+	if err != nil {
+		return err
+	}
+	setSquashing(chain[:len(chain)-1], true)
+	defer setSquashing(chain[:len(chain)-1], false)
+	if chain, err = r.squashChain(fromV, toV); err != nil {
+		return err
+	}
+	chainIndex := make(map[dvid.VersionID]int, len(chain))
+	for i, node := range chain {
+		chainIndex[node.version] = i
+	}
+
+	if !job.DisableCancel() {
+		return dvid.ErrJobCanceled
+	}
+	timedLog := dvid.NewTimeLog()
+	var dataservices []DataService
+	r.RLock()
+	for _, dataservice := range r.data {
+		dataservices = append(dataservices, dataservice)
+	}
+	r.RUnlock()
+	for i, dataservice := range dataservices {
+		if dataservice.Versioned() {
+			if err := m.squashData(dataservice, chainIndex, fromV); err != nil {
+				return fmt.Errorf("unable to squash data %q: %v", dataservice.DataName(), err)
+			}
+		}
+		if err := squashLogs(dataservice, chain); err != nil {
+			return fmt.Errorf("unable to squash mutation log of data %q: %v", dataservice.DataName(), err)
+		}
+		job.SetProgress(uint64(i+1), uint64(len(dataservices)))
+	}
+
+	// Key-values are now stored in the ancestor, so modify the DAG.
+	survivor := chain[0]
+	last := chain[len(chain)-1]
+	setSquashing([]*nodeT{last}, true)
+	var logs []string
+	for _, node := range chain {
+		node.RLock()
+		logs = append(logs, node.log...)
+		node.RUnlock()
+	}
+	last.RLock()
+	children := make([]dvid.VersionID, len(last.children))
+	copy(children, last.children)
+	note, branch := last.note, last.branch
+	tags := last.tags
+	last.RUnlock()
+
+	r.RLock()
+	childNodes := make([]*nodeT, 0, len(children))
+	for _, childV := range children {
+		if child, found := r.dag.nodes[childV]; found {
+			childNodes = append(childNodes, child)
+		}
+	}
+	r.RUnlock()
+	t := time.Now()
+	for _, child := range childNodes {
+		child.Lock()
+		for i, parentV := range child.parents {
+			if parentV == toV {
+				child.parents[i] = fromV
+			}
+		}
+		child.updated = t
+		child.Unlock()
+	}
+	survivor.Lock()
+	survivor.children = children
+	survivor.note = note
+	survivor.branch = branch
+	survivor.log = logs
+	survivor.tags = tags
+	survivor.updated = t
+	survivor.Unlock()
+
+	removed := make(map[dvid.UUID]dvid.VersionID, len(chain)-1)
+	r.Lock()
+	for _, node := range chain[1:] {
+		removed[node.uuid] = node.version
+		delete(r.dag.nodes, node.version)
+	}
+	r.updated = t
+	for _, dataservice := range r.data {
+		if _, found := removed[dataservice.RootUUID()]; found {
+			dataservice.SetRootUUID(from)
+		}
+	}
+	r.Unlock()
+
+	m.repoMutex.Lock()
+	for u := range removed {
+		delete(m.repos, u)
+	}
+	m.repoMutex.Unlock()
+	m.idMutex.Lock()
+	vmap := make(dvid.VersionMap, len(m.versionToUUID))
+	for v := range m.versionToUUID {
+		vmap[v] = v
+	}
+	for u, v := range removed {
+		delete(m.uuidToVersion, u)
+		delete(m.versionToUUID, v)
+		vmap[v] = fromV
+	}
+	m.idMutex.Unlock()
+	if err := m.putCaches(); err != nil {
+		return err
+	}
+
+	// Let data instances remap any version-specific properties.
+	for _, dataservice := range dataservices {
+		if remapper, ok := dataservice.(VersionRemapper); ok {
+			if err := remapper.RemapVersions(vmap); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	timedLog.Infof("Squashed %d nodes from %s to %s into node %s", len(chain), from, to, from)
+	return nil
+}
+
+// squashLogs appends the mutation log entries of the later versions in the chain to the
+// log of the first version in chain order.
+func squashLogs(data DataService, chain []*nodeT) error {
+	logable, ok := data.(storage.Logable)
+	if !ok {
+		return nil
+	}
+	rl := logable.GetReadLog()
+	wl := logable.GetWriteLog()
+	if rl == nil || wl == nil {
+		return nil
+	}
+	from := chain[0].uuid
+	var numMsgs int
+	for _, node := range chain[1:] {
+		msgs, err := rl.ReadAll(data.DataUUID(), node.uuid)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if err := wl.Append(data.DataUUID(), from, msg); err != nil {
+				return err
+			}
+		}
+		numMsgs += len(msgs)
+	}
+	if numMsgs == 0 {
+		return nil
+	}
+	dvid.Infof("Squashed %d mutation log entries of data %q into version %s\n", numMsgs, data.DataName(), from)
+	return wl.CloseLog(data.DataUUID(), from)
+}
+
+// squashData rewrites the key-values of a data instance at versions in the chain so the
+// data visible at the last version of the chain is stored at the first version.
+func (m *repoManager) squashData(data DataService, chainIndex map[dvid.VersionID]int, fromV dvid.VersionID) error {
+	store, err := GetOrderedKeyValueDB(data)
+	if err != nil {
+		return err
+	}
+	ctx := NewVersionedCtx(data, fromV)
+
+	ch := make(chan *storage.KeyValue, 1000)
+	cancel := make(chan struct{})
+	queryErr := make(chan error, 1)
+	minKey, maxKey := ctx.KeyRange()
+	go func() {
+		queryErr <- store.RawRangeQuery(minKey, maxKey, false, ch, cancel)
+	}()
+
+	// For each TKey, the key-value at the latest version in the chain is written at the
+	// first version and all other keys in the chain are deleted.
+	var curTKey storage.TKey
+	var keys []storage.Key
+	var latest *storage.KeyValue
+	latestIndex := -1
+	var numRewritten, numDeleted uint64
+	flush := func() error {
+		if latest == nil {
+			return nil
+		}
+		newK := keyAtVersion(latest.K, fromV)
+		if !bytes.Equal(newK, latest.K) {
+			if err := store.RawPut(newK, latest.V); err != nil {
+				return err
+			}
+			numRewritten++
+		}
+		for _, k := range keys {
+			if bytes.Equal(k, newK) {
+				continue
+			}
+			if err := store.RawDelete(k); err != nil {
+				return err
+			}
+			numDeleted++
+		}
+		keys = keys[:0]
+		latest = nil
+		latestIndex = -1
+		return nil
+	}
+	for {
+		kv := <-ch
+		if kv == nil {
+			break
+		}
+		v, err := ctx.VersionFromKey(kv.K)
+		if err != nil {
+			close(cancel)
+			return err
+		}
+		index, found := chainIndex[v]
+		if !found {
+			continue
+		}
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			close(cancel)
+			return err
+		}
+		if !bytes.Equal(tk, curTKey) {
+			if err := flush(); err != nil {
+				close(cancel)
+				return err
+			}
+			curTKey = tk
+		}
+		keys = append(keys, kv.K)
+		if index >= latestIndex {
+			latest = kv
+			latestIndex = index
+		}
+	}
+	if err := <-queryErr; err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	dvid.Infof("Squashed data %q: rewrote %d key-values and deleted %d\n", data.DataName(), numRewritten, numDeleted)
+	return nil
+}
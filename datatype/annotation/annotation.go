@@ -61,7 +61,10 @@ $ dvid node <UUID> <data name> reload <settings...>
 	Forces asynchornous denormalization of all annotations for labels and tags.  Because
 	this is a special request for mass mutations that require static "normalized" data
 	(only verifies and changes the label and tag denormalizations), any POST requests
-	while this is running results in an error.
+	while this is running results in an error.  The reload runs as a job whose id is
+	returned and can be used to check progress or cancel it via /api/jobs/{id}.  Unless
+	"check" is "true", the reload starts by deleting the denormalizations and then can't
+	be canceled since stopping would leave the labels and tags without an index.
 
     Configuration Settings (case-insensitive keys)
 
@@ -306,7 +309,11 @@ POST <api URL>/node/<UUID>/<data name>/reload[?<options>]
 
 	Forces asynchronous recreation of its tag and label indexed denormalizations.  Can be 
 	used to initialize a newly added instance.  Note that this instance will return errors
-	for any POST request while denormalization is ongoing.
+	for any POST request while denormalization is ongoing.  Returns the id of the reload
+	job, e.g., {"job": 12}, whose progress can be checked or which can be canceled using
+	/api/jobs/{id}.  Unless "check" is "true", the reload starts by deleting the
+	denormalizations and then can't be canceled since stopping would leave the labels and
+	tags without an index.
 
 	POST Query-string Options:
 
@@ -1796,7 +1803,8 @@ func (d *Data) modifyTagElements(ctx *datastore.VersionedCtx, batch storage.Batc
 }
 
 // ProcessLabelAnnotations will pass all annotations, label by label, to the given function.
-func (d *Data) ProcessLabelAnnotations(v dvid.VersionID, f func(label uint64, elems ElementsNR)) error {
+// Processing stops if the function returns an error.
+func (d *Data) ProcessLabelAnnotations(v dvid.VersionID, f func(label uint64, elems ElementsNR) error) error {
 	minTKey := storage.MinTKey(keyLabel)
 	maxTKey := storage.MaxTKey(keyLabel)
 
@@ -1824,8 +1832,7 @@ func (d *Data) ProcessLabelAnnotations(v dvid.VersionID, f func(label uint64, el
 		if len(elems) == 0 {
 			return nil
 		}
-		f(label, elems)
-		return nil
+		return f(label, elems)
 	})
 	if err != nil {
 		return fmt.Errorf("Unable to get label-based annotations for data %q: %v\n", d.DataName(), err)
@@ -2293,14 +2300,17 @@ func (d *Data) MoveElement(ctx *datastore.VersionedCtx, from, to dvid.Point3d, k
 	return batch.Commit()
 }
 
-// RecreateDenormalizations will recreate label and tag denormalizations from
-// the block-based elements.
-func (d *Data) RecreateDenormalizations(ctx *datastore.VersionedCtx, inMemory, check bool) {
-	if inMemory {
-		go d.resyncInMemory(ctx, check)
-	} else {
-		go d.resyncLowMemory(ctx)
-	}
+// RecreateDenormalizations will asynchronously recreate label and tag denormalizations from
+// the block-based elements, finishing the given job when done.  The denormalization stops
+// if the job is canceled.
+func (d *Data) RecreateDenormalizations(ctx *datastore.VersionedCtx, inMemory, check bool, job *dvid.Job) {
+	go func() {
+		if inMemory {
+			job.Finish(d.resyncInMemory(ctx, check, job))
+		} else {
+			job.Finish(d.resyncLowMemory(ctx, job))
+		}
+	}()
 }
 
 func (d *Data) storeTags(batcher storage.KeyValueBatcher, ctx *datastore.VersionedCtx, tagE map[Tag]Elements) error {
@@ -2358,8 +2368,8 @@ type denormElems struct {
 
 // Do in-memory resync of all keyBlock kv pairs, forcing the label and tag denormalizations.
 // If check is true, checks denormalizations, logging any issues, and only replaces denormalizations
-// when they are incorrect.
-func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool) {
+// when they are incorrect.  The resync stops if the optional job is canceled.
+func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool, job *dvid.Job) error {
 	d.Lock()
 	d.denormOngoing = true
 	d.Unlock()
@@ -2371,13 +2381,15 @@ func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool) {
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
-		dvid.Errorf("Annotation %q had error initializing store: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("annotation %q had error initializing store: %v", d.DataName(), err)
 	}
 	if !check {
+		// Stopping after the delete would leave no denormalizations.
+		if !job.DisableCancel() {
+			return dvid.ErrJobCanceled
+		}
 		if err := d.deleteDenormalizations(ctx); err != nil {
-			dvid.Errorf("Can't delete denormalizations: %v\n", err)
-			return
+			return fmt.Errorf("can't delete denormalizations: %v", err)
 		}
 	}
 
@@ -2397,6 +2409,9 @@ func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool) {
 		if c.V == nil {
 			return nil
 		}
+		if job.Canceled() {
+			return dvid.ErrJobCanceled
+		}
 		chunkPt, err := DecodeBlockTKey(c.K)
 		if err != nil {
 			return fmt.Errorf("couldn't decode chunk key %v for data %q", c.K, d.DataName())
@@ -2438,6 +2453,9 @@ func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool) {
 		}
 		return nil
 	})
+	if err == dvid.ErrJobCanceled {
+		return err
+	}
 	if err != nil {
 		dvid.Errorf("Error in reload of data %q: %v\n", d.DataName(), err)
 	}
@@ -2485,9 +2503,15 @@ func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool) {
 
 	dvid.Infof("Writing elements for %d labels, %d tags ...", len(labelE), len(tagE))
 	for label, elems := range labelE {
+		if job.Canceled() {
+			break
+		}
 		ch <- denormElems{tk: NewLabelTKey(label), elems: elems}
 	}
 	for tag, elems := range tagE {
+		if job.Canceled() {
+			break
+		}
 		tk, err := NewTagTKey(tag)
 		if err != nil {
 			dvid.Errorf("problem with tag key tkey for tag %q: %v\n", tag, err)
@@ -2498,11 +2522,16 @@ func (d *Data) resyncInMemory(ctx *datastore.VersionedCtx, check bool) {
 	}
 	close(ch)
 	wg.Wait()
+	if job.Canceled() {
+		return dvid.ErrJobCanceled
+	}
 	timedLog.Infof("Finished denormalization of %d kvs, %d changed (%d errors)", numProcessed, numChanged, numErrs)
+	return nil
 }
 
-// Get all keyBlock kv pairs, forcing the label and tag denormalizations.
-func (d *Data) resyncLowMemory(ctx *datastore.VersionedCtx) {
+// Get all keyBlock kv pairs, forcing the label and tag denormalizations.  Since the
+// denormalizations are first deleted, the optional job can't be canceled once started.
+func (d *Data) resyncLowMemory(ctx *datastore.VersionedCtx, job *dvid.Job) error {
 	d.Lock()
 	d.denormOngoing = true
 	d.Unlock()
@@ -2516,18 +2545,18 @@ func (d *Data) resyncLowMemory(ctx *datastore.VersionedCtx) {
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
-		dvid.Errorf("Annotation %q had error initializing store: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("annotation %q had error initializing store: %v", d.DataName(), err)
 	}
 	batcher, ok := store.(storage.KeyValueBatcher)
 	if !ok {
-		dvid.Errorf("Data type annotation requires batch-enabled store, which %q is not\n", store)
-		return
+		return fmt.Errorf("data type annotation requires batch-enabled store, which %q is not", store)
 	}
 
+	if !job.DisableCancel() {
+		return dvid.ErrJobCanceled
+	}
 	if err := d.deleteDenormalizations(ctx); err != nil {
-		dvid.Errorf("Can't delete denormalizations: %v\n", err)
-		return
+		return fmt.Errorf("can't delete denormalizations: %v", err)
 	}
 
 	var numBlocks, numBlockE, numTagE int
@@ -2626,17 +2655,18 @@ func (d *Data) resyncLowMemory(ctx *datastore.VersionedCtx) {
 	if numTagE > 0 {
 		totTagE += numTagE
 		if err := d.storeTags(batcher, ctx, tagE); err != nil {
-			dvid.Errorf("Error writing final set of tags of data %q: %v", d.DataName(), err)
+			return fmt.Errorf("error writing final set of tags of data %q: %v", d.DataName(), err)
 		}
 	}
 	if numBlockE > 0 {
 		totBlockE += numBlockE
 		if err := d.storeLabels(batcher, ctx, blockE); err != nil {
-			dvid.Errorf("Error writing final set of label elements of data %q: %v", d.DataName(), err)
+			return fmt.Errorf("error writing final set of label elements of data %q: %v", d.DataName(), err)
 		}
 	}
 
 	timedLog.Infof("Completed asynchronous annotation %q reload of %d block and %d tag elements.", d.DataName(), totBlockE, totTagE)
+	return nil
 }
 
 // GetByDataUUID returns a pointer to annotation data given a data UUID.
@@ -2730,8 +2760,9 @@ func (d *Data) DoRPC(req datastore.Request, reply *datastore.Response) error {
 			check = true
 		}
 		ctx := datastore.NewVersionedCtx(d, v)
-		d.RecreateDenormalizations(ctx, inMemory, check)
-		reply.Text = fmt.Sprintf("Asynchronously checking and restoring label and tag denormalizations for annotation %q\n", d.DataName())
+		job := dvid.NewJob("annotation reload", req.User, uuid, d.DataName())
+		d.RecreateDenormalizations(ctx, inMemory, check, job)
+		reply.Text = fmt.Sprintf("Asynchronously checking and restoring label and tag denormalizations for annotation %q as job %d\n", d.DataName(), job.ID())
 		return nil
 
 	default:
@@ -3040,7 +3071,10 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 		inMemory := !(r.URL.Query().Get("inmemory") == "false")
 		check := r.URL.Query().Get("check") == "true"
-		d.RecreateDenormalizations(ctx, inMemory, check)
+		job := dvid.NewJob("annotation reload", ctx.AuthUser, uuid, d.DataName())
+		d.RecreateDenormalizations(ctx, inMemory, check, job)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"job": %d}`, job.ID())
 
 	default:
 		server.BadAPIRequest(w, r, d)
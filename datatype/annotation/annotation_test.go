@@ -1774,7 +1774,9 @@ func TestLabelblkReload(t *testing.T) {
 		t.Fatal(err)
 	}
 	ctx := datastore.NewVersionedCtx(d, v)
-	d.resyncInMemory(ctx, true)
+	if err := d.resyncInMemory(ctx, true, nil); err != nil {
+		t.Fatal(err)
+	}
 
 	testLabelsReload(t, uuid, "labels", "bodies")
 }
@@ -1818,9 +1820,12 @@ func testLabelReload(t *testing.T, typename string, inMemory bool) {
 	}
 	ctx := datastore.NewVersionedCtx(d, v)
 	if inMemory {
-		d.resyncInMemory(ctx, true)
+		err = d.resyncInMemory(ctx, true, nil)
 	} else {
-		d.resyncLowMemory(ctx)
+		err = d.resyncLowMemory(ctx, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
 	}
 	testLabelsReload(t, uuid, "labels", "labels")
 }
@@ -0,0 +1,356 @@
+/*
+	This file supports type-specific merging and diffing of annotation versions.
+*/
+
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// posElements maps element positions to their JSON serialization.
+type posElements map[dvid.Point3d]json.RawMessage
+
+func decodePosElements(val []byte) (posElements, error) {
+	pe := make(posElements)
+	if val == nil {
+		return pe, nil
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(val, &raws); err != nil {
+		return nil, err
+	}
+	for _, raw := range raws {
+		var elem struct {
+			Pos dvid.Point3d
+		}
+		if err := json.Unmarshal(raw, &elem); err != nil {
+			return nil, err
+		}
+		pe[elem.Pos] = raw
+	}
+	return pe, nil
+}
+
+// positions is a sortable slice of element positions in z, y, x order.
+type positions []dvid.Point3d
+
+func (p positions) Len() int           { return len(p) }
+func (p positions) Less(i, j int) bool { return p[i].Less(p[j]) }
+func (p positions) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// resolvePositions merges the elements of a conflicting key by position, returning the
+// resolved elements and every position seen in the base or any parent.
+func resolvePositions(conflict datastore.MergeConflict) (resolved posElements, candidates positions, err error) {
+	base, err := decodePosElements(conflict.Base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't decode base elements for key %v: %v", conflict.TKey, err)
+	}
+	values := make([]posElements, len(conflict.Values))
+	seen := make(map[dvid.Point3d]struct{}, len(base))
+	for pos := range base {
+		seen[pos] = struct{}{}
+	}
+	for i, val := range conflict.Values {
+		if values[i], err = decodePosElements(val); err != nil {
+			return nil, nil, fmt.Errorf("can't decode elements for key %v: %v", conflict.TKey, err)
+		}
+		for pos := range values[i] {
+			seen[pos] = struct{}{}
+		}
+	}
+	candidates = make(positions, 0, len(seen))
+	for pos := range seen {
+		candidates = append(candidates, pos)
+	}
+	sort.Sort(candidates)
+
+	resolved = make(posElements, len(candidates))
+	for _, pos := range candidates {
+		baseElem, inBase := base[pos]
+		elem, present := baseElem, inBase
+		for _, pe := range values {
+			parentElem, inParent := pe[pos]
+			if inParent != inBase || !bytes.Equal(parentElem, baseElem) {
+				elem, present = parentElem, inParent
+				break
+			}
+		}
+		if present {
+			resolved[pos] = elem
+		}
+	}
+	return resolved, candidates, nil
+}
+
+// putMergedElements stores the elements sorted by position or deletes the key if there
+// are no elements.
+func putMergedElements(ctx *datastore.VersionedCtx, tk storage.TKey, elems ElementsNR) error {
+	if len(elems) == 0 {
+		store, err := ctx.GetOrderedKeyValueDB()
+		if err != nil {
+			return err
+		}
+		return store.Delete(ctx, tk)
+	}
+	sort.Sort(elems)
+	return putElements(ctx, tk, elems)
+}
+
+// MergeConflicts resolves block, label and tag keys modified in more than one parent
+// during an auto merge, implementing the datastore.Merger interface.  Block elements are
+// merged by position: an element added, modified or deleted in a parent relative to
+// the common ancestor takes precedence over an unchanged element, and if more than one
+// parent changed an element, the earliest parent in merge order wins.  Conflicting label
+// and tag keys are then rebuilt from the merged blocks so the denormalizations always
+// agree with the block elements.
+func (d *Data) MergeConflicts(ctx *datastore.VersionedCtx, parents []dvid.VersionID, conflicts []datastore.MergeConflict) error {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	var labelConflicts, tagConflicts []datastore.MergeConflict
+	for _, conflict := range conflicts {
+		class, err := conflict.TKey.Class()
+		if err != nil {
+			return err
+		}
+		switch class {
+		case keyBlock:
+		case keyLabel:
+			labelConflicts = append(labelConflicts, conflict)
+			continue
+		case keyTag:
+			tagConflicts = append(tagConflicts, conflict)
+			continue
+		default:
+			return fmt.Errorf("unable to merge conflicting %s %v", d.DescribeTKeyClass(class), conflict.TKey)
+		}
+		resolved, candidates, err := resolvePositions(conflict)
+		if err != nil {
+			return err
+		}
+		var merged []json.RawMessage
+		for _, pos := range candidates {
+			if elem, present := resolved[pos]; present {
+				merged = append(merged, elem)
+			}
+		}
+		if len(merged) == 0 {
+			if err := store.Delete(ctx, conflict.TKey); err != nil {
+				return err
+			}
+			continue
+		}
+		val, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		if err := store.Put(ctx, conflict.TKey, val); err != nil {
+			return err
+		}
+	}
+
+	// Label and tag elements are taken from the merged blocks in the child.
+	blockSize := d.blockSize()
+	blocks := make(map[dvid.ChunkPoint3d]map[dvid.Point3d]Element)
+	mergedElement := func(pos dvid.Point3d) (*Element, error) {
+		bcoord := pos.Chunk(blockSize).(dvid.ChunkPoint3d)
+		blockElems, found := blocks[bcoord]
+		if !found {
+			elems, err := getElements(ctx, NewBlockTKey(bcoord))
+			if err != nil {
+				return nil, err
+			}
+			blockElems = make(map[dvid.Point3d]Element, len(elems))
+			for _, elem := range elems {
+				blockElems[elem.Pos] = elem
+			}
+			blocks[bcoord] = blockElems
+		}
+		elem, found := blockElems[pos]
+		if !found {
+			return nil, nil
+		}
+		return &elem, nil
+	}
+
+	// A label keeps an element if the merged label sets say so and the element still
+	// exists in its block.
+	for _, conflict := range labelConflicts {
+		resolved, candidates, err := resolvePositions(conflict)
+		if err != nil {
+			return err
+		}
+		var elems ElementsNR
+		for _, pos := range candidates {
+			if _, present := resolved[pos]; !present {
+				continue
+			}
+			elem, err := mergedElement(pos)
+			if err != nil {
+				return err
+			}
+			if elem != nil {
+				elems = append(elems, elem.ElementNR)
+			}
+		}
+		if err := putMergedElements(ctx, conflict.TKey, elems); err != nil {
+			return err
+		}
+	}
+
+	// A tag holds exactly the merged block elements that carry it.
+	for _, conflict := range tagConflicts {
+		tag, err := DecodeTagTKey(conflict.TKey)
+		if err != nil {
+			return err
+		}
+		_, candidates, err := resolvePositions(conflict)
+		if err != nil {
+			return err
+		}
+		var elems ElementsNR
+		for _, pos := range candidates {
+			elem, err := mergedElement(pos)
+			if err != nil {
+				return err
+			}
+			if elem == nil {
+				continue
+			}
+			for _, elemTag := range elem.Tags {
+				if elemTag == tag {
+					elems = append(elems, elem.ElementNR)
+					break
+				}
+			}
+		}
+		if err := putMergedElements(ctx, conflict.TKey, elems); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elementChange describes an element modified between two versions.
+type elementChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// membershipChange lists the positions of elements added to or removed from a label
+// or tag between two versions.
+type membershipChange struct {
+	Added   []dvid.Point3d `json:"added"`
+	Removed []dvid.Point3d `json:"removed"`
+}
+
+// changedMembership returns the sorted positions added and removed between two
+// element lists.
+func changedMembership(fromElems, toElems posElements) membershipChange {
+	change := membershipChange{Added: []dvid.Point3d{}, Removed: []dvid.Point3d{}}
+	for pos := range toElems {
+		if _, found := fromElems[pos]; !found {
+			change.Added = append(change.Added, pos)
+		}
+	}
+	for pos := range fromElems {
+		if _, found := toElems[pos]; !found {
+			change.Removed = append(change.Removed, pos)
+		}
+	}
+	sort.Sort(positions(change.Added))
+	sort.Sort(positions(change.Removed))
+	return change
+}
+
+// DiffSummary returns the elements added, modified, or deleted between two versions
+// by comparing the elements of changed blocks, as well as the element positions added
+// to or removed from each changed label and tag.  Implements the datastore.Differ interface.
+func (d *Data) DiffSummary(from, to dvid.VersionID, diff *datastore.VersionDiff) (interface{}, error) {
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return nil, err
+	}
+	fromCtx := datastore.NewVersionedCtx(d, from)
+	toCtx := datastore.NewVersionedCtx(d, to)
+
+	summary := struct {
+		Added    []json.RawMessage           `json:"added"`
+		Modified []elementChange             `json:"modified"`
+		Deleted  []json.RawMessage           `json:"deleted"`
+		Labels   map[uint64]membershipChange `json:"labels"`
+		Tags     map[Tag]membershipChange    `json:"tags"`
+	}{
+		Added:    []json.RawMessage{},
+		Modified: []elementChange{},
+		Deleted:  []json.RawMessage{},
+		Labels:   make(map[uint64]membershipChange),
+		Tags:     make(map[Tag]membershipChange),
+	}
+	for _, tks := range [][]storage.TKey{diff.Added, diff.Modified, diff.Deleted} {
+		for _, tk := range tks {
+			class, err := tk.Class()
+			if err != nil {
+				return nil, err
+			}
+			if class != keyBlock && class != keyLabel && class != keyTag {
+				continue
+			}
+			fromVal, err := store.Get(fromCtx, tk)
+			if err != nil {
+				return nil, err
+			}
+			toVal, err := store.Get(toCtx, tk)
+			if err != nil {
+				return nil, err
+			}
+			fromElems, err := decodePosElements(fromVal)
+			if err != nil {
+				return nil, fmt.Errorf("can't decode elements for key %v: %v", tk, err)
+			}
+			toElems, err := decodePosElements(toVal)
+			if err != nil {
+				return nil, fmt.Errorf("can't decode elements for key %v: %v", tk, err)
+			}
+			switch class {
+			case keyLabel:
+				label, err := DecodeLabelTKey(tk)
+				if err != nil {
+					return nil, err
+				}
+				summary.Labels[label] = changedMembership(fromElems, toElems)
+				continue
+			case keyTag:
+				tag, err := DecodeTagTKey(tk)
+				if err != nil {
+					return nil, err
+				}
+				summary.Tags[tag] = changedMembership(fromElems, toElems)
+				continue
+			}
+			for pos, toElem := range toElems {
+				fromElem, found := fromElems[pos]
+				if !found {
+					summary.Added = append(summary.Added, toElem)
+				} else if !bytes.Equal(fromElem, toElem) {
+					summary.Modified = append(summary.Modified, elementChange{fromElem, toElem})
+				}
+			}
+			for pos, fromElem := range fromElems {
+				if _, found := toElems[pos]; !found {
+					summary.Deleted = append(summary.Deleted, fromElem)
+				}
+			}
+		}
+	}
+	return summary, nil
+}
@@ -1731,6 +1731,9 @@ func (b *Block) setExportedVars() (err error) {
 		return fmt.Errorf("number of labels (%d) exceeds what can be contained in max block size %d", numLabels, MaxBlockSize)
 	}
 
+	if uint32(len(b.data)) < 16+numLabels*8 {
+		return fmt.Errorf("block with %d labels has only %d bytes", numLabels, len(b.data))
+	}
 	b.Labels, err = dvid.AliasByteToUint64(b.data[16 : 16+numLabels*8])
 	if err != nil {
 		return
@@ -1746,6 +1749,9 @@ func (b *Block) setExportedVars() (err error) {
 	pos := uint32(16)
 	pos += numLabels * 8
 	nbytes := numSubBlocks * 2
+	if uint32(len(b.data)) < pos+nbytes {
+		return fmt.Errorf("block with %d sub-blocks is truncated at %d bytes", numSubBlocks, len(b.data))
+	}
 	b.NumSBLabels, err = dvid.AliasByteToUint16(b.data[pos : pos+nbytes])
 	if err != nil {
 		return
@@ -1757,6 +1763,9 @@ func (b *Block) setExportedVars() (err error) {
 
 	pos += nbytes
 	subBlockIndexBytes := numSubBlockIndices * 4
+	if uint32(len(b.data)) < pos+subBlockIndexBytes {
+		return fmt.Errorf("block with %d sub-block indices is truncated at %d bytes", numSubBlockIndices, len(b.data))
+	}
 	b.SBIndices, err = dvid.AliasByteToUint32(b.data[pos : pos+subBlockIndexBytes])
 	if err != nil {
 		return
@@ -13,7 +13,7 @@ import (
 	"testing"
 
 	"github.com/janelia-flyem/dvid/dvid"
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 )
 
 type testData struct {
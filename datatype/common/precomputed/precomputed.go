@@ -0,0 +1,198 @@
+/*
+	Package precomputed supports read-only views of block-based data types in the neuroglancer
+	"precomputed" layout: an info JSON describing the available scales and chunk URLs of the
+	form <scale key>/<x0>-<x1>_<y0>-<y1>_<z0>-<z1>.  Data types use this package to describe
+	their volumes and parse chunk requests, and they supply the chunk data themselves.
+*/
+package precomputed
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// Scale describes one resolution level of a precomputed volume.
+type Scale struct {
+	Key         string     `json:"key"`
+	Size        [3]int32   `json:"size"`
+	VoxelOffset [3]int32   `json:"voxel_offset"`
+	Resolution  [3]float32 `json:"resolution"`
+	ChunkSizes  [][3]int32 `json:"chunk_sizes"`
+	Encoding    string     `json:"encoding"`
+	SegBlock    *[3]int32  `json:"compressed_segmentation_block_size,omitempty"`
+}
+
+// Info is the JSON description of a precomputed volume.
+type Info struct {
+	Type        string  `json:"@type"`
+	VolumeType  string  `json:"type"`
+	DataType    string  `json:"data_type"`
+	NumChannels int     `json:"num_channels"`
+	Scales      []Scale `json:"scales"`
+}
+
+// Volume gives the properties of a data instance needed to construct its Info.
+type Volume struct {
+	VolumeType  string // "image" or "segmentation"
+	DataType    string // e.g., "uint8" or "uint64"
+	Encoding    string // "raw" or "compressed_segmentation"
+	NumChannels int
+
+	BlockSize  dvid.Point3d
+	Resolution [3]float32
+
+	// MinPoint and MaxPoint are the voxel extents at scale 0.  If Empty is true,
+	// there is no stored data and all scales have zero size.
+	MinPoint, MaxPoint dvid.Point3d
+	Empty              bool
+
+	MaxScale uint8
+}
+
+// ScaleKey returns the key used for the given scale in chunk URLs.
+func ScaleKey(scale uint8) string {
+	return fmt.Sprintf("s%d", scale)
+}
+
+// ParseScaleKey returns the scale for a key returned by ScaleKey.
+func ParseScaleKey(key string) (uint8, error) {
+	if !strings.HasPrefix(key, "s") {
+		return 0, fmt.Errorf("bad precomputed scale key %q", key)
+	}
+	scale, err := strconv.ParseUint(key[1:], 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("bad precomputed scale key %q: %v", key, err)
+	}
+	return uint8(scale), nil
+}
+
+// DataTypeName returns the precomputed data_type for a DVID value type.
+func DataTypeName(t dvid.DataType) (string, error) {
+	switch t {
+	case dvid.T_uint8:
+		return "uint8", nil
+	case dvid.T_int8:
+		return "int8", nil
+	case dvid.T_uint16:
+		return "uint16", nil
+	case dvid.T_int16:
+		return "int16", nil
+	case dvid.T_uint32:
+		return "uint32", nil
+	case dvid.T_int32:
+		return "int32", nil
+	case dvid.T_uint64:
+		return "uint64", nil
+	case dvid.T_int64:
+		return "int64", nil
+	case dvid.T_float32:
+		return "float32", nil
+	case dvid.T_float64:
+		return "float64", nil
+	default:
+		return "", fmt.Errorf("data type %v has no precomputed equivalent", t)
+	}
+}
+
+// floorDiv divides rounding toward negative infinity.
+func floorDiv(a, b int32) int32 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// NewInfo returns the Info for a volume.  The bounds at each scale are expanded to
+// block boundaries so every chunk corresponds to a full stored block.
+func NewInfo(vol Volume) Info {
+	info := Info{
+		Type:        "neuroglancer_multiscale_volume",
+		VolumeType:  vol.VolumeType,
+		DataType:    vol.DataType,
+		NumChannels: vol.NumChannels,
+		Scales:      make([]Scale, vol.MaxScale+1),
+	}
+	for scale := uint8(0); scale <= vol.MaxScale; scale++ {
+		s := Scale{
+			Key:        ScaleKey(scale),
+			ChunkSizes: [][3]int32{{vol.BlockSize[0], vol.BlockSize[1], vol.BlockSize[2]}},
+			Encoding:   vol.Encoding,
+		}
+		factor := int32(1) << scale
+		for dim := 0; dim < 3; dim++ {
+			s.Resolution[dim] = vol.Resolution[dim] * float32(factor)
+			if vol.Empty {
+				continue
+			}
+			bs := vol.BlockSize[dim]
+			minBlock := floorDiv(floorDiv(vol.MinPoint[dim], factor), bs)
+			maxBlock := floorDiv(floorDiv(vol.MaxPoint[dim], factor), bs)
+			s.VoxelOffset[dim] = minBlock * bs
+			s.Size[dim] = (maxBlock - minBlock + 1) * bs
+		}
+		if vol.Encoding == "compressed_segmentation" {
+			s.SegBlock = &[3]int32{8, 8, 8}
+		}
+		info.Scales[scale] = s
+	}
+	return info
+}
+
+// ParseChunkName returns the voxel offset and size of a chunk named
+// <x0>-<x1>_<y0>-<y1>_<z0>-<z1> where the end coordinates are exclusive.
+func ParseChunkName(name string) (offset, size dvid.Point3d, err error) {
+	ranges := strings.Split(name, "_")
+	if len(ranges) != 3 {
+		err = fmt.Errorf("bad precomputed chunk name %q: expected x0-x1_y0-y1_z0-z1", name)
+		return
+	}
+	for dim, r := range ranges {
+		// Skip the first character when finding the separator since coordinates may be negative.
+		var sep int
+		if len(r) > 1 {
+			sep = strings.Index(r[1:], "-") + 1
+		}
+		if sep == 0 {
+			err = fmt.Errorf("bad precomputed chunk name %q: expected x0-x1_y0-y1_z0-z1", name)
+			return
+		}
+		var beg, end int64
+		if beg, err = strconv.ParseInt(r[:sep], 10, 32); err != nil {
+			return
+		}
+		if end, err = strconv.ParseInt(r[sep+1:], 10, 32); err != nil {
+			return
+		}
+		if end <= beg {
+			err = fmt.Errorf("bad precomputed chunk name %q: end must be greater than start", name)
+			return
+		}
+		offset[dim] = int32(beg)
+		size[dim] = int32(end - beg)
+	}
+	return
+}
+
+// Planar reorders interleaved voxel data, where each voxel holds numChannels values of
+// bytesPerValue bytes, into the channel-major layout expected by the precomputed raw encoding.
+// Data with a single channel is returned as is.
+func Planar(data []byte, numChannels, bytesPerValue int) []byte {
+	if numChannels <= 1 {
+		return data
+	}
+	voxelBytes := numChannels * bytesPerValue
+	numVoxels := len(data) / voxelBytes
+	planar := make([]byte, len(data))
+	for c := 0; c < numChannels; c++ {
+		dst := planar[c*numVoxels*bytesPerValue:]
+		for i := 0; i < numVoxels; i++ {
+			src := data[i*voxelBytes+c*bytesPerValue:]
+			copy(dst[i*bytesPerValue:(i+1)*bytesPerValue], src[:bytesPerValue])
+		}
+	}
+	return planar
+}
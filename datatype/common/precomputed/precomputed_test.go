@@ -0,0 +1,80 @@
+package precomputed
+
+import (
+	"testing"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+func TestParseChunkName(t *testing.T) {
+	offset, size, err := ParseChunkName("0-64_32-96_128-160")
+	if err != nil {
+		t.Fatalf("Unable to parse chunk name: %v\n", err)
+	}
+	if !offset.Equals(dvid.Point3d{0, 32, 128}) || !size.Equals(dvid.Point3d{64, 64, 32}) {
+		t.Errorf("Bad parse of chunk name: got offset %s, size %s\n", offset, size)
+	}
+	offset, size, err = ParseChunkName("-128--64_-64-0_128-160")
+	if err != nil {
+		t.Fatalf("Unable to parse chunk name with negative coordinates: %v\n", err)
+	}
+	if !offset.Equals(dvid.Point3d{-128, -64, 128}) || !size.Equals(dvid.Point3d{64, 64, 32}) {
+		t.Errorf("Bad parse of chunk name: got offset %s, size %s\n", offset, size)
+	}
+	for _, name := range []string{"0-64_32-96", "0-64_32-96_128", "0-64_96-32_128-160", "a-64_32-96_128-160", "64_0-32_0-32", "-_0-32_0-32"} {
+		if _, _, err := ParseChunkName(name); err == nil {
+			t.Errorf("Expected error parsing bad chunk name %q\n", name)
+		}
+	}
+}
+
+func TestNewInfo(t *testing.T) {
+	info := NewInfo(Volume{
+		VolumeType:  "segmentation",
+		DataType:    "uint64",
+		Encoding:    "compressed_segmentation",
+		NumChannels: 1,
+		BlockSize:   dvid.Point3d{64, 64, 64},
+		Resolution:  [3]float32{8, 8, 8},
+		MinPoint:    dvid.Point3d{-10, 0, 100},
+		MaxPoint:    dvid.Point3d{200, 63, 300},
+		MaxScale:    2,
+	})
+	if len(info.Scales) != 3 {
+		t.Fatalf("Expected 3 scales, got %d\n", len(info.Scales))
+	}
+	expected := []struct {
+		offset, size [3]int32
+		res          [3]float32
+	}{
+		{[3]int32{-64, 0, 64}, [3]int32{320, 64, 256}, [3]float32{8, 8, 8}},
+		{[3]int32{-64, 0, 0}, [3]int32{192, 64, 192}, [3]float32{16, 16, 16}},
+		{[3]int32{-64, 0, 0}, [3]int32{128, 64, 128}, [3]float32{32, 32, 32}},
+	}
+	for scale, s := range info.Scales {
+		if s.Key != ScaleKey(uint8(scale)) {
+			t.Errorf("Bad key for scale %d: %s\n", scale, s.Key)
+		}
+		if s.VoxelOffset != expected[scale].offset || s.Size != expected[scale].size || s.Resolution != expected[scale].res {
+			t.Errorf("Bad scale %d: expected %v, got %v\n", scale, expected[scale], s)
+		}
+		if s.SegBlock == nil || *s.SegBlock != [3]int32{8, 8, 8} {
+			t.Errorf("Bad compressed segmentation block size for scale %d: %v\n", scale, s.SegBlock)
+		}
+	}
+	key, err := ParseScaleKey("s2")
+	if err != nil || key != 2 {
+		t.Errorf("Bad parse of scale key: %d, %v\n", key, err)
+	}
+}
+
+func TestPlanar(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	got := Planar(data, 4, 1)
+	expected := []byte{1, 5, 2, 6, 3, 7, 4, 8}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Bad planar conversion: expected %v, got %v\n", expected, got)
+		}
+	}
+}
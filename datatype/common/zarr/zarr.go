@@ -0,0 +1,699 @@
+/*
+	Package zarr reads and writes 3d chunked arrays stored as zarr (v2) or N5 directory
+	hierarchies on a filesystem visible to the DVID server.  Data types use this package to
+	export a subvolume of their voxels to an array or to import an array into a version.
+
+	Only single-channel arrays of the numeric types supported by dvid.DataType are handled.
+	Voxel data passed to and from this package is always little-endian with x varying fastest,
+	the same layout used by DVID blocks, regardless of the byte order and dimension order
+	used by the array format.
+*/
+package zarr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/server"
+)
+
+// Format is the on-disk layout of a chunked array.
+type Format uint8
+
+const (
+	Zarr Format = iota
+	N5
+)
+
+func (f Format) String() string {
+	switch f {
+	case Zarr:
+		return "zarr"
+	case N5:
+		return "n5"
+	default:
+		return fmt.Sprintf("unknown format %d", f)
+	}
+}
+
+// ParseFormat returns the Format for a string "zarr" or "n5".
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "zarr":
+		return Zarr, nil
+	case "n5":
+		return N5, nil
+	default:
+		return Zarr, fmt.Errorf("unknown array format %q, must be 'zarr' or 'n5'", s)
+	}
+}
+
+// dataType gives the zarr and N5 names of a dvid.DataType.
+type dataType struct {
+	t    dvid.DataType
+	kind byte // zarr type kind: 'u', 'i', or 'f'
+	n5   string
+}
+
+var dataTypes = []dataType{
+	{dvid.T_uint8, 'u', "uint8"},
+	{dvid.T_int8, 'i', "int8"},
+	{dvid.T_uint16, 'u', "uint16"},
+	{dvid.T_int16, 'i', "int16"},
+	{dvid.T_uint32, 'u', "uint32"},
+	{dvid.T_int32, 'i', "int32"},
+	{dvid.T_uint64, 'u', "uint64"},
+	{dvid.T_int64, 'i', "int64"},
+	{dvid.T_float32, 'f', "float32"},
+	{dvid.T_float64, 'f', "float64"},
+}
+
+func getDataType(t dvid.DataType) (dataType, error) {
+	for _, dt := range dataTypes {
+		if dt.t == t {
+			return dt, nil
+		}
+	}
+	return dataType{}, fmt.Errorf("data type %v can't be stored in zarr or N5 arrays", t)
+}
+
+// Array is a 3d chunked array in zarr or N5 format.
+type Array struct {
+	Format Format
+	Path   string
+
+	// Shape and ChunkSize are in voxels and ordered x, y, z.
+	Shape     dvid.Point3d
+	ChunkSize dvid.Point3d
+
+	DataType dvid.DataType
+
+	// Compression of chunks: "raw", "gzip", or for reading only, "zlib".
+	Compression string
+
+	bigEndian bool   // true if values are stored big-endian
+	separator string // zarr chunk key separator
+}
+
+// NumChunks returns the number of chunks along each dimension.
+func (a *Array) NumChunks() dvid.Point3d {
+	var n dvid.Point3d
+	for dim := 0; dim < 3; dim++ {
+		n[dim] = (a.Shape[dim] + a.ChunkSize[dim] - 1) / a.ChunkSize[dim]
+	}
+	return n
+}
+
+// ChunkBytes returns the number of bytes in the full-sized chunk passed to WriteChunk
+// or returned by ReadChunk.
+func (a *Array) ChunkBytes() int {
+	return int(a.ChunkSize.Prod()) * int(dvid.DataTypeBytes(a.DataType))
+}
+
+// Create makes a new array directory at path and writes its metadata.  Any existing
+// metadata is overwritten.
+func Create(path string, format Format, shape, chunkSize dvid.Point3d, t dvid.DataType, compression string) (*Array, error) {
+	dt, err := getDataType(t)
+	if err != nil {
+		return nil, err
+	}
+	for dim := 0; dim < 3; dim++ {
+		if shape[dim] <= 0 || chunkSize[dim] <= 0 {
+			return nil, fmt.Errorf("array shape %s and chunk size %s must be positive", shape, chunkSize)
+		}
+	}
+	switch compression {
+	case "":
+		compression = "raw"
+	case "raw", "gzip":
+	default:
+		return nil, fmt.Errorf("unsupported array compression %q, must be 'raw' or 'gzip'", compression)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+	a := &Array{
+		Format:      format,
+		Path:        path,
+		Shape:       shape,
+		ChunkSize:   chunkSize,
+		DataType:    t,
+		Compression: compression,
+		bigEndian:   format == N5,
+		separator:   ".",
+	}
+	var metadata interface{}
+	var filename string
+	switch format {
+	case Zarr:
+		filename = ".zarray"
+		zarray := zarrayJSON{
+			ZarrFormat: 2,
+			Shape:      []int32{shape[2], shape[1], shape[0]},
+			Chunks:     []int32{chunkSize[2], chunkSize[1], chunkSize[0]},
+			DType:      fmt.Sprintf("<%c%d", dt.kind, dvid.DataTypeBytes(t)),
+			FillValue:  0,
+			Order:      "C",
+		}
+		if dvid.DataTypeBytes(t) == 1 {
+			zarray.DType = fmt.Sprintf("|%c1", dt.kind)
+		}
+		if compression == "gzip" {
+			zarray.Compressor = &zarrCompressor{ID: "gzip", Level: gzip.DefaultCompression}
+		}
+		metadata = zarray
+	case N5:
+		filename = "attributes.json"
+		metadata = n5AttributesJSON{
+			N5:          "2.0.0",
+			Dimensions:  []int32{shape[0], shape[1], shape[2]},
+			BlockSize:   []int32{chunkSize[0], chunkSize[1], chunkSize[2]},
+			DataType:    dt.n5,
+			Compression: &n5Compression{Type: compression},
+		}
+	default:
+		return nil, fmt.Errorf("unknown array format %d", format)
+	}
+	jsonBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(path, filename), jsonBytes, 0644); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Open reads the metadata of an existing zarr or N5 array.  The format is determined by
+// the presence of a ".zarray" or "attributes.json" file in the directory.
+func Open(path string) (*Array, error) {
+	if jsonBytes, err := ioutil.ReadFile(filepath.Join(path, ".zarray")); err == nil {
+		return openZarr(path, jsonBytes)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if jsonBytes, err := ioutil.ReadFile(filepath.Join(path, "attributes.json")); err == nil {
+		return openN5(path, jsonBytes)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no zarr or N5 array found at %q", path)
+}
+
+type zarrCompressor struct {
+	ID    string `json:"id"`
+	Level int    `json:"level,omitempty"`
+}
+
+type zarrayJSON struct {
+	ZarrFormat         int             `json:"zarr_format"`
+	Shape              []int32         `json:"shape"`
+	Chunks             []int32         `json:"chunks"`
+	DType              string          `json:"dtype"`
+	Compressor         *zarrCompressor `json:"compressor"`
+	FillValue          interface{}     `json:"fill_value"`
+	Order              string          `json:"order"`
+	Filters            []interface{}   `json:"filters"`
+	DimensionSeparator string          `json:"dimension_separator,omitempty"`
+}
+
+func openZarr(path string, jsonBytes []byte) (*Array, error) {
+	var zarray zarrayJSON
+	if err := json.Unmarshal(jsonBytes, &zarray); err != nil {
+		return nil, fmt.Errorf("bad .zarray in %q: %v", path, err)
+	}
+	if zarray.ZarrFormat != 2 {
+		return nil, fmt.Errorf("zarr array %q has unsupported zarr_format %d", path, zarray.ZarrFormat)
+	}
+	if len(zarray.Shape) != 3 || len(zarray.Chunks) != 3 {
+		return nil, fmt.Errorf("zarr array %q must be 3d, has shape %v and chunks %v", path, zarray.Shape, zarray.Chunks)
+	}
+	if zarray.Order != "C" {
+		return nil, fmt.Errorf("zarr array %q has unsupported order %q", path, zarray.Order)
+	}
+	if len(zarray.Filters) != 0 {
+		return nil, fmt.Errorf("zarr array %q uses filters, which aren't supported", path)
+	}
+	a := &Array{
+		Format:    Zarr,
+		Path:      path,
+		Shape:     dvid.Point3d{zarray.Shape[2], zarray.Shape[1], zarray.Shape[0]},
+		ChunkSize: dvid.Point3d{zarray.Chunks[2], zarray.Chunks[1], zarray.Chunks[0]},
+		separator: ".",
+	}
+	if zarray.DimensionSeparator != "" {
+		a.separator = zarray.DimensionSeparator
+	}
+	if zarray.Compressor == nil {
+		a.Compression = "raw"
+	} else {
+		switch zarray.Compressor.ID {
+		case "gzip", "zlib":
+			a.Compression = zarray.Compressor.ID
+		default:
+			return nil, fmt.Errorf("zarr array %q has unsupported compressor %q", path, zarray.Compressor.ID)
+		}
+	}
+	if len(zarray.DType) < 3 {
+		return nil, fmt.Errorf("zarr array %q has bad dtype %q", path, zarray.DType)
+	}
+	a.bigEndian = zarray.DType[0] == '>'
+	size, err := strconv.Atoi(zarray.DType[2:])
+	if err != nil {
+		return nil, fmt.Errorf("zarr array %q has bad dtype %q", path, zarray.DType)
+	}
+	found := false
+	for _, dt := range dataTypes {
+		if dt.kind == zarray.DType[1] && int(dvid.DataTypeBytes(dt.t)) == size {
+			a.DataType = dt.t
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("zarr array %q has unsupported dtype %q", path, zarray.DType)
+	}
+	return a, nil
+}
+
+type n5Compression struct {
+	Type    string `json:"type"`
+	UseZlib bool   `json:"useZlib,omitempty"`
+}
+
+type n5AttributesJSON struct {
+	N5          string         `json:"n5,omitempty"`
+	Dimensions  []int32        `json:"dimensions"`
+	BlockSize   []int32        `json:"blockSize"`
+	DataType    string         `json:"dataType"`
+	Compression *n5Compression `json:"compression"`
+}
+
+func openN5(path string, jsonBytes []byte) (*Array, error) {
+	var attrs n5AttributesJSON
+	if err := json.Unmarshal(jsonBytes, &attrs); err != nil {
+		return nil, fmt.Errorf("bad attributes.json in %q: %v", path, err)
+	}
+	if len(attrs.Dimensions) != 3 || len(attrs.BlockSize) != 3 {
+		return nil, fmt.Errorf("N5 dataset %q must be 3d, has dimensions %v and block size %v", path, attrs.Dimensions, attrs.BlockSize)
+	}
+	a := &Array{
+		Format:      N5,
+		Path:        path,
+		Shape:       dvid.Point3d{attrs.Dimensions[0], attrs.Dimensions[1], attrs.Dimensions[2]},
+		ChunkSize:   dvid.Point3d{attrs.BlockSize[0], attrs.BlockSize[1], attrs.BlockSize[2]},
+		Compression: "raw",
+		bigEndian:   true,
+	}
+	if attrs.Compression != nil {
+		switch attrs.Compression.Type {
+		case "raw":
+		case "gzip":
+			a.Compression = "gzip"
+			if attrs.Compression.UseZlib {
+				a.Compression = "zlib"
+			}
+		default:
+			return nil, fmt.Errorf("N5 dataset %q has unsupported compression %q", path, attrs.Compression.Type)
+		}
+	}
+	found := false
+	for _, dt := range dataTypes {
+		if dt.n5 == attrs.DataType {
+			a.DataType = dt.t
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("N5 dataset %q has unsupported dataType %q", path, attrs.DataType)
+	}
+	return a, nil
+}
+
+// chunkPath returns the file path for the chunk with the given chunk coordinate.
+func (a *Array) chunkPath(c dvid.ChunkPoint3d) string {
+	if a.Format == N5 {
+		return filepath.Join(a.Path, strconv.Itoa(int(c[0])), strconv.Itoa(int(c[1])), strconv.Itoa(int(c[2])))
+	}
+	key := strings.Join([]string{strconv.Itoa(int(c[2])), strconv.Itoa(int(c[1])), strconv.Itoa(int(c[0]))}, a.separator)
+	return filepath.Join(a.Path, filepath.FromSlash(key))
+}
+
+// storedSize returns the size of the chunk as stored.  N5 truncates chunks at the
+// upper boundary of the array while zarr always stores full chunks.
+func (a *Array) storedSize(c dvid.ChunkPoint3d) dvid.Point3d {
+	size := a.ChunkSize
+	if a.Format == N5 {
+		for dim := 0; dim < 3; dim++ {
+			if end := (c[dim] + 1) * a.ChunkSize[dim]; end > a.Shape[dim] {
+				size[dim] -= end - a.Shape[dim]
+			}
+		}
+	}
+	return size
+}
+
+// swapBytes reverses the byte order of each value in place.
+func swapBytes(data []byte, valueBytes int) {
+	if valueBytes == 1 {
+		return
+	}
+	for i := 0; i+valueBytes <= len(data); i += valueBytes {
+		for j, k := i, i+valueBytes-1; j < k; j, k = j+1, k-1 {
+			data[j], data[k] = data[k], data[j]
+		}
+	}
+}
+
+// copyBox copies a box of the given size from the start of src, with dimensions srcSize,
+// to the start of dst, with dimensions dstSize.
+func copyBox(dst []byte, dstSize dvid.Point3d, src []byte, srcSize dvid.Point3d, box dvid.Point3d, valueBytes int) {
+	rowBytes := int(box[0]) * valueBytes
+	for z := int32(0); z < box[2]; z++ {
+		for y := int32(0); y < box[1]; y++ {
+			i := int((z*srcSize[1]+y)*srcSize[0]) * valueBytes
+			j := int((z*dstSize[1]+y)*dstSize[0]) * valueBytes
+			copy(dst[j:j+rowBytes], src[i:i+rowBytes])
+		}
+	}
+}
+
+// zeroOutside zeroes the voxels of a full chunk that lie beyond the array shape.
+func (a *Array) zeroOutside(c dvid.ChunkPoint3d, data []byte) {
+	valueBytes := int(dvid.DataTypeBytes(a.DataType))
+	var valid dvid.Point3d
+	for dim := 0; dim < 3; dim++ {
+		valid[dim] = a.Shape[dim] - c[dim]*a.ChunkSize[dim]
+		if valid[dim] >= a.ChunkSize[dim] {
+			valid[dim] = a.ChunkSize[dim]
+		}
+	}
+	if valid == a.ChunkSize {
+		return
+	}
+	nx, ny := a.ChunkSize[0], a.ChunkSize[1]
+	for z := int32(0); z < a.ChunkSize[2]; z++ {
+		for y := int32(0); y < ny; y++ {
+			row := int((z*ny+y)*nx) * valueBytes
+			x0 := valid[0]
+			if z >= valid[2] || y >= valid[1] {
+				x0 = 0
+			}
+			for i := row + int(x0)*valueBytes; i < row+int(nx)*valueBytes; i++ {
+				data[i] = 0
+			}
+		}
+	}
+}
+
+func (a *Array) compress(data []byte) ([]byte, error) {
+	if a.Compression == "raw" {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch a.Compression {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported array compression %q", a.Compression)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (a *Array) decompress(data []byte) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch a.Compression {
+	case "raw":
+		return data, nil
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case "zlib":
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported array compression %q", a.Compression)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// WriteChunk stores a full-sized chunk of little-endian voxel data at chunk coordinate c.
+// Voxels beyond the array shape are not stored by N5 and are zeroed for zarr.
+func (a *Array) WriteChunk(c dvid.ChunkPoint3d, data []byte) error {
+	if len(data) != a.ChunkBytes() {
+		return fmt.Errorf("chunk %s has %d bytes, expected %d", c, len(data), a.ChunkBytes())
+	}
+	valueBytes := int(dvid.DataTypeBytes(a.DataType))
+	size := a.storedSize(c)
+	var out []byte
+	if size == a.ChunkSize {
+		out = make([]byte, len(data))
+		copy(out, data)
+	} else {
+		out = make([]byte, int(size.Prod())*valueBytes)
+		copyBox(out, size, data, a.ChunkSize, size, valueBytes)
+	}
+	if a.Format == Zarr {
+		a.zeroOutside(c, out)
+	}
+	if a.bigEndian {
+		swapBytes(out, valueBytes)
+	}
+	compressed, err := a.compress(out)
+	if err != nil {
+		return err
+	}
+	if a.Format == N5 {
+		header := make([]byte, 16)
+		binary.BigEndian.PutUint16(header[0:2], 0) // default block mode
+		binary.BigEndian.PutUint16(header[2:4], 3)
+		for dim := 0; dim < 3; dim++ {
+			binary.BigEndian.PutUint32(header[4+4*dim:8+4*dim], uint32(size[dim]))
+		}
+		compressed = append(header, compressed...)
+	}
+	path := a.chunkPath(c)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, compressed, 0644)
+}
+
+// ReadChunk returns a full-sized chunk of little-endian voxel data for chunk coordinate c
+// with voxels beyond the array shape set to zero.  If the chunk isn't stored, nil is returned.
+func (a *Array) ReadChunk(c dvid.ChunkPoint3d) ([]byte, error) {
+	stored, err := ioutil.ReadFile(a.chunkPath(c))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	valueBytes := int(dvid.DataTypeBytes(a.DataType))
+	size := a.ChunkSize
+	if a.Format == N5 {
+		if len(stored) < 4 {
+			return nil, fmt.Errorf("N5 chunk %s in %q is too short", c, a.Path)
+		}
+		mode := binary.BigEndian.Uint16(stored[0:2])
+		numDims := int(binary.BigEndian.Uint16(stored[2:4]))
+		headerLen := 4 + 4*numDims
+		if mode == 1 {
+			headerLen += 4
+		} else if mode != 0 {
+			return nil, fmt.Errorf("N5 chunk %s in %q has unsupported mode %d", c, a.Path, mode)
+		}
+		if numDims != 3 || len(stored) < headerLen {
+			return nil, fmt.Errorf("N5 chunk %s in %q has bad header", c, a.Path)
+		}
+		for dim := 0; dim < 3; dim++ {
+			size[dim] = int32(binary.BigEndian.Uint32(stored[4+4*dim : 8+4*dim]))
+			if size[dim] > a.ChunkSize[dim] {
+				return nil, fmt.Errorf("N5 chunk %s in %q has size %s larger than block size %s", c, a.Path, size, a.ChunkSize)
+			}
+		}
+		stored = stored[headerLen:]
+	}
+	data, err := a.decompress(stored)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress chunk %s in %q: %v", c, a.Path, err)
+	}
+	numBytes := int(size.Prod()) * valueBytes
+	if len(data) < numBytes {
+		return nil, fmt.Errorf("chunk %s in %q has %d bytes, expected %d", c, a.Path, len(data), numBytes)
+	}
+	data = data[:numBytes]
+	if a.bigEndian {
+		swapBytes(data, valueBytes)
+	}
+	if size != a.ChunkSize {
+		full := make([]byte, a.ChunkBytes())
+		copyBox(full, a.ChunkSize, data, size, size, valueBytes)
+		data = full
+	}
+	a.zeroOutside(c, data)
+	return data, nil
+}
+
+// SubvolumeReader returns little-endian voxel data for a block-aligned subvolume.
+type SubvolumeReader func(offset, size dvid.Point3d) ([]byte, error)
+
+// SubvolumeWriter stores little-endian voxel data for a block-aligned subvolume.
+type SubvolumeWriter func(offset, size dvid.Point3d, data []byte) error
+
+// Export reads each chunk of the array from the subvolume starting at offset and
+// writes it to the array.  Chunks are read sequentially since the reader is expected to
+// use server.HandlerToken workers itself, while encoding and writing chunk files is
+// parallelized across server.HandlerToken workers.  Returns the number of chunks written.
+func Export(a *Array, offset dvid.Point3d, read SubvolumeReader) (int, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	var numChunks int
+
+	n := a.NumChunks()
+	for z := int32(0); z < n[2]; z++ {
+		for y := int32(0); y < n[1]; y++ {
+			for x := int32(0); x < n[0]; x++ {
+				c := dvid.ChunkPoint3d{x, y, z}
+				chunkOffset := dvid.Point3d{
+					offset[0] + x*a.ChunkSize[0],
+					offset[1] + y*a.ChunkSize[1],
+					offset[2] + z*a.ChunkSize[2],
+				}
+				data, err := read(chunkOffset, a.ChunkSize)
+				if err != nil {
+					wg.Wait()
+					return numChunks, fmt.Errorf("unable to read chunk %s at offset %s: %v", c, chunkOffset, err)
+				}
+				<-server.HandlerToken
+				wg.Add(1)
+				go func(c dvid.ChunkPoint3d, data []byte) {
+					defer func() {
+						server.HandlerToken <- 1
+						wg.Done()
+					}()
+					err := a.WriteChunk(c, data)
+					mu.Lock()
+					if err != nil && firstErr == nil {
+						firstErr = err
+					}
+					if err == nil {
+						numChunks++
+					}
+					mu.Unlock()
+				}(c, data)
+
+				mu.Lock()
+				err = firstErr
+				mu.Unlock()
+				if err != nil {
+					wg.Wait()
+					return numChunks, err
+				}
+			}
+		}
+	}
+	wg.Wait()
+	return numChunks, firstErr
+}
+
+type importedChunk struct {
+	c    dvid.ChunkPoint3d
+	data []byte
+	err  error
+}
+
+// Import reads each chunk of the array and writes it into the subvolume starting at offset.
+// The array chunk size must be a multiple of the block size of the receiving data so each
+// chunk is block-aligned.  Reading and decoding chunk files is parallelized across
+// server.HandlerToken workers, while chunks are written sequentially since the writer is
+// expected to use server.HandlerToken workers itself.  Chunks not stored in the array are
+// skipped.  Returns the number of chunks written.
+func Import(a *Array, offset dvid.Point3d, write SubvolumeWriter) (int, error) {
+	// Limit the number of decoded chunks waiting to be written.
+	pending := make(chan struct{}, server.MaxChunkHandlers)
+	chunkCh := make(chan importedChunk, server.MaxChunkHandlers)
+	go func() {
+		var wg sync.WaitGroup
+		n := a.NumChunks()
+		for z := int32(0); z < n[2]; z++ {
+			for y := int32(0); y < n[1]; y++ {
+				for x := int32(0); x < n[0]; x++ {
+					pending <- struct{}{}
+					<-server.HandlerToken
+					wg.Add(1)
+					go func(c dvid.ChunkPoint3d) {
+						data, err := a.ReadChunk(c)
+						server.HandlerToken <- 1
+						chunkCh <- importedChunk{c, data, err}
+						wg.Done()
+					}(dvid.ChunkPoint3d{x, y, z})
+				}
+			}
+		}
+		wg.Wait()
+		close(chunkCh)
+	}()
+
+	var numChunks int
+	var firstErr error
+	for chunk := range chunkCh {
+		if firstErr == nil && chunk.err != nil {
+			firstErr = chunk.err
+		}
+		if firstErr == nil && chunk.data != nil {
+			chunkOffset := dvid.Point3d{
+				offset[0] + chunk.c[0]*a.ChunkSize[0],
+				offset[1] + chunk.c[1]*a.ChunkSize[1],
+				offset[2] + chunk.c[2]*a.ChunkSize[2],
+			}
+			if err := write(chunkOffset, a.ChunkSize, chunk.data); err != nil {
+				firstErr = fmt.Errorf("unable to write chunk %s at offset %s: %v", chunk.c, chunkOffset, err)
+			} else {
+				numChunks++
+			}
+		}
+		<-pending
+	}
+	return numChunks, firstErr
+}
+
+// CheckAlignment returns an error if the offset isn't aligned with blocks of the given
+// size or the chunk size isn't a multiple of the block size.
+func CheckAlignment(offset, chunkSize, blockSize dvid.Point3d) error {
+	for dim := 0; dim < 3; dim++ {
+		if offset[dim]%blockSize[dim] != 0 {
+			return fmt.Errorf("offset %s must be aligned with block size %s", offset, blockSize)
+		}
+		if chunkSize[dim]%blockSize[dim] != 0 {
+			return fmt.Errorf("chunk size %s must be a multiple of block size %s", chunkSize, blockSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package zarr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// testVolume is a uint16 volume with x varying fastest.
+type testVolume struct {
+	size dvid.Point3d
+	data []byte
+}
+
+func newTestVolume(size dvid.Point3d) *testVolume {
+	vol := &testVolume{size: size, data: make([]byte, size.Prod()*2)}
+	for i := 0; i < int(size.Prod()); i++ {
+		binary.LittleEndian.PutUint16(vol.data[i*2:i*2+2], uint16(i*7+1))
+	}
+	return vol
+}
+
+// subvolume returns the data for a subvolume with zeros outside the volume.
+func (vol *testVolume) subvolume(offset, size dvid.Point3d) []byte {
+	out := make([]byte, size.Prod()*2)
+	for z := int32(0); z < size[2]; z++ {
+		for y := int32(0); y < size[1]; y++ {
+			for x := int32(0); x < size[0]; x++ {
+				vx, vy, vz := offset[0]+x, offset[1]+y, offset[2]+z
+				if vx >= vol.size[0] || vy >= vol.size[1] || vz >= vol.size[2] {
+					continue
+				}
+				i := ((vz*vol.size[1]+vy)*vol.size[0] + vx) * 2
+				j := ((z*size[1]+y)*size[0] + x) * 2
+				copy(out[j:j+2], vol.data[i:i+2])
+			}
+		}
+	}
+	return out
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-zarr-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vol := newTestVolume(dvid.Point3d{40, 20, 30})
+	chunkSize := dvid.Point3d{16, 16, 16}
+	for _, format := range []Format{Zarr, N5} {
+		for _, compression := range []string{"raw", "gzip"} {
+			path := filepath.Join(dir, format.String()+"-"+compression)
+			arr, err := Create(path, format, vol.size, chunkSize, dvid.T_uint16, compression)
+			if err != nil {
+				t.Fatalf("unable to create %s array: %v\n", format, err)
+			}
+			numChunks, err := Export(arr, dvid.Point3d{0, 0, 0}, func(offset, size dvid.Point3d) ([]byte, error) {
+				return vol.subvolume(offset, size), nil
+			})
+			if err != nil {
+				t.Fatalf("unable to export %s array: %v\n", format, err)
+			}
+			if numChunks != 3*2*2 {
+				t.Errorf("expected 12 chunks exported to %s array, got %d\n", format, numChunks)
+			}
+
+			arr2, err := Open(path)
+			if err != nil {
+				t.Fatalf("unable to open %s array: %v\n", format, err)
+			}
+			if arr2.Format != format || arr2.Shape != vol.size || arr2.ChunkSize != chunkSize ||
+				arr2.DataType != dvid.T_uint16 || arr2.Compression != compression {
+				t.Errorf("bad metadata for reopened %s array: %v\n", format, arr2)
+			}
+
+			got := make([]byte, len(vol.data))
+			numChunks, err = Import(arr2, dvid.Point3d{0, 0, 0}, func(offset, size dvid.Point3d, data []byte) error {
+				if !bytes.Equal(data, vol.subvolume(offset, size)) {
+					t.Errorf("%s chunk at offset %s doesn't match volume\n", format, offset)
+				}
+				for z := int32(0); z < size[2] && offset[2]+z < vol.size[2]; z++ {
+					for y := int32(0); y < size[1] && offset[1]+y < vol.size[1]; y++ {
+						for x := int32(0); x < size[0] && offset[0]+x < vol.size[0]; x++ {
+							i := (((offset[2]+z)*vol.size[1]+offset[1]+y)*vol.size[0] + offset[0] + x) * 2
+							j := ((z*size[1]+y)*size[0] + x) * 2
+							copy(got[i:i+2], data[j:j+2])
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unable to import %s array: %v\n", format, err)
+			}
+			if numChunks != 12 {
+				t.Errorf("expected 12 chunks imported from %s array, got %d\n", format, numChunks)
+			}
+			if !bytes.Equal(got, vol.data) {
+				t.Errorf("imported %s array doesn't match exported volume\n", format)
+			}
+		}
+	}
+}
+
+func TestN5ChunkLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-n5-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	arr, err := Create(dir, N5, dvid.Point3d{3, 2, 2}, dvid.Point3d{2, 2, 2}, dvid.T_uint16, "raw")
+	if err != nil {
+		t.Fatalf("unable to create N5 array: %v\n", err)
+	}
+	chunk := make([]byte, arr.ChunkBytes())
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint16(chunk[i*2:i*2+2], uint16(0x0100+i))
+	}
+	if err := arr.WriteChunk(dvid.ChunkPoint3d{1, 0, 0}, chunk); err != nil {
+		t.Fatalf("unable to write N5 chunk: %v\n", err)
+	}
+
+	// The edge chunk should be truncated to 1x2x2 with a big-endian header and values.
+	stored, err := ioutil.ReadFile(filepath.Join(dir, "1", "0", "0"))
+	if err != nil {
+		t.Fatalf("unable to read N5 chunk file: %v\n", err)
+	}
+	expected := []byte{0, 0, 0, 3, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 2, 1, 0, 1, 2, 1, 4, 1, 6}
+	if !bytes.Equal(stored, expected) {
+		t.Errorf("bad N5 chunk file: expected %v, got %v\n", expected, stored)
+	}
+
+	read, err := arr.ReadChunk(dvid.ChunkPoint3d{1, 0, 0})
+	if err != nil {
+		t.Fatalf("unable to read N5 chunk: %v\n", err)
+	}
+	for i := 0; i < 8; i++ {
+		value := binary.LittleEndian.Uint16(read[i*2 : i*2+2])
+		if i%2 == 0 && value != uint16(0x0100+i) {
+			t.Errorf("voxel %d of read chunk: expected %d, got %d\n", i, 0x0100+i, value)
+		}
+		if i%2 == 1 && value != 0 {
+			t.Errorf("voxel %d beyond array shape should be 0, got %d\n", i, value)
+		}
+	}
+
+	missing, err := arr.ReadChunk(dvid.ChunkPoint3d{0, 0, 0})
+	if err != nil || missing != nil {
+		t.Errorf("expected nil for missing chunk, got %v, %v\n", missing, err)
+	}
+}
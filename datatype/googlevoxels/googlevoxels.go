@@ -19,6 +19,7 @@ import (
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/datatype/imagetile"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
 
@@ -26,7 +27,6 @@ import (
 	"golang.org/x/oauth2/google"
 
 	"github.com/golang/snappy"
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 const (
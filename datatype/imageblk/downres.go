@@ -0,0 +1,331 @@
+/*
+	This file supports multi-scale down-resolution of image blocks via the downres package.
+*/
+
+package imageblk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/downres"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// GetMaxDownresLevel returns the number of down-res levels, where level 0 = high-resolution
+// and each subsequent level has one-half the resolution.
+func (d *Data) GetMaxDownresLevel() uint8 {
+	return d.MaxDownresLevel
+}
+
+func (d *Data) StartScaleUpdate(scale uint8) {
+	d.updateMu.Lock()
+	if int(scale) >= len(d.updates) {
+		updates := make([]uint32, int(scale)+1)
+		copy(updates, d.updates)
+		d.updates = updates
+	}
+	d.updates[scale]++
+	d.updateMu.Unlock()
+}
+
+func (d *Data) StopScaleUpdate(scale uint8) {
+	d.updateMu.Lock()
+	if int(scale) >= len(d.updates) || d.updates[scale] == 0 {
+		dvid.Criticalf("StopScaleUpdate(%d) called more than StartScaleUpdate.", scale)
+	} else {
+		d.updates[scale]--
+	}
+	d.updateMu.Unlock()
+}
+
+func (d *Data) ScaleUpdating(scale uint8) bool {
+	d.updateMu.RLock()
+	updating := int(scale) < len(d.updates) && d.updates[scale] > 0
+	d.updateMu.RUnlock()
+	return updating
+}
+
+func (d *Data) AnyScaleUpdating() bool {
+	d.updateMu.RLock()
+	defer d.updateMu.RUnlock()
+	for _, n := range d.updates {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newDownresMutation returns a downres.Mutation if the data supports lower resolution
+// scales, else nil.
+func (d *Data) newDownresMutation(v dvid.VersionID, mutID uint64) *downres.Mutation {
+	if d.MaxDownresLevel == 0 {
+		return nil
+	}
+	return downres.NewMutation(d, v, mutID)
+}
+
+// For any lores block, the mutated higher-res blocks for each of its octants.
+type octantMap map[dvid.IZYXString][8][]byte
+
+// Group hires blocks by octants so we see when we actually need to GET a lower-res block.
+func getHiresChanges(hires downres.BlockMap) (octantMap, error) {
+	octants := make(octantMap)
+	for hiresZYX, value := range hires {
+		block, ok := value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("bad changing block %s: expected []byte got %v", hiresZYX, value)
+		}
+		hresCoord, err := hiresZYX.ToChunkPoint3d()
+		if err != nil {
+			return nil, err
+		}
+		loresZYX := dvid.ChunkPoint3d{hresCoord[0] >> 1, hresCoord[1] >> 1, hresCoord[2] >> 1}.ToIZYXString()
+		octidx := ((hresCoord[2] & 1) << 2) + ((hresCoord[1] & 1) << 1) + (hresCoord[0] & 1)
+		oct := octants[loresZYX]
+		oct[octidx] = block
+		octants[loresZYX] = oct
+	}
+	return octants, nil
+}
+
+// downresOctants writes the down-res of any non-nil hires octant blocks into the lores block.
+func (d *Data) downresOctants(lores []byte, octants [8][]byte) error {
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return fmt.Errorf("block size for data %q is not 3d: %v", d.DataName(), d.BlockSize())
+	}
+	if blockSize[0]%2 != 0 || blockSize[1]%2 != 0 || blockSize[2]%2 != 0 {
+		return fmt.Errorf("block size for data %q must be even for down-res: %s", d.DataName(), blockSize)
+	}
+	elemBytes := int64(d.Values.BytesPerElement())
+	bx, by, bz := int64(blockSize[0]), int64(blockSize[1]), int64(blockSize[2])
+	blockBytes := bx * by * bz * elemBytes
+	if int64(len(lores)) != blockBytes {
+		return fmt.Errorf("lores block for data %q has %d bytes, expected %d", d.DataName(), len(lores), blockBytes)
+	}
+	hx, hy, hz := bx/2, by/2, bz/2
+	var voxels [8][]byte
+	for octidx, hires := range octants {
+		if hires == nil {
+			continue
+		}
+		if int64(len(hires)) != blockBytes {
+			return fmt.Errorf("hires block for data %q has %d bytes, expected %d", d.DataName(), len(hires), blockBytes)
+		}
+		ox, oy, oz := int64(octidx&1)*hx, int64((octidx>>1)&1)*hy, int64((octidx>>2)&1)*hz
+		for z := int64(0); z < hz; z++ {
+			for y := int64(0); y < hy; y++ {
+				for x := int64(0); x < hx; x++ {
+					var n int
+					for dz := int64(0); dz < 2; dz++ {
+						for dy := int64(0); dy < 2; dy++ {
+							i := (((2*z+dz)*by+2*y+dy)*bx + 2*x) * elemBytes
+							voxels[n] = hires[i : i+elemBytes]
+							voxels[n+1] = hires[i+elemBytes : i+2*elemBytes]
+							n += 2
+						}
+					}
+					j := (((oz+z)*by+oy+y)*bx + ox + x) * elemBytes
+					dst := lores[j : j+elemBytes]
+					if d.Interpolable {
+						d.averageElements(dst, voxels)
+					} else {
+						modeElement(dst, voxels)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// averageElements stores into dst the average of each value across the given elements.
+func (d *Data) averageElements(dst []byte, elems [8][]byte) {
+	var offset int
+	for _, dv := range d.Values {
+		size := int(dv.ValueBytes())
+		end := offset + size
+		switch dv.T {
+		case dvid.T_uint8:
+			var sum uint32
+			for _, elem := range elems {
+				sum += uint32(elem[offset])
+			}
+			dst[offset] = uint8(sum / 8)
+		case dvid.T_int8:
+			var sum int32
+			for _, elem := range elems {
+				sum += int32(int8(elem[offset]))
+			}
+			dst[offset] = uint8(int8(sum / 8))
+		case dvid.T_uint16:
+			var sum uint32
+			for _, elem := range elems {
+				sum += uint32(binary.LittleEndian.Uint16(elem[offset:end]))
+			}
+			binary.LittleEndian.PutUint16(dst[offset:end], uint16(sum/8))
+		case dvid.T_int16:
+			var sum int32
+			for _, elem := range elems {
+				sum += int32(int16(binary.LittleEndian.Uint16(elem[offset:end])))
+			}
+			binary.LittleEndian.PutUint16(dst[offset:end], uint16(int16(sum/8)))
+		case dvid.T_uint32:
+			var sum uint64
+			for _, elem := range elems {
+				sum += uint64(binary.LittleEndian.Uint32(elem[offset:end]))
+			}
+			binary.LittleEndian.PutUint32(dst[offset:end], uint32(sum/8))
+		case dvid.T_int32:
+			var sum int64
+			for _, elem := range elems {
+				sum += int64(int32(binary.LittleEndian.Uint32(elem[offset:end])))
+			}
+			binary.LittleEndian.PutUint32(dst[offset:end], uint32(int32(sum/8)))
+		case dvid.T_uint64:
+			// Sum quotients and remainders separately to avoid overflow.
+			var quot, rem uint64
+			for _, elem := range elems {
+				val := binary.LittleEndian.Uint64(elem[offset:end])
+				quot += val >> 3
+				rem += val & 7
+			}
+			binary.LittleEndian.PutUint64(dst[offset:end], quot+rem>>3)
+		case dvid.T_int64:
+			var quot, rem int64
+			for _, elem := range elems {
+				val := int64(binary.LittleEndian.Uint64(elem[offset:end]))
+				quot += val >> 3
+				rem += val & 7
+			}
+			binary.LittleEndian.PutUint64(dst[offset:end], uint64(quot+rem>>3))
+		case dvid.T_float32:
+			var sum float64
+			for _, elem := range elems {
+				sum += float64(math.Float32frombits(binary.LittleEndian.Uint32(elem[offset:end])))
+			}
+			binary.LittleEndian.PutUint32(dst[offset:end], math.Float32bits(float32(sum/8)))
+		case dvid.T_float64:
+			var sum float64
+			for _, elem := range elems {
+				sum += math.Float64frombits(binary.LittleEndian.Uint64(elem[offset:end]))
+			}
+			binary.LittleEndian.PutUint64(dst[offset:end], math.Float64bits(sum/8))
+		}
+		offset = end
+	}
+}
+
+// modeElement stores into dst the most frequent element, with ties going to the
+// earliest element.
+func modeElement(dst []byte, elems [8][]byte) {
+	best, bestCount := 0, 0
+	for i := range elems {
+		var count int
+		for j := range elems {
+			if string(elems[i]) == string(elems[j]) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+	copy(dst, elems[best])
+}
+
+type octantMsg struct {
+	loresZYX dvid.IZYXString
+	octant   [8][]byte
+}
+
+func (d *Data) downresOctant(v dvid.VersionID, hiresScale uint8, mu *sync.Mutex, downresBMap downres.BlockMap, batch storage.Batch, octantCh chan octantMsg, errCh chan error) {
+	for msg := range octantCh {
+		var numBlocks int
+		for _, block := range msg.octant {
+			if block != nil {
+				numBlocks++
+			}
+		}
+
+		// If not all octants changed, start from the stored lores block.
+		var loresBlock []byte
+		if numBlocks < 8 {
+			var err error
+			loresBlock, err = d.GetBlock(v, NewScaledTKeyByCoord(hiresScale+1, msg.loresZYX))
+			if err != nil {
+				errCh <- err
+				return
+			}
+		}
+		if len(loresBlock) == 0 {
+			loresBlock = d.BackgroundBlock()
+		}
+		if err := d.downresOctants(loresBlock, msg.octant); err != nil {
+			errCh <- err
+			return
+		}
+
+		serialization, err := dvid.SerializeData(loresBlock, d.Compression(), d.Checksum())
+		if err != nil {
+			errCh <- fmt.Errorf("unable to serialize downres block in %q: %v", d.DataName(), err)
+			return
+		}
+		mu.Lock()
+		downresBMap[msg.loresZYX] = loresBlock
+		batch.Put(NewScaledTKeyByCoord(hiresScale+1, msg.loresZYX), serialization)
+		mu.Unlock()
+		errCh <- nil
+	}
+}
+
+// StoreDownres computes a downscale representation of a set of mutated blocks.
+// Implements the downres.Downreser interface.
+func (d *Data) StoreDownres(v dvid.VersionID, hiresScale uint8, hires downres.BlockMap) (downres.BlockMap, error) {
+	timedLog := dvid.NewTimeLog()
+	if hiresScale >= d.MaxDownresLevel {
+		return nil, fmt.Errorf("can't downres %q scale %d since max downres scale is %d", d.DataName(), hiresScale, d.MaxDownresLevel)
+	}
+	octants, err := getHiresChanges(hires)
+	if err != nil {
+		return nil, err
+	}
+
+	batcher, err := datastore.GetKeyValueBatcher(d)
+	if err != nil {
+		return nil, err
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	batch := batcher.NewBatch(ctx)
+
+	mu := new(sync.Mutex)
+	downresBMap := make(downres.BlockMap)
+	octantCh := make(chan octantMsg, len(octants))
+	errCh := make(chan error, len(octants))
+	defer close(octantCh)
+
+	numProcessors := runtime.NumCPU()
+	for i := 0; i < numProcessors; i++ {
+		go d.downresOctant(v, hiresScale, mu, downresBMap, batch, octantCh, errCh)
+	}
+	for loresZYX, octant := range octants {
+		octantCh <- octantMsg{loresZYX, octant}
+	}
+	for i := 0; i < len(octants); i++ {
+		if err := <-errCh; err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		return nil, fmt.Errorf("error on trying to write downres batch of scale %d->%d: %v", hiresScale, hiresScale+1, err)
+	}
+	timedLog.Infof("Computed down-resolution of %d octants for data %q", len(octants), d.DataName())
+	return downresBMap, nil
+}
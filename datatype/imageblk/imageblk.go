@@ -14,6 +14,7 @@ import (
 	"image"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
 	"strconv"
@@ -21,6 +22,7 @@ import (
 	"sync"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/zarr"
 	"github.com/janelia-flyem/dvid/datatype/roi"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
@@ -64,6 +66,11 @@ $ dvid repo <UUID> new imageblk <data name> <settings...>
     VoxelSize      Resolution of voxels (default: %f)
     VoxelUnits     Resolution units (default: "nanometers")
     Background     Integer value that signifies background in any element (default: 0)
+    MaxDownresLevel  The maximum down-res level supported.  Each down-res is factor of 2.  Lower
+                     resolution scales are computed as blocks are POSTed, using averaging for
+                     interpolable data and the most frequent value otherwise.  (default: 0)
+    Compression    Compression of stored blocks: "none", "snappy", "lz4" (default), "gzip", "jpeg",
+                     "zstd", or "gzip:<level>" and "zstd:<level>" with zstd levels from 1 to 22.
 
 $ dvid node <UUID> <data name> load <offset> <image glob>
 
@@ -110,6 +117,46 @@ $ dvid node <UUID> <data name> roi <new roi data name> <background values separa
 
     $ dvid node 3f8c mygrayscale roi grayscale_roi 0,255
 
+$ dvid node <UUID> <data name> export <format> <offset> <size> <path> [compression=gzip]
+
+    Asynchronously writes a subvolume of single-channel data into a new zarr or N5 array
+    on the DVID server's filesystem.  The array chunks correspond to blocks of the data
+    instance, so the offset must be block-aligned.  The export runs as a job that can be
+    monitored and canceled using the /api/jobs endpoints.
+
+    Example:
+
+    $ dvid node 3f8c mygrayscale export zarr 0,0,0 1024,1024,512 /data/grayscale.zarr compression=gzip
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data to export.
+    format        "zarr" or "n5".
+    offset        3d coordinate in the format "x,y,z" of the first voxel exported.
+    size          Size of the exported subvolume in the format "x,y,z".
+    path          Path of the array directory visible to the DVID server.
+    compression   Optional compression of chunks: "raw" (default) or "gzip".
+
+$ dvid node <UUID> <data name> import <offset> <path>
+
+    Asynchronously writes an existing zarr or N5 array on the DVID server's filesystem into
+    the version node, with the first voxel of the array stored at the given block-aligned
+    offset.  The array must be 3d with the same value type as the data instance and a chunk
+    size that is a multiple of the block size.  Chunks missing from the array are skipped.
+    The import runs as a job that can be monitored and canceled using the /api/jobs endpoints.
+
+    Example:
+
+    $ dvid node 3f8c mygrayscale import 0,0,0 /data/grayscale.n5/s0
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data to receive the array.
+    offset        3d coordinate in the format "x,y,z" at which to store the first array voxel.
+    path          Path of the array directory visible to the DVID server.
+
     
     ------------------
 
@@ -212,6 +259,8 @@ GET  <api URL>/node/<UUID>/<data name>/isotropic/<dims>/<size>/<offset>[/<format
     throttle      Only works for 3d data requests.  If "true", makes sure only N compute-intense operation 
                     (all API calls that can be throttled) are handled.  If the server can't initiate the API 
                     call right away, a 503 (Service Unavailable) status code is returned.
+    scale         A number from 0 up to MaxDownresLevel where each level beyond 0 has 1/2 resolution
+                    of the previous level.  Level 0 (default) is the highest resolution.
 
 GET  <api URL>/node/<UUID>/<data name>/specificblocks[?queryopts]
 
@@ -262,9 +311,13 @@ GET  <api URL>/node/<UUID>/<data name>/specificblocks[?queryopts]
 
     Query-string Options:
 
-    compression   Allows retrieval of block data in default storage or as "uncompressed".
+    compression   Allows retrieval of block data in default storage, as "uncompressed", or
+                    "zstd" compressed.  Zstd-compressed blocks are sent without recompression
+                    if they are stored with zstd compression.
     blocks	  x,y,z... block string
     prefetch	  ("on" or "true") Do not actually send data, non-blocking (default "off")
+    scale         A number from 0 up to MaxDownresLevel where each level beyond 0 has 1/2 resolution
+                    of the previous level.  Level 0 (default) is the highest resolution.
 
 
 GET  <api URL>/node/<UUID>/<data name>/subvolblocks/<size>/<offset>[?queryopts]
@@ -318,10 +371,12 @@ GET  <api URL>/node/<UUID>/<data name>/subvolblocks/<size>/<offset>[?queryopts]
 
     Query-string Options:
 
-    compression   Allows retrieval of block data in "jpeg" (default) or "uncompressed".
+    compression   Allows retrieval of block data in "jpeg" (default), "uncompressed", or "zstd".
     throttle      If "true", makes sure only N compute-intense operation (all API calls that can be throttled) 
                     are handled.  If the server can't initiate the API call right away, a 503 (Service Unavailable) 
                     status code is returned.
+    scale         A number from 0 up to MaxDownresLevel where each level beyond 0 has 1/2 resolution
+                    of the previous level.  Level 0 (default) is the highest resolution.
 
 
 
@@ -371,6 +426,9 @@ GET  <api URL>/node/<UUID>/<data name>/raw/<dims>/<size>/<offset>[/<format>][?qu
     throttle      Only works for 3d data requests.  If "true", makes sure only N compute-intense operation 
                     (all API calls that can be throttled) are handled.  If the server can't initiate the API 
                     call right away, a 503 (Service Unavailable) status code is returned.
+    scale         A number from 0 up to MaxDownresLevel where each level beyond 0 has 1/2 resolution
+                    of the previous level.  Level 0 (default) is the highest resolution.  The size and
+                    offset are given in voxels at the requested scale.  An roi can only be used at scale 0.
 
 POST <api URL>/node/<UUID>/<data name>/raw/0_1_2/<size>/<offset>[?queryopts]
 
@@ -381,6 +439,10 @@ POST <api URL>/node/<UUID>/<data name>/raw/0_1_2/<size>/<offset>[?queryopts]
 
     POST <api URL>/node/3f8c/grayscale/raw/0_1_2/512_256_128/0_0_32
 
+    If the data instance has a MaxDownresLevel greater than 0, the lower resolution scales
+    are updated for all changed blocks before the POST returns.  The same holds for POSTs
+    to the "blocks" endpoint.
+
     Throttling can be enabled by passing a "throttle=true" query string.  Throttling makes sure
     only one compute-intense operation (all API calls that can be throttled) is handled.
     If the server can't initiate the API call right away, a 503 (Service Unavailable) status
@@ -404,6 +466,84 @@ POST <api URL>/node/<UUID>/<data name>/raw/0_1_2/<size>/<offset>[?queryopts]
                     (all API calls that can be throttled) are handled.  If the server can't initiate the API 
                     call right away, a 503 (Service Unavailable) status code is returned.
 
+POST <api URL>/node/<UUID>/<data name>/export?format=<format>&offset=<offset>&size=<size>&path=<path>[&compression=gzip]
+
+    Writes a subvolume of single-channel data into a new zarr or N5 array on the DVID
+    server's filesystem and returns JSON giving the number of chunks written, e.g.,
+    { "chunks": 64 }.  The array chunks correspond to blocks of the data instance, so the
+    offset must be block-aligned.  Exports are allowed on locked nodes.
+
+    The path is relative to the "exportRoot" directory in the server configuration, and
+    absolute paths or paths containing ".." are rejected.  HTTP exports are disabled if no
+    export root is configured, although the command line can write to any path.
+
+    Example: 
+
+    POST <api URL>/node/3f8c/grayscale/export?format=n5&offset=0_0_0&size=512_512_256&path=gray.n5
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data.
+
+    Query-string Options:
+
+    format        "zarr" or "n5".
+    offset        Coordinate of the first exported voxel in "x_y_z" format.
+    size          Size of the exported subvolume in "x_y_z" format.
+    path          Path of the array directory relative to the server's export root.
+    compression   Compression of chunks: "raw" (default) or "gzip".
+
+POST <api URL>/node/<UUID>/<data name>/import?offset=<offset>&path=<path>[&mutate=true]
+
+    Writes an existing zarr or N5 array on the DVID server's filesystem into the version node
+    and returns JSON giving the number of chunks written.  The array must be 3d with the same
+    value type as the data instance and a chunk size that is a multiple of the block size.
+    Chunk files are read in parallel.  Chunks missing from the array are skipped, and voxels
+    of edge chunks beyond the array shape are stored as zero.
+
+    As with exports, the path is relative to the "exportRoot" directory in the server
+    configuration, and HTTP imports are disabled if no export root is configured.
+
+    Example: 
+
+    POST <api URL>/node/3f8c/grayscale/import?offset=0_0_1024&path=gray.zarr
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data.
+
+    Query-string Options:
+
+    offset        Coordinate at which the first array voxel is stored in "x_y_z" format.
+                    Must be block-aligned.
+    path          Path of the array directory relative to the server's export root.
+    mutate        If "true", the import is handled as a mutation of prior data.  See the
+                    POST /raw endpoint.
+
+GET  <api URL>/node/<UUID>/<data name>/precomputed/info
+GET  <api URL>/node/<UUID>/<data name>/precomputed/<scale key>/<chunk name>
+
+    Read-only view of the data in the neuroglancer "precomputed" format, so the URL
+    <api URL>/node/<UUID>/<data name>/precomputed can be used as a precomputed data source.
+    The "info" endpoint returns the JSON description of the volume with one scale for each
+    level from 0 up to MaxDownresLevel.  Chunks correspond to blocks and are returned using
+    the "raw" encoding.  Multi-channel data is returned with channels in separate planes.
+
+    Example: 
+
+    GET <api URL>/node/3f8c/grayscale/precomputed/info
+    GET <api URL>/node/3f8c/grayscale/precomputed/s1/0-32_32-64_64-96
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data.
+    scale key     The key of a scale given in the info, e.g., "s0" for scale 0.
+    chunk name    The voxel bounds of the chunk at the given scale in the form
+                    <x0>-<x1>_<y0>-<y1>_<z0>-<z1> where the end coordinates are exclusive.
+
 GET  <api URL>/node/<UUID>/<data name>/arb/<top left>/<top right>/<bottom left>/<res>[/<format>][?queryopts]
 
     Retrieves non-orthogonal (arbitrarily oriented planar) image data of named 3d data 
@@ -459,6 +599,11 @@ POST <api URL>/node/<UUID>/<data name>/blocks/<block coord>/<spanX>
     block coord   The block coordinate of the first block in X_Y_Z format.  Block coordinates
                   can be derived from voxel coordinates by dividing voxel coordinates by
                   the block size for a data type.
+
+    Query-string Options:
+
+    compression   "uncompressed" (default) or "zstd", in which case the entire sequence of
+                  blocks is zstd-compressed for both GET and POST.
 `
 
 var (
@@ -757,6 +902,10 @@ type Properties struct {
 
 	// Background value for data
 	Background uint8
+
+	// Maximum down-resolution level supported.  Each down-res level is 2x scope of
+	// the higher level.
+	MaxDownresLevel uint8
 }
 
 func (d *Data) PropertiesWithExtents(ctx *datastore.VersionedCtx) (props Properties, err error) {
@@ -775,6 +924,7 @@ func (d *Data) PropertiesWithExtents(ctx *datastore.VersionedCtx) (props Propert
 	props.Extents.MinIndex = verExtents.MinIndex
 	props.Extents.MaxIndex = verExtents.MaxIndex
 	props.Background = d.Properties.Background
+	props.MaxDownresLevel = d.Properties.MaxDownresLevel
 	return
 }
 
@@ -805,6 +955,7 @@ func (p *Properties) copyImmutable(p2 *Properties) {
 	copy(p.Resolution.VoxelUnits, p2.Resolution.VoxelUnits)
 
 	p.Background = p2.Background
+	p.MaxDownresLevel = p2.MaxDownresLevel
 }
 
 // setDefault sets Voxels properties to default values.
@@ -880,6 +1031,16 @@ func (p *Properties) setByConfig(config dvid.Config) error {
 		}
 		p.Background = uint8(background)
 	}
+	levels, found, err := config.GetInt("MaxDownresLevel")
+	if err != nil {
+		return err
+	}
+	if found {
+		if levels < 0 || levels > 255 {
+			return fmt.Errorf("illegal number of down-res levels specified (%d): must be 0 <= n <= 255", levels)
+		}
+		p.MaxDownresLevel = uint8(levels)
+	}
 	return nil
 }
 
@@ -1036,6 +1197,18 @@ type Data struct {
 	*datastore.Data
 	Properties
 	sync.Mutex // to protect extent updates
+
+	updates  []uint32 // tracks updating to each scale [0:MaxDownresLevel+1]
+	updateMu sync.RWMutex
+}
+
+// IsMutationRequest overrides the default behavior to specify POST /export as an immutable
+// request.
+func (d *Data) IsMutationRequest(action, endpoint string) bool {
+	if endpoint == "export" && strings.ToLower(action) == "post" {
+		return false
+	}
+	return d.Data.IsMutationRequest(action, endpoint) // default for rest.
 }
 
 func (d *Data) Equals(d2 *Data) bool {
@@ -1700,6 +1873,67 @@ func (d *Data) DoRPC(req datastore.Request, reply *datastore.Response) error {
 		}
 		return d.ForegroundROI(req, reply)
 
+	case "export":
+		var uuidStr, dataName, cmdStr, formatStr, offsetStr, sizeStr, path string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &formatStr, &offsetStr, &sizeStr, &path)
+		if path == "" {
+			return fmt.Errorf("Poorly formatted export command.  See command-line help.")
+		}
+		format, err := zarr.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+		offset, err := dvid.StringToPoint3d(offsetStr, ",")
+		if err != nil {
+			return fmt.Errorf("Illegal offset specification: %s: %v", offsetStr, err)
+		}
+		size, err := dvid.StringToPoint3d(sizeStr, ",")
+		if err != nil {
+			return fmt.Errorf("Illegal size specification: %s: %v", sizeStr, err)
+		}
+		compression, _ := req.Setting("compression")
+		uuid, versionID, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		job := dvid.NewJob("imageblk export", req.User, uuid, d.DataName())
+		reply.Text = fmt.Sprintf("Exporting data instance %q @ node %s to %s array %s as job %d...\n", dataName, uuidStr, format, path, job.ID())
+		go func() {
+			_, err := d.ExportArray(versionID, path, format, offset, size, compression, job)
+			job.Finish(err)
+		}()
+
+	case "import":
+		var uuidStr, dataName, cmdStr, offsetStr, path string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &offsetStr, &path)
+		if path == "" {
+			return fmt.Errorf("Poorly formatted import command.  See command-line help.")
+		}
+		offset, err := dvid.StringToPoint3d(offsetStr, ",")
+		if err != nil {
+			return fmt.Errorf("Illegal offset specification: %s: %v", offsetStr, err)
+		}
+		uuid, versionID, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		locked, err := datastore.LockedVersion(versionID)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return fmt.Errorf("cannot import into locked node %s", uuid)
+		}
+		if err = datastore.AddToNodeLog(uuid, []string{req.Command.String()}); err != nil {
+			return err
+		}
+		job := dvid.NewJob("imageblk import", req.User, uuid, d.DataName())
+		reply.Text = fmt.Sprintf("Importing array %s into data instance %q @ node %s as job %d...\n", path, dataName, uuidStr, job.ID())
+		go func() {
+			_, err := d.ImportArray(versionID, path, offset, false, job)
+			job.Finish(err)
+		}()
+
 	default:
 		return fmt.Errorf("Unknown command.  Data instance '%s' [%s] does not support '%s' command.",
 			d.DataName(), d.TypeName(), req.TypeCommand())
@@ -1748,12 +1982,17 @@ func (d *Data) SendBlockSimple(w http.ResponseWriter, x, y, z int32, v []byte, c
 
 	// Do any adjustment of sent data based on compression request
 	var data []byte
-	if compression == "uncompressed" {
+	if compression == "uncompressed" || (compression == "zstd" && format != dvid.Zstd) {
 		var err error
 		data, _, err = dvid.DeserializeData(v, true)
 		if err != nil {
 			return err
 		}
+		if compression == "zstd" {
+			if data, err = dvid.ZstdCompress(data, dvid.DefaultCompression); err != nil {
+				return err
+			}
+		}
 	} else {
 		data = v[start:]
 	}
@@ -1773,13 +2012,16 @@ func (d *Data) SendBlockSimple(w http.ResponseWriter, x, y, z int32, v []byte, c
 }
 
 // SendBlocksSpecific writes data to the blocks specified -- best for non-ordered backend
-func (d *Data) SendBlocksSpecific(ctx *datastore.VersionedCtx, w http.ResponseWriter, compression string, blockstring string, isprefetch bool) (numBlocks int, err error) {
+func (d *Data) SendBlocksSpecific(ctx *datastore.VersionedCtx, w http.ResponseWriter, scale uint8, compression string, blockstring string, isprefetch bool) (numBlocks int, err error) {
 	w.Header().Set("Content-type", "application/octet-stream")
 
-	if compression != "uncompressed" && compression != "jpeg" && compression != "" {
+	if compression != "uncompressed" && compression != "jpeg" && compression != "zstd" && compression != "" {
 		err = fmt.Errorf("don't understand 'compression' query string value: %s", compression)
 		return
 	}
+	if err = d.checkScale(scale); err != nil {
+		return
+	}
 	timedLog := dvid.NewTimeLog()
 	defer timedLog.Infof("SendBlocks Specific ")
 
@@ -1829,7 +2071,7 @@ func (d *Data) SendBlocksSpecific(ctx *datastore.VersionedCtx, w http.ResponseWr
 				}()
 			}
 			indexBeg := dvid.IndexZYX(dvid.ChunkPoint3d{xloc, yloc, zloc})
-			keyBeg := NewTKey(&indexBeg)
+			keyBeg := NewScaledTKey(scale, &indexBeg)
 
 			value, err := store.Get(ctx, keyBeg)
 			if err != nil {
@@ -1858,13 +2100,17 @@ func (d *Data) SendBlocksSpecific(ctx *datastore.VersionedCtx, w http.ResponseWr
 	return
 }
 
-// GetBlocks returns a slice of bytes corresponding to all the blocks along a span in X
-func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, subvol *dvid.Subvolume, compression string) error {
+// SendBlocks writes all the blocks within a block-aligned subvolume at the given scale,
+// where the subvolume is in the voxel space of that scale.
+func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, scale uint8, subvol *dvid.Subvolume, compression string) error {
 	w.Header().Set("Content-type", "application/octet-stream")
 
-	if compression != "uncompressed" && compression != "jpeg" && compression != "" {
+	if compression != "uncompressed" && compression != "jpeg" && compression != "zstd" && compression != "" {
 		return fmt.Errorf("don't understand 'compression' query string value: %s", compression)
 	}
+	if err := d.checkScale(scale); err != nil {
+		return err
+	}
 
 	// convert x,y,z coordinates to block coordinates
 	blocksize := subvol.Size().Div(d.BlockSize())
@@ -1881,7 +2127,7 @@ func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, su
 	// if only one block is requested, avoid the range query
 	if blocksize.Value(0) == int32(1) && blocksize.Value(1) == int32(1) && blocksize.Value(2) == int32(1) {
 		indexBeg := dvid.IndexZYX(dvid.ChunkPoint3d{blockoffset.Value(0), blockoffset.Value(1), blockoffset.Value(2)})
-		keyBeg := NewTKey(&indexBeg)
+		keyBeg := NewScaledTKey(scale, &indexBeg)
 
 		value, err := store.Get(ctx, keyBeg)
 		if err != nil {
@@ -1911,9 +2157,9 @@ func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, su
 				endPoint := dvid.ChunkPoint3d{blockoffset.Value(0) + blocksize.Value(0) - 1, blockoffset.Value(1) + yiter, blockoffset.Value(2) + ziter}
 				indexBeg := dvid.IndexZYX(beginPoint)
 				sx, sy, sz := indexBeg.Unpack()
-				begTKey := NewTKey(&indexBeg)
+				begTKey := NewScaledTKey(scale, &indexBeg)
 				indexEnd := dvid.IndexZYX(endPoint)
-				endTKey := NewTKey(&indexEnd)
+				endTKey := NewScaledTKey(scale, &indexEnd)
 
 				// Send the entire range of key-value pairs to chunk processor
 				err = okv.ProcessRange(ctx, begTKey, endTKey, &storage.ChunkOp{}, func(c *storage.Chunk) error {
@@ -1948,7 +2194,7 @@ func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, su
 				for xiter := int32(0); xiter < blocksize.Value(0); xiter++ {
 					currPoint := dvid.ChunkPoint3d{blockoffset.Value(0) + xiter, blockoffset.Value(1) + yiter, blockoffset.Value(2) + ziter}
 					currPoint2 := dvid.IndexZYX(currPoint)
-					currTKey := NewTKey(&currPoint2)
+					currTKey := NewScaledTKey(scale, &currPoint2)
 					tkeys = append(tkeys, currTKey)
 				}
 				// Send the entire range of key-value pairs to chunk processor
@@ -1994,6 +2240,23 @@ func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, su
 	return err
 }
 
+func getScale(queryStrings url.Values) (scale uint8, err error) {
+	scaleStr := queryStrings.Get("scale")
+	if scaleStr != "" {
+		var scaleInt int
+		scaleInt, err = strconv.Atoi(scaleStr)
+		if err != nil {
+			return
+		}
+		if scaleInt < 0 || scaleInt > 255 {
+			err = fmt.Errorf("scale must be 0 to 255, not %d", scaleInt)
+			return
+		}
+		scale = uint8(scaleInt)
+	}
+	return
+}
+
 // ServeHTTP handles all incoming HTTP requests for this data.
 func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request) (activity map[string]interface{}) {
 	timedLog := dvid.NewTimeLog()
@@ -2036,6 +2299,12 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 	}
 
+	scale, err := getScale(queryStrings)
+	if err != nil {
+		server.BadRequest(w, r, "bad scale specified: %v", err)
+		return
+	}
+
 	// Handle POST on data -> setting of configuration
 	if len(parts) == 3 && action == "put" {
 		fmt.Printf("Setting configuration of data '%s'\n", d.DataName())
@@ -2110,6 +2379,84 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		fmt.Fprintf(w, string(jsonBytes))
 		return
 
+	case "export":
+		// POST <api URL>/node/<UUID>/<data name>/export?format=...&offset=...&size=...&path=...
+		if action != "post" {
+			server.BadRequest(w, r, "DVID only accepts POST on the 'export' endpoint")
+			return
+		}
+		format, err := zarr.ParseFormat(queryStrings.Get("format"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		offset, err := dvid.StringToPoint3d(queryStrings.Get("offset"), "_")
+		if err != nil {
+			server.BadRequest(w, r, "bad offset for export: %v", err)
+			return
+		}
+		size, err := dvid.StringToPoint3d(queryStrings.Get("size"), "_")
+		if err != nil {
+			server.BadRequest(w, r, "bad size for export: %v", err)
+			return
+		}
+		if queryStrings.Get("path") == "" {
+			server.BadRequest(w, r, "export requires a 'path' query string")
+			return
+		}
+		path, err := server.ExportPath(queryStrings.Get("path"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		numChunks, err := d.ExportArray(ctx.VersionID(), path, format, offset, size, queryStrings.Get("compression"), nil)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"chunks": %d}`, numChunks)
+		timedLog.Infof("HTTP %s: %s", r.Method, r.URL)
+		return
+
+	case "import":
+		// POST <api URL>/node/<UUID>/<data name>/import?offset=...&path=...
+		if action != "post" {
+			server.BadRequest(w, r, "DVID only accepts POST on the 'import' endpoint")
+			return
+		}
+		offset, err := dvid.StringToPoint3d(queryStrings.Get("offset"), "_")
+		if err != nil {
+			server.BadRequest(w, r, "bad offset for import: %v", err)
+			return
+		}
+		if queryStrings.Get("path") == "" {
+			server.BadRequest(w, r, "import requires a 'path' query string")
+			return
+		}
+		path, err := server.ExportPath(queryStrings.Get("path"))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		mutate := queryStrings.Get("mutate") == "true"
+		numChunks, err := d.ImportArray(ctx.VersionID(), path, offset, mutate, nil)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"chunks": %d}`, numChunks)
+		timedLog.Infof("HTTP %s: %s", r.Method, r.URL)
+		return
+
+	case "precomputed":
+		// GET <api URL>/node/<UUID>/<data name>/precomputed/info
+		// GET <api URL>/node/<UUID>/<data name>/precomputed/<scale key>/<chunk name>
+		d.handlePrecomputed(ctx, w, r, parts)
+		timedLog.Infof("HTTP %s: %s", r.Method, r.URL)
+		return
+
 	case "rawkey":
 		// GET <api URL>/node/<UUID>/<data name>/rawkey?x=<block x>&y=<block y>&z=<block z>
 		if len(parts) != 4 {
@@ -2127,7 +2474,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 
 		if action == "get" {
-			numBlocks, err := d.SendBlocksSpecific(ctx, w, compression, blocklist, isprefetch)
+			numBlocks, err := d.SendBlocksSpecific(ctx, w, scale, compression, blocklist, isprefetch)
 			if err != nil {
 				server.BadRequest(w, r, err)
 				return
@@ -2170,7 +2517,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 		}
 
 		if action == "get" {
-			if err := d.SendBlocks(ctx, w, subvol, compression); err != nil {
+			if err := d.SendBlocks(ctx, w, scale, subvol, compression); err != nil {
 				server.BadRequest(w, r, err)
 				return
 			}
@@ -2197,12 +2544,27 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			server.BadRequest(w, r, err)
 			return
 		}
+		if scale != 0 {
+			server.BadRequest(w, r, "the 'blocks' endpoint only supports scale 0")
+			return
+		}
+		compression := queryStrings.Get("compression")
+		if compression != "" && compression != "uncompressed" && compression != "zstd" {
+			server.BadRequest(w, r, "the 'blocks' endpoint only supports 'uncompressed' (default) or 'zstd' compression")
+			return
+		}
 		if action == "get" {
 			data, err := d.GetBlocks(ctx.VersionID(), bcoord, int32(span))
 			if err != nil {
 				server.BadRequest(w, r, err)
 				return
 			}
+			if compression == "zstd" {
+				if data, err = dvid.ZstdCompress(data, dvid.DefaultCompression); err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+			}
 			w.Header().Set("Content-type", "application/octet-stream")
 			_, err = w.Write(data)
 			if err != nil {
@@ -2210,9 +2572,23 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				return
 			}
 		} else {
+			body := r.Body
+			if compression == "zstd" {
+				compressed, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+				data, err := dvid.ZstdUncompress(compressed)
+				if err != nil {
+					server.BadRequest(w, r, err)
+					return
+				}
+				body = ioutil.NopCloser(bytes.NewBuffer(data))
+			}
 			mutID := d.NewMutationID()
 			mutate := (queryStrings.Get("mutate") == "true")
-			if err := d.PutBlocks(ctx.VersionID(), mutID, bcoord, span, r.Body, mutate); err != nil {
+			if err := d.PutBlocks(ctx.VersionID(), mutID, bcoord, span, body, mutate); err != nil {
 				server.BadRequest(w, r, err)
 				return
 			}
@@ -2282,7 +2658,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				server.BadRequest(w, r, err)
 				return
 			}
-			img, err := d.GetImage(ctx.VersionID(), vox, roiname)
+			img, err := d.GetImage(ctx.VersionID(), vox, scale, roiname)
 			if err != nil {
 				server.BadRequest(w, r, err)
 				return
@@ -2328,7 +2704,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				if len(parts) >= 8 && (parts[7] == "jpeg" || parts[7] == "jpg") {
 
 					// extract volume
-					if err := d.GetVoxels(ctx.VersionID(), vox, roiname); err != nil {
+					if err := d.GetScaledVoxels(ctx.VersionID(), vox, scale, roiname); err != nil {
 						server.BadRequest(w, r, err)
 						return
 					}
@@ -2357,7 +2733,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 					}
 				} else {
 
-					data, err := d.GetVolume(ctx.VersionID(), vox, roiname)
+					data, err := d.GetVolume(ctx.VersionID(), vox, scale, roiname)
 					if err != nil {
 						server.BadRequest(w, r, err)
 						return
@@ -2375,6 +2751,10 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 					server.BadRequest(w, r, err)
 					return
 				}
+				if scale != 0 {
+					server.BadRequest(w, r, "can only POST voxels at scale 0; lower scales are computed automatically")
+					return
+				}
 				data, err := ioutil.ReadAll(r.Body)
 				if err != nil {
 					server.BadRequest(w, r, err)
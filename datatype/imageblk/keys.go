@@ -25,6 +25,10 @@ const (
 
 	// legacy key class where extents property is stored
 	metaKeyClass = 24
+
+	// key class for image blocks at lower resolution scales.  Scale 0 blocks use keyImageBlock
+	// for compatibility with data stored before multi-scale support.
+	keyScaledBlock = 25
 )
 
 // DescribeTKeyClass returns a string explanation of what a particular TKeyClass
@@ -35,6 +39,8 @@ func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
 		return "imageblk properties key"
 	case keyImageBlock:
 		return "imageblk block coord key"
+	case keyScaledBlock:
+		return "imageblk scaled block coord key"
 	default:
 		return "unknown imageblk key"
 	}
@@ -52,21 +58,86 @@ func NewTKey(idx dvid.Index) storage.TKey {
 	return NewTKeyByCoord(izyx.ToIZYXString())
 }
 
+// NewScaledTKeyByCoord returns a TKey for a block coord in string format at the given
+// scale, where scale 0 is the highest resolution.
+func NewScaledTKeyByCoord(scale uint8, izyx dvid.IZYXString) storage.TKey {
+	if scale == 0 {
+		return NewTKeyByCoord(izyx)
+	}
+	buf := make([]byte, 13)
+	buf[0] = byte(scale)
+	copy(buf[1:], []byte(izyx))
+	return storage.NewTKey(keyScaledBlock, buf)
+}
+
+// NewScaledTKey returns a type-specific key component for an image block at the given scale.
+func NewScaledTKey(scale uint8, idx dvid.Index) storage.TKey {
+	izyx := idx.(*dvid.IndexZYX)
+	return NewScaledTKeyByCoord(scale, izyx.ToIZYXString())
+}
+
 // MetaTKey provides a TKey for metadata (extents)
 func MetaTKey() storage.TKey {
 	return storage.NewTKey(metaKeyClass, nil)
 }
 
-// DecodeTKey returns a spatial index from a image block key.
+// DecodeTKey returns a spatial index from a image block key at any scale.
 // TODO: Extend this when necessary to allow any form of spatial indexing like CZYX.
 func DecodeTKey(tk storage.TKey) (*dvid.IndexZYX, error) {
-	ibytes, err := tk.ClassBytes(keyImageBlock)
-	if err != nil {
-		return nil, err
+	_, zyx, err := DecodeScaledTKey(tk)
+	return zyx, err
+}
+
+// DecodeScaledTKey returns the scale and spatial index from an image block key.
+func DecodeScaledTKey(tk storage.TKey) (scale uint8, zyx *dvid.IndexZYX, err error) {
+	var class storage.TKeyClass
+	if class, err = tk.Class(); err != nil {
+		return
+	}
+	var ibytes []byte
+	if class == keyScaledBlock {
+		if ibytes, err = tk.ClassBytes(keyScaledBlock); err != nil {
+			return
+		}
+		if len(ibytes) != 13 {
+			err = fmt.Errorf("bad scaled image block key of %d bytes: %v", len(ibytes), ibytes)
+			return
+		}
+		scale = uint8(ibytes[0])
+		ibytes = ibytes[1:]
+	} else if ibytes, err = tk.ClassBytes(keyImageBlock); err != nil {
+		return
 	}
-	var zyx dvid.IndexZYX
+	zyx = new(dvid.IndexZYX)
 	if err = zyx.IndexFromBytes(ibytes); err != nil {
-		return nil, fmt.Errorf("Cannot recover ZYX index from image block key %v: %v\n", tk, err)
+		err = fmt.Errorf("Cannot recover ZYX index from image block key %v: %v\n", tk, err)
+	}
+	return
+}
+
+// ScrubValue verifies a stored value by deserializing it, which checks any checksum,
+// and checking that image blocks have the expected number of bytes.  Implements the
+// datastore.Scrubber interface.
+func (d *Data) ScrubValue(tk storage.TKey, value []byte) (position string, err error) {
+	class, err := tk.Class()
+	if err != nil {
+		return "", err
+	}
+	if class != keyImageBlock && class != keyScaledBlock {
+		return "", nil
+	}
+	scale, zyx, err := DecodeScaledTKey(tk)
+	if err != nil {
+		return "", err
+	}
+	position = fmt.Sprintf("block %s scale %d", dvid.ChunkPoint3d(*zyx), scale)
+	data, _, err := dvid.DeserializeData(value, true)
+	if err != nil {
+		return position, err
+	}
+	expected := d.BlockSize().Prod() * int64(d.Properties.Values.BytesPerElement())
+	if int64(len(data)) != expected {
+		return position, fmt.Errorf("block has %d bytes, expected %d bytes", len(data), expected)
 	}
-	return &zyx, nil
+	return position, nil
 }
@@ -0,0 +1,119 @@
+/*
+	This file supports a read-only neuroglancer precomputed view of image blocks.
+*/
+
+package imageblk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/precomputed"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/server"
+)
+
+// PrecomputedVolume returns a description of the data at the given version suitable
+// for constructing a neuroglancer precomputed info.  Data types that embed imageblk
+// can modify the returned volume, e.g., to change the encoding or number of scales.
+func (d *Data) PrecomputedVolume(ctx *datastore.VersionedCtx) (vol precomputed.Volume, err error) {
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		err = fmt.Errorf("precomputed view of %q requires 3d block size, not %s", d.DataName(), d.BlockSize())
+		return
+	}
+	if len(d.Properties.VoxelSize) != 3 {
+		err = fmt.Errorf("precomputed view of %q requires 3d voxel size, not %v", d.DataName(), d.Properties.VoxelSize)
+		return
+	}
+	var dataType dvid.DataType
+	if dataType, err = d.Values.ValueDataType(); err != nil {
+		return
+	}
+	if vol.DataType, err = precomputed.DataTypeName(dataType); err != nil {
+		return
+	}
+	vol.VolumeType = "image"
+	vol.Encoding = "raw"
+	vol.NumChannels = len(d.Values)
+	vol.BlockSize = blockSize
+	for dim := 0; dim < 3; dim++ {
+		vol.Resolution[dim] = d.Properties.VoxelSize[dim]
+	}
+	vol.MaxScale = d.MaxDownresLevel
+
+	var extents dvid.Extents
+	if extents, err = d.GetExtents(ctx); err != nil {
+		return
+	}
+	if extents.MinPoint == nil || extents.MaxPoint == nil {
+		vol.Empty = true
+		return
+	}
+	minPt, ok1 := extents.MinPoint.(dvid.Point3d)
+	maxPt, ok2 := extents.MaxPoint.(dvid.Point3d)
+	if !ok1 || !ok2 {
+		err = fmt.Errorf("precomputed view of %q requires 3d extents, not %s -> %s", d.DataName(), extents.MinPoint, extents.MaxPoint)
+		return
+	}
+	vol.MinPoint, vol.MaxPoint = minPt, maxPt
+	return
+}
+
+// handlePrecomputed serves the endpoints under <data name>/precomputed.
+func (d *Data) handlePrecomputed(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	if r.Method != "GET" {
+		server.BadRequest(w, r, "only GET is supported on the precomputed endpoint")
+		return
+	}
+	if len(parts) == 5 && parts[4] == "info" {
+		vol, err := d.PrecomputedVolume(ctx)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		jsonBytes, err := json.Marshal(precomputed.NewInfo(vol))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, string(jsonBytes))
+		return
+	}
+	if len(parts) != 6 {
+		server.BadRequest(w, r, "precomputed endpoint must be followed by 'info' or <scale key>/<chunk name>")
+		return
+	}
+	scale, err := precomputed.ParseScaleKey(parts[4])
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	offset, size, err := precomputed.ParseChunkName(parts[5])
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	vox, err := d.NewVoxels(dvid.NewSubvolume(offset, size), nil)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	data, err := d.GetVolume(ctx.VersionID(), vox, scale, "")
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	bytesPerValue, err := d.Values.BytesPerValue()
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := w.Write(precomputed.Planar(data, len(d.Values), int(bytesPerValue))); err != nil {
+		server.BadRequest(w, r, err)
+	}
+}
@@ -297,17 +297,17 @@ func (d *Data) BackgroundBlock() []byte {
 	return blockData
 }
 
-// GetImage retrieves a 2d image from a version node given a geometry of voxels.
-func (d *Data) GetImage(v dvid.VersionID, vox *Voxels, roiname dvid.InstanceName) (*dvid.Image, error) {
-	if err := d.GetVoxels(v, vox, roiname); err != nil {
+// GetImage retrieves a 2d image from a version node given a geometry of voxels at the given scale.
+func (d *Data) GetImage(v dvid.VersionID, vox *Voxels, scale uint8, roiname dvid.InstanceName) (*dvid.Image, error) {
+	if err := d.GetScaledVoxels(v, vox, scale, roiname); err != nil {
 		return nil, err
 	}
 	return vox.GetImage2d()
 }
 
-// GetVolume retrieves a n-d volume from a version node given a geometry of voxels.
-func (d *Data) GetVolume(v dvid.VersionID, vox *Voxels, roiname dvid.InstanceName) ([]byte, error) {
-	if err := d.GetVoxels(v, vox, roiname); err != nil {
+// GetVolume retrieves a n-d volume from a version node given a geometry of voxels at the given scale.
+func (d *Data) GetVolume(v dvid.VersionID, vox *Voxels, scale uint8, roiname dvid.InstanceName) ([]byte, error) {
+	if err := d.GetScaledVoxels(v, vox, scale, roiname); err != nil {
 		return nil, err
 	}
 	return vox.Data(), nil
@@ -321,13 +321,34 @@ type getOperation struct {
 
 // GetVoxels copies voxels from the storage engine to Voxels, a requested subvolume or 2d image.
 func (d *Data) GetVoxels(v dvid.VersionID, vox *Voxels, roiname dvid.InstanceName) error {
+	return d.GetScaledVoxels(v, vox, 0, roiname)
+}
+
+// checkScale returns an error if the given scale is not available for the data.
+func (d *Data) checkScale(scale uint8) error {
+	if scale > d.MaxDownresLevel {
+		return fmt.Errorf("data %q only supports scales 0 to %d, not %d", d.DataName(), d.MaxDownresLevel, scale)
+	}
+	return nil
+}
+
+// GetScaledVoxels copies voxels at the given scale from the storage engine to Voxels,
+// a requested subvolume or 2d image.  The geometry of the Voxels is in the voxel space
+// of the given scale.  ROI masking is only supported at scale 0.
+func (d *Data) GetScaledVoxels(v dvid.VersionID, vox *Voxels, scale uint8, roiname dvid.InstanceName) error {
+	if err := d.checkScale(scale); err != nil {
+		return err
+	}
+	if scale != 0 && roiname != "" {
+		return fmt.Errorf("roi masking is only supported at scale 0, not scale %d", scale)
+	}
 	r, err := GetROI(v, roiname, vox)
 	if err != nil {
 		return err
 	}
 
 	timedLog := dvid.NewTimeLog()
-	defer timedLog.Infof("GetVoxels %s", vox)
+	defer timedLog.Infof("GetVoxels %s, scale %d", vox, scale)
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
@@ -354,8 +375,8 @@ func (d *Data) GetVoxels(v dvid.VersionID, vox *Voxels, roiname dvid.InstanceNam
 		if err != nil {
 			return err
 		}
-		begTKey := NewTKey(indexBeg)
-		endTKey := NewTKey(indexEnd)
+		begTKey := NewScaledTKey(scale, indexBeg)
+		endTKey := NewScaledTKey(scale, indexEnd)
 
 		// Get set of blocks in ROI if ROI provided
 		var chunkOp *storage.ChunkOp
@@ -399,7 +420,7 @@ func (d *Data) GetVoxels(v dvid.VersionID, vox *Voxels, roiname dvid.InstanceNam
 			for x := begX; x <= endX; x++ {
 				c[0] = x
 				curIndex := dvid.IndexZYX(c)
-				currTKey := NewTKey(&curIndex)
+				currTKey := NewScaledTKey(scale, &curIndex)
 				tkeys = append(tkeys, currTKey)
 
 			}
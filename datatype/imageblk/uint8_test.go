@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"testing"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/downres"
+	"github.com/janelia-flyem/dvid/datatype/common/precomputed"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
 )
@@ -414,6 +419,208 @@ func TestBlockAPI(t *testing.T) {
 	}
 }
 
+// downsample returns a uint8 volume averaged over 2x2x2 voxels.
+func downsample(vol []byte, size dvid.Point3d) ([]byte, dvid.Point3d) {
+	lores := dvid.Point3d{size[0] / 2, size[1] / 2, size[2] / 2}
+	out := make([]byte, lores.Prod())
+	var i int
+	for z := int32(0); z < lores[2]; z++ {
+		for y := int32(0); y < lores[1]; y++ {
+			for x := int32(0); x < lores[0]; x++ {
+				var sum int
+				for dz := int32(0); dz < 2; dz++ {
+					for dy := int32(0); dy < 2; dy++ {
+						for dx := int32(0); dx < 2; dx++ {
+							sum += int(vol[((2*z+dz)*size[1]+2*y+dy)*size[0]+2*x+dx])
+						}
+					}
+				}
+				out[i] = byte(sum / 8)
+				i++
+			}
+		}
+	}
+	return out, lores
+}
+
+func TestGrayscaleDownres(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	config.Set("MaxDownresLevel", "2")
+	if _, err := datastore.NewData(uuid, grayscaleT, "grayscale", config); err != nil {
+		t.Fatalf("Unable to create grayscale instance: %v\n", err)
+	}
+
+	size := dvid.Point3d{128, 64, 64}
+	vol := testVolume{
+		data: makeVolume(dvid.Point3d{0, 0, 0}, size),
+		size: size,
+	}
+	vol.put(t, uuid, "grayscale")
+	if err := downres.BlockOnUpdating(uuid, "grayscale"); err != nil {
+		t.Fatalf("Error blocking on update for grayscale: %v\n", err)
+	}
+
+	expected, expectedSize := vol.data, size
+	for scale := 1; scale <= 2; scale++ {
+		expected, expectedSize = downsample(expected, expectedSize)
+		apiStr := fmt.Sprintf("%snode/%s/grayscale/raw/0_1_2/%d_%d_%d/0_0_0?scale=%d", server.WebAPIPath,
+			uuid, expectedSize[0], expectedSize[1], expectedSize[2], scale)
+		got := server.TestHTTP(t, "GET", apiStr, nil)
+		if len(got) != len(expected) {
+			t.Fatalf("Expected %d bytes at scale %d, got %d bytes\n", len(expected), scale, len(got))
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Fatalf("Scale %d voxel %d: expected %d, got %d\n", scale, i, expected[i], got[i])
+			}
+		}
+	}
+
+	// Blocks at lower scales should be retrievable by block coordinate.
+	apiStr := fmt.Sprintf("%snode/%s/grayscale/specificblocks?blocks=0,0,0,1,0,0&compression=uncompressed&scale=1", server.WebAPIPath, uuid)
+	data := server.TestHTTP(t, "GET", apiStr, nil)
+	blockBytes := 16 + int(DefaultBlockSize*DefaultBlockSize*DefaultBlockSize)
+	if len(data) != 2*blockBytes {
+		t.Errorf("Expected 2 blocks of %d bytes at scale 1, got %d bytes\n", blockBytes, len(data))
+	}
+
+	// Scales beyond MaxDownresLevel are an error.
+	apiStr = fmt.Sprintf("%snode/%s/grayscale/raw/0_1_2/32_32_32/0_0_0?scale=3", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "GET", apiStr, nil)
+}
+
+func TestGrayscalePrecomputed(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	config := dvid.NewConfig()
+	config.Set("MaxDownresLevel", "1")
+	if _, err := datastore.NewData(uuid, grayscaleT, "grayscale", config); err != nil {
+		t.Fatalf("Unable to create grayscale instance: %v\n", err)
+	}
+
+	size := dvid.Point3d{128, 64, 64}
+	vol := testVolume{
+		data: makeVolume(dvid.Point3d{0, 0, 0}, size),
+		size: size,
+	}
+	vol.put(t, uuid, "grayscale")
+	if err := downres.BlockOnUpdating(uuid, "grayscale"); err != nil {
+		t.Fatalf("Error blocking on update for grayscale: %v\n", err)
+	}
+
+	apiStr := fmt.Sprintf("%snode/%s/grayscale/precomputed/info", server.WebAPIPath, uuid)
+	var info precomputed.Info
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", apiStr, nil), &info); err != nil {
+		t.Fatalf("Unable to parse precomputed info: %v\n", err)
+	}
+	if info.VolumeType != "image" || info.DataType != "uint8" || info.NumChannels != 1 {
+		t.Errorf("Bad precomputed info: %v\n", info)
+	}
+	if len(info.Scales) != 2 {
+		t.Fatalf("Expected 2 scales in precomputed info, got %d\n", len(info.Scales))
+	}
+	if info.Scales[0].Key != "s0" || info.Scales[0].Size != [3]int32{128, 64, 64} {
+		t.Errorf("Bad scale 0 in precomputed info: %v\n", info.Scales[0])
+	}
+	if info.Scales[1].Key != "s1" || info.Scales[1].Size != [3]int32{64, 32, 32} {
+		t.Errorf("Bad scale 1 in precomputed info: %v\n", info.Scales[1])
+	}
+
+	// Chunks should match the same subvolume from the raw endpoint.
+	for scale := 0; scale <= 1; scale++ {
+		apiStr = fmt.Sprintf("%snode/%s/grayscale/precomputed/s%d/32-64_0-32_0-32", server.WebAPIPath, uuid, scale)
+		chunk := server.TestHTTP(t, "GET", apiStr, nil)
+		apiStr = fmt.Sprintf("%snode/%s/grayscale/raw/0_1_2/32_32_32/32_0_0?scale=%d", server.WebAPIPath, uuid, scale)
+		expected := server.TestHTTP(t, "GET", apiStr, nil)
+		if !bytes.Equal(chunk, expected) {
+			t.Errorf("Precomputed chunk at scale %d doesn't match raw subvolume\n", scale)
+		}
+	}
+
+	apiStr = fmt.Sprintf("%snode/%s/grayscale/precomputed/s0/32-0_0-32_0-32", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "GET", apiStr, nil)
+	apiStr = fmt.Sprintf("%snode/%s/grayscale/precomputed/s2/0-32_0-32_0-32", server.WebAPIPath, uuid)
+	server.TestBadHTTP(t, "GET", apiStr, nil)
+}
+
+func TestGrayscaleArrayExportImport(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	dir, err := ioutil.TempDir("", "dvid-array-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	server.SetExportRoot(dir)
+	defer server.SetExportRoot("")
+
+	uuid, _ := initTestRepo()
+	makeGrayscale(uuid, t, "grayscale")
+	makeGrayscale(uuid, t, "grayscale2")
+
+	size := dvid.Point3d{64, 64, 96}
+	vol := testVolume{
+		data:   makeVolume(dvid.Point3d{32, 0, 64}, size),
+		offset: dvid.Point3d{32, 0, 64},
+		size:   size,
+	}
+	vol.put(t, uuid, "grayscale")
+
+	for _, format := range []string{"zarr", "n5"} {
+		path := "grayscale." + format
+		apiStr := fmt.Sprintf("%snode/%s/grayscale/export?format=%s&offset=32_0_64&size=64_64_96&path=%s&compression=gzip",
+			server.WebAPIPath, uuid, format, path)
+		var result struct {
+			Chunks int `json:"chunks"`
+		}
+		if err := json.Unmarshal(server.TestHTTP(t, "POST", apiStr, nil), &result); err != nil {
+			t.Fatalf("unable to parse export response: %v\n", err)
+		}
+		if result.Chunks != 2*2*3 {
+			t.Errorf("expected 12 chunks exported to %s, got %d\n", format, result.Chunks)
+		}
+
+		apiStr = fmt.Sprintf("%snode/%s/grayscale2/import?offset=0_32_0&path=%s", server.WebAPIPath, uuid, path)
+		if err := json.Unmarshal(server.TestHTTP(t, "POST", apiStr, nil), &result); err != nil {
+			t.Fatalf("unable to parse import response: %v\n", err)
+		}
+		if result.Chunks != 12 {
+			t.Errorf("expected 12 chunks imported from %s, got %d\n", format, result.Chunks)
+		}
+
+		apiStr = fmt.Sprintf("%snode/%s/grayscale2/raw/0_1_2/64_64_96/0_32_0", server.WebAPIPath, uuid)
+		if got := server.TestHTTP(t, "GET", apiStr, nil); !bytes.Equal(got, vol.data) {
+			t.Errorf("imported %s array doesn't match exported grayscale\n", format)
+		}
+	}
+
+	// Offsets must be block-aligned.
+	apiStr := fmt.Sprintf("%snode/%s/grayscale/export?format=zarr&offset=3_0_0&size=64_64_64&path=%s",
+		server.WebAPIPath, uuid, "bad.zarr")
+	server.TestBadHTTP(t, "POST", apiStr, nil)
+	apiStr = fmt.Sprintf("%snode/%s/grayscale2/import?offset=0_0_0&path=%s", server.WebAPIPath, uuid, "missing.zarr")
+	server.TestBadHTTP(t, "POST", apiStr, nil)
+
+	// Paths must stay under the export root.
+	for _, path := range []string{filepath.Join(dir, "grayscale.zarr"), "../grayscale.zarr"} {
+		apiStr = fmt.Sprintf("%snode/%s/grayscale2/import?offset=0_0_0&path=%s", server.WebAPIPath, uuid, path)
+		server.TestBadHTTP(t, "POST", apiStr, nil)
+	}
+}
+
 func TestGrayscaleRepoPersistence(t *testing.T) {
 	if err := server.OpenTest(); err != nil {
 		t.Fatalf("can't open test server: %v\n", err)
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/downres"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
@@ -101,11 +102,12 @@ func (v *Voxels) writeBlock(block *storage.TKeyValue, blockSize dvid.Point) erro
 }
 
 type putOperation struct {
-	voxels   *Voxels
-	indexZYX dvid.IndexZYX
-	version  dvid.VersionID
-	mutate   bool   // if false, we just ingest without needing to GET previous value
-	mutID    uint64 // should be unique within a server's uptime.
+	voxels     *Voxels
+	indexZYX   dvid.IndexZYX
+	version    dvid.VersionID
+	mutate     bool   // if false, we just ingest without needing to GET previous value
+	mutID      uint64 // should be unique within a server's uptime.
+	downresMut *downres.Mutation
 }
 
 type patchGeo struct {
@@ -132,7 +134,7 @@ func (d *Data) MutateVoxels(v dvid.VersionID, mutID uint64, vox *Voxels, roiname
 // PutVoxels persists voxels from a subvolume into the storage engine.
 // The subvolume must be aligned to blocks of the data instance, which simplifies
 // the routine if the PUT is a mutation (signals MutateBlockEvent) instead of ingestion.
-func (d *Data) PutVoxels(v dvid.VersionID, mutID uint64, vox *Voxels, roiname dvid.InstanceName, mutate bool) error {
+func (d *Data) PutVoxels(v dvid.VersionID, mutID uint64, vox *Voxels, roiname dvid.InstanceName, mutate bool) (err error) {
 	r, err := GetROI(v, roiname, vox)
 	if err != nil {
 		return err
@@ -178,6 +180,15 @@ func (d *Data) PutVoxels(v dvid.VersionID, mutID uint64, vox *Voxels, roiname dv
 	voxstartpt := vox.Geometry.StartPoint()
 	voxendpt := vox.Geometry.EndPoint()
 
+	downresMut := d.newDownresMutation(v, mutID)
+	if downresMut != nil {
+		defer func() {
+			if derr := downresMut.Execute(); derr != nil && err == nil {
+				err = derr
+			}
+		}()
+	}
+
 	// Iterate through index space for this data.
 	for it, err := vox.NewIndexIterator(d.BlockSize()); err == nil && it.Valid(); it.NextSpan() {
 		i0, i1, err := it.IndexSpan()
@@ -254,7 +265,7 @@ func (d *Data) PutVoxels(v dvid.VersionID, mutID uint64, vox *Voxels, roiname dv
 			}
 
 			kv := &storage.TKeyValue{K: NewTKey(&curIndex)}
-			putOp := &putOperation{vox, curIndex, v, mutate, mutID}
+			putOp := &putOperation{vox, curIndex, v, mutate, mutID, downresMut}
 			op := &storage.ChunkOp{putOp, nil}
 			putrequests++
 			d.PutChunk(&storage.Chunk{op, kv}, hasbuffer, patchgeo, finishedRequests)
@@ -271,7 +282,7 @@ func (d *Data) PutVoxels(v dvid.VersionID, mutID uint64, vox *Voxels, roiname dv
 }
 
 // PutBlocks stores blocks of data in a span along X
-func (d *Data) PutBlocks(v dvid.VersionID, mutID uint64, start dvid.ChunkPoint3d, span int, data io.ReadCloser, mutate bool) error {
+func (d *Data) PutBlocks(v dvid.VersionID, mutID uint64, start dvid.ChunkPoint3d, span int, data io.ReadCloser, mutate bool) (err error) {
 	batcher, err := datastore.GetKeyValueBatcher(d)
 	if err != nil {
 		return err
@@ -280,6 +291,15 @@ func (d *Data) PutBlocks(v dvid.VersionID, mutID uint64, start dvid.ChunkPoint3d
 	ctx := datastore.NewVersionedCtx(d, v)
 	batch := batcher.NewBatch(ctx)
 
+	downresMut := d.newDownresMutation(v, mutID)
+	if downresMut != nil {
+		defer func() {
+			if derr := downresMut.Execute(); derr != nil && err == nil {
+				err = derr
+			}
+		}()
+	}
+
 	// Read blocks from the stream until we can output a batch put.
 	const BatchSize = 1000
 	var readBlocks int
@@ -325,6 +345,13 @@ func (d *Data) PutBlocks(v dvid.VersionID, mutID uint64, start dvid.ChunkPoint3d
 
 		// Write the new block
 		batch.Put(tk, serialization)
+		if downresMut != nil {
+			block := make([]byte, len(buf))
+			copy(block, buf)
+			if err := downresMut.BlockMutated(zyx.ToIZYXString(), block); err != nil {
+				return err
+			}
+		}
 
 		// Notify any subscribers that you've changed block.
 		var event string
@@ -474,6 +501,11 @@ func (d *Data) putChunk(chunk *storage.Chunk, hasbuffer bool, patchgeo *patchGeo
 		if err = datastore.NotifySubscribers(evt, msg); err != nil {
 			dvid.Errorf("Unable to notify subscribers of event %s in %s\n", event, d.DataName())
 		}
+		if op.downresMut != nil {
+			if merr := op.downresMut.BlockMutated(op.indexZYX.ToIZYXString(), block.V); merr != nil {
+				dvid.Errorf("data %q publishing downres: %v\n", d.DataName(), merr)
+			}
+		}
 	}
 
 	// put data -- use buffer if available
@@ -595,6 +627,14 @@ func (d *Data) writeBlocks(v dvid.VersionID, b storage.TKeyValues, wg1, wg2 *syn
 		}()
 
 		mutID := d.NewMutationID()
+		downresMut := d.newDownresMutation(v, mutID)
+		if downresMut != nil {
+			defer func() {
+				if err := downresMut.Execute(); err != nil {
+					dvid.Errorf("Unable to compute downres for data %q: %v\n", d.DataName(), err)
+				}
+			}()
+		}
 		batch := batcher.NewBatch(ctx)
 		for i, block := range b {
 			serialization, err := dvid.SerializeData(block.V, d.Compression(), d.Checksum())
@@ -616,6 +656,12 @@ func (d *Data) writeBlocks(v dvid.VersionID, b storage.TKeyValues, wg1, wg2 *syn
 				dvid.Errorf("Unable to notify subscribers of ChangeBlockEvent in %s\n", d.DataName())
 				return
 			}
+			if downresMut != nil {
+				if err := downresMut.BlockMutated(indexZYX.ToIZYXString(), block.V); err != nil {
+					dvid.Errorf("data %q publishing downres: %v\n", d.DataName(), err)
+					return
+				}
+			}
 
 			// Check if we should commit
 			if i%KVWriteSize == KVWriteSize-1 {
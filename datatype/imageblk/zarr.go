@@ -0,0 +1,105 @@
+/*
+	This file supports export and import of image blocks to and from zarr and N5 arrays.
+*/
+
+package imageblk
+
+import (
+	"fmt"
+
+	"github.com/janelia-flyem/dvid/datatype/common/zarr"
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// arrayDataType returns the value type of single-channel data with 3d blocks.
+func (d *Data) arrayDataType() (dvid.DataType, dvid.Point3d, error) {
+	if len(d.Values) != 1 {
+		return 0, dvid.Point3d{}, fmt.Errorf("data %q has %d channels, only single-channel data can use zarr or N5 arrays", d.DataName(), len(d.Values))
+	}
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return 0, dvid.Point3d{}, fmt.Errorf("data %q must have 3d block size, not %s", d.DataName(), d.BlockSize())
+	}
+	return d.Values[0].T, blockSize, nil
+}
+
+// ExportArray writes the subvolume of the given size starting at a block-aligned offset
+// into a new zarr or N5 array at path, using the block size of the data as the chunk size.
+// The export stops if the optional job is canceled.  Returns the number of chunks written.
+func (d *Data) ExportArray(v dvid.VersionID, path string, format zarr.Format, offset, size dvid.Point3d, compression string, job *dvid.Job) (int, error) {
+	t, blockSize, err := d.arrayDataType()
+	if err != nil {
+		return 0, err
+	}
+	if err := zarr.CheckAlignment(offset, blockSize, blockSize); err != nil {
+		return 0, err
+	}
+	arr, err := zarr.Create(path, format, size, blockSize, t, compression)
+	if err != nil {
+		return 0, err
+	}
+	timedLog := dvid.NewTimeLog()
+	n := arr.NumChunks()
+	totalChunks := uint64(n[0]) * uint64(n[1]) * uint64(n[2])
+	var readChunks uint64
+	numChunks, err := zarr.Export(arr, offset, func(offset, size dvid.Point3d) ([]byte, error) {
+		if job.Canceled() {
+			return nil, dvid.ErrJobCanceled
+		}
+		job.SetProgress(readChunks, totalChunks)
+		readChunks++
+		vox, err := d.NewVoxels(dvid.NewSubvolume(offset, size), nil)
+		if err != nil {
+			return nil, err
+		}
+		return d.GetVolume(v, vox, 0, "")
+	})
+	if err != nil {
+		return numChunks, err
+	}
+	timedLog.Infof("Exported %d chunks of data %q to %s array %s", numChunks, d.DataName(), format, path)
+	return numChunks, nil
+}
+
+// ImportArray writes the zarr or N5 array at path into the data starting at a block-aligned
+// offset.  The array must have the same value type as the data and a chunk size that is a
+// multiple of the block size.  Voxels in edge chunks that lie beyond the array shape are
+// stored as zero.  The import stops if the optional job is canceled, leaving chunks already
+// written in place.  Returns the number of chunks written.
+func (d *Data) ImportArray(v dvid.VersionID, path string, offset dvid.Point3d, mutate bool, job *dvid.Job) (int, error) {
+	t, blockSize, err := d.arrayDataType()
+	if err != nil {
+		return 0, err
+	}
+	arr, err := zarr.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	if arr.DataType != t {
+		return 0, fmt.Errorf("%s array %s has different value type than data %q", arr.Format, path, d.DataName())
+	}
+	if err := zarr.CheckAlignment(offset, arr.ChunkSize, blockSize); err != nil {
+		return 0, err
+	}
+	timedLog := dvid.NewTimeLog()
+	n := arr.NumChunks()
+	totalChunks := uint64(n[0]) * uint64(n[1]) * uint64(n[2])
+	var writtenChunks uint64
+	numChunks, err := zarr.Import(arr, offset, func(offset, size dvid.Point3d, data []byte) error {
+		if job.Canceled() {
+			return dvid.ErrJobCanceled
+		}
+		job.SetProgress(writtenChunks, totalChunks)
+		writtenChunks++
+		vox, err := d.NewVoxels(dvid.NewSubvolume(offset, size), data)
+		if err != nil {
+			return err
+		}
+		return d.PutVoxels(v, d.NewMutationID(), vox, "", mutate)
+	})
+	if err != nil {
+		return numChunks, err
+	}
+	timedLog.Infof("Imported %d chunks from %s array %s into data %q", numChunks, arr.Format, path, d.DataName())
+	return numChunks, nil
+}
@@ -818,12 +818,18 @@ func (d *Data) DoRPC(request datastore.Request, reply *datastore.Response) error
 			}
 		}
 	}
-	reply.Text = fmt.Sprintf("Tiling data instance %q @ node %s...\n", dataName, uuidStr)
+	uuid, _, err := datastore.MatchingUUID(uuidStr)
+	if err != nil {
+		return err
+	}
+	job := dvid.NewJob("imagetile generate", request.User, uuid, d.DataName())
+	reply.Text = fmt.Sprintf("Tiling data instance %q @ node %s as job %d...\n", dataName, uuidStr, job.ID())
 	go func() {
-		err := d.ConstructTiles(uuidStr, tileSpec, request)
+		err := d.ConstructTiles(uuidStr, tileSpec, request, job)
 		if err != nil {
 			dvid.Errorf("Cannot construct tiles for data instance %q @ node %s: %v\n", dataName, uuidStr, err)
 		}
+		job.Finish(err)
 	}()
 	return nil
 }
@@ -1358,7 +1364,9 @@ func (d *Data) putTileFunc(versionID dvid.VersionID) (outFunc, error) {
 	}, nil
 }
 
-func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datastore.Request) error {
+// ConstructTiles generates tiles from the source imageblk data.  The optional job is
+// updated with the percent of slices tiled and stops the tiling if canceled.
+func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datastore.Request, job *dvid.Job) error {
 	config := request.Settings()
 	uuid, versionID, err := datastore.MatchingUUID(uuidStr)
 	if err != nil {
@@ -1463,7 +1471,12 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 	}
 	sort.Ints(sortedKeys)
 
-	for _, plane := range planes {
+	// sets the job progress after tiling slice i of n slices in the given plane.
+	setProgress := func(planeNum int, i, n int32) {
+		job.SetPercent(100 * (float64(planeNum) + float64(i+1)/float64(n)) / float64(len(planes)))
+	}
+
+	for planeNum, plane := range planes {
 		timedLog := dvid.NewTimeLog()
 		offset := minTiledPt.Duplicate()
 
@@ -1484,6 +1497,9 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 				z1 = *maxz
 			}
 			for z := z0; z <= z1; z++ {
+				if job.Canceled() {
+					return dvid.ErrJobCanceled
+				}
 				server.BlockOnInteractiveRequests("imagetile.ConstructTiles [xy]")
 
 				sliceLog := dvid.NewTimeLog()
@@ -1527,6 +1543,7 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 
 				sliceLog.Infof("Read XY Tile @ Z = %d, now tiling...", z)
 				bufferNum = (bufferNum + 1) % 2
+				setProgress(planeNum, z-z0, z1-z0+1)
 			}
 			timedLog.Infof("Total time to generate XY Tiles")
 
@@ -1545,6 +1562,9 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 				y1 = *maxy
 			}
 			for y := y0; y <= y1; y++ {
+				if job.Canceled() {
+					return dvid.ErrJobCanceled
+				}
 				server.BlockOnInteractiveRequests("imagetile.ConstructTiles [xz]")
 
 				sliceLog := dvid.NewTimeLog()
@@ -1588,6 +1608,7 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 
 				sliceLog.Infof("Read XZ Tile @ Y = %d, now tiling...", y)
 				bufferNum = (bufferNum + 1) % 2
+				setProgress(planeNum, y-y0, y1-y0+1)
 			}
 			timedLog.Infof("Total time to generate XZ Tiles")
 
@@ -1606,6 +1627,9 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 				x1 = *maxx
 			}
 			for x := x0; x <= x1; x++ {
+				if job.Canceled() {
+					return dvid.ErrJobCanceled
+				}
 				server.BlockOnInteractiveRequests("imagetile.ConstructTiles [yz]")
 
 				sliceLog := dvid.NewTimeLog()
@@ -1650,6 +1674,7 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 
 				sliceLog.Debugf("Read YZ Tile @ X = %d, now tiling...", x)
 				bufferNum = (bufferNum + 1) % 2
+				setProgress(planeNum, x-x0, x1-x0+1)
 			}
 			timedLog.Infof("Total time to generate YZ Tiles")
 
@@ -1657,5 +1682,11 @@ func (d *Data) ConstructTiles(uuidStr string, tileSpec TileSpec, request datasto
 			dvid.Infof("Skipping request to tile '%s'.  Unsupported.", plane)
 		}
 	}
+
+	// Wait for tiling of the last slices.
+	for i := range bufferLock {
+		bufferLock[i].Lock()
+		bufferLock[i].Unlock()
+	}
 	return nil
 }
@@ -387,6 +387,86 @@ func (d *Data) DeleteData(ctx storage.Context, keyStr string) error {
 	return db.Delete(ctx, tk)
 }
 
+// MergeConflicts resolves keys modified in more than one parent during an auto merge,
+// implementing the datastore.Merger interface.  A change from the common ancestor's value
+// is preferred over an unchanged value, and if more than one parent changed the value,
+// the earliest parent in merge order wins.
+func (d *Data) MergeConflicts(ctx *datastore.VersionedCtx, parents []dvid.VersionID, conflicts []datastore.MergeConflict) error {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	uncompress := true
+	for _, conflict := range conflicts {
+		var base []byte
+		if conflict.Base != nil {
+			if base, _, err = dvid.DeserializeData(conflict.Base, uncompress); err != nil {
+				return fmt.Errorf("unable to deserialize base value for key %v: %v", conflict.TKey, err)
+			}
+		}
+		winner := 0
+		for i, serialization := range conflict.Values {
+			var value []byte
+			if serialization != nil {
+				if value, _, err = dvid.DeserializeData(serialization, uncompress); err != nil {
+					return fmt.Errorf("unable to deserialize value for key %v: %v", conflict.TKey, err)
+				}
+			}
+			if (serialization == nil) != (conflict.Base == nil) || !bytes.Equal(value, base) {
+				winner = i
+				break
+			}
+		}
+		if conflict.Values[winner] == nil {
+			err = db.Delete(ctx, conflict.TKey)
+		} else {
+			err = db.Put(ctx, conflict.TKey, conflict.Values[winner])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SerializeValue compresses and checksums a client-supplied value as a POST would,
+// implementing the datastore.ValueSerializer interface.
+func (d *Data) SerializeValue(tk storage.TKey, value []byte) ([]byte, error) {
+	return dvid.SerializeData(value, d.Compression(), d.Checksum())
+}
+
+// DiffSummary returns the keys added, modified, or deleted between two versions,
+// implementing the datastore.Differ interface.
+func (d *Data) DiffSummary(from, to dvid.VersionID, diff *datastore.VersionDiff) (interface{}, error) {
+	decode := func(tks []storage.TKey) ([]string, error) {
+		keys := make([]string, len(tks))
+		for i, tk := range tks {
+			key, err := DecodeTKey(tk)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = key
+		}
+		return keys, nil
+	}
+	var summary struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Deleted  []string `json:"deleted"`
+	}
+	var err error
+	if summary.Added, err = decode(diff.Added); err != nil {
+		return nil, err
+	}
+	if summary.Modified, err = decode(diff.Modified); err != nil {
+		return nil, err
+	}
+	if summary.Deleted, err = decode(diff.Deleted); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
 // put handles a PUT command-line request.
 func (d *Data) put(cmd datastore.Request, reply *datastore.Response) error {
 	if len(cmd.Command) < 5 {
@@ -874,6 +874,369 @@ func TestDiamondGetOnMerge(t *testing.T) {
 	}
 }
 
+func TestAutoMerge(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+
+	config := dvid.NewConfig()
+	dataservice, err := datastore.NewData(uuid, kvtype, "automerge", config)
+	if err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	data, ok := dataservice.(*Data)
+	if !ok {
+		t.Fatalf("Returned new data instance is not keyvalue.Data\n")
+	}
+
+	keyreq := func(uuid dvid.UUID, key string) string {
+		return fmt.Sprintf("%snode/%s/%s/key/%s", server.WebAPIPath, uuid, data.DataName(), key)
+	}
+	server.TestHTTP(t, "POST", keyreq(uuid, "a"), strings.NewReader("a-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "b"), strings.NewReader("b-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "c"), strings.NewReader("c-root"))
+	if err = datastore.Commit(uuid, "root", nil); err != nil {
+		t.Fatalf("Unable to commit root node %s: %v\n", uuid, err)
+	}
+
+	// Left branch modifies a and deletes c.
+	left, err := datastore.NewVersion(uuid, "left child", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create 1st child off root %s: %v\n", uuid, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(left, "a"), strings.NewReader("a-left"))
+	server.TestHTTP(t, "DELETE", keyreq(left, "c"), nil)
+	if err = datastore.Commit(left, "left child", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", left, err)
+	}
+
+	// Right branch modifies a, b, and c.
+	right, err := datastore.NewVersion(uuid, "right child", "rightbranch", nil)
+	if err != nil {
+		t.Fatalf("Unable to create 2nd child off root %s: %v\n", uuid, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(right, "a"), strings.NewReader("a-right"))
+	server.TestHTTP(t, "POST", keyreq(right, "b"), strings.NewReader("b-right"))
+	server.TestHTTP(t, "POST", keyreq(right, "c"), strings.NewReader("c-right"))
+	if err = datastore.Commit(right, "right child", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", right, err)
+	}
+
+	// Auto merge of uncommitted parents should fail.
+	open, err := datastore.NewVersion(right, "open child", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off %s: %v\n", right, err)
+	}
+	if _, err = datastore.Merge([]dvid.UUID{left, open}, "bad merge", datastore.MergeTypeSpecificAuto); err == nil {
+		t.Fatalf("Expected error on auto merge with uncommitted parent\n")
+	}
+
+	// Dry run should report keys a and c as conflicting.
+	conflictsReq := fmt.Sprintf("%srepo/%s/merge-conflicts?parents=%s,%s", server.WebAPIPath, uuid, left, right)
+	returnValue := server.TestHTTP(t, "GET", conflictsReq, nil)
+	var report map[dvid.InstanceName][]datastore.MergeConflictClass
+	if err := json.Unmarshal(returnValue, &report); err != nil {
+		t.Fatalf("Can't parse return of merge-conflicts request: %s\n", string(returnValue))
+	}
+	classes, found := report[data.DataName()]
+	if !found || len(classes) != 1 {
+		t.Fatalf("Expected one class of conflicts for %q, got: %s\n", data.DataName(), string(returnValue))
+	}
+	if classes[0].Class != keyStandard || len(classes[0].Keys) != 2 {
+		t.Fatalf("Expected 2 conflicting keyvalue keys, got: %s\n", string(returnValue))
+	}
+
+	// Parents must be in the repo given in the URL.
+	otherUUID, _ := initTestRepo()
+	otherReq := fmt.Sprintf("%srepo/%s/merge-conflicts?parents=%s,%s", server.WebAPIPath, otherUUID, left, right)
+	server.TestBadHTTP(t, "GET", otherReq, nil)
+
+	mergeJSON := fmt.Sprintf(`{"mergeType":"auto","parents":[%q,%q],"note":"auto merge"}`, left, right)
+	mergeReq := fmt.Sprintf("%srepo/%s/merge", server.WebAPIPath, uuid)
+	returnValue = server.TestHTTP(t, "POST", mergeReq, bytes.NewBufferString(mergeJSON))
+	mergeResp := struct {
+		Child dvid.UUID `json:"child"`
+	}{}
+	if err := json.Unmarshal(returnValue, &mergeResp); err != nil {
+		t.Fatalf("Can't parse return of merge request: %s\n", string(returnValue))
+	}
+
+	// Key a was modified in both parents so the first parent wins.
+	returnValue = server.TestHTTP(t, "GET", keyreq(mergeResp.Child, "a"), nil)
+	if string(returnValue) != "a-left" {
+		t.Errorf("Error on auto merged child, key a: expected %q, got %q\n", "a-left", string(returnValue))
+	}
+
+	// Key b was only modified in the right parent.
+	returnValue = server.TestHTTP(t, "GET", keyreq(mergeResp.Child, "b"), nil)
+	if string(returnValue) != "b-right" {
+		t.Errorf("Error on auto merged child, key b: expected %q, got %q\n", "b-right", string(returnValue))
+	}
+
+	// Key c was deleted in the first parent so the deletion wins.
+	server.TestBadHTTP(t, "GET", keyreq(mergeResp.Child, "c"), nil)
+}
+
+func TestExternalMerge(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+
+	config := dvid.NewConfig()
+	dataservice, err := datastore.NewData(uuid, kvtype, "externalmerge", config)
+	if err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	data, ok := dataservice.(*Data)
+	if !ok {
+		t.Fatalf("Returned new data instance is not keyvalue.Data\n")
+	}
+
+	keyreq := func(uuid dvid.UUID, key string) string {
+		return fmt.Sprintf("%snode/%s/%s/key/%s", server.WebAPIPath, uuid, data.DataName(), key)
+	}
+	server.TestHTTP(t, "POST", keyreq(uuid, "a"), strings.NewReader("a-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "b"), strings.NewReader("b-root"))
+	if err = datastore.Commit(uuid, "root", nil); err != nil {
+		t.Fatalf("Unable to commit root node %s: %v\n", uuid, err)
+	}
+	left, err := datastore.NewVersion(uuid, "left child", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create 1st child off root %s: %v\n", uuid, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(left, "a"), strings.NewReader("a-left"))
+	if err = datastore.Commit(left, "left child", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", left, err)
+	}
+	right, err := datastore.NewVersion(uuid, "right child", "rightbranch", nil)
+	if err != nil {
+		t.Fatalf("Unable to create 2nd child off root %s: %v\n", uuid, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(right, "a"), strings.NewReader("a-right"))
+	if err = datastore.Commit(right, "right child", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", right, err)
+	}
+
+	parents := []dvid.UUID{left, right}
+	bTKey, err := NewTKey("b")
+	if err != nil {
+		t.Fatalf("Unable to create key: %v\n", err)
+	}
+
+	// Resolutions that don't cover the conflicting key should fail.
+	deleteB := datastore.MergeResolution{Data: data.DataName(), Key: fmt.Sprintf("%x", []byte(bTKey)), Delete: true}
+	if _, err = datastore.MergeWithResolutions(parents, "bad merge", []datastore.MergeResolution{deleteB}); err == nil {
+		t.Fatalf("Expected error on external merge with unresolved conflicts\n")
+	}
+
+	cTKey, err := NewTKey("c")
+	if err != nil {
+		t.Fatalf("Unable to create key: %v\n", err)
+	}
+	resolutions := []datastore.MergeResolution{
+		deleteB,
+		{Data: data.DataName(), Key: fmt.Sprintf("%x", []byte(cTKey)), Value: []byte("c-merged")},
+		{Data: data.DataName(), Parent: string(right)},
+	}
+	child, err := datastore.MergeWithResolutions(parents, "external merge", resolutions)
+	if err != nil {
+		t.Fatalf("Error doing external merge: %v\n", err)
+	}
+	returnValue := server.TestHTTP(t, "GET", keyreq(child, "a"), nil)
+	if string(returnValue) != "a-right" {
+		t.Errorf("Error on external merged child, key a: expected %q, got %q\n", "a-right", string(returnValue))
+	}
+	server.TestBadHTTP(t, "GET", keyreq(child, "b"), nil)
+
+	// Explicit values are given as plain values and serialized by the server.
+	returnValue = server.TestHTTP(t, "GET", keyreq(child, "c"), nil)
+	if string(returnValue) != "c-merged" {
+		t.Errorf("Error on external merged child, key c: expected %q, got %q\n", "c-merged", string(returnValue))
+	}
+}
+
+func TestVersionDiff(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+
+	config := dvid.NewConfig()
+	dataservice, err := datastore.NewData(uuid, kvtype, "difftest", config)
+	if err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	data, ok := dataservice.(*Data)
+	if !ok {
+		t.Fatalf("Returned new data instance is not keyvalue.Data\n")
+	}
+
+	keyreq := func(uuid dvid.UUID, key string) string {
+		return fmt.Sprintf("%snode/%s/%s/key/%s", server.WebAPIPath, uuid, data.DataName(), key)
+	}
+	server.TestHTTP(t, "POST", keyreq(uuid, "a"), strings.NewReader("a-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "b"), strings.NewReader("b-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "d"), strings.NewReader("d-root"))
+	if err = datastore.Commit(uuid, "root", nil); err != nil {
+		t.Fatalf("Unable to commit root node %s: %v\n", uuid, err)
+	}
+	child, err := datastore.NewVersion(uuid, "child", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off root %s: %v\n", uuid, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(child, "a"), strings.NewReader("a-child"))
+	server.TestHTTP(t, "DELETE", keyreq(child, "b"), nil)
+	server.TestHTTP(t, "POST", keyreq(child, "c"), strings.NewReader("c-child"))
+
+	diffReq := fmt.Sprintf("%srepo/%s/diff?from=%s&to=%s&data=%s", server.WebAPIPath, uuid, uuid, child, data.DataName())
+	returnValue := server.TestHTTP(t, "GET", diffReq, nil)
+	var diffs map[dvid.InstanceName]struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Deleted  []string `json:"deleted"`
+	}
+	if err := json.Unmarshal(returnValue, &diffs); err != nil {
+		t.Fatalf("Can't parse return of diff request: %s\n", string(returnValue))
+	}
+	diff, found := diffs[data.DataName()]
+	if !found {
+		t.Fatalf("Expected diff for %q, got: %s\n", data.DataName(), string(returnValue))
+	}
+	if len(diff.Added) != 1 || diff.Added[0] != "c" {
+		t.Errorf("Expected added key c, got: %s\n", string(returnValue))
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0] != "a" {
+		t.Errorf("Expected modified key a, got: %s\n", string(returnValue))
+	}
+	if len(diff.Deleted) != 1 || diff.Deleted[0] != "b" {
+		t.Errorf("Expected deleted key b, got: %s\n", string(returnValue))
+	}
+
+	// Reversing the direction should swap added and deleted keys.
+	diffReq = fmt.Sprintf("%srepo/%s/diff?from=%s&to=%s&data=%s", server.WebAPIPath, uuid, child, uuid, data.DataName())
+	returnValue = server.TestHTTP(t, "GET", diffReq, nil)
+	if err := json.Unmarshal(returnValue, &diffs); err != nil {
+		t.Fatalf("Can't parse return of diff request: %s\n", string(returnValue))
+	}
+	diff = diffs[data.DataName()]
+	if len(diff.Added) != 1 || diff.Added[0] != "b" || len(diff.Deleted) != 1 || diff.Deleted[0] != "c" {
+		t.Errorf("Bad reverse diff: %s\n", string(returnValue))
+	}
+}
+
+func TestSquash(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+
+	config := dvid.NewConfig()
+	dataservice, err := datastore.NewData(uuid, kvtype, "squashtest", config)
+	if err != nil {
+		t.Fatalf("Error creating new keyvalue instance: %v\n", err)
+	}
+	data, ok := dataservice.(*Data)
+	if !ok {
+		t.Fatalf("Returned new data instance is not keyvalue.Data\n")
+	}
+
+	keyreq := func(uuid dvid.UUID, key string) string {
+		return fmt.Sprintf("%snode/%s/%s/key/%s", server.WebAPIPath, uuid, data.DataName(), key)
+	}
+	checkKeys := func(uuid dvid.UUID, expected map[string]string) {
+		keysReq := fmt.Sprintf("%snode/%s/%s/keys", server.WebAPIPath, uuid, data.DataName())
+		returnValue := server.TestHTTP(t, "GET", keysReq, nil)
+		var keys []string
+		if err := json.Unmarshal(returnValue, &keys); err != nil {
+			t.Fatalf("Can't parse return of keys request: %s\n", string(returnValue))
+		}
+		if len(keys) != len(expected) {
+			t.Errorf("Expected %d keys at %s, got: %v\n", len(expected), uuid, keys)
+		}
+		for key, value := range expected {
+			got := server.TestHTTP(t, "GET", keyreq(uuid, key), nil)
+			if string(got) != value {
+				t.Errorf("Expected key %q at %s to be %q, got %q\n", key, uuid, value, string(got))
+			}
+		}
+	}
+	server.TestHTTP(t, "POST", keyreq(uuid, "a"), strings.NewReader("a-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "b"), strings.NewReader("b-root"))
+	server.TestHTTP(t, "POST", keyreq(uuid, "d"), strings.NewReader("d-root"))
+	if err = datastore.Commit(uuid, "root", nil); err != nil {
+		t.Fatalf("Unable to commit root node %s: %v\n", uuid, err)
+	}
+	v1, err := datastore.NewVersion(uuid, "v1", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off root %s: %v\n", uuid, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(v1, "a"), strings.NewReader("a-1"))
+	server.TestHTTP(t, "DELETE", keyreq(v1, "b"), nil)
+	server.TestHTTP(t, "POST", keyreq(v1, "c"), strings.NewReader("c-1"))
+	if err = datastore.Commit(v1, "v1", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", v1, err)
+	}
+	v2, err := datastore.NewVersion(v1, "v2", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off %s: %v\n", v1, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(v2, "c"), strings.NewReader("c-2"))
+	server.TestHTTP(t, "POST", keyreq(v2, "e"), strings.NewReader("e-2"))
+	if err = datastore.Commit(v2, "v2", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", v2, err)
+	}
+	if err = datastore.TagNode(v2, "squash-release"); err != nil {
+		t.Fatalf("Unable to tag node %s: %v\n", v2, err)
+	}
+	v3, err := datastore.NewVersion(v2, "v3", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off %s: %v\n", v2, err)
+	}
+	server.TestHTTP(t, "POST", keyreq(v3, "f"), strings.NewReader("f-3"))
+
+	if err := datastore.Squash(uuid, v1, v3, nil); err == nil {
+		t.Errorf("Expected error squashing uncommitted node\n")
+	}
+	if err := datastore.Squash(uuid, v2, v1, nil); err == nil {
+		t.Errorf("Expected error squashing from a descendant\n")
+	}
+	if err := datastore.Squash(uuid, v1, v2, nil); err != nil {
+		t.Fatalf("Unable to squash %s through %s: %v\n", v1, v2, err)
+	}
+
+	if _, _, err := datastore.MatchingUUID(string(v2)); err == nil {
+		t.Errorf("Expected squashed node %s to be removed\n", v2)
+	}
+	if tagged, _, err := datastore.MatchingUUID(":squash-release"); err != nil || tagged != v1 {
+		t.Errorf("Expected tag to move to %s, got %s: %v\n", v1, tagged, err)
+	}
+	v3V, err := datastore.VersionFromUUID(v3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1V, err := datastore.VersionFromUUID(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents, err := datastore.GetParentsByVersion(v3V)
+	if err != nil || len(parents) != 1 || parents[0] != v1V {
+		t.Errorf("Expected parent of %s to be remapped to %s, got %v: %v\n", v3, v1, parents, err)
+	}
+	checkKeys(uuid, map[string]string{"a": "a-root", "b": "b-root", "d": "d-root"})
+	checkKeys(v1, map[string]string{"a": "a-1", "c": "c-2", "d": "d-root", "e": "e-2"})
+	checkKeys(v3, map[string]string{"a": "a-1", "c": "c-2", "d": "d-root", "e": "e-2", "f": "f-3"})
+}
+
 /*
 TODO -- Complete when mutation log access added, so we can check mutation is logged and test blobstore
 		fetch with reference.
@@ -20,9 +20,8 @@ import (
 	"github.com/janelia-flyem/dvid/datatype/common/downres"
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
-
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 type testBody struct {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/dvid"
 	"github.com/janelia-flyem/dvid/storage"
 )
@@ -129,3 +130,58 @@ func DecodeAffinitiesTKey(tk storage.TKey) (label uint64, err error) {
 	label = binary.BigEndian.Uint64(ibytes[0:8])
 	return
 }
+
+// ScrubValue verifies a stored value by deserializing it, which checks any checksum,
+// and unmarshaling label blocks and indices.  Implements the datastore.Scrubber interface.
+func (d *Data) ScrubValue(tk storage.TKey, value []byte) (position string, err error) {
+	class, err := tk.Class()
+	if err != nil {
+		return "", err
+	}
+	switch class {
+	case keyLabelBlock:
+		scale, idx, err := DecodeBlockTKey(tk)
+		if err != nil {
+			return "", err
+		}
+		position = fmt.Sprintf("block %s scale %d", dvid.ChunkPoint3d(*idx), scale)
+		data, _, err := dvid.DeserializeData(value, true)
+		if err != nil {
+			return position, err
+		}
+		var block labels.Block
+		if err = block.UnmarshalBinary(data); err != nil {
+			return position, err
+		}
+		blockSize := d.BlockSize()
+		for dim := uint8(0); dim < 3; dim++ {
+			if block.Size[dim] != blockSize.Value(dim) {
+				return position, fmt.Errorf("block size %s doesn't match data block size %s", block.Size, blockSize)
+			}
+		}
+		for _, index := range block.SBIndices {
+			if int(index) >= len(block.Labels) {
+				return position, fmt.Errorf("sub-block index %d exceeds %d labels in block", index, len(block.Labels))
+			}
+		}
+	case keyLabelIndex:
+		label, err := DecodeLabelIndexTKey(tk)
+		if err != nil {
+			return "", err
+		}
+		position = fmt.Sprintf("label %d", label)
+		if len(value) == 0 {
+			return position, nil
+		}
+		data, _, err := dvid.DeserializeData(value, true)
+		if err != nil {
+			return position, err
+		}
+		idx := new(labels.Index)
+		if err = idx.Unmarshal(data); err != nil {
+			return position, err
+		}
+	default:
+	}
+	return position, nil
+}
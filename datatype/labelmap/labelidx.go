@@ -17,9 +17,9 @@ import (
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/datatype/common/proto"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 const (
@@ -1351,8 +1351,9 @@ func (d *Data) countThread(f *os.File, mu *sync.Mutex, wg *sync.WaitGroup, chunk
 	}
 }
 
-// scan all label blocks in this labelmap instance, writing supervoxel counts into a given file
-func (d *Data) writeSVCounts(f *os.File, outPath string, v dvid.VersionID) {
+// scan all label blocks in this labelmap instance, writing supervoxel counts into a given file.
+// The scan stops if the optional job is canceled.
+func (d *Data) writeSVCounts(f *os.File, outPath string, v dvid.VersionID, job *dvid.Job) error {
 	timedLog := dvid.NewTimeLog()
 
 	// Start the counting goroutine
@@ -1368,8 +1369,7 @@ func (d *Data) writeSVCounts(f *os.File, outPath string, v dvid.VersionID) {
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
-		dvid.Errorf("problem getting store for data %q: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("problem getting store for data %q: %v", d.DataName(), err)
 	}
 	ctx := datastore.NewVersionedCtx(d, v)
 	begTKey := NewBlockTKeyByCoord(0, dvid.MinIndexZYX.ToIZYXString())
@@ -1384,6 +1384,10 @@ func (d *Data) writeSVCounts(f *os.File, outPath string, v dvid.VersionID) {
 			wg.Done()
 			return nil
 		}
+		if job.Canceled() {
+			wg.Done()
+			return dvid.ErrJobCanceled
+		}
 		numBlocks++
 		if numBlocks%10000 == 0 {
 			timedLog.Infof("Now counting block %d with chunk channel at %d", numBlocks, len(chunkCh))
@@ -1391,28 +1395,32 @@ func (d *Data) writeSVCounts(f *os.File, outPath string, v dvid.VersionID) {
 		chunkCh <- c
 		return nil
 	})
-	if err != nil {
-		dvid.Errorf("problem during process range: %v\n", err)
-	}
 	close(chunkCh)
 	wg.Wait()
-	if err = f.Close(); err != nil {
-		dvid.Errorf("problem closing file %q: %v\n", outPath, err)
+	if closeErr := f.Close(); closeErr != nil {
+		dvid.Errorf("problem closing file %q: %v\n", outPath, closeErr)
+	}
+	if err != nil {
+		return err
 	}
 	timedLog.Infof("Finished counting supervoxels in %d blocks and sent to output file %q", numBlocks, outPath)
+	return nil
 }
 
-func (d *Data) writeFileMappings(f *os.File, outPath string, v dvid.VersionID) {
-	if err := d.writeMappings(f, v); err != nil {
-		dvid.Errorf("error writing mapping to file %q: %v\n", outPath, err)
-		return
+func (d *Data) writeFileMappings(f *os.File, outPath string, v dvid.VersionID, job *dvid.Job) error {
+	if err := d.writeMappings(f, v, job); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing mapping to file %q: %v", outPath, err)
 	}
 	if err := f.Close(); err != nil {
-		dvid.Errorf("problem closing file %q: %v\n", outPath, err)
+		return fmt.Errorf("problem closing file %q: %v", outPath, err)
 	}
+	return nil
 }
 
-func (d *Data) writeMappings(w io.Writer, v dvid.VersionID) error {
+// writeMappings writes the supervoxel to label mappings at the given version.  The optional
+// job is updated with the percent of mappings written and stops the write if canceled.
+func (d *Data) writeMappings(w io.Writer, v dvid.VersionID, job *dvid.Job) error {
 	timedLog := dvid.NewTimeLog()
 
 	svm, err := getMapping(d, v)
@@ -1441,8 +1449,15 @@ func (d *Data) writeMappings(w io.Writer, v dvid.VersionID) error {
 	svm.RUnlock()
 	timedLog.Infof("write mappings: made duplicate of %d mappings", len(mapping))
 
-	var numMappings, numErrors uint64
+	var numMappings, numErrors, numChecked uint64
 	for supervoxel, vm := range mapping {
+		numChecked++
+		if numChecked%100000 == 0 {
+			if job.Canceled() {
+				return dvid.ErrJobCanceled
+			}
+			job.SetProgress(numChecked, uint64(len(mapping)))
+		}
 		label, present := vm.value(ancestry)
 		if present {
 			numMappings++
@@ -1504,8 +1519,9 @@ func (d *Data) indexThread(f *os.File, mu *sync.Mutex, wg *sync.WaitGroup, chunk
 	}
 }
 
-// scan all label indices in this labelmap instance, writing Blocks data into a given file
-func (d *Data) writeIndices(f *os.File, outPath string, v dvid.VersionID) {
+// scan all label indices in this labelmap instance, writing Blocks data into a given file.
+// The scan stops if the optional job is canceled.
+func (d *Data) writeIndices(f *os.File, outPath string, v dvid.VersionID, job *dvid.Job) error {
 	timedLog := dvid.NewTimeLog()
 
 	// Start the counting goroutine
@@ -1521,8 +1537,7 @@ func (d *Data) writeIndices(f *os.File, outPath string, v dvid.VersionID) {
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
-		dvid.Errorf("problem getting store for data %q: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("problem getting store for data %q: %v", d.DataName(), err)
 	}
 	ctx := datastore.NewVersionedCtx(d, v)
 	begTKey := NewLabelIndexTKey(0)
@@ -1537,6 +1552,10 @@ func (d *Data) writeIndices(f *os.File, outPath string, v dvid.VersionID) {
 			wg.Done()
 			return nil
 		}
+		if job.Canceled() {
+			wg.Done()
+			return dvid.ErrJobCanceled
+		}
 		numIndices++
 		if numIndices%10000 == 0 {
 			timedLog.Infof("Now dumping label index %d with chunk channel at %d", numIndices, len(chunkCh))
@@ -1544,13 +1563,14 @@ func (d *Data) writeIndices(f *os.File, outPath string, v dvid.VersionID) {
 		chunkCh <- c
 		return nil
 	})
-	if err != nil {
-		dvid.Errorf("problem during process range: %v\n", err)
-	}
 	close(chunkCh)
 	wg.Wait()
-	if err = f.Close(); err != nil {
-		dvid.Errorf("problem closing file %q: %v\n", outPath, err)
+	if closeErr := f.Close(); closeErr != nil {
+		dvid.Errorf("problem closing file %q: %v\n", outPath, closeErr)
+	}
+	if err != nil {
+		return err
 	}
 	timedLog.Infof("Finished dumping %d label indices to output file %q", numIndices, outPath)
+	return nil
 }
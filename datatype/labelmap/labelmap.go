@@ -30,12 +30,14 @@ import (
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/datatype/common/downres"
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
+	"github.com/janelia-flyem/dvid/datatype/common/precomputed"
 	"github.com/janelia-flyem/dvid/datatype/common/proto"
+	"github.com/janelia-flyem/dvid/datatype/common/zarr"
 	"github.com/janelia-flyem/dvid/datatype/imageblk"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 const (
@@ -76,6 +78,8 @@ $ dvid repo <UUID> new labelmap <data name> <settings...>
     VoxelUnits      Resolution units (default: "nanometers")
 	IndexedLabels   "false" if no sparse volume support is required (default "true")
 	MaxDownresLevel  The maximum down-res level supported.  Each down-res is factor of 2.
+	Compression     Compression of stored blocks: "gzip" (default), "gzip:<level>", "zstd", or
+	                "zstd:<level>" where zstd levels go from 1 to 22 (default 3).
 
 $ dvid node <UUID> <data name> load <offset> <image glob> <settings...>
 
@@ -136,6 +140,47 @@ $ dvid node <UUID> <data name> dump <dump type> <file path>
 	data name     Name of data to add.
 	dump type     One of "svcount", "mappings", or "indices".
 	file path     Absolute path to a writable file that the dvid server has write privileges to.
+
+$ dvid node <UUID> <data name> export <format> <offset> <size> <path> [compression=gzip] [supervoxels=true]
+
+    Asynchronously writes the labels of a subvolume into a new uint64 zarr or N5 array on
+    the DVID server's filesystem.  The array chunks correspond to blocks of the data instance,
+    so the offset must be block-aligned.  The export runs as a job that can be monitored and
+    canceled using the /api/jobs endpoints.
+
+    Example:
+
+    $ dvid node 3f8c segmentation export n5 0,0,0 1024,1024,512 /data/seg.n5 compression=gzip
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data to export.
+    format        "zarr" or "n5".
+    offset        3d coordinate in the format "x,y,z" of the first voxel exported.
+    size          Size of the exported subvolume in the format "x,y,z".
+    path          Path of the array directory visible to the DVID server.
+    compression   Optional compression of chunks: "raw" (default) or "gzip".
+    supervoxels   If "true", exports unmapped supervoxel ids instead of agglomerated labels.
+
+$ dvid node <UUID> <data name> import <offset> <path>
+
+    Asynchronously writes the labels of an existing zarr or N5 array on the DVID server's
+    filesystem into the version node, with the first voxel of the array stored at the given
+    block-aligned offset.  The array must be 3d with an unsigned integer type and a chunk
+    size that is a multiple of the block size.  Chunks missing from the array are skipped.
+    The import runs as a job that can be monitored and canceled using the /api/jobs endpoints.
+
+    Example:
+
+    $ dvid node 3f8c segmentation import 0,0,0 /data/seg.zarr
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of data to receive the array.
+    offset        3d coordinate in the format "x,y,z" at which to store the first array voxel.
+    path          Path of the array directory visible to the DVID server.
 	
 	
     ------------------
@@ -273,7 +318,8 @@ GET  <api URL>/node/<UUID>/<data name>/specificblocks[?queryopts]
     scale         A number from 0 up to MaxDownresLevel where each level has 1/2 resolution of
 	              previous level.  Level 0 (default) is the highest resolution.
 	compression   Allows retrieval of block data in "lz4", "gzip", "blocks" (native DVID
-				  label blocks), or "uncompressed" (uint64 labels). Default is "blocks".
+				  label blocks), "zstd" (native DVID label blocks with zstd compression), or 
+				  "uncompressed" (uint64 labels). Default is "blocks".
   
 
 GET  <api URL>/node/<UUID>/<data name>/isotropic/<dims>/<size>/<offset>[/<format>][?queryopts]
@@ -427,6 +473,89 @@ GET  <api URL>/node/<UUID>/<data name>/pseudocolor/<dims>/<size>/<offset>[?query
                     are handled.  If the server can't initiate the API call right away, a 503 (Service Unavailable) 
                     status code is returned.
 
+POST <api URL>/node/<UUID>/<data name>/export?format=<format>&offset=<offset>&size=<size>&path=<path>[&queryopts]
+
+    Writes the labels of a subvolume into a new uint64 zarr or N5 array on the DVID server's
+    filesystem and returns JSON giving the number of chunks written, e.g., { "chunks": 64 }.
+    The array chunks correspond to blocks of the data instance, so the offset must be
+    block-aligned.  Exports are allowed on locked nodes.
+
+    The path is relative to the "exportRoot" directory in the server configuration, and
+    absolute paths or paths containing ".." are rejected.  HTTP exports are disabled if no
+    export root is configured, although the command line can write to any path.
+
+    Example: 
+
+    POST <api URL>/node/3f8c/segmentation/export?format=zarr&offset=0_0_0&size=512_512_256&path=seg.zarr
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of labelmap instance.
+
+    Query-string Options:
+
+    format        "zarr" or "n5".
+    offset        Coordinate of the first exported voxel in "x_y_z" format.
+    size          Size of the exported subvolume in "x_y_z" format.
+    path          Path of the array directory relative to the server's export root.
+    compression   Compression of chunks: "raw" (default) or "gzip".
+    supervoxels   If "true", exports unmapped supervoxel ids instead of agglomerated labels.
+
+POST <api URL>/node/<UUID>/<data name>/import?offset=<offset>&path=<path>[&mutate=true]
+
+    Writes the labels of an existing zarr or N5 array on the DVID server's filesystem into
+    the version node and returns JSON giving the number of chunks written.  The array must
+    be 3d with an unsigned integer type and a chunk size that is a multiple of the block size.
+    Chunk files are read in parallel.  Chunks missing from the array are skipped, and voxels
+    of edge chunks beyond the array shape are stored as label 0.
+
+    As with exports, the path is relative to the "exportRoot" directory in the server
+    configuration, and HTTP imports are disabled if no export root is configured.
+
+    Example: 
+
+    POST <api URL>/node/3f8c/segmentation/import?offset=0_0_1024&path=seg.n5/s0
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of labelmap instance.
+
+    Query-string Options:
+
+    offset        Coordinate at which the first array voxel is stored in "x_y_z" format.
+                    Must be block-aligned.
+    path          Path of the array directory relative to the server's export root.
+    mutate        If "true", the import is handled as a mutation of prior labels.  See the
+                    POST /raw endpoint.
+
+GET  <api URL>/node/<UUID>/<data name>/precomputed/info
+GET  <api URL>/node/<UUID>/<data name>/precomputed/<scale key>/<chunk name>[?queryopts]
+
+    Read-only view of the labels in the neuroglancer "precomputed" format, so the URL
+    <api URL>/node/<UUID>/<data name>/precomputed can be used as a precomputed segmentation
+    source.  The "info" endpoint returns the JSON description of the volume with one scale for
+    each level from 0 up to MaxDownresLevel.  Chunks correspond to blocks and are returned
+    using the "compressed_segmentation" encoding.
+
+    Example: 
+
+    GET <api URL>/node/3f8c/segmentation/precomputed/info
+    GET <api URL>/node/3f8c/segmentation/precomputed/s2/0-64_64-128_128-192
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of labelmap instance.
+    scale key     The key of a scale given in the info, e.g., "s0" for scale 0.
+    chunk name    The voxel bounds of the chunk at the given scale in the form
+                    <x0>-<x1>_<y0>-<y1>_<z0>-<z1> where the end coordinates are exclusive.
+
+    Query-string Options:
+
+    supervoxels   If "true", returns unmapped supervoxels, disregarding any merges.
+
 GET <api URL>/node/<UUID>/<data name>/label/<coord>[?queryopts]
 
 	Returns JSON for the label at the given coordinate:
@@ -569,7 +698,8 @@ GET <api URL>/node/<UUID>/<data name>/blocks/<size>/<offset>[?queryopts]
 	scale         A number from 0 up to MaxDownresLevel where each level beyond 0 has 1/2 resolution
 	                of previous level.  Level 0 is the highest resolution.
     compression   Allows retrieval of block data in "lz4" (default), "gzip", blocks" (native DVID
-	              label blocks) or "uncompressed" (uint64 labels).
+	              label blocks), "zstd" (native DVID label blocks with zstd compression) or 
+	              "uncompressed" (uint64 labels).
     throttle      If "true", makes sure only N compute-intense operation (all API calls that can be 
 	              throttled) are handled.  If the server can't initiate the API call right away, a 503 
                   (Service Unavailable) status code is returned.
@@ -579,8 +709,8 @@ POST <api URL>/node/<UUID>/<data name>/blocks[?queryopts]
 
     Puts properly-sized supervoxel block data.  This is the most server-efficient way of
     storing labelmap data, where data read from the HTTP stream is written directly to the 
-	underlying storage.  The default (and currently only supported) compression is gzip on compressed 
-	DVID label Block serialization.
+	underlying storage.  The default compression is gzip on compressed DVID label Block serialization,
+	although zstd can be specified with the "compression" query string.
 
 	Note that maximum label and extents are automatically handled during these calls.
 	If the optional "scale" query is greater than 0, these ingestions will not trigger
@@ -651,8 +781,10 @@ POST <api URL>/node/<UUID>/<data name>/blocks[?queryopts]
 	                of previous level.  Level 0 is the highest resolution.
 	downres       "false" (default) or "true", specifies whether the given blocks should be
 	                down-sampled to lower resolution.  If "true", scale must be "0" or absent.
-    compression   Specifies compression format of block data: default and only option currently is
-					"blocks" (native DVID label blocks).
+    compression   Specifies compression format of block data: "blocks" (default, native DVID label 
+	                blocks with gzip compression) or "zstd" (native DVID label blocks with zstd
+	                compression).  If the compression differs from the instance's stored compression,
+	                blocks are recompressed before storage.
 	noindexing	  If "true" (default "false"), will not compute label indices from the received voxel data.  
 	                Use this in conjunction with POST /index and /affinities endpoint for faster ingestion.
     throttle      If "true", makes sure only N compute-intense operation (all API calls that can be 
@@ -1008,6 +1140,39 @@ POST <api URL>/node/<UUID>/<data name>/merge
 			"UUID": <UUID on which split was done>
 		}
 
+POST <api URL>/node/<UUID>/<data name>/undo/<mutation id>
+
+	Undoes a merge or cleave by applying its exact inverse using the mutation log of
+	this version.  An undone merge cleaves the supervoxels of each merged body back
+	into its original label, and an undone cleave merges the cleaved label back into
+	its original label.  The mappings, label indices and any synced data (e.g.,
+	labelsz and annotations) are updated as for a normal cleave or merge.
+
+	A bad request error (status 400) is returned if the mutation isn't a merge or cleave
+	in this version's log, or if any later mutation modified the same bodies.  Note that 
+	splits cannot be undone.
+
+	Returns JSON:
+	{
+		"MutationID": <unique id for the undo mutation>
+	}
+
+	Kafka JSON message generated by this request:
+		{ 
+			"Action": "undo",
+			"UndoneMutationID": <id of mutation being undone>,
+			"UUID": <UUID on which undo was done>,
+			"MutationID": <unique id for mutation>
+		}
+
+	The inverse cleaves or merges generate their own Kafka messages, and after completion
+	of the undo, the following JSON message is published:
+		{ 
+			"Action": "undo-complete",
+			"MutationID": <unique id for mutation>
+			"UUID": <UUID on which undo was done>
+		}
+
 POST <api URL>/node/<UUID>/<data name>/cleave/<label>
 
 	Cleaves a label given supervoxels to be cleaved.  Requires JSON in request body 
@@ -1733,6 +1898,38 @@ func (d *Data) persistMaxLabel(v dvid.VersionID) error {
 	return store.Put(ctx, maxLabelTKey, buf)
 }
 
+// RemoveVersion removes the max label of a deleted version, implementing the
+// datastore.VersionRemover interface.  The stored max label is deleted along with
+// the version's other key-values.
+func (d *Data) RemoveVersion(uuid dvid.UUID, v dvid.VersionID) error {
+	d.mlMu.Lock()
+	delete(d.MaxLabel, v)
+	d.mlMu.Unlock()
+	return nil
+}
+
+// RemapVersions modifies internal data instance properties that depend on server-specific
+// version ids, implementing the datastore.VersionRemapper interface.  When several versions
+// are squashed into one, the largest max label is kept.
+func (d *Data) RemapVersions(vmap dvid.VersionMap) error {
+	d.mlMu.Lock()
+	defer d.mlMu.Unlock()
+	maxLabels := make(map[dvid.VersionID]uint64, len(d.MaxLabel))
+	for oldv, label := range d.MaxLabel {
+		newv, found := vmap[oldv]
+		if !found {
+			dvid.Infof("No version %d in labelmap %q... discarding max label", oldv, d.DataName())
+			continue
+		}
+		if curMax, found := maxLabels[newv]; found && label < curMax {
+			continue
+		}
+		maxLabels[newv] = label
+	}
+	d.MaxLabel = maxLabels
+	return nil
+}
+
 func (d *Data) persistMaxRepoLabel() error {
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
@@ -2061,10 +2258,10 @@ func (d *Data) sendBlocksSpecific(ctx *datastore.VersionedCtx, w http.ResponseWr
 	switch compression {
 	case "":
 		compression = "blocks"
-	case "lz4", "gzip", "blocks", "uncompressed":
+	case "lz4", "gzip", "blocks", "zstd", "uncompressed":
 		break
 	default:
-		err = fmt.Errorf(`compression must be "lz4" (default), "gzip", "blocks" or "uncompressed"`)
+		err = fmt.Errorf(`compression must be "lz4" (default), "gzip", "blocks", "zstd" or "uncompressed"`)
 		return
 	}
 
@@ -2204,6 +2401,14 @@ type blockData struct {
 	data        []byte
 }
 
+// zstdLevel returns the compression level used when sending zstd-compressed blocks.
+func (d *Data) zstdLevel() dvid.CompressionLevel {
+	if d.Compression().Format() == dvid.Zstd {
+		return d.Compression().Level()
+	}
+	return dvid.DefaultCompression
+}
+
 // transcodes a block of data by doing any data modifications necessary to meet requested
 // compression compared to stored compression as well as raw supervoxels versus mapped labels.
 func (d *Data) transcodeBlock(b blockData) (out []byte, err error) {
@@ -2226,7 +2431,7 @@ func (d *Data) transcodeBlock(b blockData) (out []byte, err error) {
 			err = fmt.Errorf("block %s was corrupted lz4: supposed size %d but had %d bytes", b.bcoord, outsize, len(out))
 			return
 		}
-	case dvid.Uncompressed, dvid.Gzip:
+	case dvid.Uncompressed, dvid.Gzip, dvid.Zstd:
 		outsize = uint32(len(b.data[start:]))
 		out = b.data[start:]
 	default:
@@ -2240,8 +2445,13 @@ func (d *Data) transcodeBlock(b blockData) (out []byte, err error) {
 		formatOut = dvid.LZ4
 	case "blocks":
 		formatOut = formatIn
+		if formatIn == dvid.Zstd { // native blocks are always sent with gzip compression
+			formatOut = dvid.Gzip
+		}
 	case "gzip":
 		formatOut = dvid.Gzip
+	case "zstd":
+		formatOut = dvid.Zstd
 	case "uncompressed":
 		formatOut = dvid.Uncompressed
 	default:
@@ -2283,6 +2493,10 @@ func (d *Data) transcodeBlock(b blockData) (out []byte, err error) {
 				return
 			}
 			zr.Close()
+		case dvid.Zstd:
+			if uncompressed, err = dvid.ZstdUncompress(out); err != nil {
+				return
+			}
 		}
 
 		var block labels.Block
@@ -2301,6 +2515,15 @@ func (d *Data) transcodeBlock(b blockData) (out []byte, err error) {
 				return nil, err
 			}
 			outsize = uint32(len(out))
+		} else if b.compression == "zstd" { // send native DVID block compression with zstd
+			var data []byte
+			if data, err = block.MarshalBinary(); err != nil {
+				return nil, err
+			}
+			if out, err = dvid.ZstdCompress(data, d.zstdLevel()); err != nil {
+				return nil, err
+			}
+			outsize = uint32(len(out))
 		} else { // we are sending raw block data
 			uint64array, size := block.MakeLabelVolume()
 			expectedSize := d.BlockSize().(dvid.Point3d)
@@ -2351,9 +2574,9 @@ func (d *Data) SendBlocks(ctx *datastore.VersionedCtx, w http.ResponseWriter, su
 	w.Header().Set("Content-type", "application/octet-stream")
 
 	switch compression {
-	case "", "lz4", "gzip", "blocks", "uncompressed":
+	case "", "lz4", "gzip", "blocks", "zstd", "uncompressed":
 	default:
-		return fmt.Errorf(`compression must be "lz4" (default), "gzip", "blocks" or "uncompressed"`)
+		return fmt.Errorf(`compression must be "lz4" (default), "gzip", "blocks", "zstd" or "uncompressed"`)
 	}
 
 	// convert x,y,z coordinates to block coordinates for this scale
@@ -2477,10 +2700,14 @@ func (d *Data) ReceiveBlocks(ctx *datastore.VersionedCtx, r io.ReadCloser, scale
 		return fmt.Errorf("cannot downscale blocks of scale > 0")
 	}
 
+	var formatIn dvid.CompressionFormat
 	switch compression {
 	case "", "blocks":
+		formatIn = dvid.Gzip
+	case "zstd":
+		formatIn = dvid.Zstd
 	default:
-		return fmt.Errorf(`compression must be "blocks" (default) at this time`)
+		return fmt.Errorf(`compression must be "blocks" (default) or "zstd"`)
 	}
 
 	timedLog := dvid.NewTimeLog()
@@ -2552,9 +2779,6 @@ func (d *Data) ReceiveBlocks(ctx *datastore.VersionedCtx, r io.ReadCloser, scale
 		putWG.Done()
 	}
 
-	if d.Compression().Format() != dvid.Gzip {
-		return fmt.Errorf("labelmap %q cannot accept GZIP /blocks POST since it internally uses %s", d.DataName(), d.Compression().Format())
-	}
 	var extentsChanged bool
 	extents, err := d.GetExtents(ctx)
 	if err != nil {
@@ -2562,7 +2786,7 @@ func (d *Data) ReceiveBlocks(ctx *datastore.VersionedCtx, r io.ReadCloser, scale
 	}
 	var numBlocks int
 	for {
-		block, compressed, bx, by, bz, err := readStreamedBlock(r, scale)
+		block, compressed, bx, by, bz, err := readStreamedBlockFormat(r, scale, formatIn)
 		if err == io.EOF {
 			break
 		}
@@ -2577,7 +2801,16 @@ func (d *Data) ReceiveBlocks(ctx *datastore.VersionedCtx, r io.ReadCloser, scale
 			}
 			go d.updateBlockMaxLabel(ctx.VersionID(), block)
 		}
-		serialization, err := dvid.SerializePrecompressedData(compressed, d.Compression(), d.Checksum())
+		// store the received compressed block directly if it matches our stored format.
+		var serialization []byte
+		if formatIn == d.Compression().Format() {
+			serialization, err = dvid.SerializePrecompressedData(compressed, d.Compression(), d.Checksum())
+		} else {
+			var data []byte
+			if data, err = block.MarshalBinary(); err == nil {
+				serialization, err = dvid.SerializeData(data, d.Compression(), d.Checksum())
+			}
+		}
 		if err != nil {
 			return fmt.Errorf("can't serialize received block %s data: %v", bcoord, err)
 		}
@@ -2697,18 +2930,88 @@ func (d *Data) DoRPC(req datastore.Request, reply *datastore.Response) error {
 		if err != nil {
 			return err
 		}
+		var dumpFunc func(*os.File, string, dvid.VersionID, *dvid.Job) error
+		var dumpDesc string
 		switch dumpType {
 		case "svcount":
-			go d.writeSVCounts(f, outPath, v)
-			reply.Text = fmt.Sprintf("Asynchronously writing supervoxel counts for data %q, uuid %s to file: %s\n", d.DataName(), uuid, outPath)
+			dumpFunc, dumpDesc = d.writeSVCounts, "supervoxel counts"
 		case "mappings":
-			go d.writeFileMappings(f, outPath, v)
-			reply.Text = fmt.Sprintf("Asynchronously writing mappings for data %q, uuid %s to file: %s\n", d.DataName(), uuid, outPath)
+			dumpFunc, dumpDesc = d.writeFileMappings, "mappings"
 		case "indices":
-			go d.writeIndices(f, outPath, v)
-			reply.Text = fmt.Sprintf("Asynchronously writing label indices for data %q, uuid %s to file: %s\n", d.DataName(), uuid, outPath)
+			dumpFunc, dumpDesc = d.writeIndices, "label indices"
 		default:
+			f.Close()
+			return fmt.Errorf("unknown dump type %q.  See command-line help", dumpType)
 		}
+		job := dvid.NewJob("labelmap dump "+dumpType, req.User, uuid, d.DataName())
+		go func() {
+			job.Finish(dumpFunc(f, outPath, v, job))
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously writing %s for data %q, uuid %s to file %s as job %d\n", dumpDesc, d.DataName(), uuid, outPath, job.ID())
+		return nil
+
+	case "export":
+		var uuidStr, dataName, cmdStr, formatStr, offsetStr, sizeStr, path string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &formatStr, &offsetStr, &sizeStr, &path)
+		if path == "" {
+			return fmt.Errorf("poorly formatted export command.  See command-line help")
+		}
+		format, err := zarr.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+		offset, err := dvid.StringToPoint3d(offsetStr, ",")
+		if err != nil {
+			return fmt.Errorf("illegal offset specification: %s: %v", offsetStr, err)
+		}
+		size, err := dvid.StringToPoint3d(sizeStr, ",")
+		if err != nil {
+			return fmt.Errorf("illegal size specification: %s: %v", sizeStr, err)
+		}
+		compression, _ := req.Setting("compression")
+		supervoxelsStr, _ := req.Setting("supervoxels")
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		job := dvid.NewJob("labelmap export", req.User, uuid, d.DataName())
+		go func() {
+			_, err := d.ExportArray(v, path, format, offset, size, compression, supervoxelsStr == "true", job)
+			job.Finish(err)
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously exporting labelmap %q, uuid %s to %s array %s as job %d\n", d.DataName(), uuidStr, format, path, job.ID())
+		return nil
+
+	case "import":
+		var uuidStr, dataName, cmdStr, offsetStr, path string
+		req.CommandArgs(1, &uuidStr, &dataName, &cmdStr, &offsetStr, &path)
+		if path == "" {
+			return fmt.Errorf("poorly formatted import command.  See command-line help")
+		}
+		offset, err := dvid.StringToPoint3d(offsetStr, ",")
+		if err != nil {
+			return fmt.Errorf("illegal offset specification: %s: %v", offsetStr, err)
+		}
+		uuid, v, err := datastore.MatchingUUID(uuidStr)
+		if err != nil {
+			return err
+		}
+		locked, err := datastore.LockedVersion(v)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return fmt.Errorf("cannot import into locked node %s", uuid)
+		}
+		if err = datastore.AddToNodeLog(uuid, []string{req.Command.String()}); err != nil {
+			return err
+		}
+		job := dvid.NewJob("labelmap import", req.User, uuid, d.DataName())
+		go func() {
+			_, err := d.ImportArray(v, path, offset, false, job)
+			job.Finish(err)
+		}()
+		reply.Text = fmt.Sprintf("Asynchronously importing array %s into labelmap %q, uuid %s as job %d\n", path, d.DataName(), uuidStr, job.ID())
 		return nil
 
 	default:
@@ -3171,6 +3474,15 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 	case "raw", "isotropic":
 		d.handleDataRequest(ctx, w, r, parts)
 
+	case "precomputed":
+		d.handlePrecomputed(ctx, w, r, parts)
+
+	case "export":
+		d.handleExport(ctx, w, r)
+
+	case "import":
+		d.handleImport(ctx, w, r)
+
 	// endpoints after this must have data instance IndexedLabels = true
 
 	case "lastmod":
@@ -3218,6 +3530,9 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 	case "merge":
 		d.handleMerge(ctx, w, r, parts)
 
+	case "undo":
+		d.handleUndo(ctx, w, r, parts)
+
 	case "index":
 		d.handleIndex(ctx, w, r, parts)
 
@@ -3687,7 +4002,7 @@ func (d *Data) handleMappings(ctx *datastore.VersionedCtx, w http.ResponseWriter
 		timedLog.Infof("HTTP POST %d merges (%s)", len(mappings.Mappings), r.URL)
 
 	case "get":
-		if err := d.writeMappings(w, ctx.VersionID()); err != nil {
+		if err := d.writeMappings(w, ctx.VersionID(), nil); err != nil {
 			server.BadRequest(w, r, "unable to write mappings: %v", err)
 		}
 		timedLog.Infof("HTTP GET mappings (%s)", r.URL)
@@ -3765,6 +4080,156 @@ func (d *Data) handlePseudocolor(ctx *datastore.VersionedCtx, w http.ResponseWri
 	timedLog.Infof("HTTP GET pseudocolor with shape %s, size %s, offset %s", parts[4], parts[5], parts[6])
 }
 
+func (d *Data) handlePrecomputed(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	// GET <api URL>/node/<UUID>/<data name>/precomputed/info
+	// GET <api URL>/node/<UUID>/<data name>/precomputed/<scale key>/<chunk name>
+	if strings.ToLower(r.Method) != "get" {
+		server.BadRequest(w, r, "only GET is supported on the precomputed endpoint")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	if len(parts) == 5 && parts[4] == "info" {
+		vol, err := d.PrecomputedVolume(ctx)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		vol.VolumeType = "segmentation"
+		vol.Encoding = "compressed_segmentation"
+		vol.MaxScale = d.MaxDownresLevel
+		jsonBytes, err := json.Marshal(precomputed.NewInfo(vol))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		w.Header().Set("Content-type", "application/json")
+		fmt.Fprintf(w, string(jsonBytes))
+		timedLog.Infof("HTTP GET precomputed info (%s)", r.URL)
+		return
+	}
+	if len(parts) != 6 {
+		server.BadRequest(w, r, "precomputed endpoint must be followed by 'info' or <scale key>/<chunk name>")
+		return
+	}
+	scale, err := precomputed.ParseScaleKey(parts[4])
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	if scale > d.MaxDownresLevel {
+		server.BadRequest(w, r, "scale %d exceeds max downres level %d of data %q", scale, d.MaxDownresLevel, d.DataName())
+		return
+	}
+	offset, size, err := precomputed.ParseChunkName(parts[5])
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	supervoxels := r.URL.Query().Get("supervoxels") == "true"
+
+	subvol := dvid.NewSubvolume(offset, size)
+	lbl, err := d.NewLabels(subvol, nil)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	data, err := d.GetVolume(ctx.VersionID(), lbl, supervoxels, scale, "")
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	compressed, err := compressGoogle(data, subvol)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-type", "application/octet-stream")
+	if _, err := w.Write(compressed); err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	timedLog.Infof("HTTP GET precomputed chunk %s at scale %d (%s)", parts[5], scale, r.URL)
+}
+
+func (d *Data) handleExport(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request) {
+	// POST <api URL>/node/<UUID>/<data name>/export?format=...&offset=...&size=...&path=...
+	if strings.ToLower(r.Method) != "post" {
+		server.BadRequest(w, r, "only POST is supported on the export endpoint")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	queryStrings := r.URL.Query()
+	format, err := zarr.ParseFormat(queryStrings.Get("format"))
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	offset, err := dvid.StringToPoint3d(queryStrings.Get("offset"), "_")
+	if err != nil {
+		server.BadRequest(w, r, "bad offset for export: %v", err)
+		return
+	}
+	size, err := dvid.StringToPoint3d(queryStrings.Get("size"), "_")
+	if err != nil {
+		server.BadRequest(w, r, "bad size for export: %v", err)
+		return
+	}
+	if queryStrings.Get("path") == "" {
+		server.BadRequest(w, r, "export requires a 'path' query string")
+		return
+	}
+	path, err := server.ExportPath(queryStrings.Get("path"))
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	supervoxels := queryStrings.Get("supervoxels") == "true"
+	numChunks, err := d.ExportArray(ctx.VersionID(), path, format, offset, size, queryStrings.Get("compression"), supervoxels, nil)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	fmt.Fprintf(w, `{"chunks": %d}`, numChunks)
+	timedLog.Infof("HTTP POST export of %d chunks to %s (%s)", numChunks, path, r.URL)
+}
+
+func (d *Data) handleImport(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request) {
+	// POST <api URL>/node/<UUID>/<data name>/import?offset=...&path=...
+	if strings.ToLower(r.Method) != "post" {
+		server.BadRequest(w, r, "only POST is supported on the import endpoint")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	queryStrings := r.URL.Query()
+	offset, err := dvid.StringToPoint3d(queryStrings.Get("offset"), "_")
+	if err != nil {
+		server.BadRequest(w, r, "bad offset for import: %v", err)
+		return
+	}
+	if queryStrings.Get("path") == "" {
+		server.BadRequest(w, r, "import requires a 'path' query string")
+		return
+	}
+	path, err := server.ExportPath(queryStrings.Get("path"))
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	mutate := queryStrings.Get("mutate") == "true"
+	numChunks, err := d.ImportArray(ctx.VersionID(), path, offset, mutate, nil)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	fmt.Fprintf(w, `{"chunks": %d}`, numChunks)
+	timedLog.Infof("HTTP POST import of %d chunks from %s (%s)", numChunks, path, r.URL)
+}
+
 func (d *Data) handleDataRequest(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
 	if len(parts) < 7 {
 		server.BadRequest(w, r, "'%s' must be followed by shape/size/offset", parts[3])
@@ -4625,6 +5090,35 @@ func (d *Data) handleMerge(ctx *datastore.VersionedCtx, w http.ResponseWriter, r
 	timedLog.Infof("HTTP merge request (%s)", r.URL)
 }
 
+func (d *Data) handleUndo(ctx *datastore.VersionedCtx, w http.ResponseWriter, r *http.Request, parts []string) {
+	// POST <api URL>/node/<UUID>/<data name>/undo/<mutation id>
+	if strings.ToLower(r.Method) != "post" {
+		server.BadRequest(w, r, "Undo requests must be POST actions.")
+		return
+	}
+	if len(parts) < 5 {
+		server.BadRequest(w, r, "ERROR: DVID requires mutation ID to follow 'undo' command")
+		return
+	}
+	timedLog := dvid.NewTimeLog()
+
+	mutID, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil {
+		server.BadRequest(w, r, err)
+		return
+	}
+	info := dvid.GetModInfo(r)
+	undoID, err := d.UndoMutation(ctx.VersionID(), mutID, info)
+	if err != nil {
+		server.BadRequest(w, r, fmt.Sprintf("Error on undo: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"MutationID": %d}`, undoID)
+
+	timedLog.Infof("HTTP undo of mutation %d request (%s)", mutID, r.URL)
+}
+
 // --------- Other functions on labelmap Data -----------------
 
 // GetLabelBlock returns a compressed label Block of the given block coordinate.
@@ -16,13 +16,14 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/datatype/common/downres"
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 var (
@@ -1556,6 +1557,78 @@ func TestPostBlock(t *testing.T) {
 	}
 }
 
+func TestZstdBlocks(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	server.CreateTestInstance(t, uuid, "labelmap", "gziplabels", dvid.Config{})
+	config := dvid.NewConfig()
+	config.Set("Compression", "zstd:5")
+	server.CreateTestInstance(t, uuid, "labelmap", "zstdlabels", config)
+
+	td := loadTestData(t, testFiles[0])
+	blockData, err := td.b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := dvid.ZstdCompress(blockData, dvid.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"gziplabels", "zstdlabels"} {
+		var buf bytes.Buffer
+		writeTestInt32(t, &buf, 1)
+		writeTestInt32(t, &buf, 2)
+		writeTestInt32(t, &buf, 3)
+		writeTestInt32(t, &buf, int32(len(compressed)))
+		buf.Write(compressed)
+		apiStr := fmt.Sprintf("%snode/%s/%s/blocks?compression=zstd", server.WebAPIPath, uuid, name)
+		server.TestHTTP(t, "POST", apiStr, &buf)
+		if err := datastore.BlockOnUpdating(uuid, dvid.InstanceName(name)); err != nil {
+			t.Fatalf("Error blocking on sync of %s: %v\n", name, err)
+		}
+
+		// default native blocks are always returned with gzip compression.
+		testGetBlock(t, uuid, name, dvid.Point3d{1, 2, 3}, td)
+
+		apiStr = fmt.Sprintf("%snode/%s/%s/specificblocks?compression=zstd&blocks=1,2,3", server.WebAPIPath, uuid, name)
+		data := server.TestHTTP(t, "GET", apiStr, nil)
+		block, _, bx, by, bz, err := readStreamedBlockFormat(bytes.NewBuffer(data), 0, dvid.Zstd)
+		if err != nil {
+			t.Fatalf("unable to read zstd block from %s: %v\n", name, err)
+		}
+		if bx != 1 || by != 2 || bz != 3 {
+			t.Fatalf("expected block (1,2,3) from %s, got (%d,%d,%d)\n", name, bx, by, bz)
+		}
+		izyx := dvid.IndexZYX{1, 2, 3}
+		checkBlock(t, labels.PositionedBlock{*block, izyx.ToIZYXString()}, td)
+	}
+
+	// make sure the zstd instance stores blocks with zstd compression.
+	d, err := GetByUUIDName(uuid, "zstdlabels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Compression().Format() != dvid.Zstd || d.Compression().Level() != 5 {
+		t.Fatalf("expected zstd level 5 compression, got %s\n", d.Compression())
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := dvid.IndexZYX{1, 2, 3}
+	val, err := store.Get(datastore.NewVersionedCtx(d, v), NewBlockTKey(0, &idx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, format, err := dvid.DeserializeData(val, true); err != nil || format != dvid.Zstd {
+		t.Fatalf("expected stored block to be zstd compressed, got %s: %v\n", format, err)
+	}
+}
+
 func TestBigPostBlock(t *testing.T) {
 	if err := server.OpenTest(); err != nil {
 		t.Fatalf("can't open test server: %v\n", err)
@@ -2081,3 +2154,232 @@ func TestLabels(t *testing.T) {
 func TestLabelsUnindexed(t *testing.T) {
 	testLabels(t, false)
 }
+
+func TestScrub(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, v := initTestRepo()
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", dvid.Config{})
+	vol := newTestVolume(128, 64, 64)
+	vol.addSubvol(dvid.Point3d{0, 0, 0}, dvid.Point3d{128, 64, 64}, 10)
+	vol.put(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+
+	// overwrite one block with a value that has a good checksum but isn't a block.
+	d, err := GetByUUIDName(uuid, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compression, _ := dvid.NewCompression(dvid.Uncompressed, dvid.DefaultCompression)
+	badValue, err := dvid.SerializeData([]byte("not a label block"), compression, dvid.CRC32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := datastore.NewVersionedCtx(d, v)
+	idx := dvid.IndexZYX{1, 0, 0}
+	if err := store.Put(ctx, NewBlockTKey(0, &idx), badValue); err != nil {
+		t.Fatal(err)
+	}
+
+	apiStr := fmt.Sprintf("%srepo/%s/scrub?data=labels", server.WebAPIPath, uuid)
+	server.TestHTTP(t, "POST", apiStr, nil)
+	var report datastore.ScrubReport
+	for i := 0; i < 500; i++ {
+		r := server.TestHTTP(t, "GET", fmt.Sprintf("%srepo/%s/scrub", server.WebAPIPath, uuid), nil)
+		if err := json.Unmarshal(r, &report); err != nil {
+			t.Fatalf("unable to parse scrub report: %v\n", err)
+		}
+		if !report.Running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report.Running || report.Error != "" || len(report.Instances) != 1 {
+		t.Fatalf("bad scrub report: %v\n", report)
+	}
+	ir := report.Instances[0]
+	if !ir.Supported || ir.KeysScanned < 2 || ir.NumDamaged != 1 || len(ir.Damaged) != 1 {
+		t.Fatalf("expected one damaged key, got report: %v\n", ir)
+	}
+	if ir.Damaged[0].Position != "block (1,0,0) scale 0" || ir.Damaged[0].UUID != uuid {
+		t.Errorf("bad damaged key report: %v\n", ir.Damaged[0])
+	}
+
+	// instances of datatypes that can't be verified are listed as skipped.
+	server.CreateTestInstance(t, uuid, "roi", "myroi", dvid.Config{})
+	server.TestHTTP(t, "POST", fmt.Sprintf("%srepo/%s/scrub", server.WebAPIPath, uuid), nil)
+	for i := 0; i < 500; i++ {
+		r := server.TestHTTP(t, "GET", fmt.Sprintf("%srepo/%s/scrub", server.WebAPIPath, uuid), nil)
+		report = datastore.ScrubReport{}
+		if err := json.Unmarshal(r, &report); err != nil {
+			t.Fatalf("unable to parse scrub report: %v\n", err)
+		}
+		if !report.Running {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report.Running || len(report.Instances) != 2 || len(report.Skipped) != 1 || report.Skipped[0] != "myroi" {
+		t.Errorf("expected roi instance to be skipped, got report: %v\n", report)
+	}
+}
+
+func TestDeleteVersionMaxLabel(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	root, _ := initTestRepo()
+	server.CreateTestInstance(t, root, "labelmap", "labels", dvid.Config{})
+	server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/commit", server.WebAPIPath, root), bytes.NewBufferString(`{"note": "base"}`))
+	respData := server.TestHTTP(t, "POST", fmt.Sprintf("%snode/%s/newversion", server.WebAPIPath, root), nil)
+	resp := struct {
+		Child string `json:"child"`
+	}{}
+	if err := json.Unmarshal(respData, &resp); err != nil {
+		t.Fatalf("Expected 'child' JSON response.  Got %s\n", string(respData))
+	}
+	child := dvid.UUID(resp.Child)
+	childV, err := datastore.VersionFromUUID(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vol := newTestVolume(64, 64, 64)
+	vol.addSubvol(dvid.Point3d{0, 0, 0}, dvid.Point3d{64, 64, 64}, 20)
+	vol.put(t, child, "labels")
+	if err := datastore.BlockOnUpdating(child, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	d, err := GetByUUIDName(child, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.mlMu.RLock()
+	maxLabel, found := d.MaxLabel[childV]
+	d.mlMu.RUnlock()
+	if !found || maxLabel != 20 {
+		t.Fatalf("expected max label 20 in child, got %d (found %t)\n", maxLabel, found)
+	}
+
+	server.TestHTTP(t, "DELETE", fmt.Sprintf("%snode/%s", server.WebAPIPath, child), nil)
+	d.mlMu.RLock()
+	_, found = d.MaxLabel[childV]
+	d.mlMu.RUnlock()
+	if found {
+		t.Errorf("expected max label of deleted version to be removed\n")
+	}
+}
+
+func TestSquashLabelmap(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	root, _ := initTestRepo()
+	server.CreateTestInstance(t, root, "labelmap", "labels", dvid.Config{})
+	vol := newTestVolume(128, 64, 64)
+	vol.addSubvol(dvid.Point3d{0, 0, 0}, dvid.Point3d{64, 64, 64}, 1)
+	vol.addSubvol(dvid.Point3d{64, 0, 0}, dvid.Point3d{64, 64, 64}, 2)
+	vol.put(t, root, "labels")
+	if err := datastore.BlockOnUpdating(root, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	if err := datastore.Commit(root, "root", nil); err != nil {
+		t.Fatalf("Unable to commit root node %s: %v\n", root, err)
+	}
+	v1, err := datastore.NewVersion(root, "v1", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off root %s: %v\n", root, err)
+	}
+	if err := datastore.Commit(v1, "v1", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", v1, err)
+	}
+	v2, err := datastore.NewVersion(v1, "v2", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off %s: %v\n", v1, err)
+	}
+
+	// add a new label and merge in v2.
+	vol.addSubvol(dvid.Point3d{0, 0, 0}, dvid.Point3d{32, 32, 32}, 30)
+	vol.put(t, v2, "labels")
+	if err := datastore.BlockOnUpdating(v2, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	mergeJSON(`[1, 2]`).send(t, v2, "labels")
+	if err := datastore.BlockOnUpdating(v2, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	if err := datastore.Commit(v2, "v2", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", v2, err)
+	}
+	v3, err := datastore.NewVersion(v2, "v3", "", nil)
+	if err != nil {
+		t.Fatalf("Unable to create child off %s: %v\n", v2, err)
+	}
+
+	v1V, err := datastore.VersionFromUUID(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2V, err := datastore.VersionFromUUID(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.Squash(root, v1, v2, nil); err != nil {
+		t.Fatalf("Unable to squash %s through %s: %v\n", v1, v2, err)
+	}
+
+	d, err := GetByUUIDName(v1, "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.mlMu.RLock()
+	maxLabel, found := d.MaxLabel[v1V]
+	_, foundV2 := d.MaxLabel[v2V]
+	d.mlMu.RUnlock()
+	if !found || maxLabel != 30 || foundV2 {
+		t.Errorf("expected max label 30 remapped to squashed version, got %d (found %t, v2 found %t)\n", maxLabel, found, foundV2)
+	}
+	msgs, err := d.GetReadLog().ReadAll(d.DataUUID(), v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) == 0 {
+		t.Errorf("expected mutation log of squashed version %s to hold merge\n", v1)
+	}
+
+	// rebuild the supervoxel mapping from the logs as done after a restart.
+	iMap.Lock()
+	delete(iMap.maps, d.DataUUID())
+	iMap.Unlock()
+	for _, uuid := range []dvid.UUID{v1, v3} {
+		apiStr := fmt.Sprintf("%snode/%s/%s/label/100_40_40", server.WebAPIPath, uuid, "labels")
+		var r labelResp
+		if err := json.Unmarshal(server.TestHTTP(t, "GET", apiStr, nil), &r); err != nil {
+			t.Fatalf("Unable to parse 'label' endpoint response: %v\n", err)
+		}
+		if r.Label != 1 {
+			t.Errorf("expected merged label 1 at %s after squash, got %d\n", uuid, r.Label)
+		}
+	}
+	apiStr := fmt.Sprintf("%snode/%s/%s/label/100_40_40", server.WebAPIPath, root, "labels")
+	var r labelResp
+	if err := json.Unmarshal(server.TestHTTP(t, "GET", apiStr, nil), &r); err != nil {
+		t.Fatalf("Unable to parse 'label' endpoint response: %v\n", err)
+	}
+	if r.Label != 2 {
+		t.Errorf("expected unmerged label 2 at root after squash, got %d\n", r.Label)
+	}
+}
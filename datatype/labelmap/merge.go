@@ -0,0 +1,291 @@
+/*
+	This file supports type-specific merging and diffing of labelmap versions.
+*/
+
+package labelmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/labels"
+	"github.com/janelia-flyem/dvid/datatype/common/proto"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// checkMergeMutations returns an error if any version along a non-first parent's
+// ancestry, not shared with the first parent, has mutation log entries.  A merge child
+// reads its supervoxel mapping along the first parent's ancestry, so merges and splits
+// logged in other parents could not be reflected in the merged child.
+func (d *Data) checkMergeMutations(parents []dvid.VersionID) error {
+	rl := d.GetReadLog()
+	if rl == nil || len(parents) < 2 {
+		return nil
+	}
+	firstAncestry, err := datastore.GetAncestry(parents[0])
+	if err != nil {
+		return err
+	}
+	shared := make(map[dvid.VersionID]struct{}, len(firstAncestry))
+	for _, v := range firstAncestry {
+		shared[v] = struct{}{}
+	}
+	for _, parent := range parents[1:] {
+		ancestry, err := datastore.GetAncestry(parent)
+		if err != nil {
+			return err
+		}
+		for _, v := range ancestry {
+			if _, found := shared[v]; found {
+				break
+			}
+			uuid, err := datastore.UUIDFromVersion(v)
+			if err != nil {
+				return err
+			}
+			data, err := rl.ReadBinary(d.DataUUID(), uuid)
+			if err != nil {
+				return err
+			}
+			if len(data) != 0 {
+				return fmt.Errorf("version %s has labelmap mutations that can't be merged into the mapping of the first parent", uuid)
+			}
+			shared[v] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// firstChanged returns the index of the earliest parent whose value differs from the
+// common ancestor's value, or 0 if no parent changed it.
+func firstChanged(conflict datastore.MergeConflict) int {
+	for i, value := range conflict.Values {
+		if (value == nil) != (conflict.Base == nil) || !bytes.Equal(value, conflict.Base) {
+			return i
+		}
+	}
+	return 0
+}
+
+// decodeMergeIndex returns the label index in a stored value or nil if there is none.
+func decodeMergeIndex(value []byte) (*labels.Index, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	data, _, err := dvid.DeserializeData(value, true)
+	if err != nil {
+		return nil, err
+	}
+	idx := new(labels.Index)
+	if err := idx.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// mergeLabelIndex rebuilds the index of a label from the merged scale 0 blocks of the
+// child, considering every block in the label's index in the common ancestor or any parent.
+func (d *Data) mergeLabelIndex(ctx *datastore.VersionedCtx, svmap *SVMap, conflict datastore.MergeConflict) error {
+	label, err := DecodeLabelIndexTKey(conflict.TKey)
+	if err != nil {
+		return err
+	}
+	values := append([][]byte{conflict.Base}, conflict.Values...)
+	winnerNum := firstChanged(conflict) + 1
+	candidates := make(map[uint64]struct{})
+	var winner *labels.Index
+	for i, value := range values {
+		idx, err := decodeMergeIndex(value)
+		if err != nil {
+			return fmt.Errorf("can't decode index for label %d: %v", label, err)
+		}
+		if idx == nil {
+			continue
+		}
+		for zyx := range idx.Blocks {
+			candidates[zyx] = struct{}{}
+		}
+		if i == winnerNum {
+			winner = idx
+		}
+	}
+
+	v := ctx.VersionID()
+	idx := new(labels.Index)
+	idx.Label = label
+	if winner != nil {
+		idx.LastMutId = winner.LastMutId
+		idx.LastModTime = winner.LastModTime
+		idx.LastModUser = winner.LastModUser
+		idx.LastModApp = winner.LastModApp
+	}
+	idx.Blocks = make(map[uint64]*proto.SVCount)
+	for zyx := range candidates {
+		pb, err := d.getLabelBlock(ctx, 0, labels.BlockIndexToIZYXString(zyx))
+		if err != nil {
+			return err
+		}
+		if pb == nil {
+			continue
+		}
+		for supervoxel, count := range pb.CalcNumLabels(nil) {
+			if count <= 0 {
+				continue
+			}
+			if mapped, _ := svmap.MappedLabel(v, supervoxel); mapped != label {
+				continue
+			}
+			svc, found := idx.Blocks[zyx]
+			if !found {
+				svc = &proto.SVCount{Counts: make(map[uint64]uint32)}
+				idx.Blocks[zyx] = svc
+			}
+			svc.Counts[supervoxel] = uint32(count)
+		}
+	}
+	if len(idx.Blocks) == 0 {
+		return deleteCachedLabelIndex(d, v, label)
+	}
+	return putCachedLabelIndex(d, v, idx)
+}
+
+// MergeConflicts resolves keys modified in more than one parent during an auto merge,
+// implementing the datastore.Merger interface.  Label blocks and affinities take the
+// value of the earliest parent in merge order that changed them.  Label indices are then
+// rebuilt from the merged blocks using the child's supervoxel mapping so they agree with
+// the block data, and the max label is the largest of the parents' max labels.  Since
+// the child's mapping follows the first parent, the merge is refused if other parents
+// have their own labelmap mutations.
+func (d *Data) MergeConflicts(ctx *datastore.VersionedCtx, parents []dvid.VersionID, conflicts []datastore.MergeConflict) error {
+	if err := d.checkMergeMutations(parents); err != nil {
+		return err
+	}
+	store, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	v := ctx.VersionID()
+	var indexConflicts []datastore.MergeConflict
+	for _, conflict := range conflicts {
+		class, err := conflict.TKey.Class()
+		if err != nil {
+			return err
+		}
+		switch class {
+		case keyLabelBlock, keyAffinities:
+			value := conflict.Values[firstChanged(conflict)]
+			if value == nil {
+				err = store.Delete(ctx, conflict.TKey)
+			} else {
+				err = store.Put(ctx, conflict.TKey, value)
+			}
+			if err != nil {
+				return err
+			}
+		case keyLabelIndex:
+			indexConflicts = append(indexConflicts, conflict)
+		case keyLabelMax:
+			var maxLabel uint64
+			for _, value := range conflict.Values {
+				if len(value) == 8 {
+					if label := binary.LittleEndian.Uint64(value); label > maxLabel {
+						maxLabel = label
+					}
+				}
+			}
+			d.mlMu.Lock()
+			d.MaxLabel[v] = maxLabel
+			err = d.persistMaxLabel(v)
+			d.mlMu.Unlock()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unable to merge conflicting %s %v", d.DescribeTKeyClass(class), conflict.TKey)
+		}
+	}
+	if len(indexConflicts) == 0 {
+		return nil
+	}
+	svmap, err := getMapping(d, v)
+	if err != nil {
+		return err
+	}
+	for _, conflict := range indexConflicts {
+		if err := d.mergeLabelIndex(ctx, svmap, conflict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SerializeValue converts a client-supplied label block or label index into its stored
+// format, implementing the datastore.ValueSerializer interface.  Other values are
+// stored as given.
+func (d *Data) SerializeValue(tk storage.TKey, value []byte) ([]byte, error) {
+	class, err := tk.Class()
+	if err != nil {
+		return nil, err
+	}
+	switch class {
+	case keyLabelBlock:
+		return dvid.SerializeData(value, d.Compression(), d.Checksum())
+	case keyLabelIndex:
+		compressFormat, _ := dvid.NewCompression(dvid.LZ4, dvid.DefaultCompression)
+		return dvid.SerializeData(value, compressFormat, dvid.NoChecksum)
+	default:
+		return value, nil
+	}
+}
+
+// DiffSummary summarizes the changes between two versions by listing the labels whose
+// label index was added, modified, or deleted as well as the coordinates of changed
+// blocks at each scale.  Implements the datastore.Differ interface.
+func (d *Data) DiffSummary(from, to dvid.VersionID, diff *datastore.VersionDiff) (interface{}, error) {
+	type labelChanges struct {
+		Added    []uint64 `json:"added"`
+		Modified []uint64 `json:"modified"`
+		Deleted  []uint64 `json:"deleted"`
+	}
+	var summary struct {
+		Labels    labelChanges                  `json:"labels"`
+		Blocks    map[uint8][]dvid.ChunkPoint3d `json:"blocks"`
+		OtherKeys int                           `json:"otherKeys"`
+	}
+	summary.Blocks = make(map[uint8][]dvid.ChunkPoint3d)
+	for i, tks := range [][]storage.TKey{diff.Added, diff.Modified, diff.Deleted} {
+		for _, tk := range tks {
+			class, err := tk.Class()
+			if err != nil {
+				return nil, err
+			}
+			switch class {
+			case keyLabelIndex:
+				label, err := DecodeLabelIndexTKey(tk)
+				if err != nil {
+					return nil, err
+				}
+				switch i {
+				case 0:
+					summary.Labels.Added = append(summary.Labels.Added, label)
+				case 1:
+					summary.Labels.Modified = append(summary.Labels.Modified, label)
+				default:
+					summary.Labels.Deleted = append(summary.Labels.Deleted, label)
+				}
+			case keyLabelBlock:
+				scale, idx, err := DecodeBlockTKey(tk)
+				if err != nil {
+					return nil, err
+				}
+				summary.Blocks[scale] = append(summary.Blocks[scale], dvid.ChunkPoint3d(*idx))
+			default:
+				summary.OtherKeys++
+			}
+		}
+	}
+	return summary, nil
+}
@@ -30,7 +30,7 @@ type sizeChange struct {
 // MergeLabels synchronously merges any number of labels throughout the various label
 // data structures.  It assumes that the merges aren't cascading, e.g., there is no
 // attempt to merge label 3 into 4 and also 4 into 5.  The caller should have flattened
-// the merges.
+// the merges.  If the op has a zero mutation ID, a new one is assigned.
 // TODO: Provide some indication that subset of labels are under evolution, returning
 //   an "unavailable" status or 203 for non-authoritative response.  This might not be
 //   feasible for clustered DVID front-ends due to coordination issues.
@@ -50,7 +50,10 @@ func (d *Data) MergeLabels(v dvid.VersionID, op labels.MergeOp, info dvid.ModInf
 	defer d.StopUpdate()
 
 	timedLog := dvid.NewTimeLog()
-	mutID = d.NewMutationID()
+	if op.MutID == 0 {
+		op.MutID = d.NewMutationID()
+	}
+	mutID = op.MutID
 
 	// send kafka merge event to instance-uuid topic
 	// msg: {"action": "merge", "target": targetlabel, "labels": [merge labels]}
@@ -223,21 +226,7 @@ func (d *Data) CleaveLabel(v dvid.VersionID, label uint64, info dvid.ModInfo, r
 		CleavedLabel:       cleaveLabel,
 		CleavedSupervoxels: cleaveSupervoxels,
 	}
-	if err = CleaveIndex(d, v, op, info); err != nil {
-		return
-	}
-	if err = addCleaveToMapping(d, v, op); err != nil {
-		return
-	}
-	if err = labels.LogCleave(d, v, op); err != nil {
-		return
-	}
-
-	// notify syncs after processing because downstream sync might rely on changes
-	evt := datastore.SyncEvent{d.DataUUID(), labels.CleaveLabelEvent}
-	msg := datastore.SyncMessage{labels.CleaveLabelEvent, v, op}
-	if err = datastore.NotifySubscribers(evt, msg); err != nil {
-		err = fmt.Errorf("can't notify subscribers for event %v: %v", evt, err)
+	if err = d.applyCleave(v, op, info); err != nil {
 		return
 	}
 
@@ -254,6 +243,28 @@ func (d *Data) CleaveLabel(v dvid.VersionID, label uint64, info dvid.ModInfo, r
 	return
 }
 
+// applyCleave modifies the label indices and mappings for a cleave, logs it, and
+// then notifies syncs.
+func (d *Data) applyCleave(v dvid.VersionID, op labels.CleaveOp, info dvid.ModInfo) error {
+	if err := CleaveIndex(d, v, op, info); err != nil {
+		return err
+	}
+	if err := addCleaveToMapping(d, v, op); err != nil {
+		return err
+	}
+	if err := labels.LogCleave(d, v, op); err != nil {
+		return err
+	}
+
+	// notify syncs after processing because downstream sync might rely on changes
+	evt := datastore.SyncEvent{d.DataUUID(), labels.CleaveLabelEvent}
+	msg := datastore.SyncMessage{labels.CleaveLabelEvent, v, op}
+	if err := datastore.NotifySubscribers(evt, msg); err != nil {
+		return fmt.Errorf("can't notify subscribers for event %v: %v", evt, err)
+	}
+	return nil
+}
+
 // created while iterating over all split RLEs and computing what the
 // split supervoxels should be and the # voxels split for each supervoxel per block.
 type blockSplitsMap map[uint64]map[uint64]labels.SVSplitCount
@@ -21,9 +21,8 @@ import (
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/datatype/common/proto"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
-
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 func checkSparsevolsCoarse(t *testing.T, encoding []byte) {
@@ -2128,13 +2127,112 @@ func TestMergeCleave(t *testing.T) {
 	server.TestBadHTTP(t, "POST", reqStr, bytes.NewBufferString("[4]"))
 
 	// Check storage stats
-	stats, err := datastore.GetStorageDetails()
+	stats, err := datastore.GetStorageDetails(nil)
 	if err != nil {
 		t.Fatalf("error getting storage details: %v\n", err)
 	}
 	dvid.Infof("storage details: %s\n", stats)
 }
 
+func TestUndoMergeCleave(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+	var config dvid.Config
+	config.Set("BlockSize", "32,32,32")
+	server.CreateTestInstance(t, uuid, "labelmap", "labels", config)
+	expected := createLabelTestVolume(t, uuid, "labels")
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+
+	var mutVal struct {
+		MutationID uint64
+	}
+
+	// Merge 3 and 2 into 4, then undo it.
+	reqStr := fmt.Sprintf("%snode/%s/labels/merge", server.WebAPIPath, uuid)
+	r := server.TestHTTP(t, "POST", reqStr, bytes.NewBufferString("[4, 3, 2]"))
+	if err := json.Unmarshal(r, &mutVal); err != nil {
+		t.Fatalf("unable to get mutation id from merge: %v\n", err)
+	}
+	mergeID := mutVal.MutationID
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/undo/%d", server.WebAPIPath, uuid, mergeID)
+	r = server.TestHTTP(t, "POST", reqStr, nil)
+	if err := json.Unmarshal(r, &mutVal); err != nil {
+		t.Fatalf("unable to get mutation id from undo: %v\n", err)
+	}
+	if mutVal.MutationID <= mergeID {
+		t.Errorf("expected new mutation id for undo, got %d\n", mutVal.MutationID)
+	}
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+
+	retrieved := newTestVolume(128, 128, 128)
+	retrieved.get(t, uuid, "labels", false)
+	if err := retrieved.equals(expected); err != nil {
+		t.Errorf("label volume after undo of merge not equal to original: %v\n", err)
+	}
+	reqStr = fmt.Sprintf("%snode/%s/labels/sparsevol/3", server.WebAPIPath, uuid)
+	body3.checkSparseVol(t, server.TestHTTP(t, "GET", reqStr, nil), dvid.OptionalBounds{})
+	reqStr = fmt.Sprintf("%snode/%s/labels/sparsevol/2", server.WebAPIPath, uuid)
+	body2.checkSparseVol(t, server.TestHTTP(t, "GET", reqStr, nil), dvid.OptionalBounds{})
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/size/4", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "GET", reqStr, nil)
+	var sizeVal struct {
+		Voxels uint64 `json:"voxels"`
+	}
+	if err := json.Unmarshal(r, &sizeVal); err != nil {
+		t.Fatalf("unable to get size for label 4: %v", err)
+	}
+	if sizeVal.Voxels != body4.voxelSpans.Count() {
+		t.Errorf("expected label 4 to have %d voxels after undo, got %d\n", body4.voxelSpans.Count(), sizeVal.Voxels)
+	}
+
+	// The merge can't be undone twice since the undo touched the same bodies.
+	reqStr = fmt.Sprintf("%snode/%s/labels/undo/%d", server.WebAPIPath, uuid, mergeID)
+	server.TestBadHTTP(t, "POST", reqStr, nil)
+
+	// Merge 3 into 4, cleave it back out, and then make sure the merge can't be undone
+	// while the cleave can.
+	reqStr = fmt.Sprintf("%snode/%s/labels/merge", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "POST", reqStr, bytes.NewBufferString("[4, 3]"))
+	if err := json.Unmarshal(r, &mutVal); err != nil {
+		t.Fatalf("unable to get mutation id from merge: %v\n", err)
+	}
+	mergeID = mutVal.MutationID
+	reqStr = fmt.Sprintf("%snode/%s/labels/cleave/4", server.WebAPIPath, uuid)
+	r = server.TestHTTP(t, "POST", reqStr, bytes.NewBufferString("[3]"))
+	if err := json.Unmarshal(r, &mutVal); err != nil {
+		t.Fatalf("unable to get mutation id from cleave: %v\n", err)
+	}
+	cleaveID := mutVal.MutationID
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/undo/%d", server.WebAPIPath, uuid, mergeID)
+	server.TestBadHTTP(t, "POST", reqStr, nil)
+
+	reqStr = fmt.Sprintf("%snode/%s/labels/undo/%d", server.WebAPIPath, uuid, cleaveID)
+	server.TestHTTP(t, "POST", reqStr, nil)
+	if err := datastore.BlockOnUpdating(uuid, "labels"); err != nil {
+		t.Fatalf("Error blocking on sync of labels: %v\n", err)
+	}
+	retrieved.get(t, uuid, "labels", false)
+	expected.addBody(body3, 4)
+	if err := retrieved.equals(expected); err != nil {
+		t.Errorf("label volume after undo of cleave not equal to expected: %v\n", err)
+	}
+
+	// Unknown mutations can't be undone.
+	reqStr = fmt.Sprintf("%snode/%s/labels/undo/%d", server.WebAPIPath, uuid, cleaveID+1000)
+	server.TestBadHTTP(t, "POST", reqStr, nil)
+}
+
 func TestMultiscaleMergeCleave(t *testing.T) {
 	testConfig := server.TestConfig{CacheSize: map[string]int{"labelmap": 10}}
 	// var testConfig server.TestConfig
@@ -18,7 +18,14 @@ import (
 	"github.com/janelia-flyem/dvid/storage"
 )
 
+// readStreamedBlock reads the next gzip-compressed block from a stream of blocks.
 func readStreamedBlock(r io.Reader, scale uint8) (block *labels.Block, compressed []byte, bx, by, bz int32, err error) {
+	return readStreamedBlockFormat(r, scale, dvid.Gzip)
+}
+
+// readStreamedBlockFormat reads the next block from a stream of blocks compressed
+// using either gzip or zstd.
+func readStreamedBlockFormat(r io.Reader, scale uint8, format dvid.CompressionFormat) (block *labels.Block, compressed []byte, bx, by, bz int32, err error) {
 	hdrBytes := make([]byte, 16)
 	var n int
 	n, err = io.ReadFull(r, hdrBytes)
@@ -45,21 +52,32 @@ func readStreamedBlock(r io.Reader, scale uint8) (block *labels.Block, compresse
 		return
 	}
 
-	gzipIn := bytes.NewBuffer(compressed)
-	var zr *gzip.Reader
-	zr, err = gzip.NewReader(gzipIn)
-	if err != nil {
-		err = fmt.Errorf("can't initiate gzip reader on compressed data of length %d: %v", len(compressed), err)
-		return
-	}
 	var uncompressed []byte
-	uncompressed, err = ioutil.ReadAll(zr)
-	if err != nil {
-		err = fmt.Errorf("can't read all %d bytes from gzipped block %s: %v", numBytes, bcoord, err)
-		return
-	}
-	if err = zr.Close(); err != nil {
-		err = fmt.Errorf("error on closing gzip on block read: %v", err)
+	switch format {
+	case dvid.Gzip:
+		gzipIn := bytes.NewBuffer(compressed)
+		var zr *gzip.Reader
+		zr, err = gzip.NewReader(gzipIn)
+		if err != nil {
+			err = fmt.Errorf("can't initiate gzip reader on compressed data of length %d: %v", len(compressed), err)
+			return
+		}
+		uncompressed, err = ioutil.ReadAll(zr)
+		if err != nil {
+			err = fmt.Errorf("can't read all %d bytes from gzipped block %s: %v", numBytes, bcoord, err)
+			return
+		}
+		if err = zr.Close(); err != nil {
+			err = fmt.Errorf("error on closing gzip on block read: %v", err)
+			return
+		}
+	case dvid.Zstd:
+		if uncompressed, err = dvid.ZstdUncompress(compressed); err != nil {
+			err = fmt.Errorf("can't uncompress %d bytes from zstd block %s: %v", numBytes, bcoord, err)
+			return
+		}
+	default:
+		err = fmt.Errorf("streamed blocks can't be compressed using %s", format)
 		return
 	}
 
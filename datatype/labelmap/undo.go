@@ -0,0 +1,329 @@
+/*
+	This file supports undoing merges and cleaves by applying the inverse of a logged mutation.
+*/
+
+package labelmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/datatype/common/labels"
+	"github.com/janelia-flyem/dvid/datatype/common/proto"
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// loggedOp is a deserialized entry from the mutation log of a version.
+type loggedOp struct {
+	mutID uint64
+	op    interface{} // *proto.MergeOp, *proto.CleaveOp, *proto.MappingOp, *proto.SplitOp, or *proto.SupervoxelSplitOp
+}
+
+// touches returns true if the logged op modified any of the given bodies or supervoxels.
+func (lop loggedOp) touches(bodies, supervoxels labels.Set) bool {
+	switch op := lop.op.(type) {
+	case *proto.MergeOp:
+		if bodies.Exists(op.Target) {
+			return true
+		}
+		for _, label := range op.Merged {
+			if bodies.Exists(label) {
+				return true
+			}
+		}
+	case *proto.CleaveOp:
+		if bodies.Exists(op.Target) || bodies.Exists(op.Cleavedlabel) {
+			return true
+		}
+		for _, supervoxel := range op.Cleaved {
+			if supervoxels.Exists(supervoxel) {
+				return true
+			}
+		}
+	case *proto.MappingOp:
+		if op.Mapped != 0 && bodies.Exists(op.Mapped) {
+			return true
+		}
+		for _, supervoxel := range op.Original {
+			if supervoxels.Exists(supervoxel) {
+				return true
+			}
+		}
+	case *proto.SplitOp:
+		if bodies.Exists(op.Target) || bodies.Exists(op.Newlabel) {
+			return true
+		}
+		for supervoxel := range op.Svsplits {
+			if supervoxels.Exists(supervoxel) {
+				return true
+			}
+		}
+	case *proto.SupervoxelSplitOp:
+		if supervoxels.Exists(op.Supervoxel) {
+			return true
+		}
+	}
+	return false
+}
+
+// readMutationLog returns the label ops in the mutation log for the given version.
+// Merges logged without a mutation ID are assigned the mutation ID of their
+// immediately preceding mapping op.
+func (d *Data) readMutationLog(v dvid.VersionID) ([]loggedOp, error) {
+	rl := d.GetReadLog()
+	if rl == nil {
+		return nil, fmt.Errorf("no mutation log was available for data %q", d.DataName())
+	}
+	uuid, err := datastore.UUIDFromVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := rl.ReadAll(d.DataUUID(), uuid)
+	if err != nil {
+		return nil, err
+	}
+	lops := make([]loggedOp, 0, len(msgs))
+	var lastMappingMutID uint64
+	for _, msg := range msgs {
+		var lop loggedOp
+		switch msg.EntryType {
+		case proto.MergeOpType:
+			op := new(proto.MergeOp)
+			if err := op.Unmarshal(msg.Data); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal merge log message: %v", err)
+			}
+			if op.Mutid == 0 {
+				op.Mutid = lastMappingMutID
+			}
+			lop = loggedOp{op.Mutid, op}
+		case proto.CleaveOpType:
+			op := new(proto.CleaveOp)
+			if err := op.Unmarshal(msg.Data); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal cleave log message: %v", err)
+			}
+			lop = loggedOp{op.Mutid, op}
+		case proto.MappingOpType:
+			op := new(proto.MappingOp)
+			if err := op.Unmarshal(msg.Data); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal mapping log message: %v", err)
+			}
+			lop = loggedOp{op.Mutid, op}
+		case proto.SplitOpType:
+			op := new(proto.SplitOp)
+			if err := op.Unmarshal(msg.Data); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal split log message: %v", err)
+			}
+			lop = loggedOp{op.Mutid, op}
+		case proto.SupervoxelSplitType:
+			op := new(proto.SupervoxelSplitOp)
+			if err := op.Unmarshal(msg.Data); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal supervoxel split log message: %v", err)
+			}
+			lop = loggedOp{op.Mutid, op}
+		default:
+			continue
+		}
+		if _, isMapping := lop.op.(*proto.MappingOp); isMapping {
+			lastMappingMutID = lop.mutID
+		} else {
+			lastMappingMutID = 0
+		}
+		lops = append(lops, lop)
+	}
+	return lops, nil
+}
+
+// priorLabels returns the label each supervoxel was mapped to just before the logged op
+// at index pos of the given version's log.
+func (d *Data) priorLabels(v dvid.VersionID, lops []loggedOp, pos int, supervoxels labels.Set) (map[uint64]uint64, error) {
+	prior := make(map[uint64]uint64, len(supervoxels))
+	for _, lop := range lops[:pos] {
+		op, isMapping := lop.op.(*proto.MappingOp)
+		if !isMapping {
+			continue
+		}
+		for _, supervoxel := range op.Original {
+			if supervoxels.Exists(supervoxel) {
+				prior[supervoxel] = op.Mapped
+			}
+		}
+	}
+	if len(prior) == len(supervoxels) {
+		return prior, nil
+	}
+	ancestors, err := datastore.GetAncestry(v)
+	if err != nil {
+		return nil, err
+	}
+	svmap, err := getMapping(d, v)
+	if err != nil {
+		return nil, err
+	}
+	for supervoxel := range supervoxels {
+		if _, found := prior[supervoxel]; found {
+			continue
+		}
+		prior[supervoxel] = supervoxel
+		if len(ancestors) > 1 {
+			if mapped, found := svmap.MappedLabel(ancestors[1], supervoxel); found {
+				prior[supervoxel] = mapped
+			}
+		}
+	}
+	return prior, nil
+}
+
+// UndoMutation applies the inverse of a merge or cleave recorded in the mutation log of
+// the given version, restoring the prior mappings and label indices and notifying syncs
+// through the usual cleave and merge events.  An undone merge cleaves each merged body's
+// supervoxels back into its original label, and an undone cleave merges the cleaved
+// label back into its target.  The undo is refused if any later mutation in the log
+// touched the same bodies or supervoxels.  Returns the mutation ID of the inverse op.
+func (d *Data) UndoMutation(v dvid.VersionID, mutID uint64, info dvid.ModInfo) (undoID uint64, err error) {
+	d.StartUpdate()
+	defer d.StopUpdate()
+
+	lops, err := d.readMutationLog(v)
+	if err != nil {
+		return
+	}
+	first, last := -1, -1
+	var merges []*proto.MergeOp
+	var cleaves []*proto.CleaveOp
+	var mappings []*proto.MappingOp
+	for i, lop := range lops {
+		if lop.mutID != mutID {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		switch op := lop.op.(type) {
+		case *proto.MergeOp:
+			merges = append(merges, op)
+		case *proto.CleaveOp:
+			cleaves = append(cleaves, op)
+		case *proto.MappingOp:
+			mappings = append(mappings, op)
+		default:
+			err = fmt.Errorf("mutation %d is a split, and only merges and cleaves can be undone", mutID)
+			return
+		}
+	}
+	if first < 0 || (len(merges) == 0 && len(cleaves) == 0) {
+		err = fmt.Errorf("no merge or cleave with mutation id %d in the mutation log of data %q for this version", mutID, d.DataName())
+		return
+	}
+	if len(merges) != 0 && len(cleaves) != 0 {
+		err = fmt.Errorf("mutation %d has both merges and cleaves and cannot be undone", mutID)
+		return
+	}
+
+	// Determine the bodies and supervoxels affected by the mutation and the inverse ops.
+	bodies := make(labels.Set)
+	supervoxels := make(labels.Set)
+	var inverseCleaves []labels.CleaveOp
+	inverseMerges := make(map[uint64]labels.Set) // target -> cleaved labels to merge back
+	for _, op := range merges {
+		bodies[op.Target] = struct{}{}
+		merged := make(labels.Set)
+		for _, mapping := range mappings {
+			if mapping.Mapped != op.Target {
+				continue
+			}
+			for _, supervoxel := range mapping.Original {
+				merged[supervoxel] = struct{}{}
+				supervoxels[supervoxel] = struct{}{}
+			}
+		}
+		var prior map[uint64]uint64
+		if prior, err = d.priorLabels(v, lops, first, merged); err != nil {
+			return
+		}
+		bodySupervoxels := make(map[uint64][]uint64)
+		for supervoxel, label := range prior {
+			if label != op.Target && label != 0 {
+				bodySupervoxels[label] = append(bodySupervoxels[label], supervoxel)
+			}
+		}
+		for label, svs := range bodySupervoxels {
+			bodies[label] = struct{}{}
+			inverseCleaves = append(inverseCleaves, labels.CleaveOp{
+				Target:             op.Target,
+				CleavedLabel:       label,
+				CleavedSupervoxels: svs,
+			})
+		}
+	}
+	for _, op := range cleaves {
+		bodies[op.Target] = struct{}{}
+		bodies[op.Cleavedlabel] = struct{}{}
+		for _, supervoxel := range op.Cleaved {
+			supervoxels[supervoxel] = struct{}{}
+		}
+		if _, found := inverseMerges[op.Target]; !found {
+			inverseMerges[op.Target] = make(labels.Set)
+		}
+		inverseMerges[op.Target][op.Cleavedlabel] = struct{}{}
+	}
+
+	for _, lop := range lops[last+1:] {
+		if lop.touches(bodies, supervoxels) {
+			err = fmt.Errorf("cannot undo mutation %d because later mutation %d modified the same bodies", mutID, lop.mutID)
+			return
+		}
+	}
+	for _, op := range inverseCleaves {
+		var idx *labels.Index
+		if idx, err = GetLabelIndex(d, v, op.CleavedLabel, false); err != nil {
+			return
+		}
+		if idx != nil {
+			err = fmt.Errorf("cannot undo mutation %d because label %d is now in use", mutID, op.CleavedLabel)
+			return
+		}
+	}
+
+	undoID = d.NewMutationID()
+	versionuuid, _ := datastore.UUIDFromVersion(v)
+	msginfo := map[string]interface{}{
+		"Action":           "undo",
+		"UndoneMutationID": mutID,
+		"MutationID":       undoID,
+		"UUID":             string(versionuuid),
+		"Timestamp":        time.Now().String(),
+	}
+	jsonmsg, _ := json.Marshal(msginfo)
+	if err := d.ProduceKafkaMsg(jsonmsg); err != nil {
+		dvid.Errorf("can't send undo op for %q to kafka: %v\n", d.DataName(), err)
+	}
+
+	for _, op := range inverseCleaves {
+		op.MutID = undoID
+		if err = d.applyCleave(v, op, info); err != nil {
+			return
+		}
+	}
+	for target, merged := range inverseMerges {
+		op := labels.MergeOp{MutID: undoID, Target: target, Merged: merged}
+		if _, err = d.MergeLabels(v, op, info); err != nil {
+			return
+		}
+	}
+	dvid.Infof("Undid mutation %d on data %q with mutation %d\n", mutID, d.DataName(), undoID)
+
+	msginfo = map[string]interface{}{
+		"Action":     "undo-complete",
+		"MutationID": undoID,
+		"UUID":       string(versionuuid),
+		"Timestamp":  time.Now().String(),
+	}
+	jsonmsg, _ = json.Marshal(msginfo)
+	if err := d.ProduceKafkaMsg(jsonmsg); err != nil {
+		dvid.Errorf("error on sending undo complete op to kafka: %v\n", err)
+	}
+	return
+}
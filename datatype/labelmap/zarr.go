@@ -0,0 +1,122 @@
+/*
+	This file supports export and import of labels to and from zarr and N5 arrays.
+*/
+
+package labelmap
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/janelia-flyem/dvid/datatype/common/zarr"
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+// ExportArray writes the labels of the subvolume of the given size starting at a block-aligned
+// offset into a new uint64 zarr or N5 array at path, using the block size of the data as the
+// chunk size.  If supervoxels is true, unmapped supervoxel ids are exported.  The export
+// stops if the optional job is canceled.  Returns the number of chunks written.
+func (d *Data) ExportArray(v dvid.VersionID, path string, format zarr.Format, offset, size dvid.Point3d, compression string, supervoxels bool, job *dvid.Job) (int, error) {
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return 0, fmt.Errorf("data %q must have 3d block size, not %s", d.DataName(), d.BlockSize())
+	}
+	if err := zarr.CheckAlignment(offset, blockSize, blockSize); err != nil {
+		return 0, err
+	}
+	arr, err := zarr.Create(path, format, size, blockSize, dvid.T_uint64, compression)
+	if err != nil {
+		return 0, err
+	}
+	timedLog := dvid.NewTimeLog()
+	n := arr.NumChunks()
+	totalChunks := uint64(n[0]) * uint64(n[1]) * uint64(n[2])
+	var readChunks uint64
+	numChunks, err := zarr.Export(arr, offset, func(offset, size dvid.Point3d) ([]byte, error) {
+		if job.Canceled() {
+			return nil, dvid.ErrJobCanceled
+		}
+		job.SetProgress(readChunks, totalChunks)
+		readChunks++
+		lbl, err := d.NewLabels(dvid.NewSubvolume(offset, size), nil)
+		if err != nil {
+			return nil, err
+		}
+		return d.GetVolume(v, lbl, supervoxels, 0, "")
+	})
+	if err != nil {
+		return numChunks, err
+	}
+	timedLog.Infof("Exported %d chunks of labelmap %q to %s array %s", numChunks, d.DataName(), format, path)
+	return numChunks, nil
+}
+
+// widenLabels converts unsigned integer labels of the given type to uint64 labels.
+func widenLabels(data []byte, t dvid.DataType) ([]byte, error) {
+	switch t {
+	case dvid.T_uint64:
+		return data, nil
+	case dvid.T_uint8, dvid.T_uint16, dvid.T_uint32:
+	default:
+		return nil, fmt.Errorf("labels can only be imported from unsigned integer arrays")
+	}
+	valueBytes := int(dvid.DataTypeBytes(t))
+	numVoxels := len(data) / valueBytes
+	labels := make([]byte, numVoxels*8)
+	for i := 0; i < numVoxels; i++ {
+		var label uint64
+		switch t {
+		case dvid.T_uint8:
+			label = uint64(data[i])
+		case dvid.T_uint16:
+			label = uint64(binary.LittleEndian.Uint16(data[i*2 : i*2+2]))
+		case dvid.T_uint32:
+			label = uint64(binary.LittleEndian.Uint32(data[i*4 : i*4+4]))
+		}
+		binary.LittleEndian.PutUint64(labels[i*8:i*8+8], label)
+	}
+	return labels, nil
+}
+
+// ImportArray writes the labels in the zarr or N5 array at path into the data starting at a
+// block-aligned offset.  The array can hold any unsigned integer type and must have a chunk
+// size that is a multiple of the block size.  Voxels in edge chunks that lie beyond the array
+// shape are stored as label 0.  The import stops if the optional job is canceled, leaving
+// chunks already written in place.  Returns the number of chunks written.
+func (d *Data) ImportArray(v dvid.VersionID, path string, offset dvid.Point3d, mutate bool, job *dvid.Job) (int, error) {
+	blockSize, ok := d.BlockSize().(dvid.Point3d)
+	if !ok {
+		return 0, fmt.Errorf("data %q must have 3d block size, not %s", d.DataName(), d.BlockSize())
+	}
+	arr, err := zarr.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := widenLabels(nil, arr.DataType); err != nil {
+		return 0, fmt.Errorf("can't import %s array %s into labelmap %q: %v", arr.Format, path, d.DataName(), err)
+	}
+	if err := zarr.CheckAlignment(offset, arr.ChunkSize, blockSize); err != nil {
+		return 0, err
+	}
+	timedLog := dvid.NewTimeLog()
+	n := arr.NumChunks()
+	totalChunks := uint64(n[0]) * uint64(n[1]) * uint64(n[2])
+	var writtenChunks uint64
+	numChunks, err := zarr.Import(arr, offset, func(offset, size dvid.Point3d, data []byte) error {
+		if job.Canceled() {
+			return dvid.ErrJobCanceled
+		}
+		job.SetProgress(writtenChunks, totalChunks)
+		writtenChunks++
+		labels, err := widenLabels(data, arr.DataType)
+		if err != nil {
+			return err
+		}
+		return d.PutLabels(v, dvid.NewSubvolume(offset, size), labels, "", mutate)
+	})
+	if err != nil {
+		return numChunks, err
+	}
+	timedLog.Infof("Imported %d chunks from %s array %s into labelmap %q", numChunks, arr.Format, path, d.DataName())
+	return numChunks, nil
+}
@@ -211,7 +211,10 @@ POST <api URL>/node/<UUID>/<data name>/reload
 
 	Forces asynchornous denormalization from its synced annotations instance.  Can be 
 	used to initialize a newly added instance.  Note that the labelsz will be locked until
-	the denormalization is finished with a log message.
+	the denormalization is finished with a log message.  Returns the id of the reload job,
+	e.g., {"job": 12}, whose progress can be checked using /api/jobs/{id}.  The reload
+	starts by deleting the existing denormalizations, so it can't be canceled once started
+	since stopping would leave the labelsz without an index.
 `
 
 var (
@@ -832,7 +835,10 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			server.BadRequest(w, r, "Only POST action is available on 'reload' endpoint.")
 			return
 		}
-		d.ReloadData(ctx)
+		job := dvid.NewJob("labelsz reload", ctx.AuthUser, uuid, d.DataName())
+		d.ReloadData(ctx, job)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"job": %d}`, job.ID())
 
 	default:
 		server.BadAPIRequest(w, r, d)
@@ -840,25 +846,29 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 	return
 }
 
-func (d *Data) ReloadData(ctx *datastore.VersionedCtx) {
-	go d.resync(ctx)
+// ReloadData asynchronously recalculates the labelsz from its synced annotations, finishing
+// the given job when done.  The job can't be canceled once the recalculation starts.
+func (d *Data) ReloadData(ctx *datastore.VersionedCtx, job *dvid.Job) {
+	go func() {
+		job.Finish(d.resync(ctx, job))
+	}()
 	dvid.Infof("Started recalculation of labelsz %q...\n", d.DataName())
 }
 
 // Get all labeled annotations from synced annotation instance and repopulate the labelsz.
-func (d *Data) resync(ctx *datastore.VersionedCtx) {
+// Since the denormalizations are first deleted, the optional job can't be canceled once
+// started.
+func (d *Data) resync(ctx *datastore.VersionedCtx, job *dvid.Job) error {
 	timedLog := dvid.NewTimeLog()
 
 	annot := d.GetSyncedAnnotation()
 	if annot == nil {
-		dvid.Errorf("Unable to get synced annotation.  Aborting reload of labelsz %q.\n", d.DataName())
-		return
+		return fmt.Errorf("unable to get synced annotation.  Aborting reload of labelsz %q", d.DataName())
 	}
 
 	store, err := datastore.GetOrderedKeyValueDB(d)
 	if err != nil {
-		dvid.Errorf("Labelsz %q had error initializing store: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("labelsz %q had error initializing store: %v", d.DataName(), err)
 	}
 
 	d.StartUpdate()
@@ -866,24 +876,25 @@ func (d *Data) resync(ctx *datastore.VersionedCtx) {
 	// d.Lock()
 	// defer d.Unlock()
 
+	if !job.DisableCancel() {
+		return dvid.ErrJobCanceled
+	}
 	minTSLTKey := storage.MinTKey(keyTypeSizeLabel)
 	maxTSLTKey := storage.MaxTKey(keyTypeSizeLabel)
 	if err := store.DeleteRange(ctx, minTSLTKey, maxTSLTKey); err != nil {
-		dvid.Errorf("Unable to delete type-size-label denormalization for labelsz %q: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("unable to delete type-size-label denormalization for labelsz %q: %v", d.DataName(), err)
 	}
 
 	minTypeTKey := storage.MinTKey(keyTypeLabel)
 	maxTypeTKey := storage.MaxTKey(keyTypeLabel)
 	if err := store.DeleteRange(ctx, minTypeTKey, maxTypeTKey); err != nil {
-		dvid.Errorf("Unable to delete type-label denormalization for labelsz %q: %v\n", d.DataName(), err)
-		return
+		return fmt.Errorf("unable to delete type-label denormalization for labelsz %q: %v", d.DataName(), err)
 	}
 
 	buf := make([]byte, 4)
 	var indexMap [AllSyn]uint32
 	var totLabels uint64
-	err = annot.ProcessLabelAnnotations(ctx.VersionID(), func(label uint64, elems annotation.ElementsNR) {
+	err = annot.ProcessLabelAnnotations(ctx.VersionID(), func(label uint64, elems annotation.ElementsNR) error {
 		totLabels++
 		for i := IndexType(0); i < AllSyn; i++ {
 			indexMap[i] = 0
@@ -905,10 +916,12 @@ func (d *Data) resync(ctx *datastore.VersionedCtx) {
 		binary.LittleEndian.PutUint32(buf, allsyn)
 		store.Put(ctx, NewTypeLabelTKey(AllSyn, label), buf)
 		store.Put(ctx, NewTypeSizeLabelTKey(AllSyn, allsyn, label), nil)
+		return nil
 	})
 	if err != nil {
-		dvid.Errorf("Error in reload of labelsz %q: %v\n", d.DataName(), err)
+		return fmt.Errorf("error in reload of labelsz %q: %v", d.DataName(), err)
 	}
 
 	timedLog.Infof("Completed labelsz %q reload of %d labels from annotation %q", d.DataName(), totLabels, annot.DataName())
+	return nil
 }
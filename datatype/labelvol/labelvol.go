@@ -25,12 +25,11 @@ import (
 	"github.com/janelia-flyem/dvid/datatype/common/labels"
 	"github.com/janelia-flyem/dvid/datatype/labelblk"
 	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 	"github.com/janelia-flyem/dvid/server"
 	"github.com/janelia-flyem/dvid/storage"
 
 	"github.com/janelia-flyem/go/go-humanize"
-
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
 )
 
 const (
@@ -588,6 +587,9 @@ func (d *Data) RemapVersions(vmap dvid.VersionMap) error {
 			dvid.Infof("No version %d in labelvol %q... discarding max label", oldv, d.DataName())
 			continue
 		}
+		if label < maxLabels[newv] {
+			continue // several versions can be squashed into one.
+		}
 		maxLabels[newv] = label
 	}
 	d.Properties.MaxLabel = maxLabels
@@ -420,7 +420,7 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 				Voxels:     v,
 				channelNum: channelNum,
 			}
-			img, err := d.GetImage(ctx.VersionID(), channel.Voxels, "")
+			img, err := d.GetImage(ctx.VersionID(), channel.Voxels, 0, "")
 			var formatStr string
 			if len(parts) >= 7 {
 				formatStr = parts[6]
@@ -0,0 +1,288 @@
+/*
+	This file supports combining ROIs using boolean expressions over roi instances.
+*/
+
+package roi
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/server"
+)
+
+// roiOperand is a reference to an roi instance, optionally at a particular version.
+type roiOperand struct {
+	name dvid.InstanceName
+	uuid string // partial UUID or empty for the version of the request.
+}
+
+// roiExpr is a node in the parse tree of a combine expression.  Leaf nodes have
+// a non-nil operand.
+type roiExpr struct {
+	op          byte // '|', '&', or '-' for interior nodes
+	left, right *roiExpr
+	operand     *roiOperand
+}
+
+// tokenizeExpr splits a combine expression into parentheses, the operators "|", "&",
+// and "-", and roi references.  Roi names can contain any character except whitespace,
+// parentheses, "|", "&", and double quotes, so the difference operator must be separated
+// from names by whitespace.  Names with other characters can be double-quoted.
+func tokenizeExpr(expr string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == '|' || c == '&':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in roi expression %q", expr)
+			}
+			j++
+			for j < len(runes) && !isExprDelimiter(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !isExprDelimiter(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+func isExprDelimiter(c rune) bool {
+	return unicode.IsSpace(c) || c == '(' || c == ')' || c == '|' || c == '&' || c == '"'
+}
+
+// exprParser is a recursive descent parser for combine expressions with the grammar:
+//
+//	expr    := term { ("|" | "-") term }
+//	term    := factor { "&" factor }
+//	factor  := "(" expr ")" | operand
+//	operand := name [ "@" uuid ]
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *exprParser) parseExpr() (*roiExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok != "|" && tok != "-" {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &roiExpr{op: tok[0], left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseTerm() (*roiExpr, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&" {
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &roiExpr{op: '&', left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseFactor() (*roiExpr, error) {
+	tok := p.next()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of roi expression")
+	case "(":
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in roi expression")
+		}
+		return e, nil
+	case ")", "|", "&", "-":
+		return nil, fmt.Errorf("unexpected %q in roi expression", tok)
+	}
+	operand, err := parseOperand(tok)
+	if err != nil {
+		return nil, err
+	}
+	return &roiExpr{operand: operand}, nil
+}
+
+func parseOperand(tok string) (*roiOperand, error) {
+	name, version := tok, ""
+	if strings.HasPrefix(tok, `"`) {
+		end := strings.Index(tok[1:], `"`) + 1
+		name, version = tok[1:end], tok[end+1:]
+	} else if i := strings.LastIndex(tok, "@"); i >= 0 {
+		name, version = tok[:i], tok[i:]
+	}
+	if name == "" {
+		return nil, fmt.Errorf("empty roi name in reference %s", tok)
+	}
+	operand := &roiOperand{name: dvid.InstanceName(name)}
+	if version != "" {
+		if !strings.HasPrefix(version, "@") || len(version) == 1 {
+			return nil, fmt.Errorf("bad version in roi reference %s", tok)
+		}
+		operand.uuid = version[1:]
+	}
+	return operand, nil
+}
+
+// parseROIExpr parses a combine expression like "(medulla | lobula) - bad_slab".
+func parseROIExpr(expr string) (*roiExpr, error) {
+	tokens, err := tokenizeExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(tokens) {
+		return nil, fmt.Errorf("unexpected %q in roi expression", tokens[p.pos])
+	}
+	return e, nil
+}
+
+// eval computes the ROI for the expression, reading operands at the version of the
+// context unless an operand specifies its own version.  Operands must have the same
+// block size as the receiver.
+func (d *Data) eval(ctx *datastore.VersionedCtx, e *roiExpr) (blockRuns, error) {
+	if e.operand != nil {
+		return d.operandRuns(ctx, e.operand)
+	}
+	left, err := d.eval(ctx, e.left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := d.eval(ctx, e.right)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case '|':
+		return left.union(right), nil
+	case '&':
+		return left.intersect(right), nil
+	case '-':
+		return left.subtract(right), nil
+	default:
+		return nil, fmt.Errorf("unknown roi operator %q", e.op)
+	}
+}
+
+// operandRuns reads an operand's roi, which must be in the repo of the request and
+// readable by the request's user.
+func (d *Data) operandRuns(ctx *datastore.VersionedCtx, operand *roiOperand) (blockRuns, error) {
+	v := ctx.VersionID()
+	if operand.uuid != "" {
+		var err error
+		if _, v, err = datastore.MatchingUUID(operand.uuid); err != nil {
+			return nil, fmt.Errorf("bad version for roi %q: %v", operand.name, err)
+		}
+		root, err := datastore.GetRepoRootVersion(ctx.VersionID())
+		if err != nil {
+			return nil, err
+		}
+		operandRoot, err := datastore.GetRepoRootVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		if operandRoot != root {
+			return nil, fmt.Errorf("version %q of roi %q is not in the repo of the request", operand.uuid, operand.name)
+		}
+	}
+	uuid, err := datastore.UUIDFromVersion(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.CheckRole(ctx.AuthUser, uuid, operand.name, "read"); err != nil {
+		return nil, err
+	}
+	dataservice, err := datastore.GetDataByVersionName(v, operand.name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get roi %q: %v", operand.name, err)
+	}
+	src, ok := dataservice.(*Data)
+	if !ok {
+		return nil, fmt.Errorf("data %q is not an roi instance", operand.name)
+	}
+	if !src.BlockSize.Equals(d.BlockSize) {
+		return nil, fmt.Errorf("roi %q has block size %s, which differs from roi %q block size %s",
+			operand.name, src.BlockSize, d.DataName(), d.BlockSize)
+	}
+	src.RLock()
+	spans, err := src.GetSpans(v)
+	src.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return newBlockRuns(spans), nil
+}
+
+// Combine returns the spans of the ROI given by a boolean expression over roi instances
+// at the version of the context.  Expressions use "|" for union, "&" for intersection,
+// and "-" for difference, with "&" binding more tightly than "|" and "-", which are
+// evaluated left to right.  An operand of the form "name@uuid" reads the roi at the
+// given version, which must be in the same repo.
+func (d *Data) Combine(ctx *datastore.VersionedCtx, expr string) ([]dvid.Span, error) {
+	e, err := parseROIExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	br, err := d.eval(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	return br.spans(), nil
+}
@@ -0,0 +1,346 @@
+/*
+	This file supports block-level morphology and set operations on ROI spans.
+*/
+
+package roi
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/server"
+)
+
+// MaxElementSize is the largest structuring element size, in blocks, accepted for
+// morphological operations.  Cost grows linearly with the size.
+const MaxElementSize = 64
+
+// rowKey identifies a row of blocks along x.
+type rowKey struct {
+	z, y int32
+}
+
+// xRun is an inclusive run of blocks along x.
+type xRun struct {
+	x0, x1 int32
+}
+
+type xRuns []xRun
+
+func (runs xRuns) Len() int           { return len(runs) }
+func (runs xRuns) Less(i, j int) bool { return runs[i].x0 < runs[j].x0 }
+func (runs xRuns) Swap(i, j int)      { runs[i], runs[j] = runs[j], runs[i] }
+
+// normalize sorts the runs and coalesces any that overlap or abut.
+func (runs xRuns) normalize() xRuns {
+	if len(runs) == 0 {
+		return nil
+	}
+	sort.Sort(runs)
+	out := xRuns{runs[0]}
+	for _, run := range runs[1:] {
+		last := &out[len(out)-1]
+		if run.x0 <= last.x1+1 {
+			if run.x1 > last.x1 {
+				last.x1 = run.x1
+			}
+		} else {
+			out = append(out, run)
+		}
+	}
+	return out
+}
+
+// intersect returns the runs in both normalized receiver and normalized runs2.
+func (runs xRuns) intersect(runs2 xRuns) xRuns {
+	var out xRuns
+	i, j := 0, 0
+	for i < len(runs) && j < len(runs2) {
+		x0, x1 := runs[i].x0, runs[i].x1
+		if runs2[j].x0 > x0 {
+			x0 = runs2[j].x0
+		}
+		if runs2[j].x1 < x1 {
+			x1 = runs2[j].x1
+		}
+		if x0 <= x1 {
+			out = append(out, xRun{x0, x1})
+		}
+		if runs[i].x1 < runs2[j].x1 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
+
+// subtract returns the runs in normalized receiver that aren't in normalized runs2.
+func (runs xRuns) subtract(runs2 xRuns) xRuns {
+	var out xRuns
+	j := 0
+	for _, run := range runs {
+		x0 := run.x0
+		for j < len(runs2) && runs2[j].x1 < x0 {
+			j++
+		}
+		for k := j; k < len(runs2) && runs2[k].x0 <= run.x1; k++ {
+			if runs2[k].x0 > x0 {
+				out = append(out, xRun{x0, runs2[k].x0 - 1})
+			}
+			x0 = runs2[k].x1 + 1
+		}
+		if x0 <= run.x1 {
+			out = append(out, xRun{x0, run.x1})
+		}
+	}
+	return out
+}
+
+// blockRuns holds an ROI as normalized runs of blocks for each row.
+type blockRuns map[rowKey]xRuns
+
+func newBlockRuns(spans []dvid.Span) blockRuns {
+	br := make(blockRuns)
+	for _, span := range spans {
+		row := rowKey{span[0], span[1]}
+		br[row] = append(br[row], xRun{span[2], span[3]})
+	}
+	for row, runs := range br {
+		br[row] = runs.normalize()
+	}
+	return br
+}
+
+type rowKeys []rowKey
+
+func (rows rowKeys) Len() int { return len(rows) }
+func (rows rowKeys) Less(i, j int) bool {
+	if rows[i].z != rows[j].z {
+		return rows[i].z < rows[j].z
+	}
+	return rows[i].y < rows[j].y
+}
+func (rows rowKeys) Swap(i, j int) { rows[i], rows[j] = rows[j], rows[i] }
+
+// spans returns the ROI as spans sorted by z, then y, then x0.
+func (br blockRuns) spans() []dvid.Span {
+	rows := make(rowKeys, 0, len(br))
+	for row := range br {
+		rows = append(rows, row)
+	}
+	sort.Sort(rows)
+	spans := []dvid.Span{}
+	for _, row := range rows {
+		for _, run := range br[row] {
+			spans = append(spans, dvid.Span{row.z, row.y, run.x0, run.x1})
+		}
+	}
+	return spans
+}
+
+func (br blockRuns) union(br2 blockRuns) blockRuns {
+	out := make(blockRuns, len(br))
+	for row, runs := range br {
+		out[row] = append(xRuns{}, runs...)
+	}
+	for row, runs := range br2 {
+		out[row] = append(out[row], runs...).normalize()
+	}
+	return out
+}
+
+func (br blockRuns) intersect(br2 blockRuns) blockRuns {
+	out := make(blockRuns)
+	for row, runs := range br {
+		if runs2, found := br2[row]; found {
+			if result := runs.intersect(runs2); len(result) != 0 {
+				out[row] = result
+			}
+		}
+	}
+	return out
+}
+
+func (br blockRuns) subtract(br2 blockRuns) blockRuns {
+	out := make(blockRuns)
+	for row, runs := range br {
+		result := runs
+		if runs2, found := br2[row]; found {
+			result = runs.subtract(runs2)
+		}
+		if len(result) != 0 {
+			out[row] = result
+		}
+	}
+	return out
+}
+
+// shift returns the row offset by d rows along y, or along z if alongZ is true.
+func (row rowKey) shift(d int32, alongZ bool) rowKey {
+	if alongZ {
+		return rowKey{row.z + d, row.y}
+	}
+	return rowKey{row.z, row.y + d}
+}
+
+// growX returns the ROI with each run extended by size blocks at both ends.
+func (br blockRuns) growX(size int32) blockRuns {
+	out := make(blockRuns, len(br))
+	for row, runs := range br {
+		grown := make(xRuns, len(runs))
+		for i, run := range runs {
+			grown[i] = xRun{run.x0 - size, run.x1 + size}
+		}
+		out[row] = grown.normalize()
+	}
+	return out
+}
+
+// shrinkX returns the ROI with each run reduced by size blocks at both ends.
+func (br blockRuns) shrinkX(size int32) blockRuns {
+	out := make(blockRuns, len(br))
+	for row, runs := range br {
+		var shrunk xRuns
+		for _, run := range runs {
+			if run.x1-run.x0 >= 2*size {
+				shrunk = append(shrunk, xRun{run.x0 + size, run.x1 - size})
+			}
+		}
+		if len(shrunk) != 0 {
+			out[row] = shrunk
+		}
+	}
+	return out
+}
+
+// dilateRows returns the union of the ROI shifted by up to size rows along y, or
+// along z if alongZ is true.
+func (br blockRuns) dilateRows(size int32, alongZ bool) blockRuns {
+	out := make(blockRuns)
+	for row, runs := range br {
+		for d := -size; d <= size; d++ {
+			nbr := row.shift(d, alongZ)
+			out[nbr] = append(out[nbr], runs...)
+		}
+	}
+	for row, runs := range out {
+		out[row] = runs.normalize()
+	}
+	return out
+}
+
+// erodeRows returns the intersection of the ROI shifted by up to size rows along y,
+// or along z if alongZ is true.
+func (br blockRuns) erodeRows(size int32, alongZ bool) blockRuns {
+	out := make(blockRuns)
+	for row, runs := range br {
+		result := runs
+		for d := -size; d <= size && len(result) != 0; d++ {
+			if d == 0 {
+				continue
+			}
+			nbrRuns, found := br[row.shift(d, alongZ)]
+			if !found {
+				result = nil
+				break
+			}
+			result = result.intersect(nbrRuns)
+		}
+		if len(result) != 0 {
+			out[row] = result
+		}
+	}
+	return out
+}
+
+// dilate returns the ROI dilated by a cubic structuring element that extends
+// the given number of blocks in each direction.  Since the element is separable,
+// the ROI is dilated along x, then y, then z.
+func (br blockRuns) dilate(size int32) blockRuns {
+	return br.growX(size).dilateRows(size, false).dilateRows(size, true)
+}
+
+// erode returns the ROI eroded by a cubic structuring element that extends
+// the given number of blocks in each direction.  Since the element is separable,
+// the ROI is eroded along x, then y, then z.
+func (br blockRuns) erode(size int32) blockRuns {
+	return br.shrinkX(size).erodeRows(size, false).erodeRows(size, true)
+}
+
+// Morphology returns the spans of the ROI after applying the named block-level
+// morphological operation ("erode", "dilate", "open", or "close") with a cubic
+// structuring element extending size blocks in each direction.
+func (d *Data) Morphology(ctx *datastore.VersionedCtx, op string, size int32) ([]dvid.Span, error) {
+	if size <= 0 || size > MaxElementSize {
+		return nil, fmt.Errorf("structuring element size must be between 1 and %d blocks, got %d", MaxElementSize, size)
+	}
+	d.RLock()
+	spans, err := GetSpans(ctx)
+	d.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	br := newBlockRuns(spans)
+	switch op {
+	case "erode":
+		br = br.erode(size)
+	case "dilate":
+		br = br.dilate(size)
+	case "open":
+		br = br.erode(size).dilate(size)
+	case "close":
+		br = br.dilate(size).erode(size)
+	default:
+		return nil, fmt.Errorf("unknown ROI morphology operation %q", op)
+	}
+	return br.spans(), nil
+}
+
+// StoreSpans writes the spans into the named ROI instance at the version of the
+// context, replacing any previous spans.  If the instance does not exist, a new ROI
+// instance is created with the same block size and versioning as the receiver.  The
+// request's user must be able to write the target instance, or to create instances
+// in the repo if the target is new.
+func (d *Data) StoreSpans(ctx *datastore.VersionedCtx, name dvid.InstanceName, spans []dvid.Span) error {
+	v := ctx.VersionID()
+	uuid, err := datastore.UUIDFromVersion(v)
+	if err != nil {
+		return err
+	}
+	var target *Data
+	dataservice, err := datastore.GetDataByVersionName(v, name)
+	if err != nil && err != datastore.ErrInvalidDataName {
+		return err
+	}
+	if err == nil {
+		if err := server.CheckRole(ctx.AuthUser, uuid, name, "write"); err != nil {
+			return err
+		}
+		var ok bool
+		if target, ok = dataservice.(*Data); !ok {
+			return fmt.Errorf("data %q is not an roi instance", name)
+		}
+		if !target.BlockSize.Equals(d.BlockSize) {
+			return fmt.Errorf("roi %q has block size %s, which differs from roi %q block size %s",
+				name, target.BlockSize, d.DataName(), d.BlockSize)
+		}
+	} else {
+		if err := server.CheckRole(ctx.AuthUser, uuid, "", "write"); err != nil {
+			return err
+		}
+		config := dvid.NewConfig()
+		config.Set("BlockSize", fmt.Sprintf("%d,%d,%d", d.BlockSize[0], d.BlockSize[1], d.BlockSize[2]))
+		if !d.Versioned() {
+			config.Set("versioned", "false")
+		}
+		dataservice, err := datastore.NewData(uuid, d.GetType(), name, config)
+		if err != nil {
+			return fmt.Errorf("unable to create roi %q: %v", name, err)
+		}
+		target = dataservice.(*Data)
+	}
+	return target.PutSpans(v, spans, true)
+}
@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"math"
 	"net/http"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
@@ -159,11 +160,25 @@ GET <api URL>/node/<UUID>/<data name>/partition?batchsize=8
     optimized   If "true" or "on", partioning returns non-fixed sized subvolumes where the coverage
                   is better in terms of subvolumes having more active blocks.
 
-TODO (API endpoints that are planned in near future)
-
 GET  <api URL>/node/<UUID>/<data name>/erode/<element size>
-
-    Returns a ROI that has been eroded with a cubic structuring element of the given size.
+GET  <api URL>/node/<UUID>/<data name>/dilate/<element size>
+GET  <api URL>/node/<UUID>/<data name>/open/<element size>
+GET  <api URL>/node/<UUID>/<data name>/close/<element size>
+POST <api URL>/node/<UUID>/<data name>/erode/<element size>?target=<roi name>
+POST <api URL>/node/<UUID>/<data name>/dilate/<element size>?target=<roi name>
+POST <api URL>/node/<UUID>/<data name>/open/<element size>?target=<roi name>
+POST <api URL>/node/<UUID>/<data name>/close/<element size>?target=<roi name>
+
+    Applies a block-level morphological operation to the ROI using a cubic structuring
+    element that extends the given number of blocks in each direction.  "open" is an
+    erosion followed by a dilation, and "close" is a dilation followed by an erosion.
+
+    A GET returns JSON for the transformed ROI in the same span format as the "roi" endpoint.
+    A POST stores the transformed ROI into the roi instance given by the "target" query 
+    string, replacing any spans at this version.  If the target instance does not exist,
+    it is created with the same block size and versioning as this ROI.  If authorization
+    is enabled, the user needs the write role for the target instance, or for the repo
+    if the target is created.
 
     Example: 
 
@@ -171,6 +186,49 @@ GET  <api URL>/node/<UUID>/<data name>/erode/<element size>
 
     This returns JSON for an ROI that has been eroded by 1 block.
 
+    POST <api URL>/node/3f8c/medulla/dilate/2?target=medulla-dilated
+
+    This stores the medulla ROI dilated by 2 blocks into a roi named "medulla-dilated".
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of roi data.
+    element size  Number of blocks the structuring element extends in each direction.
+                  Must be between 1 and 64.
+
+    Query-string Options:
+
+    target        (POST only) Name of roi instance to store result.
+
+POST <api URL>/node/<UUID>/<data name>/combine[?target=<roi name>]
+
+    Combines roi instances using a boolean expression sent as the POST body, e.g.,
+
+        (medulla | lobula) - bad_slab
+
+    The operators are "|" for union, "&" for intersection, and "-" for difference.
+    "&" binds more tightly than "|" and "-", which are evaluated left to right, and
+    parentheses can be used for grouping.  The "-" operator must be separated from
+    roi names by whitespace since names may contain hyphens.  Names with unusual
+    characters can be double-quoted.  By default, each roi is read at the version
+    given by UUID, but an roi at another version of the same repo can be specified as
+    "name@uuid", e.g., "medulla@3f8c".  All rois must have the same block size as this
+    roi, and if authorization is enabled, the user needs the read role for each of them.
+
+    If no target is given, JSON for the resulting ROI is returned in the same span
+    format as the "roi" endpoint, and the request is allowed on committed nodes.  If a
+    target is given, the result is stored into that roi instance at this version,
+    creating it if necessary as with morphology POSTs.
+
+    Arguments:
+
+    UUID          Hexadecimal string with enough characters to uniquely identify a version node.
+    data name     Name of roi data whose block size must match all rois in expression.
+
+    Query-string Options:
+
+    target        Name of roi instance to store result.
 `
 
 func init() {
@@ -315,6 +373,15 @@ func (d *Data) IsMutationRequest(action, endpoint string) bool {
 	return d.Data.IsMutationRequest(action, endpoint) // default for rest.
 }
 
+// IsMutationQuery treats a POST /combine without a target as immutable since it only
+// returns the combined ROI.  Implements the datastore.QueryMutationChecker interface.
+func (d *Data) IsMutationQuery(action, endpoint string, query url.Values) bool {
+	if endpoint == "combine" && strings.ToLower(action) == "post" {
+		return query.Get("target") != ""
+	}
+	return d.IsMutationRequest(action, endpoint)
+}
+
 // DescribeTKeyClass returns a string explanation of what a particular TKeyClass
 // is used for.  Implements the datastore.TKeyClassDescriber interface.
 func (d *Data) DescribeTKeyClass(tkc storage.TKeyClass) string {
@@ -670,6 +737,56 @@ func (d *Data) PutSpans(versionID dvid.VersionID, spans []dvid.Span, init bool)
 	return nil
 }
 
+// MergeConflicts resolves spans modified in more than one parent during an auto merge,
+// implementing the datastore.Merger interface.  Since span values are empty, only the
+// presence of a span matters: a parent that added or removed a span relative to the
+// common ancestor takes precedence over an unchanged parent, with earlier parents in
+// merge order winning ties.
+func (d *Data) MergeConflicts(ctx *datastore.VersionedCtx, parents []dvid.VersionID, conflicts []datastore.MergeConflict) error {
+	db, err := datastore.GetOrderedKeyValueDB(d)
+	if err != nil {
+		return err
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	for _, conflict := range conflicts {
+		inBase := conflict.Base != nil
+		present := inBase
+		for _, value := range conflict.Values {
+			if (value != nil) != inBase {
+				present = !inBase
+				break
+			}
+		}
+		if !present {
+			if err := db.Delete(ctx, conflict.TKey); err != nil {
+				return err
+			}
+			continue
+		}
+		ibytes, err := conflict.TKey.ClassBytes(keyROI)
+		if err != nil {
+			return err
+		}
+		var index indexRLE
+		if err := index.IndexFromBytes(ibytes); err != nil {
+			return err
+		}
+		z := index.start.Value(2)
+		if z < d.MinZ {
+			d.MinZ = z
+		}
+		if z > d.MaxZ {
+			d.MaxZ = z
+		}
+		if err := db.Put(ctx, conflict.TKey, dvid.EmptyValue()); err != nil {
+			return err
+		}
+	}
+	return datastore.SaveDataByVersion(ctx.VersionID(), d)
+}
+
 // PutJSON saves JSON-encoded data representing an ROI into the datastore.
 func (d *Data) PutJSON(v dvid.VersionID, jsonBytes []byte) error {
 	spans := []dvid.Span{}
@@ -1436,6 +1553,82 @@ func (d *Data) ServeHTTP(uuid dvid.UUID, ctx *datastore.VersionedCtx, w http.Res
 			fmt.Fprintf(w, string(jsonBytes))
 			comment = fmt.Sprintf("HTTP POST ptquery '%s'", d.DataName())
 		}
+	case "erode", "dilate", "open", "close":
+		if len(parts) < 5 {
+			server.BadRequest(w, r, "%q must be followed by structuring element size in blocks", command)
+			return
+		}
+		size, err := strconv.Atoi(parts[4])
+		if err != nil {
+			server.BadRequest(w, r, "bad structuring element size %q: %v", parts[4], err)
+			return
+		}
+		if size <= 0 || size > MaxElementSize {
+			server.BadRequest(w, r, "structuring element size must be between 1 and %d blocks, got %d", MaxElementSize, size)
+			return
+		}
+		spans, err := d.Morphology(ctx, command, int32(size))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		switch method {
+		case "get":
+			jsonBytes, err := json.Marshal(spans)
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, string(jsonBytes))
+			comment = fmt.Sprintf("HTTP GET %s ROI %q by %d blocks: %d spans", command, d.DataName(), size, len(spans))
+		case "post":
+			target := dvid.InstanceName(r.URL.Query().Get("target"))
+			if target == "" {
+				server.BadRequest(w, r, "POST on %q requires 'target' query string giving the roi instance to store result", command)
+				return
+			}
+			if err := d.StoreSpans(ctx, target, spans); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			comment = fmt.Sprintf("HTTP POST %s ROI %q by %d blocks into roi %q: %d spans", command, d.DataName(), size, target, len(spans))
+		default:
+			server.BadRequest(w, r, "%q only supports GET or POST", command)
+			return
+		}
+	case "combine":
+		if method != "post" {
+			server.BadRequest(w, r, "combine only supports POST request")
+			return
+		}
+		expr, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		spans, err := d.Combine(ctx, string(expr))
+		if err != nil {
+			server.BadRequest(w, r, err)
+			return
+		}
+		target := dvid.InstanceName(r.URL.Query().Get("target"))
+		if target == "" {
+			jsonBytes, err := json.Marshal(spans)
+			if err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, string(jsonBytes))
+			comment = fmt.Sprintf("HTTP POST combine %q via ROI %q: %d spans", expr, d.DataName(), len(spans))
+		} else {
+			if err := d.StoreSpans(ctx, target, spans); err != nil {
+				server.BadRequest(w, r, err)
+				return
+			}
+			comment = fmt.Sprintf("HTTP POST combine %q via ROI %q into roi %q: %d spans", expr, d.DataName(), target, len(spans))
+		}
 	case "partition":
 		if method != "get" {
 			server.BadRequest(w, r, "partition only supports GET request")
@@ -312,6 +312,261 @@ func TestROIRequests(t *testing.T) {
 	}
 }
 
+func cubeSpans(min, max int32) []dvid.Span {
+	var spans []dvid.Span
+	for z := min; z <= max; z++ {
+		for y := min; y <= max; y++ {
+			spans = append(spans, dvid.Span{z, y, min, max})
+		}
+	}
+	return spans
+}
+
+func TestROIMorphology(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+
+	config := dvid.NewConfig()
+	dataservice, err := datastore.NewData(uuid, roitype, "cube", config)
+	if err != nil {
+		t.Fatalf("Error creating new roi instance: %v\n", err)
+	}
+	data, ok := dataservice.(*Data)
+	if !ok {
+		t.Fatalf("Returned new data instance is not roi.Data\n")
+	}
+
+	roiRequest := fmt.Sprintf("%snode/%s/%s/roi", server.WebAPIPath, uuid, data.DataName())
+	server.TestHTTP(t, "POST", roiRequest, getSpansJSON(cubeSpans(10, 14)))
+
+	tests := []struct {
+		op       string
+		expected []dvid.Span
+	}{
+		{"erode", cubeSpans(11, 13)},
+		{"dilate", cubeSpans(9, 15)},
+		{"open", cubeSpans(10, 14)},
+		{"close", cubeSpans(10, 14)},
+	}
+	for _, test := range tests {
+		req := fmt.Sprintf("%snode/%s/%s/%s/1", server.WebAPIPath, uuid, data.DataName(), test.op)
+		spans, err := putSpansJSON(server.TestHTTP(t, "GET", req, nil))
+		if err != nil {
+			t.Fatalf("Error on getting back JSON from %s: %v\n", test.op, err)
+		}
+		if !reflect.DeepEqual(spans, test.expected) {
+			t.Errorf("Bad %s result\nExpected:\n%s\nReturned:\n%s\n", test.op, test.expected, spans)
+		}
+	}
+
+	// Eroding more than the cube allows should give an empty ROI.
+	req := fmt.Sprintf("%snode/%s/%s/erode/3", server.WebAPIPath, uuid, data.DataName())
+	spans, err := putSpansJSON(server.TestHTTP(t, "GET", req, nil))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from erode: %v\n", err)
+	}
+	if len(spans) != 0 {
+		t.Errorf("Expected empty ROI after erosion, got %s\n", spans)
+	}
+
+	// Non-positive and oversized structuring elements are rejected.
+	for _, size := range []int{0, -1, MaxElementSize + 1} {
+		req = fmt.Sprintf("%snode/%s/%s/dilate/%d", server.WebAPIPath, uuid, data.DataName(), size)
+		server.TestBadHTTP(t, "GET", req, nil)
+	}
+
+	// Store the eroded ROI into a new instance.
+	req = fmt.Sprintf("%snode/%s/%s/erode/1?target=eroded", server.WebAPIPath, uuid, data.DataName())
+	server.TestHTTP(t, "POST", req, nil)
+	roiRequest = fmt.Sprintf("%snode/%s/eroded/roi", server.WebAPIPath, uuid)
+	spans, err = putSpansJSON(server.TestHTTP(t, "GET", roiRequest, nil))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from roi GET: %v\n", err)
+	}
+	if !reflect.DeepEqual(spans, cubeSpans(11, 13)) {
+		t.Errorf("Bad stored erosion\nExpected:\n%s\nReturned:\n%s\n", cubeSpans(11, 13), spans)
+	}
+}
+
+// bruteMorphology dilates or erodes a set of blocks by checking every block of a cubic
+// structuring element.
+func bruteMorphology(blocks map[dvid.ChunkPoint3d]bool, size int32, dilate bool) map[dvid.ChunkPoint3d]bool {
+	candidates := blocks
+	if dilate {
+		candidates = make(map[dvid.ChunkPoint3d]bool)
+		for block := range blocks {
+			for dz := -size; dz <= size; dz++ {
+				for dy := -size; dy <= size; dy++ {
+					for dx := -size; dx <= size; dx++ {
+						candidates[dvid.ChunkPoint3d{block[0] + dx, block[1] + dy, block[2] + dz}] = true
+					}
+				}
+			}
+		}
+		return candidates
+	}
+	out := make(map[dvid.ChunkPoint3d]bool)
+	for block := range candidates {
+		inside := true
+		for dz := -size; dz <= size && inside; dz++ {
+			for dy := -size; dy <= size && inside; dy++ {
+				for dx := -size; dx <= size && inside; dx++ {
+					inside = blocks[dvid.ChunkPoint3d{block[0] + dx, block[1] + dy, block[2] + dz}]
+				}
+			}
+		}
+		if inside {
+			out[block] = true
+		}
+	}
+	return out
+}
+
+func TestBlockRunsMorphology(t *testing.T) {
+	// An L-shaped ROI with a hole and a separate bar.
+	var spans []dvid.Span
+	for z := int32(0); z < 9; z++ {
+		for y := int32(0); y < 9; y++ {
+			if y < 4 {
+				spans = append(spans, dvid.Span{z, y, 0, 12})
+			} else if z != 4 {
+				spans = append(spans, dvid.Span{z, y, 0, 3}, dvid.Span{z, y, 5, 8})
+			}
+		}
+		spans = append(spans, dvid.Span{z, 12, 20, 22})
+	}
+	blocks := blockSet(spans)
+	for size := int32(1); size <= 3; size++ {
+		br := newBlockRuns(spans)
+		if got, expected := blockSet(br.dilate(size).spans()), bruteMorphology(blocks, size, true); !reflect.DeepEqual(got, expected) {
+			t.Errorf("bad dilation by %d: got %d blocks, expected %d blocks\n", size, len(got), len(expected))
+		}
+		if got, expected := blockSet(br.erode(size).spans()), bruteMorphology(blocks, size, false); !reflect.DeepEqual(got, expected) {
+			t.Errorf("bad erosion by %d: got %d blocks, expected %d blocks\n", size, len(got), len(expected))
+		}
+	}
+}
+
+// blockSet returns the set of blocks covered by spans.
+func blockSet(spans []dvid.Span) map[dvid.ChunkPoint3d]bool {
+	blocks := make(map[dvid.ChunkPoint3d]bool)
+	for _, span := range spans {
+		for x := span[2]; x <= span[3]; x++ {
+			blocks[dvid.ChunkPoint3d{x, span[1], span[0]}] = true
+		}
+	}
+	return blocks
+}
+
+func TestROICombine(t *testing.T) {
+	if err := server.OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer server.CloseTest()
+
+	uuid, _ := initTestRepo()
+
+	rois := map[dvid.InstanceName][]dvid.Span{
+		"a":        cubeSpans(10, 14),
+		"b":        cubeSpans(12, 16),
+		"bad-slab": {{12, 0, 0, 20}, {13, 5, 11, 13}},
+	}
+	for name, spans := range rois {
+		if _, err := datastore.NewData(uuid, roitype, name, dvid.NewConfig()); err != nil {
+			t.Fatalf("Error creating new roi instance %q: %v\n", name, err)
+		}
+		roiRequest := fmt.Sprintf("%snode/%s/%s/roi", server.WebAPIPath, uuid, name)
+		server.TestHTTP(t, "POST", roiRequest, getSpansJSON(spans))
+	}
+
+	a, b, slab := blockSet(rois["a"]), blockSet(rois["b"]), blockSet(rois["bad-slab"])
+	expected := make(map[dvid.ChunkPoint3d]bool)
+	for block := range a {
+		if !slab[block] {
+			expected[block] = true
+		}
+	}
+	for block := range b {
+		if !slab[block] {
+			expected[block] = true
+		}
+	}
+
+	combineReq := fmt.Sprintf("%snode/%s/a/combine", server.WebAPIPath, uuid)
+	expr := fmt.Sprintf("(a | b@%s) - bad-slab", uuid)
+	spans, err := putSpansJSON(server.TestHTTP(t, "POST", combineReq, bytes.NewBufferString(expr)))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from combine: %v\n", err)
+	}
+	if got := blockSet(spans); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Bad combine result for %q: got %d blocks, expected %d blocks\n", expr, len(got), len(expected))
+	}
+
+	spans, err = putSpansJSON(server.TestHTTP(t, "POST", combineReq, bytes.NewBufferString("a & b")))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from combine: %v\n", err)
+	}
+	if !reflect.DeepEqual(spans, cubeSpans(12, 14)) {
+		t.Errorf("Bad intersection\nExpected:\n%s\nReturned:\n%s\n", cubeSpans(12, 14), spans)
+	}
+
+	// Intersection binds more tightly than difference.
+	spans, err = putSpansJSON(server.TestHTTP(t, "POST", combineReq, bytes.NewBufferString("a - a & b")))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from combine: %v\n", err)
+	}
+	if got := blockSet(spans); len(got) != 125-27 {
+		t.Errorf("Expected %d blocks for a - (a & b), got %d\n", 125-27, len(got))
+	}
+
+	// Store result into a new roi.
+	server.TestHTTP(t, "POST", combineReq+"?target=both", bytes.NewBufferString("a | b"))
+	roiRequest := fmt.Sprintf("%snode/%s/both/roi", server.WebAPIPath, uuid)
+	spans, err = putSpansJSON(server.TestHTTP(t, "GET", roiRequest, nil))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from roi GET: %v\n", err)
+	}
+	union := blockSet(rois["a"])
+	for block := range b {
+		union[block] = true
+	}
+	if got := blockSet(spans); !reflect.DeepEqual(got, union) {
+		t.Errorf("Bad stored union: got %d blocks, expected %d blocks\n", len(got), len(union))
+	}
+
+	// Operands can't be read from another repo.
+	otherUUID, _ := initTestRepo()
+	if _, err := datastore.NewData(otherUUID, roitype, "secret", dvid.NewConfig()); err != nil {
+		t.Fatalf("Error creating new roi instance in other repo: %v\n", err)
+	}
+	otherROIReq := fmt.Sprintf("%snode/%s/secret/roi", server.WebAPIPath, otherUUID)
+	server.TestHTTP(t, "POST", otherROIReq, getSpansJSON(rois["a"]))
+	server.TestBadHTTP(t, "POST", combineReq, bytes.NewBufferString(fmt.Sprintf("a | secret@%s", otherUUID)))
+
+	// Malformed expressions and unknown rois should fail.
+	for _, bad := range []string{"", "(a | b", "a |", "a b", "a | nonexistent", "a@"} {
+		server.TestBadHTTP(t, "POST", combineReq, bytes.NewBufferString(bad))
+	}
+
+	// A combine without a target is read-only so works on committed nodes, but storing
+	// a result does not.
+	if err := datastore.Commit(uuid, "rois", nil); err != nil {
+		t.Fatalf("Unable to commit node %s: %v\n", uuid, err)
+	}
+	spans, err = putSpansJSON(server.TestHTTP(t, "POST", combineReq, bytes.NewBufferString("a & b")))
+	if err != nil {
+		t.Fatalf("Error on getting back JSON from combine on committed node: %v\n", err)
+	}
+	if got := blockSet(spans); len(got) != 27 {
+		t.Errorf("Expected 27 blocks for a & b on committed node, got %d\n", len(got))
+	}
+	server.TestBadHTTP(t, "POST", combineReq+"?target=locked", bytes.NewBufferString("a | b"))
+}
+
 func TestROIPostAndDelete(t *testing.T) {
 	if err := server.OpenTest(); err != nil {
 		t.Fatalf("can't open test server: %v\n", err)
@@ -0,0 +1,268 @@
+/*
+	This file contains a server-wide registry of long-running background jobs so their
+	progress can be monitored and they can be canceled.
+*/
+
+package dvid
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxFinishedJobs is the number of finished jobs kept in the registry.
+const MaxFinishedJobs = 100
+
+// JobID identifies a background job.
+type JobID uint64
+
+// JobStatus describes the state of a background job.
+type JobStatus struct {
+	ID       JobID
+	Type     string       // kind of job, e.g., "push" or "imagetile generate"
+	Owner    string       `json:",omitempty"` // user that requested the job, if known
+	UUID     UUID         `json:",omitempty"`
+	Instance InstanceName `json:",omitempty"`
+	Started  time.Time
+	Finished *time.Time `json:",omitempty"`
+	Running  bool
+	Canceled bool `json:",omitempty"`
+
+	// Percent is an estimate of how much of the job is complete.  It is only updated
+	// during the job if the job can estimate its progress.
+	Percent float64
+
+	Error string `json:",omitempty"`
+}
+
+// Job is a registered background job.  All methods can be used concurrently and are
+// no-ops on a nil *Job, so code can be shared with callers that don't track jobs.
+type Job struct {
+	sync.Mutex
+	status JobStatus
+	cancel chan struct{}
+
+	noCancel bool // set once the job can no longer stop safely
+}
+
+// ErrJobCanceled is returned by jobs that stopped because they were canceled.
+var ErrJobCanceled = fmt.Errorf("job canceled")
+
+var jobs struct {
+	sync.Mutex
+	nextID JobID
+	byID   map[JobID]*Job
+}
+
+// NewJob registers a new running job of the given type.  The owner, UUID, and data
+// instance name are optional.
+func NewJob(jobType, owner string, uuid UUID, instance InstanceName) *Job {
+	job := &Job{
+		status: JobStatus{
+			Type:     jobType,
+			Owner:    owner,
+			UUID:     uuid,
+			Instance: instance,
+			Started:  time.Now(),
+			Running:  true,
+		},
+		cancel: make(chan struct{}),
+	}
+	jobs.Lock()
+	if jobs.byID == nil {
+		jobs.byID = make(map[JobID]*Job)
+	}
+	jobs.nextID++
+	job.status.ID = jobs.nextID
+	jobs.byID[job.status.ID] = job
+	jobs.Unlock()
+	Infof("Started job %d: %s\n", job.status.ID, jobType)
+	return job
+}
+
+// ID returns the id of the job.
+func (j *Job) ID() JobID {
+	if j == nil {
+		return 0
+	}
+	j.Lock()
+	defer j.Unlock()
+	return j.status.ID
+}
+
+// Status returns a copy of the job's status.
+func (j *Job) Status() JobStatus {
+	if j == nil {
+		return JobStatus{}
+	}
+	j.Lock()
+	defer j.Unlock()
+	status := j.status
+	if j.status.Finished != nil {
+		finished := *j.status.Finished
+		status.Finished = &finished
+	}
+	return status
+}
+
+// SetPercent sets the estimated percent complete of the job.
+func (j *Job) SetPercent(percent float64) {
+	if j == nil {
+		return
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	j.Lock()
+	j.status.Percent = percent
+	j.Unlock()
+}
+
+// SetProgress sets the estimated percent complete from a number of completed items
+// out of a total.
+func (j *Job) SetProgress(done, total uint64) {
+	if total == 0 {
+		return
+	}
+	j.SetPercent(100 * float64(done) / float64(total))
+}
+
+// CancelChan returns a channel that is closed if the job is canceled.  A nil *Job
+// returns a nil channel, which is never closed.
+func (j *Job) CancelChan() <-chan struct{} {
+	if j == nil {
+		return nil
+	}
+	return j.cancel
+}
+
+// Canceled returns true if the job has been canceled.
+func (j *Job) Canceled() bool {
+	if j == nil {
+		return false
+	}
+	select {
+	case <-j.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cancel requests the job to stop.  Jobs stop at their next check for cancellation.
+func (j *Job) Cancel() error {
+	if j == nil {
+		return fmt.Errorf("can't cancel untracked job")
+	}
+	j.Lock()
+	defer j.Unlock()
+	if !j.status.Running {
+		return fmt.Errorf("job %d has already finished", j.status.ID)
+	}
+	if j.noCancel && !j.status.Canceled {
+		return fmt.Errorf("job %d can no longer be canceled", j.status.ID)
+	}
+	if !j.status.Canceled {
+		j.status.Canceled = true
+		close(j.cancel)
+	}
+	return nil
+}
+
+// DisableCancel refuses later cancel requests, e.g., once a job has started changes
+// that would be left inconsistent if it stopped partway.  It returns false if the job
+// was already canceled.
+func (j *Job) DisableCancel() bool {
+	if j == nil {
+		return true
+	}
+	j.Lock()
+	defer j.Unlock()
+	if j.status.Canceled {
+		return false
+	}
+	j.noCancel = true
+	return true
+}
+
+// Finish marks the job as finished with an optional error.  Successful jobs are set
+// to 100 percent complete.  Only the most recent MaxFinishedJobs finished jobs are kept.
+func (j *Job) Finish(err error) {
+	if j == nil {
+		return
+	}
+	j.Lock()
+	finished := time.Now()
+	j.status.Finished = &finished
+	j.status.Running = false
+	if err != nil {
+		j.status.Error = err.Error()
+	} else if !j.status.Canceled {
+		j.status.Percent = 100
+	}
+	id, jobType := j.status.ID, j.status.Type
+	j.Unlock()
+	if err != nil {
+		Errorf("Job %d (%s) failed: %v\n", id, jobType, err)
+	} else {
+		Infof("Finished job %d: %s\n", id, jobType)
+	}
+	pruneJobs()
+}
+
+// pruneJobs removes the oldest finished jobs beyond MaxFinishedJobs.
+func pruneJobs() {
+	jobs.Lock()
+	defer jobs.Unlock()
+	var finished []JobID
+	for id, job := range jobs.byID {
+		job.Lock()
+		if !job.status.Running {
+			finished = append(finished, id)
+		}
+		job.Unlock()
+	}
+	if len(finished) <= MaxFinishedJobs {
+		return
+	}
+	sort.Sort(jobIDs(finished))
+	for _, id := range finished[:len(finished)-MaxFinishedJobs] {
+		delete(jobs.byID, id)
+	}
+}
+
+type jobIDs []JobID
+
+func (ids jobIDs) Len() int           { return len(ids) }
+func (ids jobIDs) Less(i, j int) bool { return ids[i] < ids[j] }
+func (ids jobIDs) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
+
+// GetJob returns the job with the given id.
+func GetJob(id JobID) (*Job, error) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	job, found := jobs.byID[id]
+	if !found {
+		return nil, fmt.Errorf("no job with id %d", id)
+	}
+	return job, nil
+}
+
+// GetJobs returns the status of all running and recently finished jobs in order of
+// their start.
+func GetJobs() []JobStatus {
+	jobs.Lock()
+	ids := make([]JobID, 0, len(jobs.byID))
+	for id := range jobs.byID {
+		ids = append(ids, id)
+	}
+	sort.Sort(jobIDs(ids))
+	statuses := make([]JobStatus, len(ids))
+	for i, id := range ids {
+		statuses[i] = jobs.byID[id].Status()
+	}
+	jobs.Unlock()
+	return statuses
+}
@@ -0,0 +1,109 @@
+package dvid
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJobProgressAndCancel(t *testing.T) {
+	job := NewJob("test", "someuser", UUID("abc123"), InstanceName("grayscale"))
+	found, err := GetJob(job.ID())
+	if err != nil {
+		t.Fatalf("unable to get registered job: %v\n", err)
+	}
+	if found != job {
+		t.Fatalf("expected job %d, got job %d\n", job.ID(), found.ID())
+	}
+	job.SetProgress(1, 4)
+	status := job.Status()
+	if !status.Running || status.Percent != 25 || status.Owner != "someuser" || status.Instance != "grayscale" {
+		t.Errorf("bad status for running job: %v\n", status)
+	}
+	if job.Canceled() {
+		t.Fatalf("job canceled before cancel request\n")
+	}
+	if err := job.Cancel(); err != nil {
+		t.Fatalf("unable to cancel job: %v\n", err)
+	}
+	if !job.Canceled() {
+		t.Fatalf("job not canceled after cancel request\n")
+	}
+	select {
+	case <-job.CancelChan():
+	default:
+		t.Fatalf("cancel channel not closed after cancel request\n")
+	}
+	job.Finish(ErrJobCanceled)
+	status = job.Status()
+	if status.Running || !status.Canceled || status.Finished == nil || status.Error != ErrJobCanceled.Error() {
+		t.Errorf("bad status for canceled job: %v\n", status)
+	}
+	if status.Percent != 25 {
+		t.Errorf("expected canceled job to keep its progress, got %f percent\n", status.Percent)
+	}
+	if err := job.Cancel(); err == nil {
+		t.Errorf("expected error canceling finished job\n")
+	}
+
+	job = NewJob("test", "", UUID("abc123"), "")
+	if !job.DisableCancel() {
+		t.Fatalf("expected cancel to be disabled for uncanceled job\n")
+	}
+	if err := job.Cancel(); err == nil || job.Canceled() {
+		t.Errorf("expected cancel to be refused after it was disabled\n")
+	}
+	job.Finish(nil)
+
+	job = NewJob("test", "", UUID("abc123"), "")
+	if err := job.Cancel(); err != nil {
+		t.Fatalf("unable to cancel job: %v\n", err)
+	}
+	if job.DisableCancel() {
+		t.Errorf("expected disabling cancel of a canceled job to fail\n")
+	}
+	job.Finish(ErrJobCanceled)
+
+	var nilJob *Job
+	if !nilJob.DisableCancel() {
+		t.Errorf("expected nil job cancel to be disabled\n")
+	}
+	nilJob.SetProgress(1, 2)
+	nilJob.Finish(nil)
+	if nilJob.Canceled() || nilJob.CancelChan() != nil || nilJob.ID() != 0 {
+		t.Errorf("expected nil job to be a no-op\n")
+	}
+	if status := nilJob.Status(); status.ID != 0 || status.Running {
+		t.Errorf("expected empty status for nil job, got %v\n", status)
+	}
+	if err := nilJob.Cancel(); err == nil {
+		t.Errorf("expected error canceling nil job\n")
+	}
+}
+
+func TestJobsPruned(t *testing.T) {
+	first := NewJob("test prune", "", "", "")
+	first.Finish(nil)
+	if status := first.Status(); status.Percent != 100 || status.Error != "" {
+		t.Errorf("bad status for successful job: %v\n", status)
+	}
+	var last *Job
+	for i := 0; i < MaxFinishedJobs; i++ {
+		last = NewJob("test prune", "", "", "")
+		last.Finish(fmt.Errorf("error %d", i))
+	}
+	if _, err := GetJob(first.ID()); err == nil {
+		t.Errorf("expected oldest finished job %d to be pruned\n", first.ID())
+	}
+	if _, err := GetJob(last.ID()); err != nil {
+		t.Errorf("expected last finished job to be kept: %v\n", err)
+	}
+	statuses := GetJobs()
+	if len(statuses) > MaxFinishedJobs {
+		t.Errorf("expected at most %d jobs, got %d\n", MaxFinishedJobs, len(statuses))
+	}
+	for i := 1; i < len(statuses); i++ {
+		if statuses[i-1].ID >= statuses[i].ID {
+			t.Fatalf("jobs not in order of start: %v\n", statuses)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+/*
+	Package lz4 provides LZ4 block compression compatible with the LZ4 C library.  Builds
+	with cgo use the C library, while builds with CGO_ENABLED=0 use a pure Go port that
+	reads and writes the same block format.
+*/
+package lz4
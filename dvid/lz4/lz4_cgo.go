@@ -0,0 +1,24 @@
+// +build cgo
+
+package lz4
+
+import (
+	lz4 "github.com/janelia-flyem/go/golz4-updated"
+)
+
+// CompressBound returns the maximum size of the compressed form of the given data.
+func CompressBound(in []byte) int {
+	return lz4.CompressBound(in)
+}
+
+// Compress writes the LZ4 block compression of in into out, which must be at least
+// CompressBound(in) bytes, and returns the compressed size.
+func Compress(in, out []byte) (outSize int, err error) {
+	return lz4.Compress(in, out)
+}
+
+// Uncompress decompresses the LZ4 block in into out, which must be large enough to hold
+// the uncompressed data.
+func Uncompress(in, out []byte) error {
+	return lz4.Uncompress(in, out)
+}
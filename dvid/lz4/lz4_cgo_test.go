@@ -0,0 +1,31 @@
+// +build cgo
+
+package lz4
+
+import (
+	"bytes"
+	"testing"
+
+	golz4 "github.com/janelia-flyem/go/golz4-updated"
+	"github.com/pierrec/lz4"
+)
+
+// Data stored by servers built with cgo must be readable by servers built without it, so
+// blocks compressed by the C library are decoded with the pure Go port used without cgo.
+func TestCgoBlocksDecodeWithoutCgo(t *testing.T) {
+	for name, data := range testData() {
+		out := make([]byte, golz4.CompressBound(data))
+		outSize, err := golz4.Compress(data, out)
+		if err != nil {
+			t.Fatalf("unable to compress %s data with golz4: %v\n", name, err)
+		}
+		decoded := make([]byte, len(data))
+		n, err := lz4.UncompressBlock(out[:outSize], decoded)
+		if err != nil {
+			t.Fatalf("unable to decompress golz4 %s data with pure Go lz4: %v\n", name, err)
+		}
+		if n != len(data) || !bytes.Equal(decoded, data) {
+			t.Errorf("pure Go lz4 decoded %d bytes of golz4 %s data that don't match the original %d bytes\n", n, name, len(data))
+		}
+	}
+}
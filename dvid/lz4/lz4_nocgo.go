@@ -0,0 +1,76 @@
+// +build !cgo
+
+package lz4
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pierrec/lz4"
+)
+
+// hashTables holds the hash tables required by the block compressor.
+var hashTables = sync.Pool{
+	New: func() interface{} {
+		return make([]int, 1<<16)
+	},
+}
+
+// CompressBound returns the maximum size of the compressed form of the given data.
+func CompressBound(in []byte) int {
+	return lz4.CompressBlockBound(len(in))
+}
+
+// Compress writes the LZ4 block compression of in into out, which must be at least
+// CompressBound(in) bytes, and returns the compressed size.
+func Compress(in, out []byte) (outSize int, err error) {
+	if len(out) < CompressBound(in) {
+		return 0, fmt.Errorf("lz4 output buffer of %d bytes is smaller than bound %d", len(out), CompressBound(in))
+	}
+	hashTable := hashTables.Get().([]int)
+	outSize, err = lz4.CompressBlock(in, out, hashTable)
+	for i := range hashTable {
+		hashTable[i] = 0
+	}
+	hashTables.Put(hashTable)
+	if err != nil {
+		return 0, err
+	}
+	if outSize == 0 {
+		// The pure Go compressor gives up on small or incompressible data, which the C
+		// library stores as a single run of literals.
+		return literalBlock(in, out), nil
+	}
+	return outSize, nil
+}
+
+// literalBlock writes the data as an LZ4 block with one literal sequence and returns
+// the block size.
+func literalBlock(in, out []byte) int {
+	n := len(in)
+	pos := 1
+	if n < 15 {
+		out[0] = byte(n << 4)
+	} else {
+		out[0] = 0xF0
+		for left := n - 15; ; left -= 255 {
+			if left < 255 {
+				out[pos] = byte(left)
+				pos++
+				break
+			}
+			out[pos] = 255
+			pos++
+		}
+	}
+	return pos + copy(out[pos:], in)
+}
+
+// Uncompress decompresses the LZ4 block in into out, which must be large enough to hold
+// the uncompressed data.
+func Uncompress(in, out []byte) error {
+	if _, err := lz4.UncompressBlock(in, out); err != nil {
+		return fmt.Errorf("lz4 decompression failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+// testData returns data compressed like stored blocks: a label block with a few large
+// regions, incompressible bytes, and data too small to compress.
+func testData() map[string][]byte {
+	labels := make([]byte, 16*16*16*8)
+	for i := 0; i < 16*16*16; i++ {
+		binary.LittleEndian.PutUint64(labels[i*8:], uint64(23+i/1000))
+	}
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(13)).Read(random)
+	return map[string][]byte{
+		"label":  labels,
+		"random": random,
+		"small":  []byte("dvid"),
+	}
+}
+
+func TestRoundtrip(t *testing.T) {
+	for name, data := range testData() {
+		out := make([]byte, CompressBound(data))
+		outSize, err := Compress(data, out)
+		if err != nil {
+			t.Fatalf("unable to compress %s data: %v\n", name, err)
+		}
+		decoded := make([]byte, len(data))
+		if err := Uncompress(out[:outSize], decoded); err != nil {
+			t.Fatalf("unable to decompress %s data: %v\n", name, err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("bad roundtrip of %s data\n", name)
+		}
+	}
+}
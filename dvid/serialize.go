@@ -15,9 +15,12 @@ import (
 	"image"
 	"image/jpeg"
 	"io"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 
 	"github.com/golang/snappy"
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
+	"github.com/klauspost/compress/zstd"
 )
 
 // Compression is the format of compression for storing data.
@@ -93,13 +96,18 @@ func NewCompression(format CompressionFormat, level CompressionLevel) (Compressi
 			return Compression{}, fmt.Errorf("Gzip compression level must be between 1 and 9")
 		}
 		return Compression{format, level}, nil
+	case Zstd:
+		if level != DefaultCompression && (level < 1 || level > 22) {
+			return Compression{}, fmt.Errorf("Zstd compression level must be between 1 and 22")
+		}
+		return Compression{format, level}, nil
 	default:
 		return Compression{}, fmt.Errorf("Unrecognized compression format requested: %d", format)
 	}
 }
 
 // CompressionLevel goes from 1 (fastest) to 9 (highest compression)
-// as in deflate.  Default compression is -1 so need signed int8.
+// as in deflate, or up to 22 for Zstd.  Default compression is -1 so need signed int8.
 type CompressionLevel int8
 
 const (
@@ -122,6 +130,7 @@ const (
 	Gzip                           = 2 // Gzip stores length and checksum automatically.
 	LZ4                            = 4
 	JPEG                           = 5
+	Zstd                           = 6
 )
 
 func (format CompressionFormat) String() string {
@@ -136,6 +145,8 @@ func (format CompressionFormat) String() string {
 		return "jpeg compression"
 	case Gzip:
 		return "gzip compression"
+	case Zstd:
+		return "zstd compression"
 	default:
 		return "Unknown compression"
 	}
@@ -237,6 +248,10 @@ func SerializeData(data []byte, compress Compression, checksum Checksum) ([]byte
 			return nil, err
 		}
 		byteData = b.Bytes()
+	case Zstd:
+		if byteData, err = ZstdCompress(data, compress.level); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("Illegal compression (%s) during serialization", compress)
 	}
@@ -384,11 +399,65 @@ func DeserializeData(s []byte, uncompress bool) ([]byte, CompressionFormat, erro
 			return nil, 0, err
 		}
 		return buffer.Bytes(), compression, nil
+	case Zstd:
+		data, err := ZstdUncompress(cdata)
+		if err != nil {
+			return nil, 0, err
+		}
+		return data, compression, nil
 	default:
 		return nil, 0, fmt.Errorf("Illegal compression format (%d) in deserialization", compression)
 	}
 }
 
+// Zstd encoders and decoders are expensive to create but safe for concurrent use,
+// so we keep one encoder per compression level and a single decoder.
+var zstdCodecs struct {
+	sync.Mutex
+	encoders map[CompressionLevel]*zstd.Encoder
+	decoder  *zstd.Decoder
+}
+
+// ZstdCompress returns the Zstandard compression of data at the given level, where
+// DefaultCompression corresponds to zstd level 3.
+func ZstdCompress(data []byte, level CompressionLevel) ([]byte, error) {
+	zstdCodecs.Lock()
+	enc, found := zstdCodecs.encoders[level]
+	if !found {
+		encLevel := zstd.SpeedDefault
+		if level != DefaultCompression {
+			encLevel = zstd.EncoderLevelFromZstd(int(level))
+		}
+		var err error
+		if enc, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(encLevel)); err != nil {
+			zstdCodecs.Unlock()
+			return nil, err
+		}
+		if zstdCodecs.encoders == nil {
+			zstdCodecs.encoders = make(map[CompressionLevel]*zstd.Encoder)
+		}
+		zstdCodecs.encoders[level] = enc
+	}
+	zstdCodecs.Unlock()
+	return enc.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+}
+
+// ZstdUncompress returns the uncompressed data from a Zstandard compressed slice.
+func ZstdUncompress(data []byte) ([]byte, error) {
+	zstdCodecs.Lock()
+	if zstdCodecs.decoder == nil {
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			zstdCodecs.Unlock()
+			return nil, err
+		}
+		zstdCodecs.decoder = dec
+	}
+	dec := zstdCodecs.decoder
+	zstdCodecs.Unlock()
+	return dec.DecodeAll(data, nil)
+}
+
 // Deserialize a Go object using Gob encoding
 func Deserialize(s []byte, object interface{}) error {
 	// Get the bytes for the Gob-encoded object
@@ -8,7 +8,7 @@ import (
 	"reflect"
 	"testing"
 
-	lz4 "github.com/janelia-flyem/go/golz4-updated"
+	"github.com/janelia-flyem/dvid/dvid/lz4"
 )
 
 func TestLocalID(t *testing.T) {
@@ -41,7 +41,7 @@ func TestSerialization(t *testing.T) {
 		},
 	}
 
-	for _, format := range []CompressionFormat{Uncompressed, Snappy, LZ4, Gzip} {
+	for _, format := range []CompressionFormat{Uncompressed, Snappy, LZ4, Gzip, Zstd} {
 		for _, checksum := range []Checksum{NoChecksum, CRC32} {
 			compression, err := NewCompression(format, DefaultCompression)
 			if err != nil {
@@ -135,6 +135,36 @@ func TestIncompressibleLZ4(t *testing.T) {
 	}
 }
 
+func TestZstdLevels(t *testing.T) {
+	data := bytes.Repeat([]byte("zstd compressible label data "), 1000)
+	for _, level := range []CompressionLevel{DefaultCompression, 1, 9, 19} {
+		compression, err := NewCompression(Zstd, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := SerializeData(data, compression, CRC32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s) >= len(data) {
+			t.Errorf("zstd level %d did not compress: %d bytes -> %d bytes\n", level, len(data), len(s))
+		}
+		out, format, err := DeserializeData(s, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != Zstd {
+			t.Errorf("expected zstd format on deserialization, got %s\n", format)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("zstd level %d round trip failed\n", level)
+		}
+	}
+	if _, err := NewCompression(Zstd, 23); err == nil {
+		t.Errorf("expected error on zstd compression level 23\n")
+	}
+}
+
 func testUncompressed(b *testing.B, checksum Checksum) {
 	stringObj := "Hi there!"
 	var returnObj string
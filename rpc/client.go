@@ -32,7 +32,7 @@ type Session struct {
 // NewSession returns a new session to the remote address where the
 // type of session is reflected by the MessageID.
 func NewSession(addr string, mid MessageID) (Session, error) {
-	c := gorpc.NewTCPClient(addr)
+	c := NewClient(addr)
 	c.Start()
 	dc := dispatcher.NewFuncClient(c)
 	if dc == nil {
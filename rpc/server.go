@@ -5,6 +5,7 @@
 package rpc
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"os"
@@ -165,6 +166,15 @@ func GetSessionHandler(sid SessionID) (SessionHandler, error) {
 
 // StartServer starts an RPC server.
 func StartServer(address string) error {
+	return startServer(address, gorpc.NewTCPServer(address, dispatcher.NewHandlerFunc()))
+}
+
+// StartTLSServer starts an RPC server that requires TLS using the given configuration.
+func StartTLSServer(address string, cfg *tls.Config) error {
+	return startServer(address, gorpc.NewTLSServer(address, dispatcher.NewHandlerFunc(), cfg))
+}
+
+func startServer(address string, s *gorpc.Server) error {
 	defer func() {
 		if e := recover(); e != nil {
 			msg := fmt.Sprintf("Panic detected on rpc serve thread: %+v\n", e)
@@ -175,7 +185,6 @@ func StartServer(address string) error {
 
 	gorpc.SetErrorLogger(dvid.Errorf) // Send gorpc errors to appropriate error log.
 
-	s := gorpc.NewTCPServer(address, dispatcher.NewHandlerFunc())
 	if servers == nil {
 		servers = make(map[string]*gorpc.Server)
 	}
@@ -0,0 +1,76 @@
+/*
+	This file supports TLS for RPC servers and clients.
+*/
+
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/valyala/gorpc"
+)
+
+var (
+	clientTLS   *tls.Config
+	clientTLSMu sync.RWMutex
+)
+
+// SetClientTLS sets the TLS configuration used by clients created via NewClient.
+// A nil configuration will make clients use plain TCP.
+func SetClientTLS(cfg *tls.Config) {
+	clientTLSMu.Lock()
+	clientTLS = cfg
+	clientTLSMu.Unlock()
+}
+
+// NewClient returns a gorpc client for the given address, using TLS if a client
+// configuration has been set via SetClientTLS.
+func NewClient(addr string) *gorpc.Client {
+	clientTLSMu.RLock()
+	cfg := clientTLS
+	clientTLSMu.RUnlock()
+	if cfg != nil {
+		return gorpc.NewTLSClient(addr, cfg)
+	}
+	return gorpc.NewTCPClient(addr)
+}
+
+// LoadCertPool returns a certificate pool from a file of PEM-encoded certificates.
+func LoadCertPool(filename string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA certificates: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %q", filename)
+	}
+	return pool, nil
+}
+
+// ClientTLSConfig returns a client TLS configuration.  The server certificate is
+// verified against the CA certificates in caFile or the system roots if caFile is
+// empty.  If certFile and keyFile are given, the client presents that certificate
+// to servers that require client certificates.
+func ClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -42,6 +42,19 @@ fi
 
 echo "Fetching third-party go sources..."
 
+# Clones a repo into the GOPATH and checks out a fixed tag, since 'go get' always
+# fetches the newest version, which may not build with our Go version.
+# Usage: get_tagged <import path> <tag>
+get_tagged() {
+    local REPO_DIR=${GOPATH}/src/$1
+    if [[ -d ${REPO_DIR} ]]; then
+        cd ${REPO_DIR} && git fetch --tags && cd -
+    else
+        git clone https://$1 ${REPO_DIR}
+    fi
+    cd ${REPO_DIR} && git checkout $2 && cd -
+}
+
 # gopackages
 go get github.com/janelia-flyem/go
 cd ${GOPATH}/src/github.com/janelia-flyem/go
@@ -140,4 +153,25 @@ go get github.com/coocood/freecache
 # Openstack Swift
 go get github.com/ncw/swift
 
+# goleveldb (pure Go leveldb for builds without cgo)
+# If you change this tag, please also change it in scripts/conda-recipe/meta.yaml
+get_tagged github.com/syndtr/goleveldb v1.0.0
+
+# btree for the in-memory engine (later versions require generics)
+# If you change this tag, please also change it in scripts/conda-recipe/meta.yaml
+get_tagged github.com/google/btree v1.0.0
+
+# AWS SDK and its jmespath dependency for the s3 engine
+# If you change these tags, please also change them in scripts/conda-recipe/meta.yaml
+get_tagged github.com/aws/aws-sdk-go v1.35.0
+get_tagged github.com/jmespath/go-jmespath v0.4.0
+
+# zstd (later versions require newer Go than the go 1.11 used for builds)
+# If you change this tag, please also change it in scripts/conda-recipe/meta.yaml
+get_tagged github.com/klauspost/compress v1.10.3
+
+# pure Go lz4 for builds without cgo
+# If you change this tag, please also change it in scripts/conda-recipe/meta.yaml
+get_tagged github.com/pierrec/lz4 v2.2.6
+
 echo "Done fetching third-party go sources."
@@ -0,0 +1,473 @@
+/*
+	This file supports authentication of HTTP requests via signed bearer tokens and
+	authorization of requests using per-repo or per-instance role rules.
+*/
+
+package server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+
+	"github.com/zenazn/goji/web"
+)
+
+// AuthConfig specifies authentication and authorization of HTTP requests.  If no
+// key file is given, all requests are allowed.
+type AuthConfig struct {
+	KeyFile   string     // file with one HMAC-SHA256 signing key per line
+	Anonymous string     // role for requests without a token: "", "read", "write", or "admin"
+	Rule      []AuthRule // role rules, where the highest role of all matching rules applies
+}
+
+// AuthRule gives a user a role for a repo or a data instance within a repo.
+type AuthRule struct {
+	User     string // user name or "*" for any authenticated user
+	Repo     string // UUID of repo root (a unique prefix is fine) or "*" for all repos and server requests
+	Instance string // data instance name or "*" for all instances and repo-level requests
+	Role     string // "read", "write", or "admin"
+}
+
+// Authenticator verifies the credentials of a HTTP request.
+type Authenticator interface {
+	// Authenticate returns the user for a request or an empty string if the request
+	// has no credentials.  An error is returned if the credentials are invalid.
+	Authenticate(r *http.Request) (user string, err error)
+}
+
+type authRole uint8
+
+const (
+	noRole authRole = iota
+	readRole
+	writeRole
+	adminRole
+)
+
+func (role authRole) String() string {
+	switch role {
+	case readRole:
+		return "read"
+	case writeRole:
+		return "write"
+	case adminRole:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+func parseAuthRole(s string) (authRole, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return noRole, nil
+	case "read":
+		return readRole, nil
+	case "write":
+		return writeRole, nil
+	case "admin":
+		return adminRole, nil
+	default:
+		return noRole, fmt.Errorf("unknown role %q, must be read, write, or admin", s)
+	}
+}
+
+type authRule struct {
+	user     string
+	repo     string
+	instance string
+	role     authRole
+}
+
+var (
+	authMu        sync.RWMutex
+	authenticator Authenticator // if nil, authentication and authorization are disabled.
+	authAnonymous authRole
+	authRules     []authRule
+)
+
+// SetAuthenticator sets the authenticator used for HTTP requests, which allows
+// other credential schemes to replace signed tokens.  A nil authenticator disables
+// authentication and authorization.
+func SetAuthenticator(a Authenticator) {
+	authMu.Lock()
+	authenticator = a
+	authMu.Unlock()
+}
+
+// SetAuthConfig sets up token authentication and the role rules.
+func SetAuthConfig(cfg AuthConfig) error {
+	anonymous, err := parseAuthRole(cfg.Anonymous)
+	if err != nil {
+		return fmt.Errorf("bad anonymous role in auth config: %v", err)
+	}
+	rules := make([]authRule, len(cfg.Rule))
+	for i, rule := range cfg.Rule {
+		if rule.User == "" {
+			return fmt.Errorf("auth rule %d must specify a user or %q", i, "*")
+		}
+		role, err := parseAuthRole(rule.Role)
+		if err != nil {
+			return fmt.Errorf("bad auth rule %d: %v", i, err)
+		}
+		rules[i] = authRule{
+			user:     rule.User,
+			repo:     rule.Repo,
+			instance: rule.Instance,
+			role:     role,
+		}
+		if rules[i].repo == "" {
+			rules[i].repo = "*"
+		}
+		if rules[i].instance == "" {
+			rules[i].instance = "*"
+		}
+	}
+	var a Authenticator
+	if cfg.KeyFile != "" {
+		keys, err := readAuthKeys(cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		a = tokenAuthenticator{keys}
+		dvid.Infof("Token authentication enabled with %d keys and %d role rules.\n", len(keys), len(rules))
+	}
+
+	authMu.Lock()
+	authenticator = a
+	authAnonymous = anonymous
+	authRules = rules
+	authMu.Unlock()
+	return nil
+}
+
+// readAuthKeys reads signing keys, one per line, ignoring blank lines and lines
+// starting with "#".
+func readAuthKeys(filename string) ([][]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open auth key file: %v", err)
+	}
+	defer f.Close()
+	var keys [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, []byte(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading auth key file %q: %v", filename, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys found in auth key file %q", filename)
+	}
+	return keys, nil
+}
+
+// ---- Signed bearer tokens, which are JSON Web Tokens using HS256 -----
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type tokenClaims struct {
+	Subject  string `json:"sub"`
+	IssuedAt int64  `json:"iat,omitempty"`
+	Expires  int64  `json:"exp,omitempty"`
+}
+
+func signToken(key []byte, signingInput string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewAuthToken returns a signed bearer token for the given user.  If expires is the
+// zero time, the token does not expire.
+func NewAuthToken(key []byte, user string, expires time.Time) (string, error) {
+	if user == "" {
+		return "", fmt.Errorf("auth token requires a user")
+	}
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims := tokenClaims{Subject: user, IssuedAt: time.Now().Unix()}
+	if !expires.IsZero() {
+		claims.Expires = expires.Unix()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + signToken(key, signingInput), nil
+}
+
+type tokenAuthenticator struct {
+	keys [][]byte
+}
+
+// Authenticate implements the Authenticator interface using the bearer token in the
+// Authorization header.
+func (ta tokenAuthenticator) Authenticate(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return "", nil
+	}
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "bearer ") {
+		return "", fmt.Errorf("authorization header must use Bearer scheme")
+	}
+	return ta.verify(strings.TrimSpace(authorization[7:]))
+}
+
+func (ta tokenAuthenticator) verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("malformed bearer token")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed bearer token header")
+	}
+	var header tokenHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return "", fmt.Errorf("malformed bearer token header")
+	}
+	if header.Alg != "HS256" {
+		return "", fmt.Errorf("bearer token algorithm %q is not supported", header.Alg)
+	}
+	signingInput := parts[0] + "." + parts[1]
+	var valid bool
+	for _, key := range ta.keys {
+		if hmac.Equal([]byte(signToken(key, signingInput)), []byte(parts[2])) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("bearer token has invalid signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed bearer token claims")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("malformed bearer token claims")
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("bearer token has no subject")
+	}
+	if claims.Expires != 0 && time.Now().Unix() >= claims.Expires {
+		return "", fmt.Errorf("bearer token for %q has expired", claims.Subject)
+	}
+	return claims.Subject, nil
+}
+
+// ---- Authorization -----
+
+// requestScope returns the repo UUID string and data instance a request targets, and
+// the role needed.  An empty UUID string denotes a server-level request.  The returned
+// role is noRole for public requests like help.
+func requestScope(r *http.Request) (uuidStr string, instance dvid.InstanceName, needed authRole) {
+	if !strings.HasPrefix(r.URL.Path, WebAPIPath) {
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path[len(WebAPIPath):], "/"), "/")
+	switch parts[0] {
+	case "help":
+		return
+	case "repo", "node":
+		if len(parts) > 1 {
+			uuidStr = parts[1]
+		}
+		if parts[0] == "node" && len(parts) > 3 {
+			instance = dvid.InstanceName(parts[2])
+		}
+	}
+	switch strings.ToLower(r.Method) {
+	case "get", "head":
+		needed = readRole
+	case "delete":
+		needed = adminRole
+		if parts[0] == "node" && len(parts) == 2 {
+			needed = writeRole // node deletion also checks for repo passcode or admin role.
+		}
+	default:
+		needed = writeRole
+		if parts[0] == "server" || parts[0] == "repos" {
+			needed = adminRole // server settings and new repos need admin role.
+		}
+	}
+	return
+}
+
+// userRole returns the highest role of the user for the given repo and instance.
+// An empty user denotes an unauthenticated request.
+func userRole(user string, root dvid.UUID, instance dvid.InstanceName) authRole {
+	authMu.RLock()
+	defer authMu.RUnlock()
+	role := authAnonymous
+	for _, rule := range authRules {
+		if rule.role <= role {
+			continue
+		}
+		if user == "" || (rule.user != "*" && rule.user != user) {
+			continue
+		}
+		if rule.repo != "*" && (root == "" || !strings.HasPrefix(string(root), rule.repo)) {
+			continue
+		}
+		if rule.instance != "*" && rule.instance != string(instance) {
+			continue
+		}
+		role = rule.role
+	}
+	return role
+}
+
+// requestIsAdmin returns whether the authenticated user of a request has the admin role
+// for the repo holding the given UUID.  It is false if authentication is disabled.
+func requestIsAdmin(c web.C, uuid dvid.UUID) bool {
+	authMu.RLock()
+	a := authenticator
+	authMu.RUnlock()
+	if a == nil {
+		return false
+	}
+	root, err := datastore.GetRepoRoot(uuid)
+	if err != nil {
+		return false
+	}
+	user, _ := c.Env["user"].(string)
+	return userRole(user, root, "") >= adminRole
+}
+
+// CheckRole returns an error if authentication is enabled and the user, e.g., the
+// AuthUser of a request's context, lacks the given role ("read", "write", or "admin")
+// for the data instance in the repo holding the UUID.  An empty instance name requires
+// the role for any instance of the repo, as needed to create instances.  Datatypes use
+// this when a request reads or writes instances other than the one in its URL.
+func CheckRole(user string, uuid dvid.UUID, instance dvid.InstanceName, role string) error {
+	authMu.RLock()
+	a := authenticator
+	authMu.RUnlock()
+	if a == nil {
+		return nil
+	}
+	needed, err := parseAuthRole(role)
+	if err != nil {
+		return err
+	}
+	root, err := datastore.GetRepoRoot(uuid)
+	if err != nil {
+		return err
+	}
+	if has := userRole(user, root, instance); has < needed {
+		if instance == "" {
+			return fmt.Errorf("user %q has %s role but %s role required for repo %s", user, has, needed, root)
+		}
+		return fmt.Errorf("user %q has %s role but %s role required for data %q", user, has, needed, instance)
+	}
+	return nil
+}
+
+// repoRoot returns the root UUID of the repo given by a potentially partial UUID
+// string or an empty UUID if it can't be determined.
+func repoRoot(uuidStr string) dvid.UUID {
+	if uuidStr == "" {
+		return ""
+	}
+	uuid, _, err := datastore.MatchingUUID(uuidStr)
+	if err != nil {
+		return ""
+	}
+	root, err := datastore.GetRepoRoot(uuid)
+	if err != nil {
+		return ""
+	}
+	return root
+}
+
+// denyRequest sends a 401 or 403 status and logs the denial with the identity of the
+// requester in the activity log.
+func denyRequest(w http.ResponseWriter, r *http.Request, user string, status int, reason string) {
+	msg := fmt.Sprintf("%s (%s)", reason, r.URL.Path)
+	dvid.Infof("Denied %s %s for user %q from %s: %s\n", r.Method, r.URL.Path, user, r.RemoteAddr, reason)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="dvid"`)
+	}
+	http.Error(w, msg, status)
+	if KafkaAvailable() {
+		activity := map[string]interface{}{
+			"time":        time.Now().Unix(),
+			"status":      status,
+			"user":        user,
+			"client":      r.URL.Query().Get("app"),
+			"method":      r.Method,
+			"uri":         r.RequestURI,
+			"remote_addr": r.RemoteAddr,
+			"denied":      reason,
+		}
+		storage.LogActivityToKafka(activity)
+	}
+}
+
+// Middleware that authenticates requests and enforces role rules if an authenticator
+// is set.  Any "u" query string is removed and replaced by the verified user, if any, so
+// downstream handlers and logs only see the authenticated identity.
+func authHandler(c *web.C, h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		authMu.RLock()
+		a := authenticator
+		authMu.RUnlock()
+		if a == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		user, err := a.Authenticate(r)
+		if err != nil {
+			denyRequest(w, r, "", http.StatusUnauthorized, err.Error())
+			return
+		}
+		uuidStr, instance, needed := requestScope(r)
+		if needed != noRole {
+			role := userRole(user, repoRoot(uuidStr), instance)
+			if role < needed {
+				if user == "" {
+					denyRequest(w, r, user, http.StatusUnauthorized, fmt.Sprintf("%s role required", needed))
+				} else {
+					denyRequest(w, r, user, http.StatusForbidden, fmt.Sprintf("user %q has %s role but %s role required", user, role, needed))
+				}
+				return
+			}
+		}
+		query := r.URL.Query()
+		query.Del("u")
+		if user != "" {
+			c.Env["user"] = user
+			query.Set("u", user)
+		}
+		r.URL.RawQuery = query.Encode()
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/janelia-flyem/dvid/datastore"
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+func TestAuthToken(t *testing.T) {
+	key := []byte("mysecretkey")
+	ta := tokenAuthenticator{[][]byte{[]byte("oldkey"), key}}
+
+	token, err := NewAuthToken(key, "alice", time.Time{})
+	if err != nil {
+		t.Fatalf("unable to create token: %v\n", err)
+	}
+	user, err := ta.verify(token)
+	if err != nil {
+		t.Fatalf("unable to verify token: %v\n", err)
+	}
+	if user != "alice" {
+		t.Errorf("expected user alice, got %q\n", user)
+	}
+
+	// Tampered claims should fail.
+	bobToken, err := NewAuthToken(key, "bob", time.Time{})
+	if err != nil {
+		t.Fatalf("unable to create token: %v\n", err)
+	}
+	parts := strings.Split(token, ".")
+	bobParts := strings.Split(bobToken, ".")
+	if _, err := ta.verify(parts[0] + "." + bobParts[1] + "." + parts[2]); err == nil {
+		t.Errorf("expected tampered token to fail verification\n")
+	}
+
+	// Tokens signed with an unknown key should fail.
+	otherToken, err := NewAuthToken([]byte("otherkey"), "alice", time.Time{})
+	if err != nil {
+		t.Fatalf("unable to create token: %v\n", err)
+	}
+	if _, err := ta.verify(otherToken); err == nil {
+		t.Errorf("expected token with unknown key to fail verification\n")
+	}
+
+	// Expired tokens should fail.
+	expiredToken, err := NewAuthToken(key, "alice", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("unable to create token: %v\n", err)
+	}
+	if _, err := ta.verify(expiredToken); err == nil {
+		t.Errorf("expected expired token to fail verification\n")
+	}
+}
+
+func authRequest(t *testing.T, method, urlStr, token string, payload io.Reader) int {
+	req, err := http.NewRequest(method, urlStr, payload)
+	if err != nil {
+		t.Fatalf("Unsuccessful %s on %q: %v\n", method, urlStr, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	ServeSingleHTTP(resp, req)
+	return resp.Code
+}
+
+func TestAuthRoles(t *testing.T) {
+	if err := OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer CloseTest()
+
+	uuid, _ := datastore.NewTestRepo()
+
+	dir, err := ioutil.TempDir("", "dvid-auth-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	keyfile := filepath.Join(dir, "keys")
+	if err := ioutil.WriteFile(keyfile, []byte("# signing keys\nmysecretkey\n"), 0600); err != nil {
+		t.Fatalf("unable to write key file: %v\n", err)
+	}
+	cfg := AuthConfig{
+		KeyFile:   keyfile,
+		Anonymous: "read",
+		Rule: []AuthRule{
+			{User: "alice", Repo: string(uuid)[:10], Role: "write"},
+			{User: "root", Repo: "*", Role: "admin"},
+			{User: "carol", Repo: "*", Instance: "rois", Role: "write"},
+		},
+	}
+	if err := SetAuthConfig(cfg); err != nil {
+		t.Fatalf("unable to set auth config: %v\n", err)
+	}
+	defer SetAuthConfig(AuthConfig{})
+
+	aliceToken, _ := NewAuthToken([]byte("mysecretkey"), "alice", time.Time{})
+	bobToken, _ := NewAuthToken([]byte("mysecretkey"), "bob", time.Time{})
+	rootToken, _ := NewAuthToken([]byte("mysecretkey"), "root", time.Time{})
+	badToken, _ := NewAuthToken([]byte("wrongkey"), "alice", time.Time{})
+
+	infoURL := fmt.Sprintf("%srepo/%s/info", WebAPIPath, uuid)
+	noteURL := fmt.Sprintf("%snode/%s/note", WebAPIPath, uuid)
+	note := func() io.Reader { return bytes.NewBufferString(`{"note": "auth test"}`) }
+	reloadURL := fmt.Sprintf("%sserver/reload-metadata", WebAPIPath)
+	reposURL := fmt.Sprintf("%srepos", WebAPIPath)
+	newRepo := func() io.Reader { return bytes.NewBufferString(`{"alias": "auth test"}`) }
+
+	tests := []struct {
+		method, url, token string
+		payload            func() io.Reader
+		status             int
+	}{
+		{"GET", infoURL, "", nil, http.StatusOK},
+		{"GET", infoURL, badToken, nil, http.StatusUnauthorized},
+		{"POST", noteURL, "", note, http.StatusUnauthorized},
+		{"POST", noteURL, bobToken, note, http.StatusForbidden},
+		{"POST", noteURL, aliceToken, note, http.StatusOK},
+		{"POST", reloadURL, aliceToken, nil, http.StatusForbidden},
+		{"POST", reloadURL, rootToken, nil, http.StatusOK},
+		{"POST", reposURL, aliceToken, newRepo, http.StatusForbidden},
+		{"POST", reposURL, rootToken, newRepo, http.StatusOK},
+		{"OPTIONS", noteURL, badToken, nil, http.StatusOK},
+	}
+	for i, test := range tests {
+		var payload io.Reader
+		if test.payload != nil {
+			payload = test.payload()
+		}
+		if status := authRequest(t, test.method, test.url, test.token, payload); status != test.status {
+			t.Errorf("test %d: %s %s expected status %d, got %d\n", i, test.method, test.url, test.status, status)
+		}
+	}
+
+	// Datatypes check roles for instances other than the one in the request URL.
+	roleTests := []struct {
+		user     string
+		instance dvid.InstanceName
+		role     string
+		ok       bool
+	}{
+		{"", "rois", "read", true},
+		{"", "rois", "write", false},
+		{"alice", "rois", "write", true},
+		{"alice", "", "write", true},
+		{"bob", "rois", "write", false},
+		{"carol", "rois", "write", true},
+		{"carol", "other", "write", false},
+		{"carol", "", "write", false},
+	}
+	for _, test := range roleTests {
+		if err := CheckRole(test.user, uuid, test.instance, test.role); (err == nil) != test.ok {
+			t.Errorf("user %q %s role on %q: expected ok %t, got error %v\n", test.user, test.role, test.instance, test.ok, err)
+		}
+	}
+
+	// CORS preflight requests must allow the Authorization header.
+	req, err := http.NewRequest("OPTIONS", noteURL, nil)
+	if err != nil {
+		t.Fatalf("unable to create OPTIONS request: %v\n", err)
+	}
+	resp := httptest.NewRecorder()
+	ServeSingleHTTP(resp, req)
+	if allowed := resp.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, "Authorization") {
+		t.Errorf("expected preflight to allow Authorization header, got %q\n", allowed)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/janelia-flyem/dvid/datastore"
 	"github.com/janelia-flyem/dvid/dvid"
@@ -25,6 +26,19 @@ Commands executed on the server (rpc address = %s):
 	help
 	shutdown
 
+	backup <target directory>
+
+		Starts an online backup of the metadata store and all other stores into the
+		given server directory, which must not exist or be empty.  Each store is
+		snapshotted in turn when the command is received while the server continues to
+		handle requests, so stop mutations first for a backup consistent across stores.
+		Restore with the offline "dvid restore" command.  See the HTTP API for
+		/api/server/backup for details.
+
+	backup status
+
+		Prints the progress of the current or last backup.
+
 	repos new  <alias> <description> <settings...>
 		where <settings> are optional "key=value" strings:
 		
@@ -49,6 +63,15 @@ Commands executed on the server (rpc address = %s):
 	
 	repo <UUID> rename <old data name> <new data name> <repo passcode if any>
 
+	repo <UUID> scrub [data name]
+
+		Starts a background scrub that reads all versions of all key-value pairs of the
+		named data instance, or all data instances in the repo if no name is given, and
+		verifies checksums and datatype-specific invariants.  Only labelmap and imageblk
+		instances can be verified and others are listed as skipped.  Damaged keys are
+		written to the log and the full report is available via GET /api/repo/{uuid}/scrub.
+		The scrub runs as a job that can be canceled via the /api/jobs HTTP endpoints.
+
 	node <UUID> <data name> <type-specific commands>
 
 DANGEROUS COMMANDS (only available via command line)
@@ -66,10 +89,42 @@ DANGEROUS COMMANDS (only available via command line)
 		Deletes a class of type-specific keys for the given data instance.
 		If "true", all versions are deleted for that class of keys, else if
 		"false" only the version corresponding to the given UUID is deleted.
+		The deletion runs as a job that can't be canceled.
+
+	gc <settings...>
+
+		Garbage collects key-value pairs in all stores that belong to deleted or
+		unknown data instances, or to versions no longer in any repo, e.g., after
+		"repo limit-versions".  Deletions are done in throttled batches and progress
+		is checkpointed in the metadata store, so an interrupted garbage collection
+		resumes on the next "gc" command or server restart.  It runs as a job that can
+		be canceled via the /api/jobs HTTP endpoints, after which it only resumes on
+		the next "gc" command.  Settings are optional "key=value" strings:
+
+		dryrun=[false | true]
+
+			If true, only reports the number of garbage keys and the approximate
+			size of unreferenced instances for stores that can estimate sizes.
+
+		batch=<number of keys>
+
+			Number of keys deleted before pausing.  The default is 1000.
+
+		pause=<milliseconds>
+
+			Pause between batches of deletions.  The default is 100 ms.
+
+	gc status
+
+		Prints the progress of the current or last garbage collection.
 
 
 EXPERIMENTAL COMMANDS
 
+	The storage-details, migrate, transfer-data, squash, copy, push, and pull commands
+	run as jobs whose progress can be checked and which can be canceled via the
+	/api/jobs HTTP endpoints.  A squash can't be canceled once it starts rewriting data.
+
 	repo <UUID> storage-details
 
 		Print information on leaf/interior nodes.
@@ -137,6 +192,22 @@ EXPERIMENTAL COMMANDS
 				...
 			}
 		}
+
+	repo <UUID> squash <from UUID> <to UUID>
+
+		Squashes a linear chain of committed versions, from an ancestor node through a 
+		descendant node, into the ancestor node.  The ancestor node will hold the data 
+		visible at the descendant, children of the descendant become children of the
+		ancestor, and the other nodes in the chain are removed.  All nodes in the chain
+		except the descendant must have exactly one child and must not be tagged.  Any
+		tags of the descendant are moved to the ancestor.  Mutation logs of the removed
+		nodes, e.g., labelmap merges and splits, are appended to the ancestor's log.
+		Squashing runs in the background as a job whose progress is available via
+		GET /api/jobs and which can be canceled until it starts rewriting key-values.
+
+		Since data instances may cache version-specific data, the server should be restarted
+		after a squash.  To keep a production server online, squash a copy of its stores
+		made with "transfer-data" and then switch to the copy.
 					
 	repo <UUID> copy <source instance name> <clone instance name> <settings...>
     
@@ -176,6 +247,10 @@ EXPERIMENTAL COMMANDS
 
 			The default transmit "all" sends all versions necessary to 
 			make the remote equivalent or a superset of the local repo.
+			If the remote already has an older copy of the repo, only the
+			key-values stored at versions it lacks are sent and the bytes
+			skipped are logged.  The push is refused if a pushed version is
+			uncommitted at the remote, since its data would be overwritten.
 			
 			A transmit "flatten" will send just the version specified and
 			flatten the key/values so there is no history.
@@ -183,6 +258,23 @@ EXPERIMENTAL COMMANDS
 			A transmit "branch" will send just the ancestor path of the
 			version specified.
 
+		The remote only adds the pushed versions after all data has been sent.
+		If the push fails or is canceled, the remote discards the repo and its
+		garbage collection removes any data already received.
+
+	repo pull <remote DVID HTTP address> <UUID> <settings...>
+
+		Replicates a repo from a remote DVID server, e.g., when the remote can't
+		reach this server to push.  The transfer is initiated by this server over
+		HTTP using the remote's /api/repo/{uuid}/pull endpoints.  The optional 
+		"data", "filter", and "transmit" settings are the same as for push, and
+		an optional "token=<bearer token>" is sent if the remote requires
+		authentication.
+
+		If a pull is interrupted or its job is canceled, running the same command
+		resumes it with the original settings, skipping data instances that were
+		completely received.
+
 	repo <UUID> merge <UUID> [, <UUID>, ...]
 
 		This requires all UUIDs to be committed and generates a new
@@ -211,7 +303,7 @@ func init() {
 
 // SendRPC sends a request to a remote DVID.
 func SendRPC(addr string, req datastore.Request) error {
-	c := gorpc.NewTCPClient(addr)
+	c := rpc.NewClient(addr)
 	c.Start()
 	defer c.Stop()
 
@@ -251,6 +343,66 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 		// launch goroutine shutdown so we can concurrently return shutdown message to client.
 		go Shutdown()
 
+	case "backup":
+		var target string
+		cmd.CommandArgs(1, &target)
+		if target == "status" {
+			status := storage.GetBackupStatus()
+			if status == nil {
+				reply.Text = "No backup has been started since this server started.\n"
+				return
+			}
+			reply.Text = backupStatusText(status)
+			return
+		}
+		var status *storage.BackupStatus
+		if status, err = storage.StartBackup(target); err != nil {
+			return
+		}
+		reply.Text = fmt.Sprintf("Started backup of %d stores to %s.  Use 'dvid backup status' for progress.\n", len(status.Stores), status.Target)
+
+	case "gc":
+		if cmd.Argument(1) == "status" {
+			report := datastore.GetGCReport()
+			if report == nil {
+				reply.Text = "No garbage collection has been run since this server started.\n"
+				return
+			}
+			reply.Text = gcReportText(report)
+			return
+		}
+		config := datastore.GCConfig{
+			BatchSize: datastore.DefaultGCBatchSize,
+			Pause:     datastore.DefaultGCPause,
+		}
+		settings := cmd.Settings()
+		if config.DryRun, _, err = settings.GetBool("dryrun"); err != nil {
+			return
+		}
+		var batchSize, pauseMs int
+		var found bool
+		if batchSize, found, err = settings.GetInt("batch"); err != nil {
+			return
+		}
+		if found {
+			config.BatchSize = batchSize
+		}
+		if pauseMs, found, err = settings.GetInt("pause"); err != nil {
+			return
+		}
+		if found {
+			config.Pause = time.Duration(pauseMs) * time.Millisecond
+		}
+		var report *datastore.GCReport
+		if report, err = datastore.StartGC(config, cmd.User); err != nil {
+			return
+		}
+		action := "Started"
+		if report.Resumed {
+			action = "Resumed"
+		}
+		reply.Text = fmt.Sprintf("%s garbage collection (dry run %t) of %d stores as job %d.  Use 'dvid gc status' for progress.\n", action, config.DryRun, len(report.Stores), report.Job)
+
 	case "types":
 		if len(cmd.Command) == 1 {
 			text := "\nData Types within this DVID Server\n"
@@ -343,6 +495,21 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 	case "repo":
 		var uuidStr, subcommand string
 		cmd.CommandArgs(1, &uuidStr, &subcommand)
+		if uuidStr == "pull" {
+			var remote, remoteUUID string
+			cmd.CommandArgs(2, &remote, &remoteUUID)
+			if remote == "" || remoteUUID == "" {
+				err = fmt.Errorf("pull requires a remote address and UUID")
+				return
+			}
+			config := cmd.Settings()
+			job := dvid.NewJob("pull", cmd.User, dvid.UUID(remoteUUID), "")
+			go func() {
+				job.Finish(datastore.PullRepo(remote, dvid.UUID(remoteUUID), config, job))
+			}()
+			reply.Text = fmt.Sprintf("Started pull of repo %s from %q as job %d...\n", remoteUUID, remote, job.ID())
+			return
+		}
 		var uuid dvid.UUID
 		var v dvid.VersionID
 		if uuid, v, err = datastore.MatchingUUID(uuidStr); err != nil {
@@ -407,6 +574,19 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 			}
 			reply.Text = fmt.Sprintf("Renamed data instance %q to %q from DAG subgraph @ root %s\n", oldname, newname, uuid)
 
+		case "scrub":
+			var dataname string
+			cmd.CommandArgs(3, &dataname)
+			var report *datastore.ScrubReport
+			if report, err = datastore.StartScrub(uuid, dvid.InstanceName(dataname), cmd.User); err != nil {
+				return
+			}
+			reply.Text = fmt.Sprintf("Started scrub of %d data instances in repo with UUID %s as job %d.  Check log or GET /api/repo/%s/scrub for report.\n",
+				len(report.Instances)-len(report.Skipped), uuid, report.Job, uuid)
+			if len(report.Skipped) != 0 {
+				reply.Text += fmt.Sprintf("Skipped data instances whose datatypes can't be verified: %v\n", report.Skipped)
+			}
+
 		case "branch":
 			var branchname string
 			cmd.CommandArgs(3, &branchname, &uuidStr)
@@ -466,13 +646,12 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 			datastore.AddToRepoLog(uuid, []string{cmd.String()})
 
 		case "storage-details":
+			job := dvid.NewJob("storage-details", cmd.User, uuid, "")
 			go func() {
-				_, err := datastore.GetStorageDetails()
-				if err != nil {
-					dvid.Errorf("storage-details: %v\n", err)
-				}
+				_, err := datastore.GetStorageDetails(job)
+				job.Finish(err)
 			}()
-			reply.Text = "Started storage details dump in log..."
+			reply.Text = fmt.Sprintf("Started storage details dump in log as job %d...\n", job.ID())
 
 		case "flatten-mutations":
 			var dataStr, filename string
@@ -504,23 +683,21 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 				return
 			}
 			config := cmd.Settings()
+			job := dvid.NewJob("migrate", cmd.User, uuid, dvid.InstanceName(source))
 			go func() {
-				if err = datastore.MigrateInstance(uuid, dvid.InstanceName(source), srcStore, dstStore, config); err != nil {
-					dvid.Errorf("migrate error: %v\n", err)
-				}
+				job.Finish(datastore.MigrateInstance(uuid, dvid.InstanceName(source), srcStore, dstStore, config, job))
 			}()
-			reply.Text = fmt.Sprintf("Started migration of uuid %s data instance %q from store %q to %q\n", uuid, source, srcStoreName, dstStoreName)
+			reply.Text = fmt.Sprintf("Started migration of uuid %s data instance %q from store %q to %q as job %d\n", uuid, source, srcStoreName, dstStoreName, job.ID())
 
 		case "copy":
 			var source, target string
 			cmd.CommandArgs(3, &source, &target)
 			config := cmd.Settings()
+			job := dvid.NewJob("copy", cmd.User, uuid, dvid.InstanceName(source))
 			go func() {
-				if err = datastore.CopyInstance(uuid, dvid.InstanceName(source), dvid.InstanceName(target), config); err != nil {
-					dvid.Errorf("copy error: %v\n", err)
-				}
+				job.Finish(datastore.CopyInstance(uuid, dvid.InstanceName(source), dvid.InstanceName(target), config, job))
 			}()
-			reply.Text = fmt.Sprintf("Started copy of uuid %s data instance %q to %q...\n", uuid, source, target)
+			reply.Text = fmt.Sprintf("Started copy of uuid %s data instance %q to %q as job %d...\n", uuid, source, target, job.ID())
 
 		case "transfer-data":
 			var oldStoreName, dstStoreName, configFName string
@@ -534,12 +711,11 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 			if err != nil {
 				return
 			}
+			job := dvid.NewJob("transfer-data", cmd.User, uuid, "")
 			go func() {
-				if err = datastore.TransferData(uuid, srcStore, dstStore, configFName); err != nil {
-					dvid.Errorf("transfer-data error: %v\n", err)
-				}
+				job.Finish(datastore.TransferData(uuid, srcStore, dstStore, configFName, job))
 			}()
-			reply.Text = fmt.Sprintf("Started data transfer of repo %s from store %q to %q\n", uuid, oldStoreName, dstStoreName)
+			reply.Text = fmt.Sprintf("Started data transfer of repo %s from store %q to %q as job %d\n", uuid, oldStoreName, dstStoreName, job.ID())
 
 		case "limit-versions":
 			var configFName string
@@ -549,27 +725,31 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 			}
 			reply.Text = fmt.Sprintf("Limited metadata versions for repo %s\n", uuid)
 
+		case "squash":
+			var fromStr, toStr string
+			cmd.CommandArgs(3, &fromStr, &toStr)
+			var from, to dvid.UUID
+			if from, _, err = datastore.MatchingUUID(fromStr); err != nil {
+				return
+			}
+			if to, _, err = datastore.MatchingUUID(toStr); err != nil {
+				return
+			}
+			job := dvid.NewJob("squash", cmd.User, uuid, "")
+			go func() {
+				job.Finish(datastore.Squash(uuid, from, to, job))
+			}()
+			reply.Text = fmt.Sprintf("Started squash of nodes %s through %s in repo %s as job %d\n", from, to, uuid, job.ID())
+
 		case "push":
 			var target string
 			cmd.CommandArgs(3, &target)
 			config := cmd.Settings()
+			job := dvid.NewJob("push", cmd.User, uuid, "")
 			go func() {
-				if err = datastore.PushRepo(uuid, target, config); err != nil {
-					dvid.Errorf("push error: %v\n", err)
-				}
+				job.Finish(datastore.PushRepo(uuid, target, config, job))
 			}()
-			reply.Text = fmt.Sprintf("Started push of repo %s to %q...\n", uuid, target)
-
-			/*
-				case "pull":
-					var target string
-					cmd.CommandArgs(3, &target)
-					config := cmd.Settings()
-					if err = datastore.Pull(uuid, target, config); err != nil {
-						return
-					}
-					reply.Text = fmt.Sprintf("Repo %s pulled from %q\n", uuid, target)
-			*/
+			reply.Text = fmt.Sprintf("Started push of repo %s to %q as job %d...\n", uuid, target, job.ID())
 
 		case "delete":
 			// Apply a global lock (if relevant) and reloads meta
@@ -627,13 +807,14 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 				reply.Text = fmt.Sprintf("The data instance %q does not support type-specific key class deletions\n", dataname)
 				return
 			}
+			// The store's deletion of a key class can't be interrupted.
+			job := dvid.NewJob("delete-class", cmd.User, uuid, dvid.InstanceName(dataname))
+			job.DisableCancel()
 			go func() {
 				ctx := datastore.NewVersionedCtx(d, v)
-				if err = deleter.DeleteTKeyClass(ctx, tkclass, allVersions); err != nil {
-					return
-				}
+				job.Finish(deleter.DeleteTKeyClass(ctx, tkclass, allVersions))
 			}()
-			reply.Text = fmt.Sprintf("Started deletion of type-specific key class %d for data instance %q, version %s (all versions = %t)\n", tkclass, dataname, uuid, allVersions)
+			reply.Text = fmt.Sprintf("Started deletion of type-specific key class %d for data instance %q, version %s (all versions = %t) as job %d\n", tkclass, dataname, uuid, allVersions, job.ID())
 
 		default:
 			err = fmt.Errorf("Unknown command: %q", cmd)
@@ -677,3 +858,54 @@ func handleCommand(cmd *datastore.Request) (reply *datastore.Response, err error
 	}
 	return
 }
+
+// backupStatusText returns a human-readable description of backup progress.
+func backupStatusText(status *storage.BackupStatus) string {
+	text := fmt.Sprintf("Backup to %s started %s\n", status.Target, status.Started.Format(time.RFC3339))
+	for _, s := range status.Stores {
+		switch {
+		case s.Skipped != "":
+			text += fmt.Sprintf("  %-20s skipped: %s\n", s.Alias, s.Skipped)
+		case s.Done:
+			text += fmt.Sprintf("  %-20s done: %d key-values, %d bytes\n", s.Alias, s.KeyValues, s.Bytes)
+		default:
+			text += fmt.Sprintf("  %-20s %d key-values, %d bytes so far\n", s.Alias, s.KeyValues, s.Bytes)
+		}
+	}
+	switch {
+	case status.Running:
+		text += "Backup is still running.\n"
+	case status.Error != "":
+		text += fmt.Sprintf("Backup failed: %s\n", status.Error)
+	default:
+		text += fmt.Sprintf("Backup completed %s\n", status.Finished.Format(time.RFC3339))
+	}
+	return text
+}
+
+// gcReportText returns a human-readable description of garbage collection progress.
+func gcReportText(report *datastore.GCReport) string {
+	text := fmt.Sprintf("Garbage collection (dry run %t) started %s\n", report.Config.DryRun, report.Started.Format(time.RFC3339))
+	for _, s := range report.Stores {
+		text += fmt.Sprintf("  %-20s scanned %d keys: %d from removed instances, %d from removed versions, %d deleted",
+			s.Alias, s.KeysScanned, s.InstanceKeys, s.VersionKeys, s.Deleted)
+		if s.Done {
+			text += " (done)"
+		}
+		text += "\n"
+		for id, numBytes := range s.InstanceBytes {
+			text += fmt.Sprintf("    instance %d: approximately %d bytes\n", id, numBytes)
+		}
+	}
+	switch {
+	case report.Running:
+		text += fmt.Sprintf("Garbage collection is still running as job %d.\n", report.Job)
+	case report.Canceled:
+		text += fmt.Sprintf("Garbage collection was canceled %s and resumes with the next 'dvid gc'.\n", report.Finished.Format(time.RFC3339))
+	case report.Error != "":
+		text += fmt.Sprintf("Garbage collection failed: %s\n", report.Error)
+	default:
+		text += fmt.Sprintf("Garbage collection completed %s\n", report.Finished.Format(time.RFC3339))
+	}
+	return text
+}
@@ -10,6 +10,7 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -42,6 +43,9 @@ var (
 	DefaultHost = "localhost"
 
 	tc tomlConfig
+
+	// serverTLS is the TLS configuration for the HTTP and RPC listeners or nil if not used.
+	serverTLS *tls.Config
 )
 
 func init() {
@@ -113,6 +117,11 @@ func CloseTest() {
 func Initialize() error {
 	tc.Logging.SetLogger()
 
+	var err error
+	if serverTLS, err = tlsConfig(); err != nil {
+		return err
+	}
+
 	if err := tc.Kafka.Initialize(WebServer()); err != nil {
 		return err
 	}
@@ -175,6 +184,7 @@ type tomlConfig struct {
 	Cache      map[string]sizeConfig
 	Groupcache storage.GroupcacheConfig
 	Mirror     map[dvid.DataSpecifier]mirrorConfig
+	Auth       AuthConfig
 }
 
 // Some settings in the TOML can be given as relative paths.
@@ -197,6 +207,24 @@ func (c *tomlConfig) convertPathsToAbsolute(configPath string) error {
 		return fmt.Errorf("Error converting logfile setting to absolute path")
 	}
 
+	// [server].tlsCertFile, tlsKeyFile, and tlsClientCAFile
+	for _, p := range []*string{&c.Server.TLSCertFile, &c.Server.TLSKeyFile, &c.Server.TLSClientCAFile} {
+		if *p == "" {
+			continue
+		}
+		if *p, err = dvid.ConvertToAbsolute(*p, configDir); err != nil {
+			return fmt.Errorf("Error converting TLS file setting to absolute path")
+		}
+	}
+
+	// [auth].keyfile
+	if c.Auth.KeyFile != "" {
+		c.Auth.KeyFile, err = dvid.ConvertToAbsolute(c.Auth.KeyFile, configDir)
+		if err != nil {
+			return fmt.Errorf("Error converting auth keyfile setting to absolute path")
+		}
+	}
+
 	// [store.foobar].path
 	for alias, sc := range c.Store {
 		p, ok := sc["path"]
@@ -271,6 +299,31 @@ func WebDefaultFile() string {
 	return tc.Server.WebDefaultFile
 }
 
+// ExportPath resolves a client-supplied relative path for HTTP array exports, imports, and backups
+// under the configured export root.  Absolute paths and paths containing ".." are rejected,
+// and an error is returned if no export root is configured.
+func ExportPath(path string) (string, error) {
+	root := tc.Server.ExportRoot
+	if root == "" {
+		return "", fmt.Errorf("exports and imports over HTTP require an exportRoot in the server configuration")
+	}
+	if path == "" || filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %q must be relative to the server's export root", path)
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("path %q must not contain \"..\"", path)
+		}
+	}
+	return filepath.Join(root, filepath.Clean(path)), nil
+}
+
+// SetExportRoot sets the directory under which HTTP array exports, imports, and backups
+// are allowed.
+func SetExportRoot(dir string) {
+	tc.Server.ExportRoot = dir
+}
+
 func AllowTiming() bool {
 	return tc.Server.AllowTiming
 }
@@ -368,6 +421,12 @@ type localConfig struct {
 
 	InteractiveOpsBeforeBlock int // # of interactive ops in 2 min period before batch processing is blocked.  Zero value = no blocking.
 	ShutdownDelay             int // seconds to delay after receiving shutdown request to let HTTP requests drain.
+
+	ExportRoot string // Directory under which HTTP array exports, imports, and backups are allowed.  Empty disables them.
+
+	TLSCertFile     string // If set with TLSKeyFile, HTTP and RPC listeners use TLS.
+	TLSKeyFile      string
+	TLSClientCAFile string // If set, clients must present certificates signed by these CAs.
 }
 
 // DatastoreConfig returns data instance configuration necessary to
@@ -413,6 +472,9 @@ func LoadConfig(filename string) error {
 	if tc.Email.IsAvailable() {
 		dvid.SetEmailServer(tc.Email)
 	}
+	if err := SetAuthConfig(tc.Auth); err != nil {
+		return fmt.Errorf("bad auth configuration: %v", err)
+	}
 	return nil
 }
 
@@ -493,15 +555,24 @@ func Serve() {
 	dvid.TimeInfof("DVID code version: %s\n", gitVersion)
 	dvid.TimeInfof("Serving HTTP on %s (host alias %q)\n", tc.Server.HTTPAddress, tc.Server.Host)
 	dvid.TimeInfof("Serving command-line use via RPC %s\n", tc.Server.RPCAddress)
+	if serverTLS != nil {
+		dvid.TimeInfof("Using TLS for HTTP and RPC with certificate %s\n", tc.Server.TLSCertFile)
+	}
 	dvid.TimeInfof("Using web client files from %s\n", tc.Server.WebClient)
 	dvid.TimeInfof("Using %d of %d logical CPUs for DVID.\n", dvid.NumCPU, runtime.NumCPU())
 
 	// Launch the web server
-	go serveHTTP()
+	go serveHTTP(serverTLS)
 
 	// Launch the rpc server
 	go func() {
-		if err := rpc.StartServer(tc.Server.RPCAddress); err != nil {
+		var err error
+		if serverTLS != nil {
+			err = rpc.StartTLSServer(tc.Server.RPCAddress, serverTLS)
+		} else {
+			err = rpc.StartServer(tc.Server.RPCAddress)
+		}
+		if err != nil {
 			dvid.Criticalf("Could not start RPC server: %v\n", err)
 		}
 	}()
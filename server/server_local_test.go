@@ -212,3 +212,29 @@ func TestTOMLConfigAbsolutePath(t *testing.T) {
 		t.Errorf("[store.bar].path was already absolute and should have been left unchanged: %s", path)
 	}
 }
+
+func TestExportPath(t *testing.T) {
+	saved := tc.Server.ExportRoot
+	defer func() {
+		tc.Server.ExportRoot = saved
+	}()
+
+	tc.Server.ExportRoot = ""
+	if _, err := ExportPath("vol.zarr"); err == nil {
+		t.Errorf("expected error on export path without export root\n")
+	}
+
+	tc.Server.ExportRoot = "/exports"
+	path, err := ExportPath("team/vol.zarr")
+	if err != nil {
+		t.Fatalf("unexpected error on export path: %v\n", err)
+	}
+	if path != "/exports/team/vol.zarr" {
+		t.Errorf("expected export path /exports/team/vol.zarr, got %s\n", path)
+	}
+	for _, bad := range []string{"", "/etc/passwd", "../vol.zarr", "team/../../vol.zarr"} {
+		if _, err := ExportPath(bad); err == nil {
+			t.Errorf("expected error on export path %q\n", bad)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// +build !clustered,!gcloud
+
+/*
+	This file supports TLS for the HTTP and RPC listeners, including optional client
+	certificate verification and reloading of certificates on SIGHUP.
+*/
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/rpc"
+)
+
+// certReloader holds the server certificate and any client CA certificates, which
+// can be reloaded from their files while the server is running.
+type certReloader struct {
+	certFile, keyFile, clientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("TLS requires both tlsCertFile and tlsKeyFile to be set")
+	}
+	cr := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// load reads the certificate files, keeping the current certificates if there
+// is any error.
+func (cr *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load TLS certificate: %v", err)
+	}
+	var pool *x509.CertPool
+	if cr.clientCAFile != "" {
+		if pool, err = rpc.LoadCertPool(cr.clientCAFile); err != nil {
+			return err
+		}
+	}
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.clientCAs = pool
+	cr.mu.Unlock()
+	return nil
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// getConfigForClient returns a configuration using the current certificates so
+// reloaded client CAs take effect on new connections.
+func (cr *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{*cr.cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"http/1.1"},
+	}
+	if cr.clientCAs != nil {
+		cfg.ClientCAs = cr.clientCAs
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// serverConfig returns a TLS configuration for listeners.
+func (cr *certReloader) serverConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate:     cr.getCertificate,
+		GetConfigForClient: cr.getConfigForClient,
+		MinVersion:         tls.VersionTLS12,
+	}
+}
+
+// reloadOnHangup reloads the certificates whenever the process receives SIGHUP.
+func (cr *certReloader) reloadOnHangup() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := cr.load(); err != nil {
+				dvid.Errorf("Unable to reload TLS certificates on SIGHUP, keeping current ones: %v\n", err)
+			} else {
+				dvid.Infof("Reloaded TLS certificates from %s on SIGHUP.\n", cr.certFile)
+			}
+		}
+	}()
+}
+
+// tlsConfig returns the TLS configuration for the HTTP and RPC listeners or nil if
+// TLS isn't configured.
+func tlsConfig() (*tls.Config, error) {
+	sc := tc.Server
+	if sc.TLSCertFile == "" && sc.TLSKeyFile == "" {
+		if sc.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("tlsClientCAFile requires tlsCertFile and tlsKeyFile to be set")
+		}
+		return nil, nil
+	}
+	cr, err := newCertReloader(sc.TLSCertFile, sc.TLSKeyFile, sc.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cr.reloadOnHangup()
+	return cr.serverConfig(), nil
+}
@@ -0,0 +1,111 @@
+// +build !clustered,!gcloud
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate and key for the given common name.
+func writeTestCert(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v\n", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v\n", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v\n", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("unable to write certificate: %v\n", err)
+	}
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("unable to write key: %v\n", err)
+	}
+}
+
+func TestCertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-tls-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	writeTestCert(t, certFile, keyFile, "first")
+
+	if _, err := newCertReloader(certFile, "", ""); err == nil {
+		t.Errorf("expected error when key file is missing\n")
+	}
+	cr, err := newCertReloader(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatalf("unable to load certificates: %v\n", err)
+	}
+	commonName := func() string {
+		cert, err := cr.getCertificate(nil)
+		if err != nil {
+			t.Fatalf("unable to get certificate: %v\n", err)
+		}
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("unable to parse certificate: %v\n", err)
+		}
+		return parsed.Subject.CommonName
+	}
+	if name := commonName(); name != "first" {
+		t.Errorf("expected certificate for %q, got %q\n", "first", name)
+	}
+	cfg, err := cr.getConfigForClient(nil)
+	if err != nil {
+		t.Fatalf("unable to get client config: %v\n", err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+		t.Errorf("expected client certificates to be required when client CA file is set\n")
+	}
+
+	// Reloading picks up new certificates, and a bad file keeps the current ones.
+	writeTestCert(t, certFile, keyFile, "second")
+	if err := cr.load(); err != nil {
+		t.Fatalf("unable to reload certificates: %v\n", err)
+	}
+	if name := commonName(); name != "second" {
+		t.Errorf("expected reloaded certificate for %q, got %q\n", "second", name)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("unable to write key: %v\n", err)
+	}
+	if err := cr.load(); err == nil {
+		t.Errorf("expected error reloading bad key\n")
+	}
+	if name := commonName(); name != "second" {
+		t.Errorf("expected certificate %q to be kept after failed reload, got %q\n", "second", name)
+	}
+}
@@ -7,6 +7,7 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -19,6 +20,7 @@ import (
 	"runtime"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -72,7 +74,16 @@ const webHelp = `
 /serverhost:someport/api/...
 		</pre>
 		The online documentation doesn't show the server host prefixed to the "/api/..." URL,
-		but it is required.
+		but it is required.</p>
+
+		<p>If the server is configured with an [auth] key file, requests must supply a signed
+		bearer token via an "Authorization: Bearer &lt;token&gt;" header unless the request is
+		allowed anonymously.  GET and HEAD requests need the "read" role for the repo or data
+		instance, other mutations need "write", and DELETE, repo creation, or server-level mutations
+		need "admin" except for node deletion, which needs "write" and either "admin" or the repo
+		passcode.  CORS preflight OPTIONS requests need no token.  Requests with missing or bad
+		tokens return 401 (Unauthorized) and requests from users without the required role return
+		403 (Forbidden).  Any "u" query string is removed and replaced by the authenticated user.</p>
 
 		<h4>General commands</h4>
 
@@ -152,12 +163,96 @@ POST  /api/server/reload-metadata
 	when prompted by an external coordinator, allowing the "slave" DVIDs to see changes made by
 	the master DVID.
 
+POST  /api/server/backup
+
+	Starts an online backup of the metadata store and all other stores to a directory on
+	the server while the server continues to handle requests.  Expects JSON to be posted
+	with the target directory, which must not exist or be empty:
+	{
+		"target": "backups/2026-10-16"
+	}
+
+	The target is relative to the "exportRoot" directory in the server configuration, and
+	backups over HTTP are disabled if no export root is set.  The "dvid backup" command can
+	use any directory on the server.
+
+	Snapshots of all stores are taken before the response.  Each store's snapshot is a
+	consistent copy of that store, but stores are snapshotted one after another without
+	pausing writes, so mutations during the request may be captured in some stores and not
+	others.  For a backup consistent across stores, stop mutations first, e.g., by restarting
+	the server with -readonly.  Each store is then written in the background to a file
+	named <store alias>.dvidkv in the target directory, and a "manifest.json" file describing
+	the backup is written on completion.  Stores with engines that can't provide snapshots
+	are skipped.  Only one backup can run at a time.  Returns the JSON backup status described
+	in the GET below.
+
+	A completed backup is restored with the offline command "dvid restore <backup directory>
+	<configuration path>" into the empty stores of a configuration before serving it.
+
+ GET  /api/server/backup
+
+	Returns JSON for the progress of the current or last backup:
+	{
+		"Target": "/data/exports/backups/2026-10-16",
+		"Started": "2026-10-16T10:11:12.1234-04:00",
+		"Running": true,
+		"Stores": [
+			{
+				"Alias": "raid6",
+				"Store": "basholeveldb @ /data/dbs/basholeveldb",
+				"Metadata": true,
+				"File": "raid6.dvidkv",
+				"KeyValues": 2198000,
+				"Bytes": 1029381920,
+				"Done": false
+			},
+			...
+		]
+	}
+
+	On completion, "Running" is false and "Finished" gives the time of completion.  Any
+	error is given in "Error".
+
 GET /api/server/blobstore/{reference}
    
 	GETs data with the given reference string from this server's blobstore. The blobstore is
 	populated as part of mutation logging and is read-only.  The reference is a URL-friendly 
 	content hash (FNV-128) of the blob data.
 
+ GET  /api/jobs
+
+	Returns a JSON list of running and recently finished background jobs, e.g., pushes, pulls,
+	copies, migrations, imagetile generation, labelmap dumps, and annotation or labelsz reloads:
+
+	[
+		{
+			"ID": 3,
+			"Type": "push",
+			"Owner": "katz",
+			"UUID": "3f8c...",
+			"Started": "2026-10-16T10:11:12.1234-04:00",
+			"Running": true,
+			"Percent": 42.5
+		},
+		...
+	]
+
+	"Owner" is the authenticated user for HTTP requests or the user reported by the "dvid"
+	command line client.  "Instance" is given for jobs on a single data 
+	instance.  "Percent" is an estimate that is only updated by jobs that can measure their 
+	progress.  Finished jobs have "Finished" and any "Error", and canceled jobs have "Canceled".
+	The last 100 finished jobs are kept.
+
+ GET  /api/jobs/{id}
+
+	Returns the JSON status of the given job in the format above.
+
+ DELETE  /api/jobs/{id}
+
+	Cancels a running job, which stops at its next check for cancellation.  Requires the
+	admin role if authentication is enabled.  Jobs that can no longer stop safely, e.g.,
+	reloads that have deleted the prior denormalizations, refuse the cancel with an error.
+
 -------------------------
 Memory Profiler endpoints
 -------------------------
@@ -189,7 +284,7 @@ Repo-Level REST endpoints
 	Creates a new repository.  Expects configuration data in JSON as the body of the POST.
 	Configuration is a JSON object with optional "alias", "description", "root" (the desired
 	UUID of the root), and "passcode" properties. Returns the root UUID of the newly created 
-	repo in JSON object: {"root": uuid}
+	repo in JSON object: {"root": uuid}.  Requires the admin role if authentication is enabled.
 
  GET  /api/repos/info
 
@@ -203,6 +298,7 @@ Repo-Level REST endpoints
 
 	Returns JSON for just the repository with given root UUID.  The UUID string can be
 	shortened as long as it is uniquely identifiable across the managed repositories.
+	The "Tags" property maps each tag name in the repo to its tagged UUID.
 
  POST /api/repo/{uuid}/instance
 
@@ -254,11 +350,25 @@ Repo-Level REST endpoints
 
  POST /api/repo/{uuid}/merge
 
-	Creates a conflict-free merge of a set of committed parent UUIDs into a child.  Note
-	the merge will not necessarily create an error immediately, but later GETs that
+	Creates a merge of a set of committed parent UUIDs into a child.  For a conflict-free
+	merge, the merge will not necessarily create an error immediately, but later GETs that
 	detect conflicts will produce an error at that time.  These can be resolved by
 	doing a POST on the "resolve" endpoint below.
 
+	An "auto" merge scans all data instances for keys modified in more than one parent
+	and lets each data instance resolve the conflicting key-values in a type-specific
+	way.  If any data instance with conflicts does not support type-specific merging,
+	no child is created and the error lists the conflicting keys.  If a data instance
+	fails while resolving its conflicts, the child and any key-values already written
+	into it are removed.
+
+	An "external" merge resolves conflicting keys using the "resolutions" list in the
+	POSTed JSON, which states for each data instance and key range which parent wins, or
+	gives an explicit replacement value for a key.  If any conflicting key is not covered
+	by a resolution, no child is created and the error lists the unresolved keys.  The
+	resolved key-values for each data instance are written into the child in one batch,
+	and if any instance fails, the child and its key-values are removed.
+
 	The post body should be JSON of the following format: 
 
 	{ 
@@ -269,9 +379,35 @@ Repo-Level REST endpoints
 
 	The elements of the JSON object are:
 
-		mergeType:  must be "conflict-free".  We will introduce other merge options in future.
-		parents:    a list of the parent UUIDs to be merged. 
+		mergeType:  one of "conflict-free", "auto", or "external".
+		parents:    a list of the parent UUIDs to be merged.  For "auto" merges, earlier
+					 parents take precedence when a datatype resolves conflicts by priority.
 		note:       any note that should be set for the child version.
+		resolutions: (only for "external" merges) a list of resolutions of the form:
+
+			{
+				"data": "instance-name",
+				"keyBeg": "b10100",         // hex-encoded key as in merge-conflicts
+				"keyEnd": "b101ff",         // empty keyBeg or keyEnd is unbounded
+				"parent": "parent-uuid2"    // parent whose value wins
+			}
+
+			or for a single key:
+
+			{
+				"data": "instance-name",
+				"key": "b101666f6f00",
+				"value": "aGVsbG8=",        // base64-encoded value
+				-- OR --
+				"delete": true,
+				-- OR --
+				"parent": "parent-uuid1"
+			}
+
+			The first resolution whose key range includes a conflicting key is used.
+			Explicit values and deletions are applied even if the key is not conflicting.
+			Values are given as a client would write them to the datatype, e.g., the raw
+			value for a keyvalue key, and are serialized by the server before storage.
 
 	A JSON response will be sent with the following format:
 
@@ -279,6 +415,117 @@ Repo-Level REST endpoints
 
 	The response includes the UUID of the new merged, child node.
 
+ GET /api/repo/{uuid}/merge-conflicts?parents=uuid1,uuid2[,...]
+
+	Performs a dry run of a merge of the given parents by scanning the key-values of each 
+	data instance, and returns the keys that were modified in more than one parent.  All
+	parents must be in the repo given by {uuid}.  No child node is created.  The returned JSON lists conflicting keys per data instance
+	and per type-specific key class (TKeyClass):
+
+	{
+		"instance-name": [
+			{
+				"class": 177,
+				"description": "keyvalue generic key",
+				"keys": [ "b101666f6f00", ... ]
+			}, ...
+		], ...
+	}
+
+	Keys are hex-encoded type-specific keys.  Data instances without conflicts are not
+	included.
+
+	Query-string Options:
+
+	parents       Comma-separated list of the parent UUIDs to be merged.
+
+ GET /api/repo/{uuid}/diff?from=uuid1&to=uuid2[&data=name]
+
+	Returns the key-values added, modified, or deleted between any two versions of the 
+	repo, where "from" and "to" can be any nodes in the DAG.  A key is considered modified
+	if it is visible in both versions but was written in different versions.  The returned 
+	JSON has a summary for each data instance:
+
+	{
+		"instance-name": { "added": [...], "modified": [...], "deleted": [...] }, ...
+	}
+
+	By default, the lists hold hex-encoded type-specific keys, although datatypes can 
+	provide more useful summaries:
+
+	keyvalue:    The lists hold key strings.
+	labelmap:    { "labels": { "added": [...], "modified": [...], "deleted": [...] },
+	               "blocks": { "<scale>": [[x,y,z], ...], ... }, "otherKeys": 3 }
+	             where labels are those with changed label indices and blocks are the
+	             coordinates of changed blocks at each scale.
+	annotation:  The "added" and "deleted" lists hold elements, while "modified" holds
+	             objects of the form { "from": <element>, "to": <element> }.  In addition,
+	             "labels" and "tags" map each changed label or tag to the positions of
+	             elements added to or removed from it:
+	             { "labels": { "23": { "added": [[x,y,z], ...], "removed": [...] } },
+	               "tags": { "synapse": { "added": [...], "removed": [...] } } }
+
+	Query-string Options:
+
+	from          UUID of the starting version.
+	to            UUID of the ending version.
+	data          (optional) Restricts the diff to the named data instance.
+
+ POST /api/repo/{uuid}/scrub[?data=name]
+
+	Starts a background scrub of the repo's data instances, which reads every version of
+	every stored key-value pair and verifies checksums and datatype-specific invariants,
+	e.g., that labelmap blocks and label indices can be decoded and imageblk blocks have
+	the expected size.  If "data" is given, only the named data instance is scrubbed.
+	Only labelmap and imageblk instances can currently be verified.  Instances of other
+	datatypes are not read, are listed as unsupported, and their names are given in the
+	"Skipped" list of the report.  Returns the initial scrub report as described below.
+	Only one scrub can run at a time per repo.  The scrub runs as a job given by "Job"
+	in the report, which can be canceled via DELETE /api/jobs/{id}.
+
+ GET /api/repo/{uuid}/scrub
+
+	Returns the JSON report of the current or last scrub of the repo:
+
+	{
+		"UUID": "3f8c...",
+		"Started": "2018-03-05T16:41:23.53-05:00",
+		"Finished": "2018-03-05T17:02:11.10-05:00",
+		"Running": false,
+		"Skipped": ["bookmarks"],
+		"Instances": [
+			{
+				"Name": "segmentation",
+				"TypeName": "labelmap",
+				"Supported": true,
+				"KeysScanned": 1234567,
+				"NumDamaged": 1,
+				"Damaged": [
+					{
+						"Key": "ba00000000...",
+						"UUID": "9a6b...",
+						"Position": "block (12,3,45) scale 0",
+						"Error": "bad checksum"
+					}
+				],
+				"Done": true
+			}, ...
+		],
+		"Job": 12
+	}
+
+	At most 10000 damaged keys are listed for each data instance although "NumDamaged"
+	gives the total.  Damaged keys are also written to the server log.
+
+ GET /api/repo/{uuid}/pull[?data=...&transmit=...]
+ GET /api/repo/{uuid}/pull/{data name}?versions=...[&filter=...&transmit=...]
+
+	Used by a remote DVID server to replicate this repo via the "repo pull" command.  The 
+	first request returns the gob-encoded repo metadata customized by the "data" and 
+	"transmit" settings.  The second streams the gob-encoded key-value pairs of a data 
+	instance at the given comma-separated version ids, applying any datatype-specific 
+	"filter" as in a push.  See "dvid about" for the settings.
+
  POST /api/repo/{uuid}/resolve
 
 	Forces a merge of a set of committed parent UUIDs into a child by specifying a
@@ -317,6 +564,9 @@ Repo-Level REST endpoints
 Node-Level REST endpoints
 -------------------------
 
+Any {uuid} in a /api/node/{uuid}/... endpoint can be given as ":tagname" to use the node
+with that tag (see POST /api/node/{uuid}/tag).
+
   GET /api/node/{uuid}/note
  POST /api/node/{uuid}/note
 
@@ -357,6 +607,42 @@ Node-Level REST endpoints
 	}
 
 
+ POST /api/node/{uuid}/tag
+
+	Adds a tag to the committed node with given UUID.  The post body should be JSON of the 
+	following format:
+
+	{ "tag": "release-2026-09" }
+
+	Tags are immutable, unique across all repos, and must start with a letter or digit followed
+	by letters, digits, '.', '_', or '-'.  A node can have more than one tag.  Once tagged, the
+	node can be specified as ":release-2026-09" wherever a UUID is expected, e.g., 
+	/api/node/:release-2026-09/grayscale/info.
+
+	A JSON message will be sent to any associated Kafka system with the following format:
+	{ 
+		"Action": "nodetag",
+		"UUID": <UUID>,
+		"Tag": <tag>
+	}
+
+DELETE /api/node/{uuid}
+
+	Deletes an uncommitted leaf node, i.e., an unlocked node without children, and all 
+	key-values stored for its version in every data instance.  The root node of a repo
+	can't be deleted.  The repo passcode, if any, must be given unless the user has the
+	"admin" role.  The passcode is sent as JSON in the request body and is not accepted
+	in the query string, where it would be written to logs:
+	{
+		"passcode": "mypasscode"
+	}
+
+	A JSON message will be sent to any associated Kafka system with the following format:
+	{ 
+		"Action": "deletenode",
+		"UUID": <UUID>
+	}
+
  GET /api/node/{uuid}/status
 
 	Returns the commit or lock status of the node with given UUID in JSON format:
@@ -555,10 +841,11 @@ func ServeSingleHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // Listen and serve HTTP requests using address and don't let stay-alive
-// connections hog goroutines for more than an hour.
+// connections hog goroutines for more than an hour.  If tlsConfig is non-nil,
+// requests are served over TLS.
 // See for discussion:
 // http://stackoverflow.com/questions/10971800/golang-http-server-leaving-open-goroutines
-func serveHTTP() {
+func serveHTTP(tlsConfig *tls.Config) {
 	var mode string
 	if readonly {
 		mode = " (read-only mode)"
@@ -583,6 +870,10 @@ func serveHTTP() {
 		WriteTimeout: WriteTimeout,
 		ReadTimeout:  ReadTimeout,
 	}
+	if tlsConfig != nil {
+		s.TLSConfig = tlsConfig
+		log.Fatal(s.ListenAndServeTLS("", ""))
+	}
 	log.Fatal(s.ListenAndServe())
 
 	// graceful.HandleSignals()
@@ -611,6 +902,7 @@ func initRoutes() {
 	mainMux.Use(httpAvailHandler)
 	mainMux.Use(recoverHandler)
 	mainMux.Use(corsHandler)
+	mainMux.Use(authHandler)
 
 	mainMux.Get("/interface", interfaceHandler)
 	mainMux.Get("/interface/version", versionHandler)
@@ -621,6 +913,10 @@ func initRoutes() {
 
 	mainMux.Get("/api/storage", serverStorageHandler)
 
+	mainMux.Get("/api/jobs", jobsHandler)
+	mainMux.Get("/api/jobs/:id", getJobHandler)
+	mainMux.Delete("/api/jobs/:id", deleteJobHandler)
+
 	serverMux := web.New()
 	mainMux.Handle("/api/server/:action", serverMux)
 	serverMux.Use(activityLogHandler)
@@ -637,6 +933,8 @@ func initRoutes() {
 	serverMux.Post("/api/server/settings", serverSettingsHandler)
 	serverMux.Post("/api/server/reload-metadata", serverReload)
 	serverMux.Post("/api/server/reload-metadata/", serverReload)
+	serverMux.Get("/api/server/backup", serverBackupHandler)
+	serverMux.Post("/api/server/backup", serverBackupHandler)
 	serverMux.Get("/api/server/blobstore/:ref", blobstoreHandler)
 
 	if !readonly {
@@ -663,6 +961,12 @@ func initRoutes() {
 	repoMux.Get("/api/repo/:uuid/log", getRepoLogHandler)
 	repoMux.Post("/api/repo/:uuid/log", postRepoLogHandler)
 	repoMux.Post("/api/repo/:uuid/merge", repoMergeHandler)
+	repoMux.Get("/api/repo/:uuid/merge-conflicts", repoMergeConflictsHandler)
+	repoMux.Get("/api/repo/:uuid/diff", repoDiffHandler)
+	repoMux.Get("/api/repo/:uuid/scrub", repoScrubReportHandler)
+	repoMux.Post("/api/repo/:uuid/scrub", repoScrubHandler)
+	repoMux.Get("/api/repo/:uuid/pull", repoPullHandler)
+	repoMux.Get("/api/repo/:uuid/pull/:name", repoPullDataHandler)
 	repoMux.Post("/api/repo/:uuid/resolve", repoResolveHandler)
 
 	nodeMux := web.New()
@@ -676,6 +980,8 @@ func initRoutes() {
 	nodeMux.Post("/api/node/:uuid/note", postNodeNoteHandler)
 	nodeMux.Get("/api/node/:uuid/log", getNodeLogHandler)
 	nodeMux.Post("/api/node/:uuid/log", postNodeLogHandler)
+	nodeMux.Post("/api/node/:uuid/tag", postNodeTagHandler)
+	nodeMux.Delete("/api/node/:uuid", deleteNodeHandler)
 	nodeMux.Get("/api/node/:uuid/commit", repoCommitStateHandler)
 	nodeMux.Get("/api/node/:uuid/status", repoCommitStateHandler)
 	nodeMux.Post("/api/node/:uuid/commit", repoCommitHandler)
@@ -885,11 +1191,18 @@ func DecodeJSON(r *http.Request) (dvid.Config, error) {
 
 // ---- Middleware -------------
 
-// corsHandler adds CORS support via header
+// corsHandler adds CORS support via header.  Preflight OPTIONS requests are answered
+// here, before authentication, since browsers don't send credentials with them.
 func corsHandler(c *web.C, h http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		// Allow cross-origin resource sharing.
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
 		h.ServeHTTP(w, r)
 	}
@@ -929,14 +1242,16 @@ func nodeSelector(c *web.C, h http.Handler) http.Handler {
 			BadRequest(w, r, msg)
 			return
 		}
-		// Make sure locked nodes can't use anything besides GET and HEAD unless we are deleting whole repo.
+		// Make sure locked nodes can't use anything besides GET and HEAD unless we are deleting whole repo,
+		// branching, or tagging.
 		locked, err := datastore.LockedUUID(uuid)
 		if err != nil {
 			BadRequest(w, r, err)
 			return
 		}
 		method := strings.ToLower(r.Method)
-		branchRequest := (c.URLParams["action"] == "branch") || (c.URLParams["action"] == "newversion")
+		action := c.URLParams["action"]
+		branchRequest := (action == "branch") || (action == "newversion") || (action == "tag")
 		if !fullwrite && locked && !branchRequest && method != "get" && method != "head" {
 			BadRequest(w, r, "Cannot do %s on locked node %s", method, uuid)
 			return
@@ -1045,7 +1360,11 @@ func instanceSelector(c *web.C, h http.Handler) http.Handler {
 				BadRequest(w, r, err)
 				return
 			}
-			if !fullwrite && locked && data.IsMutationRequest(r.Method, c.URLParams["keyword"]) {
+			mutation := data.IsMutationRequest(r.Method, c.URLParams["keyword"])
+			if checker, ok := data.(datastore.QueryMutationChecker); ok {
+				mutation = checker.IsMutationQuery(r.Method, c.URLParams["keyword"], r.URL.Query())
+			}
+			if !fullwrite && locked && mutation {
 				BadRequest(w, r, "Cannot do %s on endpoint %q of locked node %s", r.Method, c.URLParams["keyword"], uuid)
 				return
 			}
@@ -1058,6 +1377,7 @@ func instanceSelector(c *web.C, h http.Handler) http.Handler {
 			}
 		}
 		ctx := datastore.NewVersionedCtx(data, v)
+		ctx.AuthUser, _ = c.Env["user"].(string)
 
 		// Also set the web request information in case logging needs it downstream.
 		ctx.SetRequestID(middleware.GetReqID(*c))
@@ -1363,6 +1683,89 @@ func serverReload(c web.C, w http.ResponseWriter, r *http.Request) {
 	datastore.MetadataUniversalUnlock()
 }
 
+func serverBackupHandler(w http.ResponseWriter, r *http.Request) {
+	var status *storage.BackupStatus
+	if strings.ToLower(r.Method) == "post" {
+		config := dvid.NewConfig()
+		if err := config.SetByJSON(r.Body); err != nil {
+			BadRequest(w, r, fmt.Sprintf("Error decoding POSTed JSON config for backup: %v", err))
+			return
+		}
+		target, found, err := config.GetString("target")
+		if err != nil || !found {
+			BadRequest(w, r, "POST on backup endpoint requires a 'target' directory string")
+			return
+		}
+		if target, err = ExportPath(target); err != nil {
+			BadRequest(w, r, err)
+			return
+		}
+		if status, err = storage.StartBackup(target); err != nil {
+			BadRequest(w, r, err)
+			return
+		}
+		dvid.Infof("Started backup to %s\n", status.Target)
+	} else if status = storage.GetBackupStatus(); status == nil {
+		BadRequest(w, r, "No backup has been started since this server started")
+		return
+	}
+	jsonBytes, err := json.Marshal(status)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, string(jsonBytes))
+}
+
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	jsonBytes, err := json.Marshal(dvid.GetJobs())
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+// jobFromRequest returns the job given by the "id" URL parameter.
+func jobFromRequest(c web.C) (*dvid.Job, error) {
+	id, err := strconv.ParseUint(c.URLParams["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad job id %q: %v", c.URLParams["id"], err)
+	}
+	return dvid.GetJob(dvid.JobID(id))
+}
+
+func getJobHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	job, err := jobFromRequest(c)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	jsonBytes, err := json.Marshal(job.Status())
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+func deleteJobHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	job, err := jobFromRequest(c)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	if err := job.Cancel(); err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "Requested cancellation of job %d (%s)\n", job.ID(), job.Status().Type)
+}
+
 func blobstoreHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	method := strings.ToLower(r.Method)
 	if method != "get" {
@@ -1673,6 +2076,73 @@ func postNodeNoteHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func postNodeTagHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid := c.Env["uuid"].(dvid.UUID)
+	jsonData := make(map[string]string)
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&jsonData); err != nil && err != io.EOF {
+		BadRequest(w, r, fmt.Sprintf("Malformed JSON request in body: %s", err))
+		return
+	}
+	tag, ok := jsonData["tag"]
+	if !ok {
+		BadRequest(w, r, "Could not find 'tag' value in POSTed JSON.")
+		return
+	}
+	if err := datastore.TagNode(uuid, tag); err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+
+	msginfo := map[string]interface{}{
+		"Action": "nodetag",
+		"UUID":   string(uuid),
+		"Tag":    tag,
+	}
+	jsonmsg, _ := json.Marshal(msginfo)
+	if err := datastore.LogRepoOpToKafka(uuid, jsonmsg); err != nil {
+		BadRequest(w, r, fmt.Sprintf("Error on sending node tag op to kafka: %v\n", err))
+		return
+	}
+}
+
+func deleteNodeHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid := c.Env["uuid"].(dvid.UUID)
+	if _, found := r.URL.Query()["passcode"]; found {
+		BadRequest(w, r, "passcode for node deletion must be sent in the JSON request body, not the query string")
+		return
+	}
+	var body struct {
+		Passcode string `json:"passcode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		BadRequest(w, r, fmt.Sprintf("malformed JSON request in body: %v", err))
+		return
+	}
+	passcode := body.Passcode
+	root, err := datastore.GetRepoRoot(uuid)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	if err := datastore.DeleteVersion(uuid, passcode, requestIsAdmin(c, uuid)); err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+
+	msginfo := map[string]interface{}{
+		"Action": "deletenode",
+		"UUID":   string(uuid),
+	}
+	jsonmsg, _ := json.Marshal(msginfo)
+	if err := datastore.LogRepoOpToKafka(root, jsonmsg); err != nil {
+		BadRequest(w, r, fmt.Sprintf("Error on sending node deletion op to kafka: %v\n", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "Deleted node %s\n", uuid)
+}
+
 func postNodeLogHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	uuid := c.Env["uuid"].(dvid.UUID)
 	jsonData := make(map[string][]string)
@@ -1925,9 +2395,10 @@ func repoMergeHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	}
 
 	jsonData := struct {
-		MergeType string   `json:"mergeType"`
-		Note      string   `json:"note"`
-		Parents   []string `json:"parents"`
+		MergeType   string                      `json:"mergeType"`
+		Note        string                      `json:"note"`
+		Parents     []string                    `json:"parents"`
+		Resolutions []datastore.MergeResolution `json:"resolutions"`
 	}{}
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		BadRequest(w, r, fmt.Sprintf("Malformed JSON request in body: %v", err))
@@ -1954,13 +2425,26 @@ func repoMergeHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	switch jsonData.MergeType {
 	case "conflict-free":
 		mt = datastore.MergeConflictFree
+	case "auto":
+		mt = datastore.MergeTypeSpecificAuto
+	case "external":
+		mt = datastore.MergeExternalData
 	default:
-		BadRequest(w, r, fmt.Sprintf("'mergeType' must be 'conflict-free' at this time"))
+		BadRequest(w, r, fmt.Sprintf("'mergeType' must be 'conflict-free', 'auto', or 'external'"))
+		return
+	}
+	if mt != datastore.MergeExternalData && len(jsonData.Resolutions) != 0 {
+		BadRequest(w, r, "'resolutions' can only be used with 'external' merge type")
 		return
 	}
 
 	// Do the merge
-	newuuid, err := datastore.Merge(parents, jsonData.Note, mt)
+	var newuuid dvid.UUID
+	if mt == datastore.MergeExternalData {
+		newuuid, err = datastore.MergeWithResolutions(parents, jsonData.Note, jsonData.Resolutions)
+	} else {
+		newuuid, err = datastore.Merge(parents, jsonData.Note, mt)
+	}
 	if err != nil {
 		BadRequest(w, r, err)
 	} else {
@@ -1969,6 +2453,204 @@ func repoMergeHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func repoMergeConflictsHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	parentsStr := r.URL.Query().Get("parents")
+	if parentsStr == "" {
+		BadRequest(w, r, "merge-conflicts requires 'parents' query string with comma-separated UUIDs")
+		return
+	}
+	uuidFrags := strings.Split(parentsStr, ",")
+	if len(uuidFrags) < 2 {
+		BadRequest(w, r, "Must specify at least two parent UUIDs using 'parents' query string")
+		return
+	}
+	repoUUID := (c.Env["uuid"]).(dvid.UUID)
+	root, err := datastore.GetRepoRoot(repoUUID)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	parents := make([]dvid.UUID, len(uuidFrags))
+	for i, uuidFrag := range uuidFrags {
+		uuid, _, err := datastore.MatchingUUID(strings.TrimSpace(uuidFrag))
+		if err != nil {
+			BadRequest(w, r, fmt.Sprintf("can't match parent %q: %v", uuidFrag, err))
+			return
+		}
+		parentRoot, err := datastore.GetRepoRoot(uuid)
+		if err != nil {
+			BadRequest(w, r, err)
+			return
+		}
+		if parentRoot != root {
+			BadRequest(w, r, fmt.Sprintf("parent %s is not in the repo of %s", uuid, repoUUID))
+			return
+		}
+		parents[i] = uuid
+	}
+
+	report, err := datastore.GetMergeConflicts(parents)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+func repoDiffHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	queryStrings := r.URL.Query()
+	fromStr := queryStrings.Get("from")
+	toStr := queryStrings.Get("to")
+	if fromStr == "" || toStr == "" {
+		BadRequest(w, r, "diff requires 'from' and 'to' query strings with version UUIDs")
+		return
+	}
+	from, _, err := datastore.MatchingUUID(fromStr)
+	if err != nil {
+		BadRequest(w, r, fmt.Sprintf("can't match 'from' version %q: %v", fromStr, err))
+		return
+	}
+	to, _, err := datastore.MatchingUUID(toStr)
+	if err != nil {
+		BadRequest(w, r, fmt.Sprintf("can't match 'to' version %q: %v", toStr, err))
+		return
+	}
+	repoUUID := (c.Env["uuid"]).(dvid.UUID)
+	root, err := datastore.GetRepoRoot(repoUUID)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	for _, uuid := range []dvid.UUID{from, to} {
+		versionRoot, err := datastore.GetRepoRoot(uuid)
+		if err != nil {
+			BadRequest(w, r, err)
+			return
+		}
+		if versionRoot != root {
+			BadRequest(w, r, fmt.Sprintf("version %s is not in the repo of %s", uuid, repoUUID))
+			return
+		}
+	}
+	name := dvid.InstanceName(queryStrings.Get("data"))
+
+	summaries, err := datastore.GetVersionDiff(from, to, name)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	jsonBytes, err := json.Marshal(summaries)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+func repoScrubHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid, _, err := datastore.MatchingUUID(c.URLParams["uuid"])
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	name := dvid.InstanceName(r.URL.Query().Get("data"))
+	user, _ := c.Env["user"].(string)
+	report, err := datastore.StartScrub(uuid, name, user)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+func repoScrubReportHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid, _, err := datastore.MatchingUUID(c.URLParams["uuid"])
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	report, err := datastore.GetScrubReport(uuid)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	if report == nil {
+		BadRequest(w, r, fmt.Sprintf("no scrub has been run on repo with UUID %s", uuid))
+		return
+	}
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonBytes)
+}
+
+func repoPullHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid, _, err := datastore.MatchingUUID(c.URLParams["uuid"])
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	config := dvid.NewConfig()
+	for key := range r.URL.Query() {
+		config.Set(key, r.URL.Query().Get(key))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := datastore.SendPullRepo(w, uuid, config); err != nil {
+		BadRequest(w, r, err)
+	}
+}
+
+func repoPullDataHandler(c web.C, w http.ResponseWriter, r *http.Request) {
+	uuid, _, err := datastore.MatchingUUID(c.URLParams["uuid"])
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	config := dvid.NewConfig()
+	for key := range r.URL.Query() {
+		config.Set(key, r.URL.Query().Get(key))
+	}
+	versionsStr, found, err := config.GetString("versions")
+	if err != nil {
+		BadRequest(w, r, err)
+		return
+	}
+	if !found || versionsStr == "" {
+		BadRequest(w, r, "pull of data requires 'versions' query string")
+		return
+	}
+	versions := make(map[dvid.VersionID]struct{})
+	for _, vStr := range strings.Split(versionsStr, ",") {
+		v, err := strconv.ParseUint(vStr, 10, 32)
+		if err != nil {
+			BadRequest(w, r, "bad version %q in 'versions' query string: %v", vStr, err)
+			return
+		}
+		versions[dvid.VersionID(v)] = struct{}{}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	name := dvid.InstanceName(c.URLParams["name"])
+	if err := datastore.SendPullData(w, uuid, name, versions, config); err != nil {
+		BadRequest(w, r, err)
+	}
+}
+
 func repoResolveHandler(c web.C, w http.ResponseWriter, r *http.Request) {
 	uuid, _, err := datastore.MatchingUUID(c.URLParams["uuid"])
 	if err != nil {
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -446,3 +450,148 @@ func TestStressConcurrentMetadataOps(t *testing.T) {
 	close(done)
 	wg.Wait()
 }
+
+func TestDeleteNode(t *testing.T) {
+	if err := OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer CloseTest()
+
+	root, err := datastore.NewRepo("test repo", "test repo with passcode", nil, "mypasscode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := datastore.Commit(root, "root node", nil); err != nil {
+		t.Fatal(err)
+	}
+	child, err := datastore.NewVersion(root, "mistaken child", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Can't delete committed nodes, delete without the passcode, or give it in the query string.
+	passcode := `{"passcode": "mypasscode"}`
+	TestBadHTTP(t, "DELETE", fmt.Sprintf("%snode/%s", WebAPIPath, root), strings.NewReader(passcode))
+	TestBadHTTP(t, "DELETE", fmt.Sprintf("%snode/%s", WebAPIPath, child), nil)
+	TestBadHTTP(t, "DELETE", fmt.Sprintf("%snode/%s", WebAPIPath, child), strings.NewReader(`{"passcode": "wrong"}`))
+	TestBadHTTP(t, "DELETE", fmt.Sprintf("%snode/%s?passcode=mypasscode", WebAPIPath, child), nil)
+
+	TestHTTP(t, "DELETE", fmt.Sprintf("%snode/%s", WebAPIPath, child), strings.NewReader(passcode))
+	if _, _, err := datastore.MatchingUUID(string(child)); err == nil {
+		t.Errorf("expected deleted node %s to be gone\n", child)
+	}
+	rootV, err := datastore.VersionFromUUID(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	children, err := datastore.GetChildrenByVersion(rootV)
+	if err != nil || len(children) != 0 {
+		t.Errorf("expected no children after deletion, got %v: %v\n", children, err)
+	}
+
+	// Parent should be able to have a new child on the same branch.
+	if _, err := datastore.NewVersion(root, "new child", "", nil); err != nil {
+		t.Errorf("unable to make new version after deleting child: %v\n", err)
+	}
+}
+
+func TestRepoDiffOtherRepo(t *testing.T) {
+	if err := OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer CloseTest()
+
+	var roots, children [2]dvid.UUID
+	for i := range roots {
+		root, err := datastore.NewRepo(fmt.Sprintf("repo %d", i), "diff test repo", nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := datastore.Commit(root, "root node", nil); err != nil {
+			t.Fatal(err)
+		}
+		child, err := datastore.NewVersion(root, "child", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots[i], children[i] = root, child
+	}
+
+	TestHTTP(t, "GET", fmt.Sprintf("%srepo/%s/diff?from=%s&to=%s", WebAPIPath, roots[0], roots[0], children[0]), nil)
+
+	// Versions must be in the repo given in the URL.
+	TestBadHTTP(t, "GET", fmt.Sprintf("%srepo/%s/diff?from=%s&to=%s", WebAPIPath, roots[0], roots[1], children[1]), nil)
+	TestBadHTTP(t, "GET", fmt.Sprintf("%srepo/%s/diff?from=%s&to=%s", WebAPIPath, children[0], roots[0], children[1]), nil)
+}
+
+func TestBackupTarget(t *testing.T) {
+	if err := OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer CloseTest()
+
+	dir, err := ioutil.TempDir("", "dvid-backup-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	outside := filepath.Join(dir, "outside")
+	backupURL := fmt.Sprintf("%sserver/backup", WebAPIPath)
+	payload := fmt.Sprintf(`{"target": %q}`, outside)
+
+	// HTTP backups are disabled without an export root.
+	TestBadHTTP(t, "POST", backupURL, strings.NewReader(`{"target": "backups/today"}`))
+
+	SetExportRoot(filepath.Join(dir, "exports"))
+	defer SetExportRoot("")
+
+	// Targets must stay within the export root.
+	TestBadHTTP(t, "POST", backupURL, strings.NewReader(payload))
+	TestBadHTTP(t, "POST", backupURL, strings.NewReader(`{"target": "../outside"}`))
+	if _, err := os.Stat(outside); !os.IsNotExist(err) {
+		t.Fatalf("rejected backup target %q should not have been created: %v\n", outside, err)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	if err := OpenTest(); err != nil {
+		t.Fatalf("can't open test server: %v\n", err)
+	}
+	defer CloseTest()
+
+	uuid, _ := datastore.NewTestRepo()
+	job := dvid.NewJob("test", "", uuid, "")
+
+	r := TestHTTP(t, "GET", fmt.Sprintf("%sjobs/%d", WebAPIPath, job.ID()), nil)
+	var status dvid.JobStatus
+	if err := json.Unmarshal(r, &status); err != nil {
+		t.Fatalf("Unable to unmarshal job status: %s\n", string(r))
+	}
+	if status.ID != job.ID() || status.UUID != uuid || !status.Running {
+		t.Errorf("bad job status: %s\n", string(r))
+	}
+
+	r = TestHTTP(t, "GET", fmt.Sprintf("%sjobs", WebAPIPath), nil)
+	var statuses []dvid.JobStatus
+	if err := json.Unmarshal(r, &statuses); err != nil {
+		t.Fatalf("Unable to unmarshal job list: %s\n", string(r))
+	}
+	var found bool
+	for _, status := range statuses {
+		if status.ID == job.ID() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("job %d not in job list: %s\n", job.ID(), string(r))
+	}
+
+	TestHTTP(t, "DELETE", fmt.Sprintf("%sjobs/%d", WebAPIPath, job.ID()), nil)
+	if !job.Canceled() {
+		t.Errorf("expected job %d to be canceled\n", job.ID())
+	}
+	job.Finish(dvid.ErrJobCanceled)
+	TestBadHTTP(t, "DELETE", fmt.Sprintf("%sjobs/%d", WebAPIPath, job.ID()), nil)
+	TestBadHTTP(t, "GET", fmt.Sprintf("%sjobs/notanid", WebAPIPath), nil)
+}
@@ -0,0 +1,438 @@
+// +build !clustered,!gcloud
+
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+)
+
+const (
+	// BackupManifest is the name of the JSON file describing a completed backup.
+	BackupManifest = "manifest.json"
+
+	// BackupFileExt is the extension of each store's backup file within the target directory.
+	BackupFileExt = ".dvidkv"
+
+	// header for backup files
+	backupFileHeader = "DVIDKV01"
+
+	// number of key-value pairs between progress updates
+	backupProgressInterval = 1000
+)
+
+// BackupStoreStatus describes the backup progress of a single store.
+type BackupStoreStatus struct {
+	Alias     Alias
+	Store     string
+	Metadata  bool   `json:",omitempty"`
+	File      string `json:",omitempty"`
+	KeyValues uint64
+	Bytes     uint64
+	Done      bool
+	Skipped   string `json:",omitempty"` // reason store was not backed up.
+}
+
+// BackupStatus describes the progress of an online backup.
+type BackupStatus struct {
+	Target   string
+	Started  time.Time
+	Finished *time.Time `json:",omitempty"`
+	Running  bool
+	Error    string `json:",omitempty"`
+	Stores   []*BackupStoreStatus
+}
+
+func (s *BackupStatus) copy() *BackupStatus {
+	c := *s
+	c.Stores = make([]*BackupStoreStatus, len(s.Stores))
+	for i, ss := range s.Stores {
+		ssCopy := *ss
+		c.Stores[i] = &ssCopy
+	}
+	return &c
+}
+
+// tracks the current or last backup
+var backup struct {
+	sync.Mutex
+	status *BackupStatus
+}
+
+// GetBackupStatus returns the status of the current or most recent backup, or nil
+// if no backup was started since the server started.
+func GetBackupStatus() *BackupStatus {
+	backup.Lock()
+	defer backup.Unlock()
+	if backup.status == nil {
+		return nil
+	}
+	return backup.status.copy()
+}
+
+// StartBackup starts an online backup of the metadata store and all other stores into
+// the given target directory, which must not exist or be empty.  Snapshots of all stores
+// are taken before this function returns while the server continues to handle reads and
+// writes.  Each store's backup is a consistent point-in-time copy of that store, but the
+// stores are snapshotted one after another without pausing writes, so an operation that
+// writes to several stores may be only partly captured.  For a backup consistent across
+// stores, stop mutations, e.g., by restarting the server with -readonly, before starting
+// the backup.  The snapshots are then written in the background, one file per store, with
+// a final manifest file written on completion.  Stores that can't provide snapshots are
+// skipped.  Only one backup can run at a time.  Backups can be restored with RestoreBackup.
+// Any target path is accepted, so callers handling remote requests should restrict it.
+func StartBackup(target string) (*BackupStatus, error) {
+	if !manager.setup {
+		return nil, fmt.Errorf("Storage manager not initialized before requesting backup")
+	}
+	if target == "" {
+		return nil, fmt.Errorf("backup requires a target directory")
+	}
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+
+	backup.Lock()
+	defer backup.Unlock()
+	if backup.status != nil && backup.status.Running {
+		return nil, fmt.Errorf("backup to %s is already running", backup.status.Target)
+	}
+	if err := makeBackupDir(target); err != nil {
+		return nil, err
+	}
+
+	// snapshot every store in quick succession, metadata store first.
+	var aliases []string
+	var metadataAlias Alias
+	for alias, store := range manager.stores {
+		if store == manager.metadataStore {
+			metadataAlias = alias
+		} else {
+			aliases = append(aliases, string(alias))
+		}
+	}
+	sort.Strings(aliases)
+	if metadataAlias != "" {
+		aliases = append([]string{string(metadataAlias)}, aliases...)
+	}
+	status := &BackupStatus{Target: target, Started: time.Now(), Running: true}
+	snapshots := make([]Snapshot, len(aliases))
+	for i, aliasStr := range aliases {
+		alias := Alias(aliasStr)
+		store := manager.stores[alias]
+		storeStatus := &BackupStoreStatus{
+			Alias:    alias,
+			Store:    store.String(),
+			Metadata: alias == metadataAlias,
+		}
+		status.Stores = append(status.Stores, storeStatus)
+		snapshotDB, ok := store.(SnapshotDB)
+		if !ok {
+			storeStatus.Skipped = "store does not support snapshots"
+			storeStatus.Done = true
+			continue
+		}
+		if snapshots[i], err = snapshotDB.NewSnapshot(); err != nil {
+			for _, snap := range snapshots[:i] {
+				if snap != nil {
+					snap.Release()
+				}
+			}
+			return nil, fmt.Errorf("unable to snapshot store %q: %v", alias, err)
+		}
+		storeStatus.File = string(alias) + BackupFileExt
+	}
+	backup.status = status
+	go runBackup(status, snapshots)
+	return status.copy(), nil
+}
+
+// makeBackupDir creates the target directory or makes sure an existing one is empty.
+func makeBackupDir(target string) error {
+	f, err := os.Open(target)
+	if os.IsNotExist(err) {
+		return os.MkdirAll(target, 0755)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("backup target %s is not a usable directory: %v", target, err)
+	}
+	if len(names) != 0 {
+		return fmt.Errorf("backup target directory %s is not empty", target)
+	}
+	return nil
+}
+
+func runBackup(status *BackupStatus, snapshots []Snapshot) {
+	timedLog := dvid.NewTimeLog()
+	var err error
+	for i, snap := range snapshots {
+		if snap == nil {
+			continue
+		}
+		if err == nil {
+			backup.Lock()
+			storeStatus := status.Stores[i]
+			filename := filepath.Join(status.Target, storeStatus.File)
+			backup.Unlock()
+			err = writeBackupFile(filename, snap, func(numKV, numBytes uint64, done bool) {
+				backup.Lock()
+				storeStatus.KeyValues = numKV
+				storeStatus.Bytes = numBytes
+				storeStatus.Done = done
+				backup.Unlock()
+			})
+			if err != nil {
+				err = fmt.Errorf("store %q: %v", storeStatus.Alias, err)
+			} else {
+				dvid.Infof("Backed up %d key-value pairs of store %q to %s\n", storeStatus.KeyValues, storeStatus.Alias, filename)
+			}
+		}
+		snap.Release()
+	}
+
+	backup.Lock()
+	finished := time.Now()
+	status.Finished = &finished
+	status.Running = false
+	if err != nil {
+		status.Error = err.Error()
+	}
+	manifest := status.copy()
+	backup.Unlock()
+
+	if err == nil {
+		err = writeBackupManifest(filepath.Join(manifest.Target, BackupManifest), manifest)
+		if err != nil {
+			backup.Lock()
+			status.Error = err.Error()
+			backup.Unlock()
+		}
+	}
+	if err != nil {
+		dvid.Errorf("Backup to %s failed: %v\n", manifest.Target, err)
+		return
+	}
+	timedLog.Infof("Completed backup of %d stores to %s", len(manifest.Stores), manifest.Target)
+}
+
+func writeBackupManifest(filename string, status *BackupStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeBackupFile writes all key-value pairs of a snapshot as a header followed by
+// uvarint length-prefixed keys and values.  The progress function is called periodically
+// with the number of key-value pairs and bytes written.
+func writeBackupFile(filename string, snap Snapshot, progress func(numKV, numBytes uint64, done bool)) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.WriteString(backupFileHeader); err != nil {
+		f.Close()
+		return err
+	}
+	var numKV, numBytes uint64
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeBytes := func(b []byte) error {
+		n := binary.PutUvarint(buf, uint64(len(b)))
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		_, err := w.Write(b)
+		numBytes += uint64(n + len(b))
+		return err
+	}
+	err = snap.SendKeyValues(func(kv *KeyValue) error {
+		if err := writeBytes(kv.K); err != nil {
+			return err
+		}
+		if err := writeBytes(kv.V); err != nil {
+			return err
+		}
+		numKV++
+		if numKV%backupProgressInterval == 0 {
+			progress(numKV, numBytes, false)
+		}
+		return nil
+	})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	progress(numKV, numBytes, true)
+	return nil
+}
+
+// ReadBackupFile sends each key-value pair in a store's backup file to f, e.g., to restore
+// the key-value pairs using RawPut into a new store.
+func ReadBackupFile(filename string, f func(*KeyValue) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	header := make([]byte, len(backupFileHeader))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("unable to read backup file %s header: %v", filename, err)
+	}
+	if string(header) != backupFileHeader {
+		return fmt.Errorf("file %s is not a DVID backup file", filename)
+	}
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	for {
+		k, err := readBytes()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("bad key in backup file %s: %v", filename, err)
+		}
+		v, err := readBytes()
+		if err != nil {
+			return fmt.Errorf("bad value in backup file %s: %v", filename, err)
+		}
+		if err := f(&KeyValue{K: k, V: v}); err != nil {
+			return err
+		}
+	}
+}
+
+// RestoreBackup writes the key-value pairs of a completed online backup in the given
+// directory into the configured stores with the same aliases as the backed up stores.
+// These stores must be empty, and the metadata store of the backup must be restored into
+// the configured metadata store.  Restores should be done before the datastore is
+// initialized, e.g., using "dvid restore" before "dvid serve".  Returns the manifest of
+// the restored backup.
+func RestoreBackup(dir string) (*BackupStatus, error) {
+	if !manager.setup {
+		return nil, fmt.Errorf("Storage manager not initialized before requesting restore")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, BackupManifest))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read backup manifest, which is only written for completed backups: %v", err)
+	}
+	var manifest BackupStatus
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("bad backup manifest in %s: %v", dir, err)
+	}
+	if manifest.Error != "" {
+		return nil, fmt.Errorf("backup in %s failed: %s", dir, manifest.Error)
+	}
+
+	// make sure all target stores are usable before writing anything.
+	dbs := make([]OrderedKeyValueDB, len(manifest.Stores))
+	for i, storeStatus := range manifest.Stores {
+		if storeStatus.File == "" {
+			continue
+		}
+		store, found := manager.stores[storeStatus.Alias]
+		if !found {
+			return nil, fmt.Errorf("backed up store %q is not in the configuration", storeStatus.Alias)
+		}
+		if storeStatus.Metadata && store != manager.metadataStore {
+			return nil, fmt.Errorf("backed up metadata store %q is not the configured metadata store", storeStatus.Alias)
+		}
+		db, ok := store.(OrderedKeyValueDB)
+		if !ok {
+			return nil, fmt.Errorf("store %q is not an ordered key-value store", storeStatus.Alias)
+		}
+		empty, err := storeIsEmpty(db)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check store %q: %v", storeStatus.Alias, err)
+		}
+		if !empty {
+			return nil, fmt.Errorf("store %q must be empty for restore", storeStatus.Alias)
+		}
+		dbs[i] = db
+	}
+
+	timedLog := dvid.NewTimeLog()
+	for i, storeStatus := range manifest.Stores {
+		if dbs[i] == nil {
+			continue
+		}
+		filename := filepath.Join(dir, storeStatus.File)
+		var numKV uint64
+		err := ReadBackupFile(filename, func(kv *KeyValue) error {
+			numKV++
+			return dbs[i].RawPut(kv.K, kv.V)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to restore store %q: %v", storeStatus.Alias, err)
+		}
+		if numKV != storeStatus.KeyValues {
+			return nil, fmt.Errorf("restored %d key-value pairs into store %q but manifest has %d", numKV, storeStatus.Alias, storeStatus.KeyValues)
+		}
+		dvid.Infof("Restored %d key-value pairs of store %q from %s\n", numKV, storeStatus.Alias, filename)
+	}
+	timedLog.Infof("Restored backup in %s", dir)
+	return &manifest, nil
+}
+
+// storeIsEmpty returns true if the store has no key-value pairs.
+func storeIsEmpty(db OrderedKeyValueDB) (bool, error) {
+	out := make(chan *KeyValue)
+	cancel := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- db.RawRangeQuery(Key{}, Key{blobKeyPrefix + 1}, true, out, cancel)
+	}()
+	select {
+	case kv := <-out:
+		if kv == nil || kv.K == nil {
+			return true, <-errCh
+		}
+		close(cancel)
+		return false, <-errCh
+	case err := <-errCh:
+		return err == nil, err
+	}
+}
@@ -0,0 +1,78 @@
+// +build !clustered,!gcloud
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testSnapshot []KeyValue
+
+func (s testSnapshot) SendKeyValues(f func(*KeyValue) error) error {
+	for i := range s {
+		if err := f(&s[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s testSnapshot) Release() {}
+
+func TestBackupFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-backup-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var snap testSnapshot
+	for i := 0; i < 2500; i++ {
+		snap = append(snap, KeyValue{K: Key(fmt.Sprintf("key%04d", i)), V: bytes.Repeat([]byte{byte(i)}, i%300)})
+	}
+	filename := filepath.Join(dir, "test"+BackupFileExt)
+	var updates int
+	var lastKV uint64
+	var done bool
+	err = writeBackupFile(filename, snap, func(numKV, numBytes uint64, finished bool) {
+		updates++
+		lastKV = numKV
+		done = finished
+	})
+	if err != nil {
+		t.Fatalf("unable to write backup file: %v\n", err)
+	}
+	if updates != 3 || lastKV != 2500 || !done {
+		t.Errorf("bad progress reporting: %d updates, last %d key-values, done %t\n", updates, lastKV, done)
+	}
+
+	var i int
+	err = ReadBackupFile(filename, func(kv *KeyValue) error {
+		if i >= len(snap) {
+			return fmt.Errorf("too many key-values read")
+		}
+		if !bytes.Equal(kv.K, snap[i].K) || !bytes.Equal(kv.V, snap[i].V) {
+			return fmt.Errorf("key-value %d read (%q) doesn't match written (%q)", i, kv.K, snap[i].K)
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error reading backup file: %v\n", err)
+	}
+	if i != len(snap) {
+		t.Errorf("expected %d key-values read from backup, got %d\n", len(snap), i)
+	}
+
+	if err := makeBackupDir(dir); err == nil {
+		t.Errorf("expected error using non-empty backup directory\n")
+	}
+	if err := makeBackupDir(filepath.Join(dir, "new")); err != nil {
+		t.Errorf("unable to make new backup directory: %v\n", err)
+	}
+}
@@ -1476,6 +1476,55 @@ func (db *LevelDB) GetApproximateSizes(ranges []storage.KeyRange) ([]uint64, err
 	return sizes, nil
 }
 
+// ---- SnapshotDB interface ----
+
+type levelSnapshot struct {
+	db   *LevelDB
+	snap *levigo.Snapshot
+}
+
+// NewSnapshot returns a leveldb snapshot that provides a consistent view of the database
+// as of this call while writes continue.
+func (db *LevelDB) NewSnapshot() (storage.Snapshot, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call NewSnapshot on nil LevelDB")
+	}
+	dvid.StartCgo()
+	defer dvid.StopCgo()
+	return &levelSnapshot{db, db.ldb.NewSnapshot()}, nil
+}
+
+// SendKeyValues iterates through the entire snapshot without filling the block cache.
+func (s *levelSnapshot) SendKeyValues(f func(*storage.KeyValue) error) error {
+	dvid.StartCgo()
+	ro := levigo.NewReadOptions()
+	ro.SetSnapshot(s.snap)
+	ro.SetFillCache(false)
+	it := s.db.ldb.NewIterator(ro)
+	defer func() {
+		it.Close()
+		ro.Close()
+		dvid.StopCgo()
+	}()
+
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		itKey := it.Key()
+		itValue := it.Value()
+		storage.StoreKeyBytesRead <- len(itKey)
+		storage.StoreValueBytesRead <- len(itValue)
+		if err := f(&storage.KeyValue{K: itKey, V: itValue}); err != nil {
+			return err
+		}
+	}
+	return it.GetError()
+}
+
+func (s *levelSnapshot) Release() {
+	dvid.StartCgo()
+	defer dvid.StopCgo()
+	s.db.ldb.ReleaseSnapshot(s.snap)
+}
+
 // ---- BlobStore interface ----
 
 // PutBlob writes unversioned data and returns a filename-friendly base64 encoding of the reference.
@@ -0,0 +1,1299 @@
+// +build goleveldb
+
+/*
+	Package goleveldb implements an embedded ordered key-value storage engine using a pure Go
+	port of leveldb, so DVID servers and tests can be built without cgo storage libraries.
+*/
+package goleveldb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+	humanize "github.com/janelia-flyem/go/go-humanize"
+	"github.com/janelia-flyem/go/semver"
+	"github.com/janelia-flyem/go/uuid"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// Default size of LRU cache that caches frequently used uncompressed blocks.
+	DefaultCacheSize = 536870912
+
+	// Default # bits for Bloom Filter.  The filter reduces the number of unnecessary
+	// disk reads needed for Get() calls by a large factor.
+	DefaultBloomBits = 16
+
+	// Number of open files that can be used by the datastore.
+	DefaultMaxOpenFiles = 1024
+
+	// Approximate size of user data packed per block before compression.
+	DefaultBlockSize = 64 * dvid.Kilo
+
+	// Amount of data to build up in memory (backed by an unsorted log
+	// on disk) before converting to a sorted on-disk file.
+	DefaultWriteBufferSize = 62914560
+
+	// If Sync=true, each write will be flushed from the operating system
+	// buffer cache before the write is considered complete.
+	DefaultSync = false
+)
+
+func init() {
+	ver, err := semver.Make("0.1.0")
+	if err != nil {
+		dvid.Errorf("Unable to make semver in goleveldb: %v\n", err)
+	}
+	e := Engine{"goleveldb", "Pure Go LevelDB", ver}
+	storage.RegisterEngine(e)
+}
+
+// --- Engine Implementation ------
+
+type Engine struct {
+	name   string
+	desc   string
+	semver semver.Version
+}
+
+func (e Engine) GetName() string {
+	return e.name
+}
+
+func (e Engine) GetDescription() string {
+	return e.desc
+}
+
+func (e Engine) IsDistributed() bool {
+	return false
+}
+
+func (e Engine) GetSemVer() semver.Version {
+	return e.semver
+}
+
+func (e Engine) String() string {
+	return fmt.Sprintf("%s [%s]", e.name, e.semver)
+}
+
+// NewStore returns a goleveldb store. The passed Config must contain "path" string.
+func (e Engine) NewStore(config dvid.StoreConfig) (dvid.Store, bool, error) {
+	return e.newLevelDB(config)
+}
+
+func parseConfig(config dvid.StoreConfig) (path string, testing bool, err error) {
+	c := config.GetAll()
+
+	v, found := c["path"]
+	if !found {
+		err = fmt.Errorf("%q must be specified for goleveldb configuration", "path")
+		return
+	}
+	var ok bool
+	path, ok = v.(string)
+	if !ok {
+		err = fmt.Errorf("%q setting must be a string (%v)", "path", v)
+		return
+	}
+	v, found = c["testing"]
+	if found {
+		testing, ok = v.(bool)
+		if !ok {
+			err = fmt.Errorf("%q setting must be a bool (%v)", "testing", v)
+			return
+		}
+	}
+	if testing {
+		path = filepath.Join(os.TempDir(), path)
+	}
+	return
+}
+
+// newLevelDB returns a goleveldb backend, creating the database
+// at the path if it doesn't already exist.
+func (e Engine) newLevelDB(config dvid.StoreConfig) (*LevelDB, bool, error) {
+	path, _, err := parseConfig(config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Is there a database already at this path?  If not, create.
+	var created bool
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		dvid.TimeInfof("Database not already at path (%s). Creating directory...\n", path)
+		created = true
+		// Make a directory at the path.
+		if err := os.MkdirAll(path, 0744); err != nil {
+			return nil, true, fmt.Errorf("Can't make directory at %s: %v", path, err)
+		}
+	} else {
+		dvid.TimeInfof("Found directory at %s (err = %v)\n", path, err)
+	}
+
+	options, err := getOptions(config.Config)
+	if err != nil {
+		return nil, false, err
+	}
+
+	db := &LevelDB{
+		directory: path,
+		config:    config,
+		options:   options,
+		locks:     newKeyLocks(),
+	}
+
+	dvid.TimeInfof("Opening goleveldb @ path %s\n", path)
+	ldb, err := leveldb.OpenFile(path, options.Options)
+	if err != nil {
+		return nil, false, err
+	}
+	db.ldb = ldb
+
+	// if we know it's newly created, just return.
+	if created {
+		return db, created, nil
+	}
+
+	// otherwise, check if there's been any metadata or we need to initialize it.
+	metadataExists, err := db.metadataExists()
+	if err != nil {
+		db.Close()
+		return nil, false, err
+	}
+
+	return db, !metadataExists, nil
+}
+
+// ---- RepairableEngine interface implementation ------
+
+// Repair tries to recover a damaged goleveldb by rebuilding its manifest from the
+// table files.  Implements the RepairableEngine interface.
+func (e Engine) Repair(path string) error {
+	options, err := getOptions(dvid.Config{})
+	if err != nil {
+		return err
+	}
+	ldb, err := leveldb.RecoverFile(path, options.Options)
+	if err != nil {
+		return err
+	}
+	return ldb.Close()
+}
+
+// ---- TestableEngine interface implementation -------
+
+// AddTestConfig sets the goleveldb as the default key-value backend.  If another
+// engine is already set, it returns an error since only one key-value backend should
+// be tested via tags.
+func (e Engine) AddTestConfig(backend *storage.Backend) (storage.Alias, error) {
+	alias := storage.Alias("goleveldb")
+	if backend.DefaultKVDB != "" {
+		return alias, fmt.Errorf("goleveldb can't be testable key-value.  DefaultKVDB already set to %s", backend.DefaultKVDB)
+	}
+	if backend.Metadata != "" {
+		return alias, fmt.Errorf("goleveldb can't be testable key-value.  Metadata already set to %s", backend.Metadata)
+	}
+	backend.Metadata = alias
+	backend.DefaultKVDB = alias
+	if backend.Stores == nil {
+		backend.Stores = make(map[storage.Alias]dvid.StoreConfig)
+	}
+	tc := map[string]interface{}{
+		"path":    fmt.Sprintf("dvid-test-goleveldb-%x", uuid.NewV4().Bytes()),
+		"testing": true,
+	}
+	var c dvid.Config
+	c.SetAll(tc)
+	backend.Stores[alias] = dvid.StoreConfig{Config: c, Engine: "goleveldb"}
+	return alias, nil
+}
+
+// Delete implements the TestableEngine interface by providing a way to dispose
+// of testing databases.
+func (e Engine) Delete(config dvid.StoreConfig) error {
+	path, _, err := parseConfig(config)
+	if err != nil {
+		return err
+	}
+
+	// Delete the directory if it exists
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("Can't delete old datastore %q: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func (db *LevelDB) String() string {
+	return fmt.Sprintf("goleveldb @ %s", db.directory)
+}
+
+// --- The goleveldb Implementation must satisfy a Engine interface ----
+
+type LevelDB struct {
+	// Directory of datastore
+	directory string
+
+	// Config at time of Open()
+	config dvid.StoreConfig
+
+	options *leveldbOptions
+	ldb     *leveldb.DB
+
+	// locks held via the TransactionDB interface and during patches
+	locks *keyLocks
+}
+
+type leveldbOptions struct {
+	*opt.Options
+	*opt.ReadOptions
+	*opt.WriteOptions
+}
+
+func getOptions(config dvid.Config) (*leveldbOptions, error) {
+	options := &leveldbOptions{
+		Options:      &opt.Options{},
+		ReadOptions:  &opt.ReadOptions{},
+		WriteOptions: &opt.WriteOptions{Sync: DefaultSync}, // Huge performance penalty to set sync to true
+	}
+
+	bloomBits, found, err := config.GetInt("BloomFilterBitsPerKey")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		bloomBits = DefaultBloomBits
+	}
+	options.Options.Filter = filter.NewBloomFilter(bloomBits)
+
+	cacheSize, found, err := config.GetInt("CacheSize")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		cacheSize = DefaultCacheSize
+	} else {
+		cacheSize *= dvid.Mega
+	}
+	dvid.TimeInfof("goleveldb cache size: %s\n", humanize.Bytes(uint64(cacheSize)))
+	options.Options.BlockCacheCapacity = cacheSize
+
+	writeBufferSize, found, err := config.GetInt("WriteBufferSize")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		writeBufferSize = DefaultWriteBufferSize
+	} else {
+		writeBufferSize *= dvid.Mega
+	}
+	dvid.TimeInfof("goleveldb write buffer size: %s\n", humanize.Bytes(uint64(writeBufferSize)))
+	options.Options.WriteBuffer = writeBufferSize
+
+	maxOpenFiles, found, err := config.GetInt("MaxOpenFiles")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		maxOpenFiles = DefaultMaxOpenFiles
+	}
+	options.Options.OpenFilesCacheCapacity = maxOpenFiles
+
+	blockSize, found, err := config.GetInt("BlockSize")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		blockSize = DefaultBlockSize
+	}
+	options.Options.BlockSize = blockSize
+
+	// Don't bother with compression on leveldb side because it will be
+	// selectively applied on DVID side.
+	options.Options.Compression = opt.NoCompression
+
+	return options, nil
+}
+
+// Close closes the goleveldb.
+func (db *LevelDB) Close() {
+	if db != nil {
+		if db.ldb != nil {
+			if err := db.ldb.Close(); err != nil {
+				dvid.Errorf("Error closing %s: %v\n", db, err)
+			}
+		}
+		db.ldb = nil
+		db.options = nil
+	}
+}
+
+// Equal returns true if the goleveldb matches the given store configuration.
+func (db *LevelDB) Equal(config dvid.StoreConfig) bool {
+	path, _, err := parseConfig(config)
+	if err != nil {
+		return false
+	}
+	return db.directory == path
+}
+
+// newIterator returns an iterator over the given range of full keys, which are inclusive
+// at both ends unlike goleveldb ranges.
+func (db *LevelDB) newIterator(begKey, endKey storage.Key) iterator.Iterator {
+	var limit []byte
+	if endKey != nil {
+		limit = append(append([]byte{}, endKey...), 0)
+	}
+	return db.ldb.NewIterator(&util.Range{Start: begKey, Limit: limit}, db.options.ReadOptions)
+}
+
+// copyBytes returns a copy since goleveldb iterator buffers are reused on Next().
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+func (db *LevelDB) metadataExists() (bool, error) {
+	var ctx storage.MetadataContext
+	keyBeg, keyEnd := ctx.KeyRange()
+	it := db.newIterator(keyBeg, keyEnd)
+	defer it.Release()
+
+	if it.First() {
+		return true, nil
+	}
+	if err := it.Error(); err != nil {
+		return false, err
+	}
+	dvid.TimeInfof("No metadata found for %s...\n", db)
+	return false, nil
+}
+
+// ---- KeyValueChecker interface ------
+
+// Exists returns true if the key exists.
+func (db *LevelDB) Exists(ctx storage.Context, tk storage.TKey) (found bool, err error) {
+	if db == nil {
+		return false, fmt.Errorf("Can't call Exists() on nil LevelDB")
+	}
+	if db.options == nil {
+		return false, fmt.Errorf("Can't call Exists() on db with nil options: %v", db)
+	}
+	if ctx == nil {
+		return false, fmt.Errorf("Received nil context in Exists()")
+	}
+	var key storage.Key
+	if ctx.Versioned() {
+		vctx, ok := ctx.(storage.VersionedCtx)
+		if !ok {
+			return false, fmt.Errorf("Bad Exists(): context is versioned but doesn't fulfill interface: %v", ctx)
+		}
+		key = vctx.ConstructKeyVersion(tk, vctx.VersionID())
+	} else {
+		key = ctx.ConstructKey(tk)
+	}
+	return db.ldb.Has(key, db.options.ReadOptions)
+}
+
+// ---- OrderedKeyValueGetter interface ------
+
+// Get returns a value given a key.
+func (db *LevelDB) Get(ctx storage.Context, tk storage.TKey) ([]byte, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call GET on nil LevelDB")
+	}
+	if db.options == nil {
+		return nil, fmt.Errorf("Can't call GET on db with nil options: %v", db)
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in Get()")
+	}
+	if ctx.Versioned() {
+		vctx, ok := ctx.(storage.VersionedCtx)
+		if !ok {
+			return nil, fmt.Errorf("Bad Get(): context is versioned but doesn't fulfill interface: %v", ctx)
+		}
+
+		// Get all versions of this key and return the most recent
+		values, err := db.getSingleKeyVersions(vctx, tk)
+		if err != nil {
+			return nil, err
+		}
+		kv, err := vctx.VersionedKeyValue(values)
+		if kv != nil {
+			return kv.V, err
+		}
+		return nil, err
+	}
+	return db.get(ctx.ConstructKey(tk))
+}
+
+// get returns the value for a full key or nil if the key isn't present.
+func (db *LevelDB) get(key storage.Key) ([]byte, error) {
+	v, err := db.ldb.Get(key, db.options.ReadOptions)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	storage.StoreValueBytesRead <- len(v)
+	return v, err
+}
+
+// getSingleKeyVersions returns all versions of a key.  These key-value pairs will be sorted
+// in ascending key order and could include a tombstone key.
+func (db *LevelDB) getSingleKeyVersions(vctx storage.VersionedCtx, tk []byte) ([]*storage.KeyValue, error) {
+	begKey, err := vctx.MinVersionKey(tk)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := vctx.MaxVersionKey(tk)
+	if err != nil {
+		return nil, err
+	}
+	it := db.newIterator(begKey, endKey)
+	defer it.Release()
+
+	values := []*storage.KeyValue{}
+	for it.Next() {
+		itKey := copyBytes(it.Key())
+		itValue := copyBytes(it.Value())
+		storage.StoreKeyBytesRead <- len(itKey)
+		storage.StoreValueBytesRead <- len(itValue)
+		values = append(values, &storage.KeyValue{K: itKey, V: itValue})
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+type errorableKV struct {
+	*storage.KeyValue
+	error
+}
+
+func sendKV(vctx storage.VersionedCtx, values []*storage.KeyValue, ch chan errorableKV) {
+	if len(values) != 0 {
+		kv, err := vctx.VersionedKeyValue(values)
+		if err != nil {
+			ch <- errorableKV{nil, err}
+			return
+		}
+		if kv != nil {
+			ch <- errorableKV{kv, nil}
+		}
+	}
+}
+
+// versionedRange sends a range of key-value pairs for a particular version down a channel.
+func (db *LevelDB) versionedRange(vctx storage.VersionedCtx, begTKey, endTKey storage.TKey, ch chan errorableKV, done <-chan struct{}, keysOnly bool) {
+	minKey, err := vctx.MinVersionKey(begTKey)
+	if err != nil {
+		ch <- errorableKV{nil, err}
+		return
+	}
+	maxKey, err := vctx.MaxVersionKey(endTKey)
+	if err != nil {
+		ch <- errorableKV{nil, err}
+		return
+	}
+	maxVersionKey, err := vctx.MaxVersionKey(begTKey)
+	if err != nil {
+		ch <- errorableKV{nil, err}
+		return
+	}
+
+	it := db.newIterator(minKey, maxKey)
+	defer it.Release()
+
+	values := []*storage.KeyValue{}
+	var itValue []byte
+	for it.Next() {
+		select {
+		case <-done: // only happens if we don't care about rest of data.
+			ch <- errorableKV{nil, nil}
+			return
+		default:
+		}
+		if !keysOnly {
+			itValue = copyBytes(it.Value())
+			storage.StoreValueBytesRead <- len(itValue)
+		}
+		itKey := copyBytes(it.Key())
+		storage.StoreKeyBytesRead <- len(itKey)
+
+		// Did we pass all versions for last key read?
+		if bytes.Compare(itKey, maxVersionKey) > 0 {
+			if storage.Key(itKey).IsDataKey() {
+				indexBytes, err := storage.TKeyFromKey(itKey)
+				if err != nil {
+					ch <- errorableKV{nil, err}
+					return
+				}
+				maxVersionKey, err = vctx.MaxVersionKey(indexBytes)
+				if err != nil {
+					ch <- errorableKV{nil, err}
+					return
+				}
+			}
+			sendKV(vctx, values, ch)
+			values = []*storage.KeyValue{}
+		}
+		values = append(values, &storage.KeyValue{K: itKey, V: itValue})
+	}
+	if err = it.Error(); err != nil {
+		ch <- errorableKV{nil, err}
+		return
+	}
+	sendKV(vctx, values, ch)
+	ch <- errorableKV{nil, nil}
+}
+
+// unversionedRange sends a range of key-value pairs down a channel.
+func (db *LevelDB) unversionedRange(ctx storage.Context, begTKey, endTKey storage.TKey, ch chan errorableKV, done <-chan struct{}, keysOnly bool) {
+	// Apply context if applicable
+	begKey := ctx.ConstructKey(begTKey)
+	endKey := ctx.ConstructKey(endTKey)
+
+	it := db.newIterator(begKey, endKey)
+	defer it.Release()
+
+	var itValue []byte
+	for it.Next() {
+		if !keysOnly {
+			itValue = copyBytes(it.Value())
+			storage.StoreValueBytesRead <- len(itValue)
+		}
+		itKey := copyBytes(it.Key())
+		storage.StoreKeyBytesRead <- len(itKey)
+		select {
+		case <-done:
+			ch <- errorableKV{nil, nil}
+			return
+		case ch <- errorableKV{&storage.KeyValue{K: itKey, V: itValue}, nil}:
+		}
+	}
+	if err := it.Error(); err != nil {
+		ch <- errorableKV{nil, err}
+	} else {
+		ch <- errorableKV{nil, nil}
+	}
+}
+
+// rangeQuery starts a range query on a potentially versioned key in a goroutine.
+func (db *LevelDB) rangeQuery(ctx storage.Context, kStart, kEnd storage.TKey, ch chan errorableKV, done <-chan struct{}, keysOnly bool) {
+	go func() {
+		if !ctx.Versioned() {
+			db.unversionedRange(ctx, kStart, kEnd, ch, done, keysOnly)
+		} else {
+			db.versionedRange(ctx.(storage.VersionedCtx), kStart, kEnd, ch, done, keysOnly)
+		}
+	}()
+}
+
+// KeysInRange returns a range of present keys spanning (kStart, kEnd).  Values
+// associated with the keys are not read.   If the keys are versioned, only keys
+// in the ancestor path of the current context's version will be returned.
+func (db *LevelDB) KeysInRange(ctx storage.Context, kStart, kEnd storage.TKey) ([]storage.TKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call KeysInRange on nil LevelDB")
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in KeysInRange()")
+	}
+	ch := make(chan errorableKV)
+	done := make(chan struct{})
+	defer close(done)
+	db.rangeQuery(ctx, kStart, kEnd, ch, done, true)
+
+	// Consume the keys.
+	values := []storage.TKey{}
+	for {
+		result := <-ch
+		if result.error != nil {
+			return nil, result.error
+		}
+		if result.KeyValue == nil {
+			return values, nil
+		}
+		tk, err := storage.TKeyFromKey(result.KeyValue.K)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, tk)
+	}
+}
+
+// SendKeysInRange sends a range of keys spanning (kStart, kEnd).  Values
+// associated with the keys are not read.   If the keys are versioned, only keys
+// in the ancestor path of the current context's version will be returned.
+// End of range is marked by a nil key.
+func (db *LevelDB) SendKeysInRange(ctx storage.Context, kStart, kEnd storage.TKey, kch storage.KeyChan) error {
+	if db == nil {
+		return fmt.Errorf("Can't call SendKeysInRange on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in SendKeysInRange()")
+	}
+	ch := make(chan errorableKV)
+	done := make(chan struct{})
+	defer close(done)
+	db.rangeQuery(ctx, kStart, kEnd, ch, done, true)
+
+	// Consume the keys.
+	for {
+		result := <-ch
+		if result.error != nil {
+			kch <- nil
+			return result.error
+		}
+		if result.KeyValue == nil {
+			kch <- nil
+			return nil
+		}
+		kch <- result.KeyValue.K
+	}
+}
+
+// GetRange returns a range of values spanning (kStart, kEnd) keys.  These key-value
+// pairs will be sorted in ascending key order.  If the keys are versioned, all key-value
+// pairs for the particular version will be returned.
+func (db *LevelDB) GetRange(ctx storage.Context, kStart, kEnd storage.TKey) ([]*storage.TKeyValue, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call GetRange on nil LevelDB")
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in GetRange()")
+	}
+	ch := make(chan errorableKV)
+	done := make(chan struct{})
+	defer close(done)
+	db.rangeQuery(ctx, kStart, kEnd, ch, done, false)
+
+	// Consume the key-value pairs.
+	values := []*storage.TKeyValue{}
+	for {
+		result := <-ch
+		if result.error != nil {
+			return nil, result.error
+		}
+		if result.KeyValue == nil {
+			return values, nil
+		}
+		tk, err := storage.TKeyFromKey(result.KeyValue.K)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, &storage.TKeyValue{K: tk, V: result.KeyValue.V})
+	}
+}
+
+// ProcessRange sends a range of key-value pairs to chunk handlers.  If the keys are versioned,
+// only key-value pairs for kStart's version will be transmitted.  If f returns an error, the
+// function is immediately terminated and returns an error.
+func (db *LevelDB) ProcessRange(ctx storage.Context, kStart, kEnd storage.TKey, op *storage.ChunkOp, f storage.ChunkFunc) error {
+	if db == nil {
+		return fmt.Errorf("Can't call ProcessRange on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in ProcessRange()")
+	}
+	ch := make(chan errorableKV)
+	done := make(chan struct{})
+	defer close(done)
+	db.rangeQuery(ctx, kStart, kEnd, ch, done, false)
+
+	// Consume the key-value pairs.
+	for {
+		result := <-ch
+		if result.error != nil {
+			return result.error
+		}
+		if result.KeyValue == nil {
+			return nil
+		}
+		if op != nil && op.Wg != nil {
+			op.Wg.Add(1)
+		}
+		tk, err := storage.TKeyFromKey(result.KeyValue.K)
+		if err != nil {
+			return err
+		}
+		tkv := storage.TKeyValue{K: tk, V: result.KeyValue.V}
+		chunk := &storage.Chunk{ChunkOp: op, TKeyValue: &tkv}
+		if err := f(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// RawRangeQuery sends a range of full keys.  This is to be used for low-level data
+// retrieval like DVID-to-DVID communication and should not be used by data type
+// implementations if possible.  A nil is sent down the channel when the
+// range is complete.
+func (db *LevelDB) RawRangeQuery(kStart, kEnd storage.Key, keysOnly bool, out chan *storage.KeyValue, cancel <-chan struct{}) error {
+	if db == nil {
+		return fmt.Errorf("Can't call RawRangeQuery on nil LevelDB")
+	}
+	it := db.newIterator(kStart, kEnd)
+	defer it.Release()
+
+	var itValue []byte
+	for it.Next() {
+		if !keysOnly {
+			itValue = copyBytes(it.Value())
+			storage.StoreValueBytesRead <- len(itValue)
+		}
+		itKey := copyBytes(it.Key())
+		storage.StoreKeyBytesRead <- len(itKey)
+		kv := storage.KeyValue{K: itKey, V: itValue}
+		select {
+		case out <- &kv:
+		case <-cancel:
+			return nil
+		}
+	}
+	out <- nil
+	return it.Error()
+}
+
+// ---- KeyValueSetter interface ------
+
+// Put writes a value with given key.
+func (db *LevelDB) Put(ctx storage.Context, tk storage.TKey, v []byte) error {
+	if db == nil {
+		return fmt.Errorf("Can't call Put on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Put()")
+	}
+	var err error
+	key := ctx.ConstructKey(tk)
+	if !ctx.Versioned() {
+		err = db.ldb.Put(key, v, db.options.WriteOptions)
+	} else {
+		vctx, ok := ctx.(storage.VersionedCtx)
+		if !ok {
+			return fmt.Errorf("Non-versioned context that says it's versioned received in Put(): %v", ctx)
+		}
+		batch := new(leveldb.Batch)
+		batch.Delete(vctx.TombstoneKey(tk))
+		batch.Put(key, v)
+		if err = db.ldb.Write(batch, db.options.WriteOptions); err != nil {
+			dvid.Criticalf("Error on batch commit of Put: %v\n", err)
+			err = fmt.Errorf("Error on batch commit of Put: %v", err)
+		}
+	}
+
+	storage.StoreKeyBytesWritten <- len(key)
+	storage.StoreValueBytesWritten <- len(v)
+	return err
+}
+
+// RawPut is a low-level function that puts a key-value pair using full keys.
+// This can be used in conjunction with RawRangeQuery.
+func (db *LevelDB) RawPut(k storage.Key, v []byte) error {
+	if db == nil {
+		return fmt.Errorf("Can't call RawPut on nil LevelDB")
+	}
+	if err := db.ldb.Put(k, v, db.options.WriteOptions); err != nil {
+		return err
+	}
+
+	storage.StoreKeyBytesWritten <- len(k)
+	storage.StoreValueBytesWritten <- len(v)
+	return nil
+}
+
+// Delete removes a value with given key.
+func (db *LevelDB) Delete(ctx storage.Context, tk storage.TKey) error {
+	if db == nil {
+		return fmt.Errorf("Can't call Delete on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Delete()")
+	}
+	var err error
+	key := ctx.ConstructKey(tk)
+	if !ctx.Versioned() {
+		err = db.ldb.Delete(key, db.options.WriteOptions)
+	} else {
+		vctx, ok := ctx.(storage.VersionedCtx)
+		if !ok {
+			return fmt.Errorf("Non-versioned context that says it's versioned received in Delete(): %v", ctx)
+		}
+		batch := new(leveldb.Batch)
+		batch.Delete(key)
+		batch.Put(vctx.TombstoneKey(tk), dvid.EmptyValue())
+		if err = db.ldb.Write(batch, db.options.WriteOptions); err != nil {
+			dvid.Criticalf("Error on batch commit of Delete: %v\n", err)
+			err = fmt.Errorf("Error on batch commit of Delete: %v", err)
+		}
+	}
+	return err
+}
+
+// RawDelete is a low-level function.  It deletes a key-value pair using full keys
+// without any context.  This can be used in conjunction with RawRangeQuery.
+func (db *LevelDB) RawDelete(k storage.Key) error {
+	if db == nil {
+		return fmt.Errorf("Can't call RawDelete on nil LevelDB")
+	}
+	return db.ldb.Delete(k, db.options.WriteOptions)
+}
+
+// ---- OrderedKeyValueSetter interface ------
+
+// PutRange puts type key-value pairs that have been sorted in sequential key order.
+func (db *LevelDB) PutRange(ctx storage.Context, kvs []storage.TKeyValue) error {
+	if db == nil {
+		return fmt.Errorf("Can't call PutRange on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in PutRange()")
+	}
+	batch := db.NewBatch(ctx)
+	for _, kv := range kvs {
+		batch.Put(kv.K, kv.V)
+	}
+	if err := batch.Commit(); err != nil {
+		dvid.Criticalf("Error on batch commit of PutRange: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteRange removes all key-value pairs with keys in the given range.
+func (db *LevelDB) DeleteRange(ctx storage.Context, kStart, kEnd storage.TKey) error {
+	if db == nil {
+		return fmt.Errorf("Can't call DeleteRange on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteRange()")
+	}
+
+	// Iterate over keys in range and delete each one using batch.
+	const BATCH_SIZE = 10000
+	batch := db.NewBatch(ctx)
+
+	ch := make(chan errorableKV)
+	done := make(chan struct{})
+	defer close(done)
+	db.rangeQuery(ctx, kStart, kEnd, ch, done, true)
+
+	numKV := 0
+	for {
+		result := <-ch
+		if result.error != nil {
+			return result.error
+		}
+		if result.KeyValue == nil {
+			break
+		}
+
+		// If versioned, batch.Delete writes a tombstone using current version id since
+		// we don't want to delete locked ancestors.
+		tk, err := storage.TKeyFromKey(result.KeyValue.K)
+		if err != nil {
+			return err
+		}
+		batch.Delete(tk)
+
+		if (numKV+1)%BATCH_SIZE == 0 {
+			if err := batch.Commit(); err != nil {
+				dvid.Criticalf("Error on batch commit of DeleteRange at key-value pair %d: %v\n", numKV, err)
+				return fmt.Errorf("Error on batch commit of DeleteRange at key-value pair %d: %v", numKV, err)
+			}
+			batch = db.NewBatch(ctx)
+		}
+		numKV++
+	}
+	if numKV%BATCH_SIZE != 0 {
+		if err := batch.Commit(); err != nil {
+			dvid.Criticalf("Error on last batch commit of DeleteRange: %v\n", err)
+			return fmt.Errorf("Error on last batch commit of DeleteRange: %v", err)
+		}
+	}
+	dvid.Debugf("Deleted %d key-value pairs via delete range for %s.\n", numKV, ctx)
+	return nil
+}
+
+// DeleteAll deletes all key-value associated with a context (data instance and version).
+func (db *LevelDB) DeleteAll(ctx storage.Context, allVersions bool) error {
+	if db == nil {
+		return fmt.Errorf("Can't call DeleteAll on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteAll()")
+	}
+	var minKey, maxKey storage.Key
+	vctx, versioned := ctx.(storage.VersionedCtx)
+	if versioned {
+		var err error
+		if minKey, err = vctx.MinVersionKey(storage.MinTKey(storage.TKeyMinClass)); err != nil {
+			return err
+		}
+		if maxKey, err = vctx.MaxVersionKey(storage.MaxTKey(storage.TKeyMaxClass)); err != nil {
+			return err
+		}
+	} else {
+		if !allVersions {
+			return fmt.Errorf("Can't ask for versioned delete from unversioned context: %s", ctx)
+		}
+		minKey, maxKey = ctx.KeyRange()
+	}
+	numKV, _, err := db.deleteKeys(minKey, maxKey, func(key storage.Key) (bool, error) {
+		if allVersions {
+			return true, nil
+		}
+		_, v, _, err := storage.DataKeyToLocalIDs(key)
+		if err != nil {
+			return false, fmt.Errorf("Error on DELETE ALL for version %d: %v", vctx.VersionID(), err)
+		}
+		return v == vctx.VersionID(), nil
+	})
+	if err != nil {
+		return err
+	}
+	dvid.Debugf("Deleted %d key-value pairs via DELETE ALL for %s.\n", numKV, ctx)
+	return nil
+}
+
+// ---- TKeyClassDeleter interface ------
+
+func (db *LevelDB) DeleteTKeyClass(ctx storage.Context, tkc storage.TKeyClass, allVersions bool) error {
+	if db == nil {
+		return fmt.Errorf("Can't call DeleteTKeyClass() on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteTKeyClass()")
+	}
+	vctx, versioned := ctx.(storage.VersionedCtx)
+	if !versioned {
+		return fmt.Errorf("Can't call DeleteTKeyClass with an unversioned context: %s", ctx)
+	}
+	minKey, err := vctx.MinVersionKey(storage.MinTKey(tkc))
+	if err != nil {
+		return err
+	}
+	maxKey, err := vctx.MaxVersionKey(storage.MaxTKey(tkc))
+	if err != nil {
+		return err
+	}
+	timedLog := dvid.NewTimeLog()
+	deleteVersion := vctx.VersionID()
+	numKV, numKVskipped, err := db.deleteKeys(minKey, maxKey, func(key storage.Key) (bool, error) {
+		if allVersions {
+			return true, nil
+		}
+		_, v, _, err := storage.DataKeyToLocalIDs(key)
+		if err != nil {
+			return false, fmt.Errorf("Error on DeleteTKeyClass: %v", err)
+		}
+		return v == deleteVersion, nil
+	})
+	if err != nil {
+		return err
+	}
+	timedLog.Infof("Deleted %d of %d key-value pairs in DeleteTKeyClass for data %s", numKV, numKV+numKVskipped, vctx.Data().DataName())
+	return nil
+}
+
+// deleteKeys deletes in batches all full keys within the given range for which the
+// shouldDelete function returns true.  Returns the number of deleted and skipped keys.
+func (db *LevelDB) deleteKeys(minKey, maxKey storage.Key, shouldDelete func(storage.Key) (bool, error)) (numKV, numKVskipped uint64, err error) {
+	const BATCH_SIZE = 10000
+	batch := new(leveldb.Batch)
+
+	it := db.newIterator(minKey, maxKey)
+	defer it.Release()
+
+	for it.Next() {
+		itKey := copyBytes(it.Key())
+		storage.StoreKeyBytesRead <- len(itKey)
+		var del bool
+		if del, err = shouldDelete(itKey); err != nil {
+			return
+		}
+		if !del {
+			numKVskipped++
+			continue
+		}
+		batch.Delete(itKey)
+		numKV++
+		if numKV%BATCH_SIZE == 0 {
+			if err = db.ldb.Write(batch, db.options.WriteOptions); err != nil {
+				dvid.Criticalf("Error on batch commit of deletion at key-value pair %d: %v\n", numKV, err)
+				return
+			}
+			batch.Reset()
+			dvid.Debugf("Deleted %d key-value pairs in ongoing deletion for %s.\n", numKV, db)
+		}
+	}
+	if err = it.Error(); err != nil {
+		return
+	}
+	if batch.Len() != 0 {
+		if err = db.ldb.Write(batch, db.options.WriteOptions); err != nil {
+			dvid.Criticalf("Error on last batch commit of deletion: %v\n", err)
+		}
+	}
+	return
+}
+
+// --- Batcher interface ----
+
+type goBatch struct {
+	ctx  storage.Context
+	vctx storage.VersionedCtx
+	*leveldb.Batch
+	db *LevelDB
+}
+
+// NewBatch returns an implementation that allows batch writes
+func (db *LevelDB) NewBatch(ctx storage.Context) storage.Batch {
+	if db == nil {
+		dvid.Criticalf("Can't call NewBatch on nil LevelDB\n")
+		return nil
+	}
+	if ctx == nil {
+		dvid.Criticalf("Received nil context in NewBatch()")
+		return nil
+	}
+	vctx, _ := ctx.(storage.VersionedCtx)
+	return &goBatch{ctx, vctx, new(leveldb.Batch), db}
+}
+
+// --- Batch interface ---
+
+func (batch *goBatch) Delete(tk storage.TKey) {
+	if batch == nil || batch.ctx == nil {
+		dvid.Criticalf("Received nil batch or nil batch context in batch.Delete()\n")
+		return
+	}
+	key := batch.ctx.ConstructKey(tk)
+	if batch.vctx != nil {
+		tombstone := batch.vctx.TombstoneKey(tk) // This will now have current version
+		batch.Batch.Put(tombstone, dvid.EmptyValue())
+	}
+	batch.Batch.Delete(key)
+}
+
+func (batch *goBatch) Put(tk storage.TKey, v []byte) {
+	if batch == nil || batch.ctx == nil {
+		dvid.Criticalf("Received nil batch or nil batch context in batch.Put()\n")
+		return
+	}
+	key := batch.ctx.ConstructKey(tk)
+	if batch.vctx != nil {
+		tombstone := batch.vctx.TombstoneKey(tk) // This will now have current version
+		batch.Batch.Delete(tombstone)
+	}
+	storage.StoreKeyBytesWritten <- len(key)
+	storage.StoreValueBytesWritten <- len(v)
+	batch.Batch.Put(key, v)
+}
+
+func (batch *goBatch) Commit() error {
+	if batch == nil {
+		return fmt.Errorf("Received nil batch in batch.Commit()\n")
+	}
+	return batch.db.ldb.Write(batch.Batch, batch.db.options.WriteOptions)
+}
+
+// ---- TransactionDB interface ------
+
+// keyLocks tracks keys locked within this process, which is sufficient since
+// the embedded database can only be opened by one process at a time.
+type keyLocks struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	held map[string]struct{}
+}
+
+func newKeyLocks() *keyLocks {
+	kl := &keyLocks{held: make(map[string]struct{})}
+	kl.cond = sync.NewCond(&kl.mu)
+	return kl
+}
+
+func (kl *keyLocks) lock(k storage.Key) {
+	kl.mu.Lock()
+	for {
+		if _, locked := kl.held[string(k)]; !locked {
+			break
+		}
+		kl.cond.Wait()
+	}
+	kl.held[string(k)] = struct{}{}
+	kl.mu.Unlock()
+}
+
+func (kl *keyLocks) unlock(k storage.Key) error {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if _, locked := kl.held[string(k)]; !locked {
+		return fmt.Errorf("key %v is not locked", k)
+	}
+	delete(kl.held, string(k))
+	kl.cond.Broadcast()
+	return nil
+}
+
+// LockKey blocks until the given key is not locked and then locks it.
+func (db *LevelDB) LockKey(k storage.Key) error {
+	if db == nil {
+		return fmt.Errorf("Can't call LockKey on nil LevelDB")
+	}
+	db.locks.lock(k)
+	return nil
+}
+
+// UnlockKey releases the lock on the given key.
+func (db *LevelDB) UnlockKey(k storage.Key) error {
+	if db == nil {
+		return fmt.Errorf("Can't call UnlockKey on nil LevelDB")
+	}
+	return db.locks.unlock(k)
+}
+
+// Patch patches the value at the given key with function f.  Concurrent patches
+// of the same key are serialized.  The patching function should work on
+// uninitialized data.
+func (db *LevelDB) Patch(ctx storage.Context, tk storage.TKey, f storage.PatchFunc) error {
+	if db == nil {
+		return fmt.Errorf("Can't call Patch on nil LevelDB")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Patch()")
+	}
+	key := ctx.ConstructKey(tk)
+	db.locks.lock(key)
+	defer db.locks.unlock(key)
+
+	val, err := db.Get(ctx, tk)
+	if err != nil {
+		return err
+	}
+	if val, err = f(val); err != nil {
+		return err
+	}
+	return db.Put(ctx, tk, val)
+}
+
+// ---- SnapshotDB interface ----
+
+type levelSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+// NewSnapshot returns a leveldb snapshot that provides a consistent view of the database
+// as of this call while writes continue.
+func (db *LevelDB) NewSnapshot() (storage.Snapshot, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call NewSnapshot on nil LevelDB")
+	}
+	snap, err := db.ldb.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &levelSnapshot{snap}, nil
+}
+
+// SendKeyValues iterates through the entire snapshot without filling the block cache.
+func (s *levelSnapshot) SendKeyValues(f func(*storage.KeyValue) error) error {
+	it := s.snap.NewIterator(nil, &opt.ReadOptions{DontFillCache: true})
+	defer it.Release()
+
+	for it.Next() {
+		itKey := copyBytes(it.Key())
+		itValue := copyBytes(it.Value())
+		storage.StoreKeyBytesRead <- len(itKey)
+		storage.StoreValueBytesRead <- len(itValue)
+		if err := f(&storage.KeyValue{K: itKey, V: itValue}); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (s *levelSnapshot) Release() {
+	s.snap.Release()
+}
+
+// ---- SizeViewer interface ------
+
+func (db *LevelDB) GetApproximateSizes(ranges []storage.KeyRange) ([]uint64, error) {
+	lr := make([]util.Range, len(ranges))
+	for i, kr := range ranges {
+		lr[i] = util.Range{
+			Start: []byte(kr.Start),
+			Limit: []byte(kr.OpenEnd),
+		}
+	}
+	sizes, err := db.ldb.SizeOf(lr)
+	if err != nil {
+		return nil, err
+	}
+	usizes := make([]uint64, len(sizes))
+	for i, size := range sizes {
+		usizes[i] = uint64(size)
+	}
+	return usizes, nil
+}
+
+// ---- BlobStore interface ----
+
+// PutBlob writes unversioned data and returns a filename-friendly base64 encoding of the reference.
+func (db *LevelDB) PutBlob(v []byte) (ref string, err error) {
+	if db == nil {
+		return "", fmt.Errorf("Can't call PutBlob on nil LevelDB")
+	}
+	if db.options == nil {
+		return "", fmt.Errorf("Can't call PutBlob on db with nil options: %v", db)
+	}
+	h := fnv.New128()
+	if _, err = h.Write(v); err != nil {
+		return
+	}
+	contentHash := h.Sum(nil)
+	key := storage.ConstructBlobKey(contentHash)
+	err = db.ldb.Put(key, v, db.options.WriteOptions)
+
+	storage.StoreKeyBytesWritten <- len(key)
+	storage.StoreValueBytesWritten <- len(v)
+
+	b64key := base64.URLEncoding.EncodeToString(contentHash)
+	return b64key, err
+}
+
+// GetBlob returns unversioned data given a reference.
+func (db *LevelDB) GetBlob(ref string) (v []byte, err error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call GetBlob on nil LevelDB")
+	}
+	if db.options == nil {
+		return nil, fmt.Errorf("Can't call GetBlob on db with nil options: %v", db)
+	}
+	var contentHash []byte
+	if contentHash, err = base64.URLEncoding.DecodeString(ref); err != nil {
+		return
+	}
+	return db.get(storage.ConstructBlobKey(contentHash))
+}
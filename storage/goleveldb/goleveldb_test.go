@@ -0,0 +1,175 @@
+// +build goleveldb
+
+package goleveldb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+func newTestStore(t *testing.T, path string) (*LevelDB, bool) {
+	var c dvid.Config
+	c.SetAll(map[string]interface{}{"path": path})
+	store, created, err := Engine{name: "goleveldb"}.NewStore(dvid.StoreConfig{Config: c, Engine: "goleveldb"})
+	if err != nil {
+		t.Fatalf("unable to create goleveldb store: %v\n", err)
+	}
+	return store.(*LevelDB), created
+}
+
+func TestGoLevelDBStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-goleveldb-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, created := newTestStore(t, filepath.Join(dir, "db"))
+	defer db.Close()
+	if !created {
+		t.Errorf("expected new store to be created\n")
+	}
+
+	var ctx storage.MetadataContext
+	batch := db.NewBatch(ctx)
+	for i := 0; i < 2500; i++ {
+		batch.Put(storage.NewTKey(1, []byte(fmt.Sprintf("key%04d", i))), []byte(fmt.Sprintf("value%d", i)))
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("unable to commit batch: %v\n", err)
+	}
+	value, err := db.Get(ctx, storage.NewTKey(1, []byte("key0042")))
+	if err != nil {
+		t.Fatalf("unable to get value: %v\n", err)
+	}
+	if string(value) != "value42" {
+		t.Errorf("expected value42, got %q\n", value)
+	}
+	if value, err := db.Get(ctx, storage.NewTKey(1, []byte("missing"))); err != nil || value != nil {
+		t.Errorf("expected nil value and error for missing key, got %q, %v\n", value, err)
+	}
+	found, err := db.Exists(ctx, storage.NewTKey(1, []byte("key0042")))
+	if err != nil || !found {
+		t.Errorf("expected key0042 to exist, got %t, %v\n", found, err)
+	}
+
+	kvs, err := db.GetRange(ctx, storage.NewTKey(1, []byte("key0100")), storage.NewTKey(1, []byte("key2199")))
+	if err != nil {
+		t.Fatalf("unable to get range: %v\n", err)
+	}
+	if len(kvs) != 2100 {
+		t.Fatalf("expected 2100 key-values in range, got %d\n", len(kvs))
+	}
+	for i, kv := range kvs {
+		expected := fmt.Sprintf("value%d", i+100)
+		if string(kv.V) != expected {
+			t.Fatalf("expected %q for range element %d, got %q\n", expected, i, kv.V)
+		}
+	}
+
+	if err := db.DeleteRange(ctx, storage.NewTKey(1, []byte("key1000")), storage.NewTKey(1, []byte("key2499"))); err != nil {
+		t.Fatalf("unable to delete range: %v\n", err)
+	}
+	keys, err := db.KeysInRange(ctx, storage.MinTKey(1), storage.MaxTKey(1))
+	if err != nil {
+		t.Fatalf("unable to get keys in range: %v\n", err)
+	}
+	if len(keys) != 1000 {
+		t.Errorf("expected 1000 keys after delete range, got %d\n", len(keys))
+	}
+
+	// Patch should work on missing data.
+	tk := storage.NewTKey(2, nil)
+	for i := 0; i < 3; i++ {
+		err := db.Patch(ctx, tk, func(data []byte) ([]byte, error) {
+			return append(data, 'x'), nil
+		})
+		if err != nil {
+			t.Fatalf("unable to patch: %v\n", err)
+		}
+	}
+	if value, _ := db.Get(ctx, tk); string(value) != "xxx" {
+		t.Errorf("expected patched value %q, got %q\n", "xxx", value)
+	}
+
+	ref, err := db.PutBlob([]byte("my blob"))
+	if err != nil {
+		t.Fatalf("unable to put blob: %v\n", err)
+	}
+	if blob, err := db.GetBlob(ref); err != nil || string(blob) != "my blob" {
+		t.Errorf("bad blob retrieval: %q, %v\n", blob, err)
+	}
+
+	var c dvid.Config
+	c.SetAll(map[string]interface{}{"path": filepath.Join(dir, "other")})
+	if db.Equal(dvid.StoreConfig{Config: c, Engine: "goleveldb"}) {
+		t.Errorf("expected store to differ from one with another path\n")
+	}
+}
+
+func TestGoLevelDBReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dvid-goleveldb-test")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db")
+
+	var ctx storage.MetadataContext
+	db, _ := newTestStore(t, path)
+	for i := 0; i < 100; i++ {
+		value := bytes.Repeat([]byte{byte(i)}, i)
+		if err := db.Put(ctx, storage.NewTKey(1, []byte{byte(i)}), value); err != nil {
+			t.Fatalf("unable to put: %v\n", err)
+		}
+	}
+	snapshot, err := db.NewSnapshot()
+	if err != nil {
+		t.Fatalf("unable to create snapshot: %v\n", err)
+	}
+	if err := db.Delete(ctx, storage.NewTKey(1, []byte{0})); err != nil {
+		t.Fatalf("unable to delete: %v\n", err)
+	}
+	var numSnapshotKV int
+	err = snapshot.SendKeyValues(func(kv *storage.KeyValue) error {
+		numSnapshotKV++
+		return nil
+	})
+	snapshot.Release()
+	if err != nil {
+		t.Fatalf("unable to read snapshot: %v\n", err)
+	}
+	if numSnapshotKV < 100 {
+		t.Errorf("expected snapshot to hold at least 100 key-values, got %d\n", numSnapshotKV)
+	}
+	db.Close()
+
+	if err := (Engine{name: "goleveldb"}).Repair(path); err != nil {
+		t.Fatalf("unable to repair database: %v\n", err)
+	}
+
+	db, created := newTestStore(t, path)
+	defer db.Close()
+	if created {
+		t.Errorf("expected reopened store to be found\n")
+	}
+	kvs, err := db.GetRange(ctx, storage.MinTKey(1), storage.MaxTKey(1))
+	if err != nil {
+		t.Fatalf("unable to get range: %v\n", err)
+	}
+	if len(kvs) != 99 {
+		t.Fatalf("expected 99 key-values after reopening, got %d\n", len(kvs))
+	}
+	for i, kv := range kvs {
+		if !bytes.Equal(kv.V, bytes.Repeat([]byte{byte(i + 1)}, i+1)) {
+			t.Errorf("bad value for key %d after reopening\n", i+1)
+		}
+	}
+}
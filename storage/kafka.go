@@ -5,17 +5,11 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
-	"time"
 
 	"github.com/janelia-flyem/dvid/dvid"
-
-	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
 
 var (
-	// global producer
-	kafkaProducer *kafka.Producer
-
 	// the kafka topic for activity logging
 	kafkaActivityTopic string
 
@@ -83,25 +77,7 @@ func (kc KafkaConfig) Initialize(hostID string) error {
 	}
 	kafkaActivityTopic = reg.ReplaceAllString(kafkaActivityTopic, "-")
 
-	configMap := &kafka.ConfigMap{
-		"client.id":         "dvid-kafkaclient",
-		"bootstrap.servers": strings.Join(kc.Servers, ","),
-	}
-	if kafkaProducer, err = kafka.NewProducer(configMap); err != nil {
-		return err
-	}
-
-	go func() {
-		for e := range kafkaProducer.Events() {
-			switch ev := e.(type) {
-			case *kafka.Message:
-				if ev.TopicPartition.Error != nil {
-					dvid.Errorf("Delivery failed to kafka servers: %v\n", ev.TopicPartition)
-				}
-			}
-		}
-	}()
-	return nil
+	return startKafkaProducer(kc.Servers)
 }
 
 // LogActivityToKafka publishes activity
@@ -121,24 +97,17 @@ func LogActivityToKafka(activity map[string]interface{}) {
 
 // KafkaProduceMsg sends a message to kafka
 func KafkaProduceMsg(value []byte, topic string) error {
-	if kafkaProducer != nil {
-		kafkaMsg := &kafka.Message{
-			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
-			Value:          value,
-			Timestamp:      time.Now(),
+	if err := produceKafkaMsg(value, topic); err != nil {
+		// Store data in append-only log
+		storeFailedMsg("failed-kafka-"+topic, value)
+
+		// Notify via email at least once per 10 minutes
+		notification := fmt.Sprintf("Error in kafka messaging to topic %q, partition id %d: %v\n", topic, partitionID, err)
+		if err := dvid.SendEmail("Kafka Error", notification, nil, "kakfa"); err != nil {
+			dvid.Errorf("couldn't send email about kafka error: %v\n", err)
 		}
-		if err := kafkaProducer.Produce(kafkaMsg, nil); err != nil {
-			// Store data in append-only log
-			storeFailedMsg("failed-kafka-"+topic, value)
-
-			// Notify via email at least once per 10 minutes
-			notification := fmt.Sprintf("Error in kafka messaging to topic %q, partition id %d: %v\n", topic, partitionID, err)
-			if err := dvid.SendEmail("Kafka Error", notification, nil, "kakfa"); err != nil {
-				dvid.Errorf("couldn't send email about kafka error: %v\n", err)
-			}
 
-			return fmt.Errorf("cannot produce message to topic %q, partition %d: %s", topic, partitionID, err)
-		}
+		return fmt.Errorf("cannot produce message to topic %q, partition %d: %s", topic, partitionID, err)
 	}
 	return nil
 }
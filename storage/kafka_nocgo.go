@@ -0,0 +1,15 @@
+// +build !cgo
+
+package storage
+
+import "fmt"
+
+// startKafkaProducer returns an error since the kafka client library requires cgo.
+func startKafkaProducer(servers []string) error {
+	return fmt.Errorf("kafka messaging is unavailable in DVID servers built without cgo")
+}
+
+// produceKafkaMsg does nothing since no kafka producer can be started without cgo.
+func produceKafkaMsg(value []byte, topic string) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+// +build cgo
+
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/janelia-flyem/dvid/dvid"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// global producer
+var kafkaProducer *kafka.Producer
+
+// startKafkaProducer connects to the kafka servers and launches goroutine for handling
+// async kafka messages.
+func startKafkaProducer(servers []string) error {
+	configMap := &kafka.ConfigMap{
+		"client.id":         "dvid-kafkaclient",
+		"bootstrap.servers": strings.Join(servers, ","),
+	}
+	var err error
+	if kafkaProducer, err = kafka.NewProducer(configMap); err != nil {
+		return err
+	}
+
+	go func() {
+		for e := range kafkaProducer.Events() {
+			switch ev := e.(type) {
+			case *kafka.Message:
+				if ev.TopicPartition.Error != nil {
+					dvid.Errorf("Delivery failed to kafka servers: %v\n", ev.TopicPartition)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// produceKafkaMsg sends a message to kafka if a producer has been started.
+func produceKafkaMsg(value []byte, topic string) error {
+	if kafkaProducer == nil {
+		return nil
+	}
+	kafkaMsg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          value,
+		Timestamp:      time.Now(),
+	}
+	return kafkaProducer.Produce(kafkaMsg, nil)
+}
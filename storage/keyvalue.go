@@ -330,6 +330,22 @@ type TransactionDB interface {
 	Patch(Context, TKey, PatchFunc) error
 }
 
+// SnapshotDB stores can provide a consistent, point-in-time view of all their key-value
+// pairs while continuing to serve reads and writes, e.g., for online backups.
+type SnapshotDB interface {
+	NewSnapshot() (Snapshot, error)
+}
+
+// Snapshot is a read-only view of a store at the time the snapshot was created.
+type Snapshot interface {
+	// SendKeyValues sends all key-value pairs in the snapshot to f in key order.
+	// If f returns an error, iteration stops and that error is returned.
+	SendKeyValues(f func(*KeyValue) error) error
+
+	// Release frees any resources held by the snapshot.
+	Release()
+}
+
 // RequestBufferSubset implements a subset of the ordered key/value interface.
 // It declares interface common to both ordered key value and RequestBuffer
 type BufferableOps interface {
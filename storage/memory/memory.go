@@ -0,0 +1,987 @@
+// +build memory
+
+/*
+	Package memory implements an in-memory ordered key-value storage engine.  It is useful
+	for fast tests and for short-lived servers, e.g., for analysis, where the data does not
+	need to persist.  If a "path" is given in the store configuration, it is used as a
+	snapshot file: the store is loaded from that file when opened and saved to it on shutdown.
+*/
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/google/btree"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+	"github.com/janelia-flyem/go/semver"
+)
+
+const (
+	// Degree of the in-memory B-tree.
+	treeDegree = 32
+
+	// Number of key-value pairs read while holding the lock during range queries.
+	// Processing of each chunk occurs without the lock so long range queries don't
+	// block writers.
+	scanChunkSize = 1000
+
+	// Identifies snapshot files and their format.
+	snapshotHeader = "DVIDMEM1"
+)
+
+func init() {
+	ver, err := semver.Make("0.1.0")
+	if err != nil {
+		dvid.Errorf("Unable to make semver in memory engine: %v\n", err)
+	}
+	e := Engine{"memory", "In-memory ordered key-value store", ver}
+	storage.RegisterEngine(e)
+}
+
+// --- Engine Implementation ------
+
+type Engine struct {
+	name   string
+	desc   string
+	semver semver.Version
+}
+
+func (e Engine) GetName() string {
+	return e.name
+}
+
+func (e Engine) GetDescription() string {
+	return e.desc
+}
+
+func (e Engine) IsDistributed() bool {
+	return false
+}
+
+func (e Engine) GetSemVer() semver.Version {
+	return e.semver
+}
+
+func (e Engine) String() string {
+	return fmt.Sprintf("%s [%s]", e.name, e.semver)
+}
+
+// NewStore returns an in-memory store.  If the configuration has a "path" for a snapshot
+// file and the file exists, the store is initialized from the file.
+func (e Engine) NewStore(config dvid.StoreConfig) (dvid.Store, bool, error) {
+	snapshot, err := parseConfig(config)
+	if err != nil {
+		return nil, false, err
+	}
+	db := &memoryDB{
+		snapshot: snapshot,
+		config:   config,
+		tree:     btree.New(treeDegree),
+		locks:    newKeyLocks(),
+	}
+	if snapshot != "" {
+		if _, err := os.Stat(snapshot); err == nil {
+			if err := db.loadSnapshot(); err != nil {
+				return nil, false, err
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+	}
+	metadataExists := db.metadataExists()
+	if !metadataExists {
+		dvid.TimeInfof("No metadata found for %s...\n", db)
+	}
+	return db, !metadataExists, nil
+}
+
+func parseConfig(config dvid.StoreConfig) (snapshot string, err error) {
+	var found bool
+	snapshot, found, err = config.GetString("path")
+	if err != nil || !found {
+		return "", err
+	}
+	return snapshot, nil
+}
+
+// ---- TestableEngine interface implementation -------
+
+// AddTestConfig sets the memory store as the default key-value backend.  If another
+// engine is already set, it returns an error since only one key-value backend should
+// be tested via tags.
+func (e Engine) AddTestConfig(backend *storage.Backend) (storage.Alias, error) {
+	alias := storage.Alias("memory")
+	if backend.DefaultKVDB != "" {
+		return alias, fmt.Errorf("memory can't be testable key-value.  DefaultKVDB already set to %s", backend.DefaultKVDB)
+	}
+	if backend.Metadata != "" {
+		return alias, fmt.Errorf("memory can't be testable key-value.  Metadata already set to %s", backend.Metadata)
+	}
+	backend.Metadata = alias
+	backend.DefaultKVDB = alias
+	if backend.Stores == nil {
+		backend.Stores = make(map[storage.Alias]dvid.StoreConfig)
+	}
+	var c dvid.Config
+	c.SetAll(map[string]interface{}{"testing": true})
+	backend.Stores[alias] = dvid.StoreConfig{Config: c, Engine: "memory"}
+	return alias, nil
+}
+
+// Delete implements the TestableEngine interface.  Testing stores have no snapshot
+// so there is nothing to delete after the store is closed.
+func (e Engine) Delete(config dvid.StoreConfig) error {
+	return nil
+}
+
+// --- The in-memory store ----
+
+type kvItem struct {
+	k, v []byte
+}
+
+func (item *kvItem) Less(than btree.Item) bool {
+	return bytes.Compare(item.k, than.(*kvItem).k) < 0
+}
+
+type memoryDB struct {
+	// Optional file path for loading and saving the store.
+	snapshot string
+
+	// Config at time of Open()
+	config dvid.StoreConfig
+
+	mu   sync.RWMutex
+	tree *btree.BTree
+
+	// locks held via the TransactionDB interface and during patches
+	locks *keyLocks
+}
+
+func (db *memoryDB) String() string {
+	if db.snapshot != "" {
+		return fmt.Sprintf("memory store with snapshot @ %s", db.snapshot)
+	}
+	return "memory store"
+}
+
+// Equal returns true if the store has the same snapshot file as the given configuration.
+// In-memory stores without snapshots are always distinct.
+func (db *memoryDB) Equal(config dvid.StoreConfig) bool {
+	if config.Engine != "memory" || db.snapshot == "" {
+		return false
+	}
+	snapshot, err := parseConfig(config)
+	if err != nil {
+		return false
+	}
+	return snapshot == db.snapshot
+}
+
+// Close saves the store to its snapshot file, if any, and releases the memory.
+func (db *memoryDB) Close() {
+	if db == nil {
+		return
+	}
+	if db.snapshot != "" {
+		timedLog := dvid.NewTimeLog()
+		if err := db.saveSnapshot(); err != nil {
+			dvid.Criticalf("Unable to save snapshot of %s: %v\n", db, err)
+		} else {
+			timedLog.Infof("Saved %d key-value pairs to snapshot %s", db.tree.Len(), db.snapshot)
+		}
+	}
+	db.mu.Lock()
+	db.tree = btree.New(treeDegree)
+	db.mu.Unlock()
+}
+
+// saveSnapshot writes all key-value pairs to a temporary file that is then renamed
+// to the snapshot path so a failed save doesn't clobber the prior snapshot.
+func (db *memoryDB) saveSnapshot() error {
+	tmpPath := db.snapshot + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.WriteString(snapshotHeader); err != nil {
+		f.Close()
+		return err
+	}
+	db.mu.RLock()
+	db.tree.Ascend(func(i btree.Item) bool {
+		item := i.(*kvItem)
+		if err = writeBytes(w, item.k); err != nil {
+			return false
+		}
+		err = writeBytes(w, item.v)
+		return err == nil
+	})
+	db.mu.RUnlock()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, db.snapshot)
+}
+
+func (db *memoryDB) loadSnapshot() error {
+	timedLog := dvid.NewTimeLog()
+	f, err := os.Open(db.snapshot)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	header := make([]byte, len(snapshotHeader))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != snapshotHeader {
+		return fmt.Errorf("file %q is not a memory store snapshot", db.snapshot)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for {
+		k, err := readBytes(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("bad snapshot %q: %v", db.snapshot, err)
+		}
+		v, err := readBytes(r)
+		if err != nil {
+			return fmt.Errorf("bad snapshot %q: %v", db.snapshot, err)
+		}
+		db.tree.ReplaceOrInsert(&kvItem{k, v})
+	}
+	timedLog.Infof("Loaded %d key-value pairs from snapshot %s", db.tree.Len(), db.snapshot)
+	return nil
+}
+
+func writeBytes(w io.Writer, b []byte) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(b)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+func (db *memoryDB) metadataExists() bool {
+	var ctx storage.MetadataContext
+	keyBeg, keyEnd := ctx.KeyRange()
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	var found bool
+	db.tree.AscendGreaterOrEqual(&kvItem{k: keyBeg}, func(i btree.Item) bool {
+		found = bytes.Compare(i.(*kvItem).k, keyEnd) <= 0
+		return false
+	})
+	return found
+}
+
+// ---- primitive operations on full keys ----
+
+func (db *memoryDB) get(k storage.Key) []byte {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if i := db.tree.Get(&kvItem{k: k}); i != nil {
+		v := copyBytes(i.(*kvItem).v)
+		storage.StoreValueBytesRead <- len(v)
+		return v
+	}
+	return nil
+}
+
+func (db *memoryDB) put(k storage.Key, v []byte) {
+	if v == nil {
+		v = []byte{}
+	}
+	db.mu.Lock()
+	db.tree.ReplaceOrInsert(&kvItem{copyBytes(k), copyBytes(v)})
+	db.mu.Unlock()
+	storage.StoreKeyBytesWritten <- len(k)
+	storage.StoreValueBytesWritten <- len(v)
+}
+
+func (db *memoryDB) delete(k storage.Key) {
+	db.mu.Lock()
+	db.tree.Delete(&kvItem{k: k})
+	db.mu.Unlock()
+}
+
+// scan calls f for each key-value pair with full key in the inclusive range [begKey, endKey].
+// If f returns false or an error, the scan stops.  The store is not locked during calls to f,
+// so f may modify the store.
+func (db *memoryDB) scan(begKey, endKey storage.Key, keysOnly bool, f func(k storage.Key, v []byte) (bool, error)) error {
+	pivot := begKey
+	for {
+		chunk := make([]kvItem, 0, scanChunkSize)
+		db.mu.RLock()
+		db.tree.AscendGreaterOrEqual(&kvItem{k: pivot}, func(i btree.Item) bool {
+			item := i.(*kvItem)
+			if bytes.Compare(item.k, endKey) > 0 || len(chunk) == scanChunkSize {
+				return false
+			}
+			kv := kvItem{k: copyBytes(item.k)}
+			if !keysOnly {
+				kv.v = copyBytes(item.v)
+			}
+			chunk = append(chunk, kv)
+			return true
+		})
+		db.mu.RUnlock()
+
+		for _, kv := range chunk {
+			storage.StoreKeyBytesRead <- len(kv.k)
+			if !keysOnly {
+				storage.StoreValueBytesRead <- len(kv.v)
+			}
+			more, err := f(kv.k, kv.v)
+			if err != nil {
+				return err
+			}
+			if !more {
+				return nil
+			}
+		}
+		if len(chunk) < scanChunkSize {
+			return nil
+		}
+		lastKey := chunk[len(chunk)-1].k
+		pivot = append(append([]byte{}, lastKey...), 0) // smallest key after last one read
+	}
+}
+
+// contextRange calls f for each key-value pair for the context within the given range of
+// type-specific keys.  If the context is versioned, only the key-value pairs visible from
+// the context's version are sent.
+func (db *memoryDB) contextRange(ctx storage.Context, kStart, kEnd storage.TKey, keysOnly bool, f func(*storage.KeyValue) error) error {
+	if !ctx.Versioned() {
+		return db.scan(ctx.ConstructKey(kStart), ctx.ConstructKey(kEnd), keysOnly, func(k storage.Key, v []byte) (bool, error) {
+			return true, f(&storage.KeyValue{K: k, V: v})
+		})
+	}
+	vctx, ok := ctx.(storage.VersionedCtx)
+	if !ok {
+		return fmt.Errorf("context is versioned but doesn't fulfill interface: %v", ctx)
+	}
+	minKey, err := vctx.MinVersionKey(kStart)
+	if err != nil {
+		return err
+	}
+	maxKey, err := vctx.MaxVersionKey(kEnd)
+	if err != nil {
+		return err
+	}
+
+	// Group all versions of each type-specific key and send the visible one.
+	var curTKey storage.TKey
+	values := []*storage.KeyValue{}
+	sendVersioned := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		kv, err := vctx.VersionedKeyValue(values)
+		if err != nil {
+			return err
+		}
+		values = []*storage.KeyValue{}
+		if kv != nil {
+			return f(kv)
+		}
+		return nil
+	}
+	err = db.scan(minKey, maxKey, keysOnly, func(k storage.Key, v []byte) (bool, error) {
+		tk, err := storage.TKeyFromKey(k)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(tk, curTKey) {
+			if err := sendVersioned(); err != nil {
+				return false, err
+			}
+			curTKey = tk
+		}
+		values = append(values, &storage.KeyValue{K: k, V: v})
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	return sendVersioned()
+}
+
+// ---- KeyValueChecker interface ------
+
+// Exists returns true if the key exists.
+func (db *memoryDB) Exists(ctx storage.Context, tk storage.TKey) (bool, error) {
+	if ctx == nil {
+		return false, fmt.Errorf("Received nil context in Exists()")
+	}
+	var key storage.Key
+	if ctx.Versioned() {
+		vctx, ok := ctx.(storage.VersionedCtx)
+		if !ok {
+			return false, fmt.Errorf("Bad Exists(): context is versioned but doesn't fulfill interface: %v", ctx)
+		}
+		key = vctx.ConstructKeyVersion(tk, vctx.VersionID())
+	} else {
+		key = ctx.ConstructKey(tk)
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.tree.Has(&kvItem{k: key}), nil
+}
+
+// ---- OrderedKeyValueGetter interface ------
+
+// Get returns a value given a key.
+func (db *memoryDB) Get(ctx storage.Context, tk storage.TKey) ([]byte, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in Get()")
+	}
+	if !ctx.Versioned() {
+		return db.get(ctx.ConstructKey(tk)), nil
+	}
+	vctx, ok := ctx.(storage.VersionedCtx)
+	if !ok {
+		return nil, fmt.Errorf("Bad Get(): context is versioned but doesn't fulfill interface: %v", ctx)
+	}
+	begKey, err := vctx.MinVersionKey(tk)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := vctx.MaxVersionKey(tk)
+	if err != nil {
+		return nil, err
+	}
+	values := []*storage.KeyValue{}
+	err = db.scan(begKey, endKey, false, func(k storage.Key, v []byte) (bool, error) {
+		values = append(values, &storage.KeyValue{K: k, V: v})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	kv, err := vctx.VersionedKeyValue(values)
+	if kv != nil {
+		return kv.V, err
+	}
+	return nil, err
+}
+
+// KeysInRange returns a range of present keys spanning (kStart, kEnd).  Values
+// associated with the keys are not read.   If the keys are versioned, only keys
+// in the ancestor path of the current context's version will be returned.
+func (db *memoryDB) KeysInRange(ctx storage.Context, kStart, kEnd storage.TKey) ([]storage.TKey, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in KeysInRange()")
+	}
+	keys := []storage.TKey{}
+	err := db.contextRange(ctx, kStart, kEnd, true, func(kv *storage.KeyValue) error {
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, tk)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SendKeysInRange sends a range of keys spanning (kStart, kEnd).  Values
+// associated with the keys are not read.   If the keys are versioned, only keys
+// in the ancestor path of the current context's version will be returned.
+// End of range is marked by a nil key.
+func (db *memoryDB) SendKeysInRange(ctx storage.Context, kStart, kEnd storage.TKey, kch storage.KeyChan) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in SendKeysInRange()")
+	}
+	err := db.contextRange(ctx, kStart, kEnd, true, func(kv *storage.KeyValue) error {
+		kch <- kv.K
+		return nil
+	})
+	kch <- nil
+	return err
+}
+
+// GetRange returns a range of values spanning (kStart, kEnd) keys.  These key-value
+// pairs will be sorted in ascending key order.  If the keys are versioned, all key-value
+// pairs for the particular version will be returned.
+func (db *memoryDB) GetRange(ctx storage.Context, kStart, kEnd storage.TKey) ([]*storage.TKeyValue, error) {
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in GetRange()")
+	}
+	values := []*storage.TKeyValue{}
+	err := db.contextRange(ctx, kStart, kEnd, false, func(kv *storage.KeyValue) error {
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			return err
+		}
+		values = append(values, &storage.TKeyValue{K: tk, V: kv.V})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ProcessRange sends a range of key-value pairs to chunk handlers.  If the keys are versioned,
+// only key-value pairs for kStart's version will be transmitted.  If f returns an error, the
+// function is immediately terminated and returns an error.
+func (db *memoryDB) ProcessRange(ctx storage.Context, kStart, kEnd storage.TKey, op *storage.ChunkOp, f storage.ChunkFunc) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in ProcessRange()")
+	}
+	return db.contextRange(ctx, kStart, kEnd, false, func(kv *storage.KeyValue) error {
+		if op != nil && op.Wg != nil {
+			op.Wg.Add(1)
+		}
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			return err
+		}
+		return f(&storage.Chunk{ChunkOp: op, TKeyValue: &storage.TKeyValue{K: tk, V: kv.V}})
+	})
+}
+
+// RawRangeQuery sends a range of full keys.  This is to be used for low-level data
+// retrieval like DVID-to-DVID communication and should not be used by data type
+// implementations if possible.  A nil is sent down the channel when the
+// range is complete.
+func (db *memoryDB) RawRangeQuery(kStart, kEnd storage.Key, keysOnly bool, out chan *storage.KeyValue, cancel <-chan struct{}) error {
+	var cancelled bool
+	err := db.scan(kStart, kEnd, keysOnly, func(k storage.Key, v []byte) (bool, error) {
+		select {
+		case out <- &storage.KeyValue{K: k, V: v}:
+			return true, nil
+		case <-cancel:
+			cancelled = true
+			return false, nil
+		}
+	})
+	if cancelled {
+		return nil
+	}
+	out <- nil
+	return err
+}
+
+// ---- KeyValueSetter interface ------
+
+// Put writes a value with given key.
+func (db *memoryDB) Put(ctx storage.Context, tk storage.TKey, v []byte) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Put()")
+	}
+	batch := db.NewBatch(ctx)
+	batch.Put(tk, v)
+	return batch.Commit()
+}
+
+// RawPut is a low-level function that puts a key-value pair using full keys.
+// This can be used in conjunction with RawRangeQuery.
+func (db *memoryDB) RawPut(k storage.Key, v []byte) error {
+	db.put(k, v)
+	return nil
+}
+
+// Delete removes a value with given key.
+func (db *memoryDB) Delete(ctx storage.Context, tk storage.TKey) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Delete()")
+	}
+	batch := db.NewBatch(ctx)
+	batch.Delete(tk)
+	return batch.Commit()
+}
+
+// RawDelete is a low-level function.  It deletes a key-value pair using full keys
+// without any context.  This can be used in conjunction with RawRangeQuery.
+func (db *memoryDB) RawDelete(k storage.Key) error {
+	db.delete(k)
+	return nil
+}
+
+// ---- OrderedKeyValueSetter interface ------
+
+// PutRange puts type key-value pairs that have been sorted in sequential key order.
+func (db *memoryDB) PutRange(ctx storage.Context, kvs []storage.TKeyValue) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in PutRange()")
+	}
+	batch := db.NewBatch(ctx)
+	for _, kv := range kvs {
+		batch.Put(kv.K, kv.V)
+	}
+	return batch.Commit()
+}
+
+// DeleteRange removes all key-value pairs with keys in the given range.  If versioned,
+// tombstones are written for the context's version.
+func (db *memoryDB) DeleteRange(ctx storage.Context, kStart, kEnd storage.TKey) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteRange()")
+	}
+	batch := db.NewBatch(ctx)
+	var numKV int
+	err := db.contextRange(ctx, kStart, kEnd, true, func(kv *storage.KeyValue) error {
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			return err
+		}
+		batch.Delete(tk)
+		numKV++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := batch.Commit(); err != nil {
+		return err
+	}
+	dvid.Debugf("Deleted %d key-value pairs via delete range for %s.\n", numKV, ctx)
+	return nil
+}
+
+// DeleteAll deletes all key-value associated with a context (data instance and version).
+func (db *memoryDB) DeleteAll(ctx storage.Context, allVersions bool) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteAll()")
+	}
+	var minKey, maxKey storage.Key
+	vctx, versioned := ctx.(storage.VersionedCtx)
+	if versioned {
+		var err error
+		if minKey, err = vctx.MinVersionKey(storage.MinTKey(storage.TKeyMinClass)); err != nil {
+			return err
+		}
+		if maxKey, err = vctx.MaxVersionKey(storage.MaxTKey(storage.TKeyMaxClass)); err != nil {
+			return err
+		}
+	} else {
+		if !allVersions {
+			return fmt.Errorf("Can't ask for versioned delete from unversioned context: %s", ctx)
+		}
+		minKey, maxKey = ctx.KeyRange()
+	}
+	numKV, err := db.deleteVersionKeys(vctx, minKey, maxKey, allVersions)
+	if err != nil {
+		return err
+	}
+	dvid.Debugf("Deleted %d key-value pairs via DELETE ALL for %s.\n", numKV, ctx)
+	return nil
+}
+
+// ---- TKeyClassDeleter interface ------
+
+func (db *memoryDB) DeleteTKeyClass(ctx storage.Context, tkc storage.TKeyClass, allVersions bool) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteTKeyClass()")
+	}
+	vctx, versioned := ctx.(storage.VersionedCtx)
+	if !versioned {
+		return fmt.Errorf("Can't call DeleteTKeyClass with an unversioned context: %s", ctx)
+	}
+	minKey, err := vctx.MinVersionKey(storage.MinTKey(tkc))
+	if err != nil {
+		return err
+	}
+	maxKey, err := vctx.MaxVersionKey(storage.MaxTKey(tkc))
+	if err != nil {
+		return err
+	}
+	numKV, err := db.deleteVersionKeys(vctx, minKey, maxKey, allVersions)
+	if err != nil {
+		return err
+	}
+	dvid.Debugf("Deleted %d key-value pairs in DeleteTKeyClass for %s.\n", numKV, ctx)
+	return nil
+}
+
+// deleteVersionKeys deletes the full keys within the given range, either for all versions
+// or just the given context's version.
+func (db *memoryDB) deleteVersionKeys(vctx storage.VersionedCtx, minKey, maxKey storage.Key, allVersions bool) (numKV int, err error) {
+	var keys []storage.Key
+	err = db.scan(minKey, maxKey, true, func(k storage.Key, v []byte) (bool, error) {
+		if !allVersions {
+			_, version, _, err := storage.DataKeyToLocalIDs(k)
+			if err != nil {
+				return false, err
+			}
+			if version != vctx.VersionID() {
+				return true, nil
+			}
+		}
+		keys = append(keys, k)
+		return true, nil
+	})
+	if err != nil {
+		return
+	}
+	db.mu.Lock()
+	for _, k := range keys {
+		db.tree.Delete(&kvItem{k: k})
+	}
+	db.mu.Unlock()
+	return len(keys), nil
+}
+
+// --- Batcher interface ----
+
+type batchOp struct {
+	del bool
+	k   storage.Key
+	v   []byte
+}
+
+type memoryBatch struct {
+	ctx  storage.Context
+	vctx storage.VersionedCtx
+	ops  []batchOp
+	db   *memoryDB
+}
+
+// NewBatch returns an implementation that allows batch writes
+func (db *memoryDB) NewBatch(ctx storage.Context) storage.Batch {
+	if ctx == nil {
+		dvid.Criticalf("Received nil context in NewBatch()")
+		return nil
+	}
+	vctx, _ := ctx.(storage.VersionedCtx)
+	return &memoryBatch{ctx: ctx, vctx: vctx, db: db}
+}
+
+// --- Batch interface ---
+
+func (batch *memoryBatch) Delete(tk storage.TKey) {
+	if batch == nil || batch.ctx == nil {
+		dvid.Criticalf("Received nil batch or nil batch context in batch.Delete()\n")
+		return
+	}
+	if batch.vctx != nil {
+		tombstone := batch.vctx.TombstoneKey(tk) // This will now have current version
+		batch.ops = append(batch.ops, batchOp{k: tombstone, v: dvid.EmptyValue()})
+	}
+	batch.ops = append(batch.ops, batchOp{del: true, k: batch.ctx.ConstructKey(tk)})
+}
+
+func (batch *memoryBatch) Put(tk storage.TKey, v []byte) {
+	if batch == nil || batch.ctx == nil {
+		dvid.Criticalf("Received nil batch or nil batch context in batch.Put()\n")
+		return
+	}
+	if batch.vctx != nil {
+		tombstone := batch.vctx.TombstoneKey(tk) // This will now have current version
+		batch.ops = append(batch.ops, batchOp{del: true, k: tombstone})
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	key := batch.ctx.ConstructKey(tk)
+	storage.StoreKeyBytesWritten <- len(key)
+	storage.StoreValueBytesWritten <- len(v)
+	batch.ops = append(batch.ops, batchOp{k: key, v: copyBytes(v)})
+}
+
+// Commit atomically applies the batched operations.
+func (batch *memoryBatch) Commit() error {
+	if batch == nil {
+		return fmt.Errorf("Received nil batch in batch.Commit()\n")
+	}
+	batch.db.mu.Lock()
+	for _, op := range batch.ops {
+		if op.del {
+			batch.db.tree.Delete(&kvItem{k: op.k})
+		} else {
+			batch.db.tree.ReplaceOrInsert(&kvItem{op.k, op.v})
+		}
+	}
+	batch.db.mu.Unlock()
+	batch.ops = nil
+	return nil
+}
+
+// ---- SnapshotDB interface ------
+
+type memorySnapshot struct {
+	tree *btree.BTree
+}
+
+// NewSnapshot returns a lazy copy-on-write clone of the tree so later writes to the
+// store don't affect the snapshot.
+func (db *memoryDB) NewSnapshot() (storage.Snapshot, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return &memorySnapshot{db.tree.Clone()}, nil
+}
+
+func (s *memorySnapshot) SendKeyValues(f func(*storage.KeyValue) error) error {
+	var err error
+	s.tree.Ascend(func(i btree.Item) bool {
+		item := i.(*kvItem)
+		err = f(&storage.KeyValue{K: item.k, V: item.v})
+		return err == nil
+	})
+	return err
+}
+
+func (s *memorySnapshot) Release() {
+	s.tree = nil
+}
+
+// ---- TransactionDB interface ------
+
+// keyLocks tracks keys locked within this process.
+type keyLocks struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	held map[string]struct{}
+}
+
+func newKeyLocks() *keyLocks {
+	kl := &keyLocks{held: make(map[string]struct{})}
+	kl.cond = sync.NewCond(&kl.mu)
+	return kl
+}
+
+func (kl *keyLocks) lock(k storage.Key) {
+	kl.mu.Lock()
+	for {
+		if _, locked := kl.held[string(k)]; !locked {
+			break
+		}
+		kl.cond.Wait()
+	}
+	kl.held[string(k)] = struct{}{}
+	kl.mu.Unlock()
+}
+
+func (kl *keyLocks) unlock(k storage.Key) error {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+	if _, locked := kl.held[string(k)]; !locked {
+		return fmt.Errorf("key %v is not locked", k)
+	}
+	delete(kl.held, string(k))
+	kl.cond.Broadcast()
+	return nil
+}
+
+// LockKey blocks until the given key is not locked and then locks it.
+func (db *memoryDB) LockKey(k storage.Key) error {
+	db.locks.lock(k)
+	return nil
+}
+
+// UnlockKey releases the lock on the given key.
+func (db *memoryDB) UnlockKey(k storage.Key) error {
+	return db.locks.unlock(k)
+}
+
+// Patch patches the value at the given key with function f.  Concurrent patches
+// of the same key are serialized.  The patching function should work on
+// uninitialized data.
+func (db *memoryDB) Patch(ctx storage.Context, tk storage.TKey, f storage.PatchFunc) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Patch()")
+	}
+	key := ctx.ConstructKey(tk)
+	db.locks.lock(key)
+	defer db.locks.unlock(key)
+
+	val, err := db.Get(ctx, tk)
+	if err != nil {
+		return err
+	}
+	if val, err = f(val); err != nil {
+		return err
+	}
+	return db.Put(ctx, tk, val)
+}
+
+// ---- SizeViewer interface ------
+
+// GetApproximateSizes returns the number of key and value bytes within each range.
+func (db *memoryDB) GetApproximateSizes(ranges []storage.KeyRange) ([]uint64, error) {
+	sizes := make([]uint64, len(ranges))
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for i, kr := range ranges {
+		db.tree.AscendRange(&kvItem{k: kr.Start}, &kvItem{k: kr.OpenEnd}, func(item btree.Item) bool {
+			kv := item.(*kvItem)
+			sizes[i] += uint64(len(kv.k) + len(kv.v))
+			return true
+		})
+	}
+	return sizes, nil
+}
+
+// ---- BlobStore interface ----
+
+// PutBlob writes unversioned data and returns a filename-friendly base64 encoding of the reference.
+func (db *memoryDB) PutBlob(v []byte) (ref string, err error) {
+	h := fnv.New128()
+	if _, err = h.Write(v); err != nil {
+		return
+	}
+	contentHash := h.Sum(nil)
+	db.put(storage.ConstructBlobKey(contentHash), v)
+	return base64.URLEncoding.EncodeToString(contentHash), nil
+}
+
+// GetBlob returns unversioned data given a reference.
+func (db *memoryDB) GetBlob(ref string) ([]byte, error) {
+	contentHash, err := base64.URLEncoding.DecodeString(ref)
+	if err != nil {
+		return nil, err
+	}
+	return db.get(storage.ConstructBlobKey(contentHash)), nil
+}
@@ -0,0 +1,991 @@
+// +build s3
+
+/*
+	Package s3 implements a storage engine for S3-compatible object stores like AWS S3 or MinIO.
+
+	Like the gbucket engine, each key-value pair is stored as an object whose name is the
+	hex-encoding of the full key, optionally with a configured name prefix.  Hex encoding
+	preserves the lexicographic order of keys, so range queries can use the ordered object
+	listings of the S3 API.  Each version of a key is stored as a separate object, and the
+	value for a versioned context is determined after listing all versions of a key.
+
+	Object stores have no multi-object transactions, so batches are not atomic: their
+	operations are simply executed in parallel on commit.
+*/
+package s3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	api "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+	"github.com/janelia-flyem/go/semver"
+	"github.com/janelia-flyem/go/uuid"
+)
+
+func init() {
+	ver, err := semver.Make("0.1.0")
+	if err != nil {
+		dvid.Errorf("Unable to make semver in s3: %v\n", err)
+	}
+	e := Engine{"s3", "S3-compatible object store", ver}
+	storage.RegisterEngine(e)
+}
+
+const (
+	// limit the number of parallel network ops
+	MAXNETOPS = 256
+
+	// maximum number of objects that can be deleted in one request
+	MAXDELETEOBJECTS = 1000
+
+	// default region if none is given
+	DefaultRegion = "us-east-1"
+)
+
+// --- Engine Implementation ------
+
+type Engine struct {
+	name   string
+	desc   string
+	semver semver.Version
+}
+
+func (e Engine) GetName() string {
+	return e.name
+}
+
+func (e Engine) GetDescription() string {
+	return e.desc
+}
+
+func (e Engine) IsDistributed() bool {
+	return true
+}
+
+func (e Engine) GetSemVer() semver.Version {
+	return e.semver
+}
+
+func (e Engine) String() string {
+	return fmt.Sprintf("%s [%s]", e.name, e.semver)
+}
+
+// NewStore returns an S3 bucket suitable as a general storage engine.  The bucket must
+// already exist.  The passed Config must contain "bucket" and can have these optional
+// settings:
+//
+//	"endpoint": URL of the S3 service, e.g., "http://localhost:9000" for a local MinIO.
+//	"region": region of the bucket, "us-east-1" by default.
+//	"accesskey", "secretkey": credentials.  If not given, the standard AWS environment
+//	    variables, shared credentials file, or instance roles are used.
+//	"pathstyle": if true, use path-style addressing (endpoint/bucket/key) as required by
+//	    most MinIO deployments instead of virtual-hosted buckets.
+//	"prefix": prefix for all object names so a bucket can be shared.
+func (e Engine) NewStore(config dvid.StoreConfig) (dvid.Store, bool, error) {
+	return e.newS3(config)
+}
+
+type s3Config struct {
+	bucket    string
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	prefix    string
+}
+
+func parseConfig(config dvid.StoreConfig) (*s3Config, error) {
+	var sc s3Config
+	var found bool
+	var err error
+	if sc.bucket, found, err = config.GetString("bucket"); err != nil {
+		return nil, err
+	}
+	if !found || sc.bucket == "" {
+		return nil, fmt.Errorf("%q must be specified for s3 configuration", "bucket")
+	}
+	if sc.endpoint, _, err = config.GetString("endpoint"); err != nil {
+		return nil, err
+	}
+	if sc.region, found, err = config.GetString("region"); err != nil {
+		return nil, err
+	}
+	if !found || sc.region == "" {
+		sc.region = DefaultRegion
+	}
+	if sc.accessKey, _, err = config.GetString("accesskey"); err != nil {
+		return nil, err
+	}
+	if sc.secretKey, _, err = config.GetString("secretkey"); err != nil {
+		return nil, err
+	}
+	if v, found := config.GetAll()["pathstyle"]; found {
+		var ok bool
+		if sc.pathStyle, ok = v.(bool); !ok {
+			return nil, fmt.Errorf("%q setting must be a bool (%v)", "pathstyle", v)
+		}
+	}
+	if sc.prefix, _, err = config.GetString("prefix"); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// newS3 sets up a client for an existing bucket.
+func (e Engine) newS3(config dvid.StoreConfig) (*S3, bool, error) {
+	sc, err := parseConfig(config)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error in newS3() %v", err)
+	}
+	awsConfig := &aws.Config{
+		Region:           aws.String(sc.region),
+		S3ForcePathStyle: aws.Bool(sc.pathStyle),
+	}
+	if sc.endpoint != "" {
+		awsConfig.Endpoint = aws.String(sc.endpoint)
+		awsConfig.DisableSSL = aws.Bool(strings.HasPrefix(sc.endpoint, "http://"))
+	}
+	if sc.accessKey != "" || sc.secretKey != "" {
+		awsConfig.Credentials = credentials.NewStaticCredentials(sc.accessKey, sc.secretKey, "")
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	return openS3(sc, api.New(sess))
+}
+
+// openS3 returns a store using the given client, which allows tests to substitute a
+// fake S3 service.
+func openS3(sc *s3Config, client s3iface.S3API) (*S3, bool, error) {
+	db := &S3{
+		config:    sc,
+		client:    client,
+		activeOps: make(chan struct{}, MAXNETOPS),
+	}
+
+	// bucket must already exist
+	if _, err := db.client.HeadBucket(&api.HeadBucketInput{Bucket: aws.String(sc.bucket)}); err != nil {
+		return nil, false, fmt.Errorf("unable to access bucket %q: %v", sc.bucket, err)
+	}
+
+	// check if there's been any metadata or we need to initialize it.
+	var ctx storage.MetadataContext
+	minKey, maxKey := ctx.KeyRange()
+	keys, err := db.getKeysInRangeRaw(minKey, maxKey, 1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(keys) == 0 {
+		dvid.TimeInfof("No metadata found for %s...\n", db)
+		return db, true, nil
+	}
+	return db, false, nil
+}
+
+// ---- TestableEngine interface implementation -------
+
+// AddTestConfig sets an S3 store as the default key-value backend.  The S3 service is
+// given by the DVID_TEST_S3_ENDPOINT environment variable, e.g., a local MinIO at
+// "http://localhost:9000", and an existing bucket by DVID_TEST_S3_BUCKET.  Credentials
+// are read from DVID_TEST_S3_ACCESSKEY and DVID_TEST_S3_SECRETKEY.  Each test store
+// uses a unique object prefix within the bucket.
+func (e Engine) AddTestConfig(backend *storage.Backend) (storage.Alias, error) {
+	alias := storage.Alias("s3")
+	endpoint := os.Getenv("DVID_TEST_S3_ENDPOINT")
+	bucket := os.Getenv("DVID_TEST_S3_BUCKET")
+	if endpoint == "" || bucket == "" {
+		return alias, fmt.Errorf("s3 can't be testable key-value without DVID_TEST_S3_ENDPOINT and DVID_TEST_S3_BUCKET")
+	}
+	if backend.DefaultKVDB != "" {
+		return alias, fmt.Errorf("s3 can't be testable key-value.  DefaultKVDB already set to %s", backend.DefaultKVDB)
+	}
+	if backend.Metadata != "" {
+		return alias, fmt.Errorf("s3 can't be testable key-value.  Metadata already set to %s", backend.Metadata)
+	}
+	backend.Metadata = alias
+	backend.DefaultKVDB = alias
+	if backend.Stores == nil {
+		backend.Stores = make(map[storage.Alias]dvid.StoreConfig)
+	}
+	tc := map[string]interface{}{
+		"bucket":    bucket,
+		"endpoint":  endpoint,
+		"accesskey": os.Getenv("DVID_TEST_S3_ACCESSKEY"),
+		"secretkey": os.Getenv("DVID_TEST_S3_SECRETKEY"),
+		"pathstyle": true,
+		"prefix":    fmt.Sprintf("dvid-test-%x/", uuid.NewV4().Bytes()),
+	}
+	var c dvid.Config
+	c.SetAll(tc)
+	backend.Stores[alias] = dvid.StoreConfig{Config: c, Engine: "s3"}
+	return alias, nil
+}
+
+// Delete implements the TestableEngine interface by deleting all objects under the
+// configured prefix.  The bucket itself is not deleted.
+func (e Engine) Delete(config dvid.StoreConfig) error {
+	sc, err := parseConfig(config)
+	if err != nil {
+		return err
+	}
+	if sc.prefix == "" {
+		return fmt.Errorf("refusing to delete all objects in bucket %q without a prefix", sc.bucket)
+	}
+	store, _, err := e.newS3(config)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	names, err := store.listObjects(sc.prefix, "", nil, 0)
+	if err != nil {
+		return err
+	}
+	return store.deleteObjects(names)
+}
+
+// --- The S3 implementation ----
+
+type S3 struct {
+	config    *s3Config
+	client    s3iface.S3API
+	activeOps chan struct{}
+}
+
+func (db *S3) String() string {
+	if db.config.endpoint != "" {
+		return fmt.Sprintf("s3 bucket %s @ %s", db.config.bucket, db.config.endpoint)
+	}
+	return fmt.Sprintf("s3 bucket %s", db.config.bucket)
+}
+
+// Close is a no-op since the client has no persistent connections to close.
+func (db *S3) Close() {}
+
+// Equal returns true if the store uses the same endpoint, bucket, and prefix.
+func (db *S3) Equal(config dvid.StoreConfig) bool {
+	sc, err := parseConfig(config)
+	if err != nil {
+		return false
+	}
+	return sc.bucket == db.config.bucket && sc.endpoint == db.config.endpoint && sc.prefix == db.config.prefix
+}
+
+// grabOpResource blocks until a network op can be started.
+func (db *S3) grabOpResource() {
+	db.activeOps <- struct{}{}
+}
+
+func (db *S3) releaseOpResource() {
+	<-db.activeOps
+}
+
+// ---- HELPER FUNCTIONS ----
+
+// objectName returns the object name for a full key.
+func (db *S3) objectName(k storage.Key) string {
+	return db.config.prefix + hex.EncodeToString(k)
+}
+
+// keyFromName returns the full key for an object name.
+func (db *S3) keyFromName(name string) (storage.Key, error) {
+	return hex.DecodeString(strings.TrimPrefix(name, db.config.prefix))
+}
+
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case api.ErrCodeNoSuchKey, "NotFound":
+			return true
+		}
+	}
+	return false
+}
+
+// getV returns the value of the object for the full key or nil if it doesn't exist.
+func (db *S3) getV(k storage.Key) ([]byte, error) {
+	db.grabOpResource()
+	defer db.releaseOpResource()
+
+	resp, err := db.client.GetObject(&api.GetObjectInput{
+		Bucket: aws.String(db.config.bucket),
+		Key:    aws.String(db.objectName(k)),
+	})
+	if err != nil {
+		// preserve interface where missing value is not an error
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	value, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	storage.StoreValueBytesRead <- len(value)
+	return value, nil
+}
+
+func (db *S3) putV(k storage.Key, value []byte) error {
+	db.grabOpResource()
+	defer db.releaseOpResource()
+
+	_, err := db.client.PutObject(&api.PutObjectInput{
+		Bucket: aws.String(db.config.bucket),
+		Key:    aws.String(db.objectName(k)),
+		Body:   bytes.NewReader(value),
+	})
+	if err != nil {
+		return err
+	}
+	storage.StoreKeyBytesWritten <- len(k)
+	storage.StoreValueBytesWritten <- len(value)
+	return nil
+}
+
+// deleteObjects deletes objects by name using multi-object delete requests.
+func (db *S3) deleteObjects(names []string) error {
+	for len(names) > 0 {
+		n := len(names)
+		if n > MAXDELETEOBJECTS {
+			n = MAXDELETEOBJECTS
+		}
+		objects := make([]*api.ObjectIdentifier, n)
+		for i, name := range names[:n] {
+			objects[i] = &api.ObjectIdentifier{Key: aws.String(name)}
+		}
+		db.grabOpResource()
+		resp, err := db.client.DeleteObjects(&api.DeleteObjectsInput{
+			Bucket: aws.String(db.config.bucket),
+			Delete: &api.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		db.releaseOpResource()
+		if err != nil {
+			return err
+		}
+		if len(resp.Errors) != 0 {
+			e := resp.Errors[0]
+			return fmt.Errorf("unable to delete %d objects, e.g., %s: %s", len(resp.Errors), aws.StringValue(e.Key), aws.StringValue(e.Message))
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+// listObjects returns the names of objects with the given prefix that are after startAfter
+// and, if stop is non-nil, until stop returns true.  If max is non-zero, at most that number
+// of names are returned.
+func (db *S3) listObjects(prefix, startAfter string, stop func(name string) bool, max int) ([]string, error) {
+	input := &api.ListObjectsV2Input{
+		Bucket: aws.String(db.config.bucket),
+		Prefix: aws.String(prefix),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+	var names []string
+	db.grabOpResource()
+	defer db.releaseOpResource()
+	err := db.client.ListObjectsV2Pages(input, func(page *api.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			name := aws.StringValue(obj.Key)
+			if stop != nil && stop(name) {
+				return false
+			}
+			names = append(names, name)
+			if max != 0 && len(names) >= max {
+				return false
+			}
+		}
+		return true
+	})
+	return names, err
+}
+
+// getKeysInRangeRaw returns all full keys in the inclusive range [minKey, maxKey] in
+// sorted order.  If max is non-zero, at most that number of keys are returned.
+func (db *S3) getKeysInRangeRaw(minKey, maxKey storage.Key, max int) ([]storage.Key, error) {
+	minName := db.objectName(minKey)
+	maxName := db.objectName(maxKey)
+
+	// list objects with common prefix starting just before the first possible key.
+	prefix := db.config.prefix + grabPrefix(minKey, maxKey)
+	var startAfter string
+	if len(minKey) > 0 {
+		startAfter = db.objectName(minKey[:len(minKey)-1])
+	}
+	var keys []storage.Key
+	var keyErr error
+	_, err := db.listObjects(prefix, startAfter, func(name string) bool {
+		if name > maxName {
+			return true
+		}
+		if name < minName {
+			return false
+		}
+		key, err := db.keyFromName(name)
+		if err != nil {
+			keyErr = err
+			return true
+		}
+		keys = append(keys, key)
+		return max != 0 && len(keys) >= max
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	sort.Sort(KeyArray(keys))
+	return keys, nil
+}
+
+// getKeysInRange returns the keys visible from the context in sorted order.  For versioned
+// contexts, all versions of each key in the range are listed and the relevant version chosen.
+func (db *S3) getKeysInRange(ctx storage.Context, TkBeg, TkEnd storage.TKey) ([]storage.Key, error) {
+	if !ctx.Versioned() {
+		return db.getKeysInRangeRaw(ctx.ConstructKey(TkBeg), ctx.ConstructKey(TkEnd), 0)
+	}
+	vctx, ok := ctx.(storage.VersionedCtx)
+	if !ok {
+		return nil, fmt.Errorf("context is versioned but doesn't fulfill interface: %v", ctx)
+	}
+	minKey, err := vctx.MinVersionKey(TkBeg)
+	if err != nil {
+		return nil, err
+	}
+	maxKey, err := vctx.MaxVersionKey(TkEnd)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := db.getKeysInRangeRaw(minKey, maxKey, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// group versions of each type-specific key, which are adjacent in sorted order.
+	var vkeys []storage.Key
+	var curTKey storage.TKey
+	var versions []*storage.KeyValue
+	addRelevant := func() error {
+		if len(versions) == 0 {
+			return nil
+		}
+		kv, err := vctx.VersionedKeyValue(versions)
+		if err != nil {
+			return err
+		}
+		if kv != nil {
+			vkeys = append(vkeys, kv.K)
+		}
+		versions = nil
+		return nil
+	}
+	for _, key := range keys {
+		tk, err := storage.TKeyFromKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(tk, curTKey) {
+			if err := addRelevant(); err != nil {
+				return nil, err
+			}
+			curTKey = tk
+		}
+		versions = append(versions, &storage.KeyValue{K: key, V: []byte{}})
+	}
+	if err := addRelevant(); err != nil {
+		return nil, err
+	}
+	return vkeys, nil
+}
+
+// getValues fetches in parallel the values for the given keys and sends them in key order
+// to f.  If f returns an error, fetching stops and the error is returned.
+func (db *S3) getValues(keys []storage.Key, f func(*storage.KeyValue) error) error {
+	type result struct {
+		value []byte
+		err   error
+	}
+	results := make([]chan result, len(keys))
+	for i := range keys {
+		results[i] = make(chan result, 1)
+	}
+
+	// limit the number of fetched values waiting to be processed.
+	window := make(chan struct{}, MAXNETOPS)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i, key := range keys {
+			select {
+			case window <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func(i int, key storage.Key) {
+				value, err := db.getV(key)
+				results[i] <- result{value, err}
+			}(i, key)
+		}
+	}()
+	for i, key := range keys {
+		r := <-results[i]
+		<-window
+		if r.err != nil {
+			return r.err
+		}
+		if err := f(&storage.KeyValue{K: key, V: r.value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---- OrderedKeyValueGetter interface ------
+
+// Get returns a value given a key.
+func (db *S3) Get(ctx storage.Context, tk storage.TKey) ([]byte, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call Get() on nil S3")
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in Get()")
+	}
+	if !ctx.Versioned() {
+		return db.getV(ctx.ConstructKey(tk))
+	}
+	keys, err := db.getKeysInRange(ctx, tk, tk)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return db.getV(keys[0])
+}
+
+// KeysInRange returns a range of type-specific key components spanning (TkBeg, TkEnd).
+func (db *S3) KeysInRange(ctx storage.Context, TkBeg, TkEnd storage.TKey) ([]storage.TKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call KeysInRange() on nil S3")
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in KeysInRange()")
+	}
+	keys, err := db.getKeysInRange(ctx, TkBeg, TkEnd)
+	if err != nil {
+		return nil, err
+	}
+	tKeys := make([]storage.TKey, len(keys))
+	for i, key := range keys {
+		if tKeys[i], err = storage.TKeyFromKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return tKeys, nil
+}
+
+// SendKeysInRange sends a range of full keys down a key channel.  End of range is
+// marked by a nil key.
+func (db *S3) SendKeysInRange(ctx storage.Context, TkBeg, TkEnd storage.TKey, ch storage.KeyChan) error {
+	if db == nil {
+		return fmt.Errorf("Can't call SendKeysInRange() on nil S3")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in SendKeysInRange()")
+	}
+	keys, err := db.getKeysInRange(ctx, TkBeg, TkEnd)
+	if err != nil {
+		ch <- nil
+		return err
+	}
+	for _, key := range keys {
+		ch <- key
+	}
+	ch <- nil
+	return nil
+}
+
+// GetRange returns a range of values spanning (TkBeg, kEnd) keys.
+func (db *S3) GetRange(ctx storage.Context, TkBeg, TkEnd storage.TKey) ([]*storage.TKeyValue, error) {
+	if db == nil {
+		return nil, fmt.Errorf("Can't call GetRange() on nil S3")
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Received nil context in GetRange()")
+	}
+	keys, err := db.getKeysInRange(ctx, TkBeg, TkEnd)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]*storage.TKeyValue, 0, len(keys))
+	err = db.getValues(keys, func(kv *storage.KeyValue) error {
+		if kv.V == nil {
+			return nil // deleted since listing
+		}
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			return err
+		}
+		values = append(values, &storage.TKeyValue{K: tk, V: kv.V})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ProcessRange sends a range of type key-value pairs to type-specific chunk handlers.
+// Values are fetched in parallel but sent to the chunk handler in key order.
+func (db *S3) ProcessRange(ctx storage.Context, TkBeg, TkEnd storage.TKey, op *storage.ChunkOp, f storage.ChunkFunc) error {
+	if db == nil {
+		return fmt.Errorf("Can't call ProcessRange() on nil S3")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in ProcessRange()")
+	}
+	keys, err := db.getKeysInRange(ctx, TkBeg, TkEnd)
+	if err != nil {
+		return err
+	}
+	return db.getValues(keys, func(kv *storage.KeyValue) error {
+		if kv.V == nil {
+			return nil // deleted since listing
+		}
+		tk, err := storage.TKeyFromKey(kv.K)
+		if err != nil {
+			return err
+		}
+		if op != nil && op.Wg != nil {
+			op.Wg.Add(1)
+		}
+		return f(&storage.Chunk{ChunkOp: op, TKeyValue: &storage.TKeyValue{K: tk, V: kv.V}})
+	})
+}
+
+// RawRangeQuery sends a range of full keys.  This is to be used for low-level data
+// retrieval like DVID-to-DVID communication and should not be used by data type
+// implementations if possible because each version's key-value pairs are sent
+// without filtering by the current version and its ancestor graph.
+func (db *S3) RawRangeQuery(kStart, kEnd storage.Key, keysOnly bool, out chan *storage.KeyValue, cancel <-chan struct{}) error {
+	if db == nil {
+		return fmt.Errorf("Can't call RawRangeQuery() on nil S3")
+	}
+	keys, err := db.getKeysInRangeRaw(kStart, kEnd, 0)
+	if err != nil {
+		return err
+	}
+	errCancelled := fmt.Errorf("cancelled")
+	send := func(kv *storage.KeyValue) error {
+		select {
+		case out <- kv:
+			return nil
+		case <-cancel:
+			return errCancelled
+		}
+	}
+	if keysOnly {
+		for _, key := range keys {
+			if err := send(&storage.KeyValue{K: key}); err != nil {
+				return nil
+			}
+		}
+	} else {
+		err = db.getValues(keys, send)
+		if err == errCancelled {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	out <- nil
+	return nil
+}
+
+// ---- KeyValueSetter interface ------
+
+// Put writes a value with given key in a possibly versioned context.
+func (db *S3) Put(ctx storage.Context, tk storage.TKey, value []byte) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Put()")
+	}
+	batch := db.NewBatch(ctx)
+	batch.Put(tk, value)
+	return batch.Commit()
+}
+
+// RawPut is a low-level function that puts a key-value pair using full keys.
+// This can be used in conjunction with RawRangeQuery.
+func (db *S3) RawPut(k storage.Key, v []byte) error {
+	return db.putV(k, v)
+}
+
+// Delete deletes a key-value pair so that subsequent Get on the key returns nil.
+func (db *S3) Delete(ctx storage.Context, tk storage.TKey) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in Delete()")
+	}
+	batch := db.NewBatch(ctx)
+	batch.Delete(tk)
+	return batch.Commit()
+}
+
+// RawDelete is a low-level function.  It deletes a key-value pair using full keys
+// without any context.  This can be used in conjunction with RawRangeQuery.
+func (db *S3) RawDelete(k storage.Key) error {
+	return db.deleteObjects([]string{db.objectName(k)})
+}
+
+// ---- OrderedKeyValueSetter interface ------
+
+// PutRange puts key-value pairs using parallel requests.
+func (db *S3) PutRange(ctx storage.Context, kvs []storage.TKeyValue) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in PutRange()")
+	}
+	batch := db.NewBatch(ctx)
+	for _, kv := range kvs {
+		batch.Put(kv.K, kv.V)
+	}
+	return batch.Commit()
+}
+
+// DeleteRange removes all key-value pairs with keys in the given range.  If versioned,
+// tombstones are written for the context's version.
+func (db *S3) DeleteRange(ctx storage.Context, TkBeg, TkEnd storage.TKey) error {
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteRange()")
+	}
+	keys, err := db.getKeysInRange(ctx, TkBeg, TkEnd)
+	if err != nil {
+		return err
+	}
+	batch := db.NewBatch(ctx)
+	for _, key := range keys {
+		tk, err := storage.TKeyFromKey(key)
+		if err != nil {
+			return err
+		}
+		batch.Delete(tk)
+	}
+	return batch.Commit()
+}
+
+// DeleteAll removes all key-value pairs for the context.  If allVersions is true,
+// then all versions of the data instance are deleted.  Will not produce any tombstones.
+func (db *S3) DeleteAll(ctx storage.Context, allVersions bool) error {
+	if db == nil {
+		return fmt.Errorf("Can't call DeleteAll() on nil S3")
+	}
+	if ctx == nil {
+		return fmt.Errorf("Received nil context in DeleteAll()")
+	}
+	var minKey, maxKey storage.Key
+	vctx, versioned := ctx.(storage.VersionedCtx)
+	if versioned {
+		var err error
+		if minKey, err = vctx.MinVersionKey(storage.MinTKey(storage.TKeyMinClass)); err != nil {
+			return err
+		}
+		if maxKey, err = vctx.MaxVersionKey(storage.MaxTKey(storage.TKeyMaxClass)); err != nil {
+			return err
+		}
+	} else {
+		if !allVersions {
+			return fmt.Errorf("Can't ask for versioned delete from unversioned context: %s", ctx)
+		}
+		minKey, maxKey = ctx.KeyRange()
+	}
+	keys, err := db.getKeysInRangeRaw(minKey, maxKey, 0)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, key := range keys {
+		if !allVersions {
+			v, err := vctx.VersionFromKey(key)
+			if err != nil {
+				return err
+			}
+			if v != vctx.VersionID() {
+				continue
+			}
+		}
+		names = append(names, db.objectName(key))
+	}
+	if err := db.deleteObjects(names); err != nil {
+		return err
+	}
+	dvid.Debugf("Deleted %d key-value pairs via DELETE ALL for %s.\n", len(names), ctx)
+	return nil
+}
+
+// --- Batcher interface ----
+
+type batchOp struct {
+	del   bool
+	value []byte
+}
+
+type goBatch struct {
+	db   *S3
+	ctx  storage.Context
+	vctx storage.VersionedCtx
+
+	// last operation for each full key and the order keys were added.
+	ops   map[string]batchOp
+	order []string
+}
+
+// NewBatch returns an implementation that allows batch writes.  Operations on the
+// same key within a batch are collapsed so only the last one is executed.
+func (db *S3) NewBatch(ctx storage.Context) storage.Batch {
+	if ctx == nil {
+		dvid.Criticalf("Received nil context in NewBatch()")
+		return nil
+	}
+	vctx, _ := ctx.(storage.VersionedCtx)
+	return &goBatch{db: db, ctx: ctx, vctx: vctx, ops: make(map[string]batchOp)}
+}
+
+func (batch *goBatch) add(k storage.Key, op batchOp) {
+	if _, found := batch.ops[string(k)]; !found {
+		batch.order = append(batch.order, string(k))
+	}
+	batch.ops[string(k)] = op
+}
+
+// --- Batch interface ---
+
+func (batch *goBatch) Delete(tk storage.TKey) {
+	if batch == nil || batch.ctx == nil {
+		dvid.Criticalf("Received nil batch or nil batch context in batch.Delete()\n")
+		return
+	}
+	if batch.vctx != nil {
+		tombstone := batch.vctx.TombstoneKey(tk) // This will now have current version
+		batch.add(tombstone, batchOp{value: dvid.EmptyValue()})
+	}
+	batch.add(batch.ctx.ConstructKey(tk), batchOp{del: true})
+}
+
+func (batch *goBatch) Put(tk storage.TKey, value []byte) {
+	if batch == nil || batch.ctx == nil {
+		dvid.Criticalf("Received nil batch or nil batch context in batch.Put()\n")
+		return
+	}
+	if batch.vctx != nil {
+		tombstone := batch.vctx.TombstoneKey(tk) // This will now have current version
+		batch.add(tombstone, batchOp{del: true})
+	}
+	batch.add(batch.ctx.ConstructKey(tk), batchOp{value: value})
+}
+
+// Commit executes the puts in parallel and then the deletes using multi-object deletes.
+// If any operation fails, an error is returned, although other operations may have succeeded.
+func (batch *goBatch) Commit() error {
+	if batch == nil {
+		return fmt.Errorf("Received nil batch in batch.Commit()\n")
+	}
+	var deletes []string
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var putErr error
+	for _, k := range batch.order {
+		op := batch.ops[k]
+		if op.del {
+			deletes = append(deletes, batch.db.objectName(storage.Key(k)))
+			continue
+		}
+		wg.Add(1)
+		go func(key storage.Key, value []byte) {
+			defer wg.Done()
+			if err := batch.db.putV(key, value); err != nil {
+				mu.Lock()
+				putErr = err
+				mu.Unlock()
+			}
+		}(storage.Key(k), op.value)
+	}
+	wg.Wait()
+	batch.ops = make(map[string]batchOp)
+	batch.order = nil
+	if putErr != nil {
+		return putErr
+	}
+	return batch.db.deleteObjects(deletes)
+}
+
+// ---- BlobStore interface ----
+
+// PutBlob writes unversioned data and returns a filename-friendly base64 encoding of the reference.
+func (db *S3) PutBlob(v []byte) (ref string, err error) {
+	h := fnv.New128()
+	if _, err = h.Write(v); err != nil {
+		return
+	}
+	contentHash := h.Sum(nil)
+	if err = db.putV(storage.ConstructBlobKey(contentHash), v); err != nil {
+		return
+	}
+	return base64.URLEncoding.EncodeToString(contentHash), nil
+}
+
+// GetBlob returns unversioned data given a reference.
+func (db *S3) GetBlob(ref string) ([]byte, error) {
+	contentHash, err := base64.URLEncoding.DecodeString(ref)
+	if err != nil {
+		return nil, err
+	}
+	return db.getV(storage.ConstructBlobKey(contentHash))
+}
+
+// ---- helpers ----
+
+// grabPrefix returns the common prefix of the hex-encoded keys.
+func grabPrefix(key1, key2 storage.Key) string {
+	key1m := hex.EncodeToString(key1)
+	key2m := hex.EncodeToString(key2)
+	var spot int
+	for spot < len(key1m) && spot < len(key2m) && key1m[spot] == key2m[spot] {
+		spot++
+	}
+	return key1m[:spot]
+}
+
+type KeyArray []storage.Key
+
+func (k KeyArray) Less(i, j int) bool {
+	return string(k[i]) < string(k[j])
+}
+
+func (k KeyArray) Swap(i, j int) {
+	k[i], k[j] = k[j], k[i]
+}
+
+func (k KeyArray) Len() int {
+	return len(k)
+}
@@ -0,0 +1,345 @@
+// +build s3
+
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	api "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"github.com/janelia-flyem/dvid/dvid"
+	"github.com/janelia-flyem/dvid/storage"
+)
+
+// fakeS3 is an in-memory stand-in for the S3 API calls used by the engine.  Listings
+// are returned in small pages to exercise paging.
+type fakeS3 struct {
+	s3iface.S3API
+
+	mu       sync.Mutex
+	bucket   string
+	objects  map[string][]byte
+	pageSize int
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: make(map[string][]byte), pageSize: 7}
+}
+
+func (f *fakeS3) checkBucket(bucket *string) error {
+	if aws.StringValue(bucket) != f.bucket {
+		return awserr.New(api.ErrCodeNoSuchBucket, "no such bucket", nil)
+	}
+	return nil
+}
+
+func (f *fakeS3) HeadBucket(input *api.HeadBucketInput) (*api.HeadBucketOutput, error) {
+	return &api.HeadBucketOutput{}, f.checkBucket(input.Bucket)
+}
+
+func (f *fakeS3) GetObject(input *api.GetObjectInput) (*api.GetObjectOutput, error) {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, found := f.objects[aws.StringValue(input.Key)]
+	if !found {
+		return nil, awserr.New(api.ErrCodeNoSuchKey, "no such key", nil)
+	}
+	return &api.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(value))}, nil
+}
+
+func (f *fakeS3) PutObject(input *api.PutObjectInput) (*api.PutObjectOutput, error) {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	value, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.objects[aws.StringValue(input.Key)] = value
+	f.mu.Unlock()
+	return &api.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObjects(input *api.DeleteObjectsInput) (*api.DeleteObjectsOutput, error) {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	for _, obj := range input.Delete.Objects {
+		delete(f.objects, aws.StringValue(obj.Key))
+	}
+	f.mu.Unlock()
+	return &api.DeleteObjectsOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2Pages(input *api.ListObjectsV2Input, fn func(*api.ListObjectsV2Output, bool) bool) error {
+	if err := f.checkBucket(input.Bucket); err != nil {
+		return err
+	}
+	prefix := aws.StringValue(input.Prefix)
+	startAfter := aws.StringValue(input.StartAfter)
+	f.mu.Lock()
+	var names []string
+	for name := range f.objects {
+		if strings.HasPrefix(name, prefix) && name > startAfter {
+			names = append(names, name)
+		}
+	}
+	f.mu.Unlock()
+	sort.Strings(names)
+	for len(names) > 0 {
+		n := len(names)
+		if n > f.pageSize {
+			n = f.pageSize
+		}
+		page := &api.ListObjectsV2Output{}
+		for _, name := range names[:n] {
+			page.Contents = append(page.Contents, &api.Object{Key: aws.String(name)})
+		}
+		names = names[n:]
+		if !fn(page, len(names) == 0) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestKeyEncoding(t *testing.T) {
+	db := &S3{config: &s3Config{bucket: "test", prefix: "pre/"}}
+	keys := []storage.Key{
+		{},
+		{0x00},
+		{0x00, 0x00},
+		{0x00, 0xff},
+		{0x01},
+		{0x0f, 0xf0},
+		{0x10},
+		{0xff},
+		{0xff, 0x00},
+	}
+	var prevName string
+	for i, key := range keys {
+		name := db.objectName(key)
+		if !strings.HasPrefix(name, "pre/") {
+			t.Errorf("object name %q for key %v doesn't have configured prefix\n", name, key)
+		}
+		if i > 0 && name <= prevName {
+			t.Errorf("object name %q for key %v doesn't sort after %q\n", name, key, prevName)
+		}
+		prevName = name
+		decoded, err := db.keyFromName(name)
+		if err != nil {
+			t.Fatalf("unable to decode object name %q: %v\n", name, err)
+		}
+		if !bytes.Equal(decoded, key) {
+			t.Errorf("expected key %v from object name %q, got %v\n", key, name, decoded)
+		}
+	}
+}
+
+func TestFakeS3Store(t *testing.T) {
+	fake := newFakeS3("test")
+	if _, _, err := openS3(&s3Config{bucket: "missing", prefix: "pre/"}, fake); err == nil {
+		t.Errorf("expected error opening store with missing bucket\n")
+	}
+	db, created, err := openS3(&s3Config{bucket: "test", prefix: "pre/"}, fake)
+	if err != nil {
+		t.Fatalf("unable to open store: %v\n", err)
+	}
+	if !created {
+		t.Errorf("expected empty store to need metadata\n")
+	}
+
+	// Keys include zero and 0xff bytes that border other keys when hex-encoded.
+	var ctx storage.MetadataContext
+	tkey := func(i int) storage.TKey {
+		return storage.NewTKey(1, []byte{byte(i / 16), 0x00, byte(i % 16), 0xff})
+	}
+	batch := db.NewBatch(ctx)
+	for i := 0; i < 64; i++ {
+		batch.Put(tkey(i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	batch.Put(storage.NewTKey(2, nil), []byte("other class"))
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("unable to commit batch: %v\n", err)
+	}
+
+	value, err := db.Get(ctx, tkey(42))
+	if err != nil {
+		t.Fatalf("unable to get value: %v\n", err)
+	}
+	if string(value) != "value42" {
+		t.Errorf("expected value42, got %q\n", value)
+	}
+	if value, err := db.Get(ctx, storage.NewTKey(1, []byte("missing"))); err != nil || value != nil {
+		t.Errorf("expected nil value and error for missing key, got %q, %v\n", value, err)
+	}
+
+	kvs, err := db.GetRange(ctx, tkey(10), tkey(49))
+	if err != nil {
+		t.Fatalf("unable to get range: %v\n", err)
+	}
+	if len(kvs) != 40 {
+		t.Fatalf("expected 40 key-values in range, got %d\n", len(kvs))
+	}
+	for i, kv := range kvs {
+		if !bytes.Equal(kv.K, tkey(i+10)) {
+			t.Errorf("expected key %v for range element %d, got %v\n", tkey(i+10), i, kv.K)
+		}
+		if expected := fmt.Sprintf("value%d", i+10); string(kv.V) != expected {
+			t.Errorf("expected %q for range element %d, got %q\n", expected, i, kv.V)
+		}
+	}
+
+	keys, err := db.KeysInRange(ctx, storage.MinTKey(1), storage.MaxTKey(1))
+	if err != nil {
+		t.Fatalf("unable to get keys in range: %v\n", err)
+	}
+	if len(keys) != 64 {
+		t.Errorf("expected 64 keys of class 1, got %d\n", len(keys))
+	}
+
+	if err := db.DeleteRange(ctx, tkey(16), tkey(63)); err != nil {
+		t.Fatalf("unable to delete range: %v\n", err)
+	}
+	keys, err = db.KeysInRange(ctx, storage.MinTKey(1), storage.MaxTKey(1))
+	if err != nil {
+		t.Fatalf("unable to get keys in range: %v\n", err)
+	}
+	if len(keys) != 16 {
+		t.Errorf("expected 16 keys after delete range, got %d\n", len(keys))
+	}
+	if value, _ := db.Get(ctx, storage.NewTKey(2, nil)); string(value) != "other class" {
+		t.Errorf("delete range removed key outside range\n")
+	}
+
+	// Reopening finds the metadata while stores with other prefixes are isolated.
+	if _, created, err = openS3(&s3Config{bucket: "test", prefix: "pre/"}, fake); err != nil || created {
+		t.Errorf("expected reopened store to have metadata, got created %t, err %v\n", created, err)
+	}
+	other, created, err := openS3(&s3Config{bucket: "test", prefix: "other/"}, fake)
+	if err != nil || !created {
+		t.Fatalf("expected store with other prefix to be empty, got created %t, err %v\n", created, err)
+	}
+	if value, _ := other.Get(ctx, tkey(0)); value != nil {
+		t.Errorf("store with other prefix can read %q\n", value)
+	}
+}
+
+func TestGrabPrefix(t *testing.T) {
+	tests := []struct {
+		key1, key2 storage.Key
+		prefix     string
+	}{
+		{storage.Key{0x01, 0x02, 0x03}, storage.Key{0x01, 0x02, 0xff}, "0102"},
+		{storage.Key{0x01, 0x12}, storage.Key{0x01, 0x1f}, "011"},
+		{storage.Key{0x01}, storage.Key{0x02}, ""},
+		{storage.Key{0xab}, storage.Key{0xab, 0x00}, "ab"},
+	}
+	for _, test := range tests {
+		if prefix := grabPrefix(test.key1, test.key2); prefix != test.prefix {
+			t.Errorf("expected prefix %q for %v and %v, got %q\n", test.prefix, test.key1, test.key2, prefix)
+		}
+	}
+}
+
+// TestS3Store requires an S3-compatible service like a local MinIO, e.g.,
+//
+//	% minio server /tmp/minio-data
+//	% DVID_TEST_S3_ENDPOINT=http://localhost:9000 DVID_TEST_S3_BUCKET=dvid-test \
+//	  DVID_TEST_S3_ACCESSKEY=minioadmin DVID_TEST_S3_SECRETKEY=minioadmin go test -tags s3
+func TestS3Store(t *testing.T) {
+	if os.Getenv("DVID_TEST_S3_ENDPOINT") == "" {
+		t.Skip("DVID_TEST_S3_ENDPOINT not set")
+	}
+	e := Engine{name: "s3"}
+	var backend storage.Backend
+	alias, err := e.AddTestConfig(&backend)
+	if err != nil {
+		t.Fatalf("unable to add test config: %v\n", err)
+	}
+	config := backend.Stores[alias]
+	defer e.Delete(config)
+
+	store, created, err := e.NewStore(config)
+	if err != nil {
+		t.Fatalf("unable to create s3 store: %v\n", err)
+	}
+	if !created {
+		t.Errorf("expected new test store to have no metadata\n")
+	}
+	db := store.(*S3)
+	defer db.Close()
+
+	var ctx storage.MetadataContext
+	batch := db.NewBatch(ctx)
+	for i := 0; i < 50; i++ {
+		batch.Put(storage.NewTKey(1, []byte(fmt.Sprintf("key%02d", i))), []byte(fmt.Sprintf("value%d", i)))
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatalf("unable to commit batch: %v\n", err)
+	}
+	value, err := db.Get(ctx, storage.NewTKey(1, []byte("key42")))
+	if err != nil {
+		t.Fatalf("unable to get value: %v\n", err)
+	}
+	if string(value) != "value42" {
+		t.Errorf("expected value42, got %q\n", value)
+	}
+	if value, err := db.Get(ctx, storage.NewTKey(1, []byte("missing"))); err != nil || value != nil {
+		t.Errorf("expected nil value and error for missing key, got %q, %v\n", value, err)
+	}
+
+	kvs, err := db.GetRange(ctx, storage.NewTKey(1, []byte("key10")), storage.NewTKey(1, []byte("key19")))
+	if err != nil {
+		t.Fatalf("unable to get range: %v\n", err)
+	}
+	if len(kvs) != 10 {
+		t.Fatalf("expected 10 key-values in range, got %d\n", len(kvs))
+	}
+	for i, kv := range kvs {
+		expected := fmt.Sprintf("value%d", i+10)
+		if string(kv.V) != expected {
+			t.Errorf("expected %q for range element %d, got %q\n", expected, i, kv.V)
+		}
+	}
+
+	if err := db.DeleteRange(ctx, storage.NewTKey(1, []byte("key20")), storage.NewTKey(1, []byte("key49"))); err != nil {
+		t.Fatalf("unable to delete range: %v\n", err)
+	}
+	keys, err := db.KeysInRange(ctx, storage.MinTKey(1), storage.MaxTKey(1))
+	if err != nil {
+		t.Fatalf("unable to get keys in range: %v\n", err)
+	}
+	if len(keys) != 20 {
+		t.Errorf("expected 20 keys after delete range, got %d\n", len(keys))
+	}
+
+	ref, err := db.PutBlob([]byte("my blob"))
+	if err != nil {
+		t.Fatalf("unable to put blob: %v\n", err)
+	}
+	if blob, err := db.GetBlob(ref); err != nil || string(blob) != "my blob" {
+		t.Errorf("bad blob retrieval: %q, %v\n", blob, err)
+	}
+
+	var c dvid.Config
+	c.SetAll(map[string]interface{}{"bucket": "other"})
+	if db.Equal(dvid.StoreConfig{Config: c, Engine: "s3"}) {
+		t.Errorf("expected store to differ from one with another bucket\n")
+	}
+}